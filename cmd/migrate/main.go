@@ -3,6 +3,10 @@ package main
 import (
 	"app/internal/config"
 	"app/migrations"
+	sqliteMigrations "app/migrations/sqlite"
+	sqliteClient "app/pkg/client/sqlite"
+	"context"
+	"database/sql"
 	"fmt"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/stdlib"
@@ -27,29 +31,50 @@ func main() {
 	log := setupLogger()
 	log.Info("starting logger")
 
-	pgDsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		cfg.DB.PGSQL.Username,
-		cfg.DB.PGSQL.Password,
-		cfg.DB.PGSQL.Host,
-		cfg.DB.PGSQL.Port,
-		cfg.DB.PGSQL.Database,
-		cfg.DB.PGSQL.SSLMode,
-	)
-
-	stdlib.GetDefaultDriver()
-
-	log.Info("Postgres SQL Migrate initializing")
-
-	db, err := goose.OpenDBWithDriver("postgres", pgDsn)
-	if err != nil {
-		panic(err)
-	}
-	goose.SetBaseFS(&migrations.Content)
-
-	err = goose.SetDialect("postgres")
-	if err != nil {
-		panic(err)
+	var db *sql.DB
+	var err error
+
+	switch cfg.DB.Driver {
+	case config.DriverSqlite:
+		log.Info("SQLite Migrate initializing")
+
+		db, err = sqliteClient.New(context.Background(), cfg.DB)
+		if err != nil {
+			panic(err)
+		}
+		goose.SetBaseFS(sqliteMigrations.Content)
+		goose.SetTableName(cfg.DB.SQLITE.MigrationsTable)
+
+		err = goose.SetDialect("sqlite3")
+		if err != nil {
+			panic(err)
+		}
+
+	default:
+		pgDsn := fmt.Sprintf(
+			"postgres://%s:%s@%s:%s/%s?sslmode=%s",
+			cfg.DB.PGSQL.Username,
+			cfg.DB.PGSQL.Password,
+			cfg.DB.PGSQL.Host,
+			cfg.DB.PGSQL.Port,
+			cfg.DB.PGSQL.Database,
+			cfg.DB.PGSQL.SSLMode,
+		)
+
+		stdlib.GetDefaultDriver()
+
+		log.Info("Postgres SQL Migrate initializing")
+
+		db, err = goose.OpenDBWithDriver("postgres", pgDsn)
+		if err != nil {
+			panic(err)
+		}
+		goose.SetBaseFS(&migrations.Content)
+
+		err = goose.SetDialect("postgres")
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	switch migrationStatus {
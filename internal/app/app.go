@@ -1,17 +1,18 @@
 package app
 
 import (
+	appCleanup "app/internal/app/cleanup"
 	appGRPC "app/internal/app/grpc"
 	appApi "app/internal/app/http"
 	appMetrics "app/internal/app/metrics"
 	appQueue "app/internal/app/queue"
 	"app/internal/config"
-	"app/pkg/client/pgsql"
+	"app/internal/storage"
 	"app/pkg/client/rabbitmq"
 	"app/pkg/common/logging"
 	"context"
+	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type App struct {
@@ -20,10 +21,11 @@ type App struct {
 	httpServerApp    *appApi.App
 	gRPCServerApp    *appGRPC.App
 	cfg              *config.Config
-	dbClient         *pgxpool.Pool
+	storages         *storage.Storage
 	metricsServerApp *appMetrics.App
 	queueClient      *rabbitmq.App
 	queueApp         *appQueue.App
+	cleanupApp       *appCleanup.App
 }
 
 func New(
@@ -44,59 +46,88 @@ func (a *App) MustRun() {
 
 func (a *App) Run() error {
 	const op = "app.Run"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	logging.WithAttrs(a.ctx,
 		logging.StringAttr("host", a.cfg.Host),
 		logging.IntAttr("port", a.cfg.HTTP.Port),
 	)
 
-	dbClient, err := pgsql.New(a.ctx, a.cfg.DB)
+	storages, err := storage.New(a.ctx, a.cfg.DB)
 
 	if err != nil {
-		logging.L(a.ctx).Error("failed to connect to db", err)
+		logging.L(a.ctx).Error("failed to connect to db", logging.ErrAttr(err))
 		return err
 	}
 
-	a.dbClient = dbClient
+	a.storages = storages
 	logging.L(a.ctx).Info("DB connected")
 
+	if err := checkStorages(a.ctx, a.cfg, storages); err != nil {
+		logging.L(a.ctx).Error("unsupported config", logging.ErrAttr(err))
+		return err
+	}
+
 	queueClient, err := rabbitmq.New(a.ctx, a.cfg.Queue)
 
 	if err != nil {
-		logging.L(a.ctx).Error("failed to connect to queue", err)
+		logging.L(a.ctx).Error("failed to connect to queue", logging.ErrAttr(err))
 		return err
 	}
 
 	a.queueClient = queueClient
 	logging.L(a.ctx).Info("Queue connected")
 
-	a.httpServerApp = appApi.New(a.ctx, dbClient, a.cfg, queueClient)
-	a.gRPCServerApp = appGRPC.New(a.ctx, dbClient, a.cfg)
+	a.gRPCServerApp = appGRPC.New(a.ctx, storages, queueClient, a.cfg)
+	a.httpServerApp = appApi.New(a.ctx, storages, a.cfg, queueClient, a.gRPCServerApp.GatewayConn())
 	a.metricsServerApp = appMetrics.New(a.ctx, a.cfg)
-	a.queueApp = appQueue.New(a.ctx, a.cfg, queueClient, dbClient)
+	a.queueApp = appQueue.New(a.ctx, a.cfg, queueClient, storages)
+	a.cleanupApp = appCleanup.New(a.ctx, a.cfg, storages)
 
 	go a.httpServerApp.MustRun()
 	go a.gRPCServerApp.MustRun()
 	go a.metricsServerApp.MustRun()
 	go a.queueApp.MustRun()
+	go a.cleanupApp.MustRun()
 
 	return nil
 }
 
 func (a *App) Stop() {
 	const op = "app.Stop"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	a.httpServerApp.Stop()
 	a.gRPCServerApp.Stop()
 	a.metricsServerApp.Stop()
+	a.cleanupApp.Stop()
 
-	if a.dbClient != nil {
-		a.dbClient.Close()
+	if a.storages != nil {
+		a.storages.Close()
 		logging.L(a.ctx).Info("Connection to DB closed")
 	}
 
 	a.queueClient.Close()
 	logging.L(a.ctx).Info("Connection to RabbitMQ closed")
 }
+
+// checkStorages fails when the config enables a feature the storages of
+// the driver can't serve, the features without a switch are left out
+// with a warning. SQLite and memory only store the users, the clients,
+// the resources and the tokens.
+func checkStorages(ctx context.Context, cfg *config.Config, storages *storage.Storage) error {
+	if storages.Full() {
+		return nil
+	}
+
+	if len(cfg.Federation.Providers) > 0 {
+		return fmt.Errorf("the federation providers need the %s driver, %s can't store them", config.DriverPgsql, cfg.DB.Driver)
+	}
+
+	logging.L(ctx).Warn("the storage driver leaves out the features that need PostgreSQL",
+		logging.StringAttr("driver", cfg.DB.Driver),
+		logging.StringAttr("disabled", "token endpoint, authorization code, PAR, device, token exchange, federation, SCIM"),
+	)
+
+	return nil
+}
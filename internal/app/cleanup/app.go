@@ -0,0 +1,87 @@
+package app
+
+import (
+	"app/internal/config"
+	"app/internal/storage"
+	"app/pkg/common/logging"
+	"context"
+	"time"
+)
+
+type expirable interface {
+	DeleteExpired(now int64) (int64, error)
+}
+
+// App periodically removes expired short-lived grants and the revocations
+// of expired tokens from the database.
+type App struct {
+	ctx      context.Context
+	cfg      *config.Config
+	storages *storage.Storage
+	stop     chan struct{}
+}
+
+func New(
+	ctx context.Context,
+	cfg *config.Config,
+	storages *storage.Storage,
+) *App {
+	return &App{
+		ctx:      ctx,
+		cfg:      cfg,
+		storages: storages,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "app.cleanup.Run"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	grants := map[string]expirable{
+		"revocation events": a.storages.Revocation,
+	}
+
+	if a.storages.Full() {
+		grants["device codes"] = a.storages.DeviceCode
+		grants["authorization requests"] = a.storages.AuthorizationRequest
+		grants["authorization codes"] = a.storages.AuthorizationCode
+		grants["federation states"] = a.storages.Federation
+	}
+
+	ticker := time.NewTicker(a.cfg.Device.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return nil
+		case <-a.ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for name, grant := range grants {
+				deleted, err := grant.DeleteExpired(now.Unix())
+				if err != nil {
+					logging.L(a.ctx).Error("failed delete expired "+name, logging.ErrAttr(err))
+					continue
+				}
+				if deleted > 0 {
+					logging.L(a.ctx).Info("deleted expired "+name, logging.IntAttr("count", int(deleted)))
+				}
+			}
+		}
+	}
+}
+
+func (a *App) Stop() {
+	const op = "app.cleanup.Stop"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	close(a.stop)
+}
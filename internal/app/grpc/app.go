@@ -2,36 +2,155 @@ package app
 
 import (
 	"app/internal/config"
+	"app/internal/grpc-server/handler/auth"
 	"app/internal/grpc-server/handler/client"
+	"app/internal/grpc-server/handler/token"
+	"app/internal/grpc-server/interceptor"
+	"app/internal/health"
+	authService "app/internal/service/auth"
+	"app/internal/storage"
+	"app/pkg/client/rabbitmq"
+	"app/pkg/common/core/mtls"
 	"app/pkg/common/logging"
+	gRPCSSO "app/pkg/grpc/sso/v1"
 	"context"
 	"fmt"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcHealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionAlphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
 	"net"
+	"sync"
+	"time"
+)
+
+const (
+	envProd = "prod"
+	// gatewayBufferSize is the buffer of the in-memory connections of the
+	// REST gateway.
+	gatewayBufferSize = 1 << 20
 )
 
 type App struct {
-	ctx        context.Context
-	cfg        *config.Config
-	pgClient   *pgxpool.Pool
-	gRPCServer *grpc.Server
+	ctx          context.Context
+	cfg          *config.Config
+	storages     *storage.Storage
+	queueClient  *rabbitmq.App
+	gRPCServer   *grpc.Server
+	healthServer *grpcHealth.Server
+	// gatewayServer serves the REST gateway in-process, it has the
+	// interceptors of gRPCServer but no TLS.
+	gatewayServer   *grpc.Server
+	gatewayListener *bufconn.Listener
+	gatewayConn     *grpc.ClientConn
+	done            chan struct{}
+	// stopOnce makes a second Stop a no-op, done is closed only once.
+	stopOnce sync.Once
+	tls      bool
+}
+
+// servers registers the services on all the servers.
+type servers []*grpc.Server
+
+func (s servers) RegisterService(desc *grpc.ServiceDesc, impl any) {
+	for _, server := range s {
+		server.RegisterService(desc, impl)
+	}
 }
 
 func New(
 	ctx context.Context,
-	pgClient *pgxpool.Pool,
+	storages *storage.Storage,
+	queueClient *rabbitmq.App,
 	cfg *config.Config,
 ) *App {
-	gRPCServer := grpc.NewServer()
+	// the listener configuration is checked at startup like the config
+	tlsConfig, err := mtls.ServerConfig(cfg.GRPC.TLS.Options())
+	if err != nil {
+		panic(err)
+	}
+
+	tokenValidator := authService.New(
+		ctx,
+		storages.User,
+		storages.AccessToken,
+		storages.RefreshToken,
+		storages.AuthToken,
+		cfg.Issuer,
+		cfg.Token,
+	)
+	// the auth and the token services authenticate their callers with the
+	// user and the client credentials of the requests
+	auth := interceptor.NewAuth(tokenValidator, cfg.GRPC.Scopes, cfg.Admin,
+		gRPCSSO.AuthService_ServiceDesc.ServiceName,
+		gRPCSSO.TokenService_ServiceDesc.ServiceName,
+		healthpb.Health_ServiceDesc.ServiceName,
+		reflectionpb.ServerReflection_ServiceDesc.ServiceName,
+		reflectionAlphapb.ServerReflection_ServiceDesc.ServiceName,
+	)
+
+	// the request ID comes first so every log line carries it, panics are
+	// recovered before they reach the logging and the metrics
+	var opts = []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			interceptor.UnaryRequestID(),
+			interceptor.UnaryLogging(),
+			interceptor.UnaryMetrics(),
+			interceptor.UnaryRecovery(),
+			auth.Unary(),
+		),
+		grpc.ChainStreamInterceptor(
+			interceptor.StreamRequestID(),
+			interceptor.StreamLogging(),
+			interceptor.StreamMetrics(),
+			interceptor.StreamRecovery(),
+			auth.Stream(),
+		),
+	}
+	gatewayServer := grpc.NewServer(opts...)
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	gatewayListener := bufconn.Listen(gatewayBufferSize)
+	gatewayConn, err := grpc.NewClient(
+		"passthrough:///gateway",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return gatewayListener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		panic(err)
+	}
+
 	return &App{
-		ctx:        ctx,
-		cfg:        cfg,
-		pgClient:   pgClient,
-		gRPCServer: gRPCServer,
+		ctx:             ctx,
+		cfg:             cfg,
+		storages:        storages,
+		queueClient:     queueClient,
+		gRPCServer:      grpc.NewServer(opts...),
+		healthServer:    grpcHealth.NewServer(),
+		gatewayServer:   gatewayServer,
+		gatewayListener: gatewayListener,
+		gatewayConn:     gatewayConn,
+		done:            make(chan struct{}),
+		tls:             tlsConfig != nil,
 	}
 }
 
+// GatewayConn is the connection the REST gateway calls the services with,
+// it can be used before the server runs.
+func (a *App) GatewayConn() *grpc.ClientConn {
+	return a.gatewayConn
+}
+
 func (a *App) MustRun() {
 	if err := a.Run(); err != nil {
 		panic(err)
@@ -41,7 +160,7 @@ func (a *App) MustRun() {
 func (a *App) Run() error {
 	const op = "app.grpc.Run"
 
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	logging.WithAttrs(a.ctx,
 		logging.StringAttr("host", a.cfg.Host),
@@ -55,22 +174,117 @@ func (a *App) Run() error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	logging.L(a.ctx).Info("gRPC server is running", logging.StringAttr("addr", l.Addr().String()))
-
-	client.Register(a.ctx, a.gRPCServer, a.pgClient)
-	//registration.Register(a.ctx, a.gRPCServer, a.pgClient)
+	logging.L(a.ctx).Info("gRPC server is running",
+		logging.StringAttr("addr", l.Addr().String()),
+		logging.BoolAttr("tls", a.tls),
+	)
 
-	if err := a.gRPCServer.Serve(l); err != nil {
+	if err := a.Serve(l); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
 
+// Serve registers the services and serves them on l until the server
+// stops, the tests serve on an in-memory listener. It fails when a
+// service can't be registered.
+func (a *App) Serve(l net.Listener) error {
+	services := servers{a.gRPCServer, a.gatewayServer}
+	client.Register(a.ctx, services, a.storages, a.cfg)
+	if err := auth.Register(a.ctx, services, a.storages, a.cfg); err != nil {
+		return err
+	}
+	if err := token.Register(a.ctx, services, a.storages, a.cfg, a.done); err != nil {
+		return err
+	}
+	healthpb.RegisterHealthServer(a.gRPCServer, a.healthServer)
+
+	if a.cfg.Env != envProd {
+		reflection.Register(a.gRPCServer)
+	}
+
+	a.checkHealth()
+	go a.watchHealth()
+
+	go func() {
+		if err := a.gatewayServer.Serve(a.gatewayListener); err != nil {
+			logging.L(a.ctx).Error("gateway server", logging.ErrAttr(err))
+		}
+	}()
+
+	return a.gRPCServer.Serve(l)
+}
+
 func (a *App) Stop() {
 	const op = "app.grpc.Stop"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	a.stopOnce.Do(a.stop)
+}
+
+func (a *App) stop() {
+	close(a.done)
+
+	// the probes see the server going away before it stops accepting
+	// calls, the health status no longer changes afterwards. New calls are
+	// still served until the load balancers have seen it.
+	a.healthServer.Shutdown()
+	time.Sleep(a.cfg.GRPC.HealthPropagationDelay)
+
+	stopped := make(chan struct{})
+	go func() {
+		a.gRPCServer.GracefulStop()
+		a.gatewayServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(a.cfg.GRPC.DrainTimeout):
+		logging.L(a.ctx).Warn("grpc drain timed out, cancelling the in-flight calls")
+		a.gRPCServer.Stop()
+		a.gatewayServer.Stop()
+	}
+
+	if err := a.gatewayConn.Close(); err != nil {
+		logging.L(a.ctx).Error("failed to close the gateway connection", logging.ErrAttr(err))
+	}
 
-	a.gRPCServer.GracefulStop()
 	logging.L(a.ctx).Info("grpc server stopped")
 }
+
+func (a *App) watchHealth() {
+	ticker := time.NewTicker(a.cfg.GRPC.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.checkHealth()
+		}
+	}
+}
+
+// checkHealth sets the status of the server and of every service from the
+// same dependencies as the HTTP health check, all services need them.
+func (a *App) checkHealth() {
+	ctx, cancel := context.WithTimeout(a.ctx, a.cfg.GRPC.HealthInterval)
+	defer cancel()
+
+	status := healthpb.HealthCheckResponse_SERVING
+	dependencies := health.Check(ctx, a.cfg, a.storages, a.queueClient)
+	if !health.Healthy(dependencies) {
+		logging.L(a.ctx).Warn("grpc dependencies are unhealthy", logging.AnyAttr("dependencies", dependencies))
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	a.healthServer.SetServingStatus("", status)
+	for service := range a.gRPCServer.GetServiceInfo() {
+		if service != healthpb.Health_ServiceDesc.ServiceName {
+			a.healthServer.SetServingStatus(service, status)
+		}
+	}
+}
@@ -3,13 +3,16 @@ package app
 import (
 	"app/internal/config"
 	server "app/internal/http-server"
+	"app/internal/storage"
 	"app/pkg/client/rabbitmq"
+	"app/pkg/common/core/mtls"
 	"app/pkg/common/logging"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/cors"
+	"google.golang.org/grpc"
 	"net"
 	"net/http"
 )
@@ -19,21 +22,24 @@ type App struct {
 	router      *chi.Mux
 	httpServer  *http.Server
 	cfg         *config.Config
-	pgClient    *pgxpool.Pool
+	storages    *storage.Storage
 	queueClient *rabbitmq.App
+	gatewayConn *grpc.ClientConn
 }
 
 func New(
 	ctx context.Context,
-	pgClient *pgxpool.Pool,
+	storages *storage.Storage,
 	cfg *config.Config,
 	queueClient *rabbitmq.App,
+	gatewayConn *grpc.ClientConn,
 ) *App {
 	return &App{
 		ctx:         ctx,
 		cfg:         cfg,
-		pgClient:    pgClient,
+		storages:    storages,
 		queueClient: queueClient,
+		gatewayConn: gatewayConn,
 	}
 }
 
@@ -45,39 +51,35 @@ func (a *App) MustRun() {
 
 func (a *App) Run() error {
 	const op = "app.http.Run"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	logging.WithAttrs(a.ctx,
 		logging.StringAttr("host", a.cfg.Host),
 		logging.IntAttr("port", a.cfg.HTTP.Port),
 	)
 
-	r, err := server.New(a.ctx, a.pgClient, a.cfg, a.queueClient)
+	handler, err := a.Handler()
 
 	if err != nil {
-		logging.L(a.ctx).Error("failed to create routers", err)
+		logging.L(a.ctx).Error("failed to create routers", logging.ErrAttr(err))
 		return err
 	}
 
 	logging.L(a.ctx).Info("starting http server")
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.HTTP.Port))
+	tlsConfig, err := mtls.ServerConfig(a.cfg.HTTP.TLS.Options())
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	c := cors.New(cors.Options{
-		AllowedMethods:     a.cfg.HTTP.CORS.AllowedMethods,
-		AllowedOrigins:     a.cfg.HTTP.CORS.AllowedOrigins,
-		AllowCredentials:   a.cfg.HTTP.CORS.AllowCredentials,
-		AllowedHeaders:     a.cfg.HTTP.CORS.AllowedHeaders,
-		OptionsPassthrough: a.cfg.HTTP.CORS.OptionsPassthrough,
-		//ExposedHeaders:     a.cfg.HTTP.CORS.ExposedHeaders,
-		// Enable Debugging for testing, consider disabling in production
-		Debug: a.cfg.HTTP.CORS.Debug,
-	})
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.cfg.HTTP.Port))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-	handler := c.Handler(r)
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
 
 	a.httpServer = &http.Server{
 		Handler:      handler,
@@ -85,21 +87,46 @@ func (a *App) Run() error {
 		ReadTimeout:  a.cfg.HTTP.ReadTimeout,
 	}
 
-	logging.L(a.ctx).Info("http server is running", logging.StringAttr("addr", l.Addr().String()))
+	logging.L(a.ctx).Info("http server is running",
+		logging.StringAttr("addr", l.Addr().String()),
+		logging.BoolAttr("tls", tlsConfig != nil),
+	)
 
 	if err := a.httpServer.Serve(l); err != nil {
-		logging.L(a.ctx).Error("http server", err)
+		logging.L(a.ctx).Error("http server", logging.ErrAttr(err))
 	}
 
 	return nil
 }
 
+// Handler returns the routes behind the CORS handler, the tests serve it
+// with httptest.
+func (a *App) Handler() (http.Handler, error) {
+	r, err := server.New(a.ctx, a.storages, a.cfg, a.queueClient, a.gatewayConn)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cors.New(cors.Options{
+		AllowedMethods:     a.cfg.HTTP.CORS.AllowedMethods,
+		AllowedOrigins:     a.cfg.HTTP.CORS.AllowedOrigins,
+		AllowCredentials:   a.cfg.HTTP.CORS.AllowCredentials,
+		AllowedHeaders:     a.cfg.HTTP.CORS.AllowedHeaders,
+		OptionsPassthrough: a.cfg.HTTP.CORS.OptionsPassthrough,
+		//ExposedHeaders:     a.cfg.HTTP.CORS.ExposedHeaders,
+		// Enable Debugging for testing, consider disabling in production
+		Debug: a.cfg.HTTP.CORS.Debug,
+	})
+
+	return c.Handler(r), nil
+}
+
 func (a *App) Stop() {
 	const op = "app.http.Stop"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	if err := a.httpServer.Shutdown(a.ctx); err != nil {
-		logging.L(a.ctx).Error("failed to stop http server", err)
+		logging.L(a.ctx).Error("failed to stop http server", logging.ErrAttr(err))
 		return
 	}
 
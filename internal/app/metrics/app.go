@@ -33,7 +33,7 @@ func (a *App) MustRun() {
 
 func (a *App) Run() error {
 	const op = "app.metrics.Run"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	logging.WithAttrs(a.ctx,
 		logging.StringAttr("host", a.cfg.Host),
@@ -54,7 +54,7 @@ func (a *App) Run() error {
 	err := http.ListenAndServe(host, mux)
 
 	if err != nil {
-		logging.L(a.ctx).Error("metrics server", err)
+		logging.L(a.ctx).Error("metrics server", logging.ErrAttr(err))
 	}
 
 	return nil
@@ -62,10 +62,10 @@ func (a *App) Run() error {
 
 func (a *App) Stop() {
 	const op = "app.metrics.Stop"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	if err := a.httpServer.Shutdown(a.ctx); err != nil {
-		logging.L(a.ctx).Error("failed to stop metrics server", err)
+		logging.L(a.ctx).Error("failed to stop metrics server", logging.ErrAttr(err))
 		return
 	}
 
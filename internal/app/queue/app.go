@@ -7,7 +7,6 @@ import (
 	"app/pkg/client/rabbitmq"
 	"app/pkg/common/logging"
 	"context"
-	"github.com/jackc/pgx/v5/pgxpool"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"os"
 	"os/signal"
@@ -18,20 +17,20 @@ type App struct {
 	ctx         context.Context
 	cfg         *config.Config
 	queueClient *rabbitmq.App
-	dbClient    *pgxpool.Pool
+	storages    *storage.Storage
 }
 
 func New(
 	ctx context.Context,
 	cfg *config.Config,
 	queueClient *rabbitmq.App,
-	dbClient *pgxpool.Pool,
+	storages *storage.Storage,
 ) *App {
 	return &App{
 		ctx:         ctx,
 		cfg:         cfg,
 		queueClient: queueClient,
-		dbClient:    dbClient,
+		storages:    storages,
 	}
 }
 
@@ -43,15 +42,9 @@ func (a *App) MustRun() {
 
 func (a *App) Run() error {
 	const op = "app.queue.Run"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
-	storages, err := storage.New(a.ctx, a.dbClient)
-	if err != nil {
-		logging.L(a.ctx).Error("failed to initialize storage", err)
-		return err
-	}
-
-	registrationHandler := handlers.NewHandleRegistration(a.dbClient, a.queueClient, storages)
+	registrationHandler := handlers.NewHandleRegistration(a.queueClient, a.storages)
 
 	go a.queueClient.ConsumeMsg("sso:user-registration", func(msg amqp.Delivery) {
 		rabbitmq.ProcessMessage(a.ctx, msg, registrationHandler)
@@ -67,11 +60,11 @@ func (a *App) Run() error {
 
 func (a *App) Stop() {
 	const op = "app.queue.Stop"
-	logging.L(a.ctx).Info("op", op)
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
 
 	//err := a.queueClient.Close()
 	//if err != nil {
-	//	logging.L(a.ctx).Error("failed to stop queue client", err)
+	//	logging.L(a.ctx).Error("failed to stop queue client", logging.ErrAttr(err))
 	//	return
 	//}
 
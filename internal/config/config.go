@@ -1,28 +1,63 @@
 package config
 
 import (
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/oidc"
+	"app/pkg/common/core/token"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const EnvLocal = "local"
+
 type Config struct {
-	Host      string     `yaml:"host"`
-	Env       string     `yaml:"env" env-default:"local"`
-	GRPC      GRPCConfig `yaml:"grpc"`
-	DB        DB         `yaml:"db"`
-	HTTP      HTTPConfig `yaml:"http"`
-	Token     Token      `yaml:"token"`
-	AppConfig AppConfig  `yaml:"appConfig"`
-	Metrics   Metrics    `yaml:"metrics"`
-	Queue     Queue      `yaml:"queue"`
+	Host          string        `yaml:"host"`
+	Issuer        string        `yaml:"issuer" env-default:"http://localhost:5462"`
+	Env           string        `yaml:"env" env-default:"local"`
+	GRPC          GRPCConfig    `yaml:"grpc"`
+	DB            DB            `yaml:"db"`
+	HTTP          HTTPConfig    `yaml:"http"`
+	Token         Token         `yaml:"token"`
+	AppConfig     AppConfig     `yaml:"appConfig"`
+	Metrics       Metrics       `yaml:"metrics"`
+	Queue         Queue         `yaml:"queue"`
+	Client        Client        `yaml:"client"`
+	Device        Device        `yaml:"device"`
+	Authorization Authorization `yaml:"authorization"`
+	DPoP          DPoP          `yaml:"dpop"`
+	Federation    Federation    `yaml:"federation"`
+	SAML          SAML          `yaml:"saml"`
+	SCIM          SCIM          `yaml:"scim"`
+	Admin         Admin         `yaml:"admin"`
 }
 
 type GRPCConfig struct {
 	Port    int           `yaml:"port"`
 	Timeout time.Duration `yaml:"timeout"`
+	TLS     TLS           `yaml:"tls"`
+	// Scopes lists the scopes of the access token a method needs, keyed by
+	// its full method name. The unlisted methods need the admin scope,
+	// except the ones of the auth, token, health and reflection services.
+	Scopes map[string][]string `yaml:"scopes"`
+	// HealthInterval is how often the dependencies of the health service
+	// are checked, DrainTimeout how long the in-flight calls are waited for
+	// on shutdown before they are cancelled. HealthPropagationDelay is how
+	// long the server keeps serving after it reports NOT_SERVING, so the
+	// load balancers stop sending new calls before it drains.
+	HealthInterval         time.Duration `yaml:"health_interval" env-default:"10s"`
+	DrainTimeout           time.Duration `yaml:"drain_timeout" env-default:"15s"`
+	HealthPropagationDelay time.Duration `yaml:"health_propagation_delay" env-default:"5s"`
+	// RevocationPollInterval is how often the revocation watches look for
+	// new events.
+	RevocationPollInterval time.Duration `yaml:"revocation_poll_interval" env-default:"2s"`
 }
 
 type HTTPConfig struct {
@@ -30,6 +65,30 @@ type HTTPConfig struct {
 	CORS         CORS          `yaml:"cors"`
 	ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"10s"`
 	WriteTimeout time.Duration `yaml:"write_timeout" env-default:"10s"`
+	TLS          TLS           `yaml:"tls"`
+}
+
+// TLS configures the listener of a server, it stays plaintext without a
+// certificate. ClientAuth is one of none, request, require,
+// verify_if_given and require_and_verify, self_signed_tls_client_auth
+// clients need one of the first three. ClientCAFile holds the CAs client
+// certificates are verified against, tls_client_auth clients of the HTTP
+// server are checked against it as well.
+type TLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth" env-default:"none"`
+}
+
+// Options returns the listener options of the mtls package.
+func (t TLS) Options() mtls.Options {
+	return mtls.Options{
+		CertFile:     t.CertFile,
+		KeyFile:      t.KeyFile,
+		ClientCAFile: t.ClientCAFile,
+		ClientAuth:   t.ClientAuth,
+	}
 }
 
 type CORS struct {
@@ -47,8 +106,157 @@ type Token struct {
 	Refresh       time.Duration `yaml:"refresh" env-default:"1d"`
 	Secret        string        `yaml:"secret" env-default:"secret"`
 	RefreshSecret string        `yaml:"secret_refresh" env-default:"refresh_secret"`
+	LegacyClaims  bool          `yaml:"legacy_claims" env-default:"true"`
+}
+
+// AccessToken returns the options of the access tokens issued by issuer.
+func (t Token) AccessToken(issuer string) token.Options {
+	return token.Options{
+		Issuer:       issuer,
+		TTL:          t.TTL,
+		Secret:       t.Secret,
+		LegacyClaims: t.LegacyClaims,
+	}
+}
+
+type Client struct {
+	SecretGracePeriod time.Duration `yaml:"secret_grace_period" env-default:"24h"`
+	// InitialAccessTokens gate dynamic client registration, it is disabled
+	// when none are configured.
+	InitialAccessTokens []string `yaml:"initial_access_tokens" env:"CLIENT_INITIAL_ACCESS_TOKENS"`
+}
+
+// Device configures the device authorization grant (RFC 8628).
+type Device struct {
+	TTL             time.Duration `yaml:"ttl" env-default:"10m"`
+	Interval        time.Duration `yaml:"interval" env-default:"5s"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1m"`
+	// VerificationURI is the page where users enter the user code, it
+	// defaults to the verification endpoint of the issuer.
+	VerificationURI string `yaml:"verification_uri"`
+}
+
+// Authorization configures the authorization endpoint and pushed
+// authorization requests (RFC 9126).
+type Authorization struct {
+	CodeTTL       time.Duration `yaml:"code_ttl" env-default:"1m"`
+	RequestURITTL time.Duration `yaml:"request_uri_ttl" env-default:"1m"`
+}
+
+// DPoP configures sender-constrained tokens (RFC 9449). The nonces are
+// signed with NonceSecret, without it with a key derived from the token
+// secret.
+type DPoP struct {
+	ProofLifetime time.Duration `yaml:"proof_lifetime" env-default:"1m"`
+	RequireNonce  bool          `yaml:"require_nonce" env-default:"false"`
+	NonceLifetime time.Duration `yaml:"nonce_lifetime" env-default:"5m"`
+	NonceSecret   string        `yaml:"nonce_secret"`
+}
+
+// NonceKey returns the key the nonces are signed with, the derived key
+// keeps the access token key out of the nonces.
+func (d DPoP) NonceKey(tokenSecret string) string {
+	if d.NonceSecret != "" {
+		return d.NonceSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(tokenSecret))
+	mac.Write([]byte("dpop-nonce-key"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Federation configures sign in with upstream OpenID Connect providers.
+// LinkMaxAge is how recently the access token of a user linking an
+// identity must have been issued, older tokens must sign in again.
+type Federation struct {
+	StateTTL   time.Duration        `yaml:"state_ttl" env-default:"10m"`
+	LinkMaxAge time.Duration        `yaml:"link_max_age" env-default:"5m"`
+	Providers  []FederationProvider `yaml:"providers"`
 }
+
+// FederationProvider is the registration of the server as a client of an
+// upstream provider. RedirectURL defaults to the callback endpoint of the
+// issuer.
+type FederationProvider struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+	RedirectURL  string   `yaml:"redirect_url"`
+}
+
+// OIDC returns the client options of the oidc package.
+func (p FederationProvider) OIDC(issuer string) oidc.Config {
+	redirectURL := p.RedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(issuer, "/") + "/oauth/federation/" + p.Name + "/callback"
+	}
+
+	return oidc.Config{
+		Issuer:       p.Issuer,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       p.Scopes,
+	}
+}
+
+// SAML configures the SAML 2.0 identity provider, it is enabled when a
+// certificate is set. Assertions are signed with the key of the
+// certificate. LoginURL is the page the browsers without a session are
+// sent to, with the SSO request to come back to in return_to, the
+// single sign on answers 401 without it.
+type SAML struct {
+	CertFile         string                `yaml:"cert_file"`
+	KeyFile          string                `yaml:"key_file"`
+	LoginURL         string                `yaml:"login_url"`
+	ServiceProviders []SAMLServiceProvider `yaml:"service_providers"`
+}
+
+// SAMLServiceProvider registers a service provider. NameIDFormat is one
+// of email, persistent, transient and unspecified, it defaults to email.
+// Attributes maps SAML attribute names to the user fields uuid, email
+// and name.
+type SAMLServiceProvider struct {
+	EntityID     string            `yaml:"entity_id"`
+	ACSURL       string            `yaml:"acs_url"`
+	NameIDFormat string            `yaml:"name_id_format"`
+	Attributes   map[string]string `yaml:"attributes"`
+}
+
+// SCIM configures the provisioning API, MaxResults caps the page size of
+// the queries.
+type SCIM struct {
+	MaxResults int `yaml:"max_results" env-default:"200"`
+}
+
+// Admin protects the management APIs of the clients, the resources and
+// the SCIM tokens, they need an access token granted Scope to one of the
+// Users, listed by UUID. The scope alone is not enough, any client
+// registered for it could have it granted.
+type Admin struct {
+	Scope string   `yaml:"scope" env-default:"admin"`
+	Users []string `yaml:"users"`
+}
+
+// Allows reports whether an access token of the user granted scope gives
+// access to the management APIs.
+func (a Admin) Allows(userUUID, scope string) bool {
+	return userUUID != "" && slices.Contains(a.Users, userUUID) && slices.Contains(strings.Fields(scope), a.Scope)
+}
+
+const (
+	DriverPgsql  = "pgsql"
+	DriverSqlite = "sqlite"
+	DriverMemory = "memory"
+)
+
+// DB selects the storage backend with Driver. SQLite and memory only store
+// the users, the clients, the resources and the tokens, the other
+// features need PostgreSQL. Memory loses everything on a restart.
 type DB struct {
+	Driver         string        `yaml:"driver" env-default:"pgsql"`
 	MigrationsPath string        `yaml:"migration_path" env-required:"true"`
 	SQLITE         SQLITE        `yaml:"sqlite"`
 	PGSQL          PGSQL         `yaml:"pgsql"`
@@ -90,6 +298,12 @@ type Queue struct {
 	MaxDelay    time.Duration `yaml:"max_delay" env-default:"6s"`
 }
 
+// IsLocal reports whether the service runs in the local development
+// environment, where some security requirements are relaxed.
+func (c *Config) IsLocal() bool {
+	return c.Env == EnvLocal
+}
+
 func MustLoad() *Config {
 	configPath := fetchConfigPath()
 	if configPath == "" {
@@ -1,15 +1,395 @@
 package client
 
+import (
+	"app/pkg/common/core/jwk"
+	"app/pkg/common/core/redirecturi"
+	"app/pkg/utils/crypt"
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Token endpoint authentication methods (RFC 7591, section 2).
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodNone              = "none"
+	// certificate based methods of RFC 8705, section 2
+	AuthMethodTLSClientAuth           = "tls_client_auth"
+	AuthMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
+)
+
+// Grant types a client can be registered for (RFC 7591, section 2).
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypePassword          = "password"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+var grantTypes = []string{
+	GrantTypeAuthorizationCode,
+	GrantTypePassword,
+	GrantTypeRefreshToken,
+	GrantTypeClientCredentials,
+	GrantTypeDeviceCode,
+	GrantTypeTokenExchange,
+}
+
+var (
+	ErrInvalidAuthMethod = errors.New("invalid token endpoint auth method")
+	ErrJWKSRequired      = errors.New("jwks is required for private_key_jwt")
+	ErrInvalidJWKS       = errors.New("invalid jwks")
+	ErrNoRedirectURIs    = errors.New("at least one redirect uri is required")
+	ErrInvalidGrantType  = errors.New("invalid grant type")
+	ErrInvalidTarget     = errors.New("audience is not allowed for the client")
+	ErrInvalidScope      = errors.New("scope is not allowed")
+	ErrInvalidScopeToken = errors.New("invalid scope")
+	ErrTLSClientAuth     = errors.New("exactly one certificate subject attribute is required for tls_client_auth")
+	ErrCertificateJWKS   = errors.New("jwks with x5c certificates is required for self_signed_tls_client_auth")
+)
+
+// Client is an OAuth client. Secret and PreviousSecret hold salted hashes,
+// the plain secret is only known when it is generated. RegistrationAccessToken
+// holds the hash of the RFC 7592 management token of dynamically registered
+// clients.
 type Client struct {
-	ID                   string `json:"id"`
-	UserId               *int64 `json:"userId"`
-	Name                 string `json:"name"`
-	Secret               string `json:"secret"`
-	Provider             string `json:"provider"`
-	Redirect             string `json:"redirect"`
-	PersonalAccessClient bool   `json:"personalAccessClient"`
-	PasswordClient       bool   `json:"passwordClient"`
-	Revoked              bool   `json:"revoked"`
-	CreatedAt            int64  `json:"createdAt"`
-	UpdatedAt            int64  `json:"updatedAt"`
+	ID                      string         `json:"id"`
+	UserId                  *int64         `json:"userId"`
+	Name                    string         `json:"name"`
+	Secret                  string         `json:"secret"`
+	PreviousSecret          *string        `json:"previousSecret"`
+	PreviousSecretExpiresAt int64          `json:"previousSecretExpiresAt"`
+	Provider                string         `json:"provider"`
+	RedirectURIs            []string       `json:"redirectUris"`
+	PersonalAccessClient    bool           `json:"personalAccessClient"`
+	PasswordClient          bool           `json:"passwordClient"`
+	Revoked                 bool           `json:"revoked"`
+	TokenEndpointAuthMethod string         `json:"tokenEndpointAuthMethod"`
+	JWKS                    *string        `json:"jwks"`
+	GrantTypes              []string       `json:"grantTypes"`
+	Contacts                []string       `json:"contacts"`
+	RegistrationAccessToken *string        `json:"-"`
+	TokenExchange           *TokenExchange `json:"tokenExchange"`
+	// Scopes are the scopes the client may request outside of the scopes
+	// of the resources.
+	Scopes []string `json:"scopes"`
+	// RequirePushedAuthorizationRequests makes the client start every
+	// authorization with a pushed authorization request (RFC 9126).
+	RequirePushedAuthorizationRequests bool           `json:"requirePushedAuthorizationRequests"`
+	TLSClientAuth                      *TLSClientAuth `json:"tlsClientAuth"`
+	CreatedAt                          int64          `json:"createdAt"`
+	UpdatedAt                          int64          `json:"updatedAt"`
+}
+
+// TokenExchange holds the token exchange rules of a client (RFC 8693).
+// Impersonation allows exchanges without an actor token, the issued token
+// then only represents the subject. Delegation allows exchanges with an
+// actor token, the actor is recorded in the act claim.
+type TokenExchange struct {
+	Audiences     []string `json:"audiences"`
+	Scopes        []string `json:"scopes"`
+	Impersonation bool     `json:"impersonation"`
+	Delegation    bool     `json:"delegation"`
+}
+
+// TLSClientAuth names the certificate a tls_client_auth client presents
+// (RFC 8705, section 2.1.2), exactly one attribute is set.
+type TLSClientAuth struct {
+	SubjectDN string `json:"subjectDn,omitempty"`
+	SANDNS    string `json:"sanDns,omitempty"`
+	SANURI    string `json:"sanUri,omitempty"`
+	SANIP     string `json:"sanIp,omitempty"`
+	SANEmail  string `json:"sanEmail,omitempty"`
+}
+
+func (t *TLSClientAuth) valid() bool {
+	set := 0
+	for _, attr := range []string{t.SubjectDN, t.SANDNS, t.SANURI, t.SANIP, t.SANEmail} {
+		if attr != "" {
+			set++
+		}
+	}
+	return set == 1
+}
+
+// Matches reports whether the certificate carries the registered subject.
+func (t *TLSClientAuth) Matches(cert *x509.Certificate) bool {
+	switch {
+	case t.SubjectDN != "":
+		return cert.Subject.String() == t.SubjectDN
+	case t.SANDNS != "":
+		return slices.Contains(cert.DNSNames, t.SANDNS)
+	case t.SANURI != "":
+		return slices.ContainsFunc(cert.URIs, func(uri *url.URL) bool { return uri.String() == t.SANURI })
+	case t.SANIP != "":
+		ip := net.ParseIP(t.SANIP)
+		return ip != nil && slices.ContainsFunc(cert.IPAddresses, ip.Equal)
+	case t.SANEmail != "":
+		return slices.Contains(cert.EmailAddresses, t.SANEmail)
+	}
+	return false
+}
+
+// Audience checks the requested audiences, the first allowed audience is
+// used when none is requested.
+func (t *TokenExchange) Audience(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		if len(t.Audiences) == 0 {
+			return nil, ErrInvalidTarget
+		}
+		return t.Audiences[:1], nil
+	}
+
+	for _, aud := range requested {
+		if !slices.Contains(t.Audiences, aud) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTarget, aud)
+		}
+	}
+
+	return requested, nil
+}
+
+// Scope narrows the scopes of the exchanged token to the ones allowed for
+// the client and held by the subject token, nil subject scopes mean the
+// subject token is not restricted.
+func (t *TokenExchange) Scope(requested, subject []string) ([]string, error) {
+	allowed := make([]string, 0, len(t.Scopes))
+	for _, scope := range t.Scopes {
+		if subject == nil || slices.Contains(subject, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	for _, scope := range requested {
+		if !slices.Contains(allowed, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	return requested, nil
+}
+
+// RotateSecret replaces the client secret hash and keeps the current one
+// as the previous secret until the grace period is over.
+func (c *Client) RotateSecret(secretHash string, now time.Time, grace time.Duration) {
+	previous := c.Secret
+	c.PreviousSecret = &previous
+	c.PreviousSecretExpiresAt = now.Add(grace).Unix()
+	c.Secret = secretHash
+	c.UpdatedAt = now.Unix()
+}
+
+// AcceptsSecret reports whether secret matches the current secret or the
+// previous one while its grace period is still running.
+func (c *Client) AcceptsSecret(secret string, now time.Time) bool {
+	if crypt.VerifySecret(c.Secret, secret) {
+		return true
+	}
+
+	if c.PreviousSecret == nil || c.PreviousSecretExpiresAt < now.Unix() {
+		return false
+	}
+
+	return crypt.VerifySecret(*c.PreviousSecret, secret)
+}
+
+// UsesSecret reports whether the client authenticates with its client secret.
+func (c *Client) UsesSecret() bool {
+	return c.TokenEndpointAuthMethod == AuthMethodClientSecretBasic ||
+		c.TokenEndpointAuthMethod == AuthMethodClientSecretPost
+}
+
+// SetTokenExchange replaces the token exchange rules, the client is
+// allowed the token exchange grant.
+func (c *Client) SetTokenExchange(rules *TokenExchange) {
+	c.TokenExchange = rules
+	if !c.AllowsGrant(GrantTypeTokenExchange) {
+		c.GrantTypes = append(c.GrantTypes, GrantTypeTokenExchange)
+	}
+}
+
+// DropSecret forgets the secrets of a client that no longer authenticates
+// with one, so they can't be used again if it switches back. It reports
+// whether there was a secret to drop.
+func (c *Client) DropSecret() bool {
+	if c.Secret == "" && c.PreviousSecret == nil {
+		return false
+	}
+	c.Secret = ""
+	c.PreviousSecret = nil
+	c.PreviousSecretExpiresAt = 0
+	return true
+}
+
+// UsesCertificate reports whether the client authenticates with a TLS
+// client certificate.
+func (c *Client) UsesCertificate() bool {
+	return c.TokenEndpointAuthMethod == AuthMethodTLSClientAuth ||
+		c.TokenEndpointAuthMethod == AuthMethodSelfSignedTLSClientAuth
+}
+
+// MatchesSelfSignedCertificate reports whether cert is one of the
+// certificates registered in the JWK set of the client.
+func (c *Client) MatchesSelfSignedCertificate(cert *x509.Certificate) bool {
+	if c.JWKS == nil {
+		return false
+	}
+
+	keys, err := jwk.Parse([]byte(*c.JWKS))
+	if err != nil {
+		return false
+	}
+
+	for _, key := range keys.Keys {
+		registered, err := key.Certificate()
+		if err == nil && bytes.Equal(registered.Raw, cert.Raw) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPublic reports whether the client can't authenticate, such clients
+// must use PKCE.
+func (c *Client) IsPublic() bool {
+	return c.TokenEndpointAuthMethod == AuthMethodNone
+}
+
+// SetAuthMethod changes the token endpoint authentication method, a JWK
+// set with the client public keys is required for private_key_jwt and
+// one with the client certificates for self_signed_tls_client_auth. The
+// certificate subject of tls_client_auth must be set before.
+func (c *Client) SetAuthMethod(method string, jwks *string) error {
+	switch method {
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodNone:
+	case AuthMethodPrivateKeyJWT, AuthMethodSelfSignedTLSClientAuth:
+		if jwks == nil && c.JWKS == nil {
+			return ErrJWKSRequired
+		}
+	case AuthMethodTLSClientAuth:
+		if c.TLSClientAuth == nil || !c.TLSClientAuth.valid() {
+			return ErrTLSClientAuth
+		}
+	default:
+		return ErrInvalidAuthMethod
+	}
+
+	if jwks == nil {
+		jwks = c.JWKS
+	}
+
+	if jwks != nil {
+		keys, err := jwk.Parse([]byte(*jwks))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJWKS, err)
+		}
+		if method == AuthMethodSelfSignedTLSClientAuth && !hasCertificates(keys) {
+			return ErrCertificateJWKS
+		}
+		c.JWKS = jwks
+	}
+
+	c.TokenEndpointAuthMethod = method
+	return nil
+}
+
+func hasCertificates(keys *jwk.Set) bool {
+	return slices.ContainsFunc(keys.Keys, func(key jwk.Key) bool {
+		_, err := key.Certificate()
+		return err == nil
+	})
+}
+
+// SetRedirectURIs replaces the registered redirect URIs. Every URI is
+// validated, plain http is only accepted for loopback redirects unless
+// allowHTTP is set.
+func (c *Client) SetRedirectURIs(uris []string, allowHTTP bool) error {
+	registered := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if err := redirecturi.Validate(uri, allowHTTP); err != nil {
+			return fmt.Errorf("%w: %s", err, uri)
+		}
+		if !slices.Contains(registered, uri) {
+			registered = append(registered, uri)
+		}
+	}
+
+	if len(registered) == 0 {
+		return ErrNoRedirectURIs
+	}
+
+	c.RedirectURIs = registered
+	return nil
+}
+
+// RedirectURI returns the URI to send the user-agent back to, every flow
+// that redirects to the client must resolve it here.
+func (c *Client) RedirectURI(requested string) (string, error) {
+	return redirecturi.Resolve(c.RedirectURIs, requested)
+}
+
+// SetGrantTypes replaces the grant types the client may use.
+func (c *Client) SetGrantTypes(types []string) error {
+	allowed := make([]string, 0, len(types))
+	for _, grantType := range types {
+		if !slices.Contains(grantTypes, grantType) {
+			return fmt.Errorf("%w: %s", ErrInvalidGrantType, grantType)
+		}
+		if !slices.Contains(allowed, grantType) {
+			allowed = append(allowed, grantType)
+		}
+	}
+
+	c.GrantTypes = allowed
+	return nil
+}
+
+// AllowsGrant reports whether the client is registered for the grant type.
+func (c *Client) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// SetScopes replaces the scopes the client may request, every scope must
+// be a scope token of RFC 6749, section 3.3.
+func (c *Client) SetScopes(scopes []string) error {
+	registered := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !validScopeToken(scope) {
+			return fmt.Errorf("%w: %q", ErrInvalidScopeToken, scope)
+		}
+		if !slices.Contains(registered, scope) {
+			registered = append(registered, scope)
+		}
+	}
+
+	c.Scopes = registered
+	return nil
+}
+
+// validScopeToken reports whether scope is a non-empty run of the
+// printable ASCII characters but the space, the quote and the backslash.
+func validScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, ch := range scope {
+		if ch < 0x21 || ch > 0x7e || ch == '"' || ch == '\\' {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,56 @@
+package federation
+
+import (
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	"errors"
+	"time"
+)
+
+var ErrLastLoginMethod = errors.New("the last login method of the user can't be unlinked")
+
+const (
+	EventIdentityLinked   = "identity.linked"
+	EventIdentityUnlinked = "identity.unlinked"
+)
+
+// State is a sign in with an upstream provider in progress. ID is the
+// hash of the state parameter, the nonce and the PKCE code verifier are
+// checked when the provider redirects back. Params is the authorization
+// request of the client the sign in ends with. A state with a UserId
+// links the upstream identity to that user instead of signing in.
+type State struct {
+	ID           string                             `json:"id"`
+	Provider     string                             `json:"provider"`
+	ClientId     string                             `json:"clientId"`
+	UserId       *int64                             `json:"userId"`
+	Params       *authorizationRequestDomain.Params `json:"params"`
+	Nonce        string                             `json:"nonce"`
+	CodeVerifier string                             `json:"codeVerifier"`
+	ExpiresAt    int64                              `json:"expiresAt"`
+	CreatedAt    int64                              `json:"createdAt"`
+}
+
+func (s *State) Expired(now time.Time) bool {
+	return s.ExpiresAt < now.Unix()
+}
+
+// Identity links a local user to the subject of an upstream provider.
+type Identity struct {
+	ID        string `json:"id"`
+	UserId    int64  `json:"userId"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Event is published when an identity is linked to or unlinked from a
+// user.
+type Event struct {
+	Event     string `json:"event"`
+	UserUUID  string `json:"userUuid"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	CreatedAt int64  `json:"createdAt"`
+}
@@ -0,0 +1,39 @@
+package group
+
+import "errors"
+
+var ErrUnknownMember = errors.New("a member of the group is not a user")
+
+// Group is a named set of users, it is provisioned through SCIM.
+type Group struct {
+	ID          int64    `json:"id"`
+	UUID        string   `json:"uuid"`
+	DisplayName string   `json:"displayName"`
+	ExternalId  *string  `json:"externalId"`
+	Members     []Member `json:"members"`
+	Version     int64    `json:"version"`
+	CreatedAt   int64    `json:"createdAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+}
+
+// Member is a user of a group, Display is the display name of the user.
+type Member struct {
+	UserUUID string `json:"userUuid"`
+	Display  string `json:"display"`
+}
+
+// Membership is a group of a user.
+type Membership struct {
+	UserId      int64  `json:"userId"`
+	GroupUUID   string `json:"groupUuid"`
+	DisplayName string `json:"displayName"`
+}
+
+// MemberUUIDs returns the users of the group.
+func (g Group) MemberUUIDs() []string {
+	uuids := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		uuids = append(uuids, m.UserUUID)
+	}
+	return uuids
+}
@@ -1,8 +1,45 @@
 package access_token
 
+// Payload is the content of an access token, AuthTime is when the user
+// authenticated, it is kept by the tokens the grant is refreshed with.
 type Payload struct {
-	UUID     string `json:"uuid"`
-	Email    string `json:"email"`
-	ClientID string `json:"client_id"`
-	Scopes   any    `json:"scopes"`
+	ID       string        `json:"id"`
+	UUID     string        `json:"uuid"`
+	Email    string        `json:"email"`
+	ClientID string        `json:"client_id"`
+	Scopes   any           `json:"scopes"`
+	Audience []string      `json:"audience"`
+	Scope    string        `json:"scope"`
+	AuthTime int64         `json:"auth_time"`
+	Act      *Actor        `json:"act"`
+	Cnf      *Confirmation `json:"cnf"`
+}
+
+// Confirmation binds a token to a key of the client (RFC 7800), JKT is
+// the thumbprint of a DPoP proof key (RFC 9449, section 6) and X5TS256
+// the one of a TLS client certificate (RFC 8705, section 3.1).
+type Confirmation struct {
+	JKT     string `json:"jkt,omitempty"`
+	X5TS256 string `json:"x5t#S256,omitempty"`
+}
+
+// SatisfiedBy reports whether presented carries every key the token is
+// bound to, an unbound token is satisfied by anything.
+func (c *Confirmation) SatisfiedBy(presented *Confirmation) bool {
+	if c == nil {
+		return true
+	}
+	if presented == nil {
+		return c.JKT == "" && c.X5TS256 == ""
+	}
+	return (c.JKT == "" || c.JKT == presented.JKT) &&
+		(c.X5TS256 == "" || c.X5TS256 == presented.X5TS256)
+}
+
+// Actor is the act claim of a delegated token (RFC 8693, section 4.1),
+// Act holds the previous actor when a delegated token is exchanged again.
+type Actor struct {
+	Sub      string `json:"sub,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Act      *Actor `json:"act,omitempty"`
 }
@@ -0,0 +1,42 @@
+package authorization_code
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+)
+
+// AuthorizationCode is an issued authorization code. ID is the hash of the
+// code handed to the client, AuthTime is when the user authenticated.
+type AuthorizationCode struct {
+	ID                  string   `json:"id"`
+	ClientId            string   `json:"clientId"`
+	UserId              int64    `json:"userId"`
+	RedirectURI         string   `json:"redirectUri"`
+	Scope               string   `json:"scope"`
+	Resources           []string `json:"resources"`
+	CodeChallenge       string   `json:"codeChallenge"`
+	CodeChallengeMethod string   `json:"codeChallengeMethod"`
+	AuthTime            int64    `json:"authTime"`
+	ExpiresAt           int64    `json:"expiresAt"`
+	CreatedAt           int64    `json:"createdAt"`
+}
+
+func (a *AuthorizationCode) Expired(now time.Time) bool {
+	return a.ExpiresAt < now.Unix()
+}
+
+// VerifyCodeVerifier checks the PKCE code verifier against the S256 code
+// challenge (RFC 7636, section 4.6). A code issued without a challenge
+// must be redeemed without a verifier.
+func (a *AuthorizationCode) VerifyCodeVerifier(verifier string) bool {
+	if a.CodeChallenge == "" {
+		return verifier == ""
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(a.CodeChallenge)) == 1
+}
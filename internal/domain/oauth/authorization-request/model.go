@@ -0,0 +1,115 @@
+package authorization_request
+
+import (
+	"app/internal/domain/client"
+	"app/internal/domain/oauth/resource"
+	"net/url"
+	"time"
+)
+
+const (
+	ResponseTypeCode        = "code"
+	CodeChallengeMethodS256 = "S256"
+)
+
+// RequestURIPrefix starts every request_uri issued for a pushed
+// authorization request (RFC 9126, section 2.2).
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// Params are the parameters of an authorization request (RFC 6749,
+// section 4.1.1, RFC 7636, section 4.3 and RFC 8707, section 2.1).
+type Params struct {
+	ResponseType        string   `json:"response_type"`
+	ClientID            string   `json:"client_id"`
+	RedirectURI         string   `json:"redirect_uri"`
+	Scope               string   `json:"scope"`
+	State               string   `json:"state"`
+	Nonce               string   `json:"nonce"`
+	CodeChallenge       string   `json:"code_challenge"`
+	CodeChallengeMethod string   `json:"code_challenge_method"`
+	Resource            []string `json:"resource,omitempty"`
+}
+
+func ParamsFromValues(values url.Values) Params {
+	return Params{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		Resource:            values["resource"],
+	}
+}
+
+// AuthorizationRequest is a pushed authorization request. ID is the hash
+// of the request_uri handed to the client.
+type AuthorizationRequest struct {
+	ID        string `json:"id"`
+	ClientId  string `json:"clientId"`
+	Params    Params `json:"params"`
+	ExpiresAt int64  `json:"expiresAt"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+func (a *AuthorizationRequest) Expired(now time.Time) bool {
+	return a.ExpiresAt < now.Unix()
+}
+
+// Error is an authorization error response (RFC 6749, section 4.1.2.1).
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// Validate checks the parameters against the client and resolves the
+// redirect URI. No redirect URI is returned when the error must not be
+// sent back to the client through the user-agent.
+func (p *Params) Validate(c client.Client) (string, error) {
+	if p.ClientID != c.ID {
+		return "", &Error{Code: "invalid_request", Description: "client_id does not match the client"}
+	}
+
+	redirectURI, err := c.RedirectURI(p.RedirectURI)
+	if err != nil {
+		return "", &Error{Code: "invalid_request", Description: err.Error()}
+	}
+
+	if !c.AllowsGrant(client.GrantTypeAuthorizationCode) {
+		return redirectURI, &Error{Code: "unauthorized_client", Description: "client is not allowed to use the authorization code grant"}
+	}
+
+	if p.ResponseType != ResponseTypeCode {
+		return redirectURI, &Error{Code: "unsupported_response_type", Description: "response_type must be code"}
+	}
+
+	for _, identifier := range p.Resource {
+		if err := resource.ValidIdentifier(identifier); err != nil {
+			return redirectURI, &Error{Code: "invalid_target", Description: err.Error()}
+		}
+	}
+
+	// PKCE is required for public clients, only S256 is accepted
+	if p.CodeChallenge == "" {
+		if c.IsPublic() {
+			return redirectURI, &Error{Code: "invalid_request", Description: "code_challenge is required"}
+		}
+		return redirectURI, nil
+	}
+
+	if p.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return redirectURI, &Error{Code: "invalid_request", Description: "code_challenge_method must be S256"}
+	}
+
+	if len(p.CodeChallenge) != 43 {
+		return redirectURI, &Error{Code: "invalid_request", Description: "invalid code_challenge"}
+	}
+
+	return redirectURI, nil
+}
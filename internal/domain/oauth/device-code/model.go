@@ -0,0 +1,93 @@
+package device_code
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+)
+
+// SlowDownStep is added to the polling interval of a client that polls
+// too fast (RFC 8628, section 3.5).
+const SlowDownStep = 5
+
+// DeviceCode is a pending device authorization. ID is the hash of the
+// device code handed to the client, UserCode is stored normalized and
+// Scopes holds the space-delimited scope the client requested. AuthTime
+// is when the user who approved it authenticated.
+type DeviceCode struct {
+	ID           string `json:"id"`
+	UserCode     string `json:"userCode"`
+	ClientId     string `json:"clientId"`
+	Scopes       string `json:"scopes"`
+	UserId       *int64 `json:"userId"`
+	AuthTime     int64  `json:"authTime"`
+	Status       string `json:"status"`
+	Interval     int64  `json:"interval"`
+	LastPolledAt int64  `json:"lastPolledAt"`
+	ExpiresAt    int64  `json:"expiresAt"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+func (d *DeviceCode) Expired(now time.Time) bool {
+	return d.ExpiresAt < now.Unix()
+}
+
+// PolledTooFast reports whether the client polls before the interval
+// since its previous request has passed.
+func (d *DeviceCode) PolledTooFast(now time.Time) bool {
+	return d.LastPolledAt != 0 && now.Unix()-d.LastPolledAt < d.Interval
+}
+
+// userCodeAlphabet has no vowels and no easily confused characters
+// (RFC 8628, section 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// NewUserCode returns a random normalized user code.
+func NewUserCode() (string, error) {
+	// bytes above the largest multiple of the alphabet size are skipped
+	// so that every character is equally likely
+	limit := byte(256 / len(userCodeAlphabet) * len(userCodeAlphabet))
+
+	code := make([]byte, 0, userCodeLength)
+	buf := make([]byte, userCodeLength)
+	for len(code) < userCodeLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b < limit && len(code) < userCodeLength {
+				code = append(code, userCodeAlphabet[int(b)%len(userCodeAlphabet)])
+			}
+		}
+	}
+
+	return string(code), nil
+}
+
+// NormalizeUserCode makes the code typed by the user comparable with the
+// stored one: case and separators are ignored.
+func NormalizeUserCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, code)
+}
+
+// FormatUserCode splits a normalized code in two halves for display.
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
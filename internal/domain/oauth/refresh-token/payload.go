@@ -1,12 +1,19 @@
 package refresh_token
 
+import accessTokenDomain "app/internal/domain/oauth/access-token"
+
 type Payload struct {
-	UUID           string `json:"uuid"`
-	Email          string `json:"email"`
-	TokenAccessId  string `json:"token_access_id"`
-	TokenRefreshId string `json:"token_refresh_id"`
-	ClientId       string `json:"client_id"`
-	UserId         int64  `json:"user_id"`
-	ExpiresAt      int64  `json:"exp_at"`
-	Scopes         any    `json:"scopes"`
+	UUID           string                          `json:"uuid"`
+	Email          string                          `json:"email"`
+	TokenAccessId  string                          `json:"token_access_id"`
+	TokenRefreshId string                          `json:"token_refresh_id"`
+	ClientId       string                          `json:"client_id"`
+	UserId         int64                           `json:"user_id"`
+	ExpiresAt      int64                           `json:"exp_at"`
+	Scopes         any                             `json:"scopes"`
+	Audience       []string                        `json:"aud,omitempty"`
+	Scope          string                          `json:"scope,omitempty"`
+	AccessTokenTTL int64                           `json:"access_token_ttl,omitempty"`
+	AuthTime       int64                           `json:"auth_time,omitempty"`
+	Cnf            *accessTokenDomain.Confirmation `json:"cnf,omitempty"`
 }
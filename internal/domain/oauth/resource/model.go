@@ -0,0 +1,93 @@
+package resource
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTarget = errors.New("resource must be an absolute URI without a fragment")
+	ErrInvalidScope  = errors.New("scope is not allowed for the client or the resource")
+)
+
+// Resource is a protected resource (API), Identifier is the value of the
+// resource parameter (RFC 8707, section 2) and the audience of the tokens
+// minted for it. ClientId is the client the resource server introspects
+// tokens with.
+type Resource struct {
+	ID         string   `json:"id"`
+	Identifier string   `json:"identifier"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	TokenTTL   int64    `json:"tokenTtl"`
+	ClientId   *string  `json:"clientId"`
+	CreatedAt  int64    `json:"createdAt"`
+	UpdatedAt  int64    `json:"updatedAt"`
+}
+
+// ValidIdentifier checks a resource indicator, it must be an absolute URI
+// without a fragment (RFC 8707, section 2).
+func ValidIdentifier(identifier string) error {
+	u, err := url.Parse(identifier)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.Contains(identifier, "#") {
+		return ErrInvalidTarget
+	}
+	return nil
+}
+
+// TTL returns the lifetime of the tokens minted for the resource.
+func (r *Resource) TTL(fallback time.Duration) time.Duration {
+	if r.TokenTTL > 0 {
+		return time.Duration(r.TokenTTL) * time.Second
+	}
+	return fallback
+}
+
+// Target is the audience, the scope and the lifetime of a token minted
+// for a set of resources.
+type Target struct {
+	Audience []string
+	Scope    string
+	TTL      time.Duration
+}
+
+// NewTarget restricts the requested scope to the scopes of the resources,
+// every requested scope must be allowed by one of them. Without a
+// requested scope the token gets every scope of the resources. When no
+// resource restricts the scopes they must be registered for the client.
+// The token lives as long as the shortest lived resource allows.
+func NewTarget(resources []Resource, scope string, registered []string, ttl time.Duration) (Target, error) {
+	target := Target{Scope: scope, TTL: ttl}
+	requested := strings.Fields(scope)
+
+	var allowed []string
+	restricted := false
+	for _, r := range resources {
+		target.Audience = append(target.Audience, r.Identifier)
+		target.TTL = min(target.TTL, r.TTL(ttl))
+
+		if len(r.Scopes) > 0 {
+			restricted = true
+			allowed = append(allowed, r.Scopes...)
+		}
+	}
+
+	if !restricted {
+		allowed = registered
+	} else if len(requested) == 0 {
+		slices.Sort(allowed)
+		target.Scope = strings.Join(slices.Compact(allowed), " ")
+		return target, nil
+	}
+
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return target, ErrInvalidScope
+		}
+	}
+
+	return target, nil
+}
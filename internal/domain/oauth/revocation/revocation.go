@@ -0,0 +1,25 @@
+package revocation
+
+const (
+	// KindToken revokes the access token TokenID.
+	KindToken = "token"
+	// KindUser revokes the access tokens of UserID issued until CreatedAt.
+	KindUser = "user"
+	// KindKey announces KeyID as the key access tokens are signed with,
+	// the tokens signed with the previous one no longer validate.
+	KindKey = "key"
+)
+
+// Event is a revocation the resource servers validating access tokens
+// locally are told about. ID orders the events and is the cursor a watch
+// resumes after, events are kept until the tokens they revoke expire.
+type Event struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	TokenID   string `json:"tokenId"`
+	UserID    int64  `json:"userId"`
+	UserUUID  string `json:"userUuid"`
+	KeyID     string `json:"keyId"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
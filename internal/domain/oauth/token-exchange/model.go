@@ -0,0 +1,15 @@
+package token_exchange
+
+// TokenExchange is the audit record of an exchanged token. The actor is
+// only set for delegation, an impersonating client is recorded as ClientId.
+type TokenExchange struct {
+	ID            string   `json:"id"`
+	ClientId      string   `json:"clientId"`
+	SubjectUserId int64    `json:"subjectUserId"`
+	ActorUserId   *int64   `json:"actorUserId"`
+	ActorClientId *string  `json:"actorClientId"`
+	AccessTokenId string   `json:"accessTokenId"`
+	Audience      []string `json:"audience"`
+	Scopes        []string `json:"scopes"`
+	CreatedAt     int64    `json:"createdAt"`
+}
@@ -0,0 +1,10 @@
+package scim
+
+// Token is a bearer token a provisioning client calls the SCIM API with,
+// only the hash of the token is stored.
+type Token struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	TokenHash string `json:"-"`
+	CreatedAt int64  `json:"createdAt"`
+}
@@ -9,6 +9,10 @@ type User struct {
 	Password        string  `json:"password"`
 	RememberToken   *string `json:"rememberToken"`
 	IsActive        int     `json:"isActive"`
+	ExternalId      *string `json:"externalId"`
+	DisplayName     *string `json:"displayName"`
+	DeactivatedAt   *int64  `json:"deactivatedAt"`
+	Version         int64   `json:"version"`
 	CreatedAt       int64   `json:"createdAt"`
 	UpdatedAt       int64   `json:"updatedAt"`
 }
@@ -30,3 +34,9 @@ type CreateUserSignal struct {
 	Service   string `json:"service"`
 	CreatedAt int64  `json:"createdAt"`
 }
+
+// Active reports whether the user can sign in, deprovisioned users are
+// deactivated.
+func (u User) Active() bool {
+	return u.DeactivatedAt == nil
+}
@@ -0,0 +1,80 @@
+package clientauth
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator authenticates the OAuth clients calling the gRPC services
+// with the credentials of the messages.
+type Authenticator struct {
+	authenticator *clientauth.Authenticator
+}
+
+func New(clients clientauth.Provider, cfg *config.Config) (*Authenticator, error) {
+	authenticator := clientauth.New(clients, cfg.Issuer)
+	if cfg.GRPC.TLS.ClientCAFile != "" {
+		clientCAs, err := mtls.LoadCertPool(cfg.GRPC.TLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.TrustClientCAs(clientCAs)
+	}
+
+	return &Authenticator{authenticator: authenticator}, nil
+}
+
+// Authenticate authenticates the client with the credentials of the
+// message and the TLS client certificate. gRPC has no Authorization header
+// to tell the secret methods apart, so a secret is accepted for both.
+// Tokens issued over mutual TLS are bound to the certificate.
+func (a *Authenticator) Authenticate(
+	ctx context.Context,
+	ID, secret string,
+) (client.Client, *accessTokenDomain.Confirmation, error) {
+	var creds = clientauth.Credentials{
+		Method:       client.AuthMethodNone,
+		ClientID:     ID,
+		ClientSecret: secret,
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			creds.Certificates = info.State.PeerCertificates
+		}
+	}
+
+	if secret != "" {
+		creds.Method = client.AuthMethodClientSecretPost
+	}
+
+	c, err := a.authenticator.Authenticate(creds)
+	if errors.Is(err, clientauth.ErrMethodNotAllowed) && secret != "" {
+		creds.Method = client.AuthMethodClientSecretBasic
+		c, err = a.authenticator.Authenticate(creds)
+	}
+	if err != nil {
+		logging.L(ctx).Warn("client authentication failed",
+			logging.StringAttr("client_id", ID),
+			logging.ErrAttr(err),
+		)
+		return client.Client{}, nil, status.Error(codes.Unauthenticated, "client authentication failed")
+	}
+
+	if len(creds.Certificates) > 0 {
+		ctx = mtls.ContextWithThumbprint(ctx, mtls.Thumbprint(creds.Certificates[0]))
+	}
+
+	return c, token.ConfirmationFromContext(ctx), nil
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/internal/domain/user"
+	"app/internal/grpc-server/clientauth"
+	authService "app/internal/service/auth"
+	"app/internal/storage"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	gRPCAuth "app/pkg/grpc/sso/v1"
+	"context"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+type Auth interface {
+	Register(reg authService.Registration) (user.User, error)
+	Login(c client.Client, login, password, scope string, cnf *accessTokenDomain.Confirmation) (authService.Tokens, error)
+	RefreshToken(c client.Client, refreshToken string, cnf *accessTokenDomain.Confirmation) (authService.Tokens, error)
+	ValidateToken(accessToken string) (*token.UserClaim, accessTokenDomain.AccessToken, error)
+	Logout(accessToken, refreshToken string) error
+	GetUser(accessToken string) (user.User, error)
+}
+
+type RegisterRequest struct {
+	Name     string `validate:"required,ascii"`
+	Email    string `validate:"required,email"`
+	Password string `validate:"required,ascii,min=9"`
+}
+
+type LoginRequest struct {
+	Login    string `validate:"required,ascii"`
+	Password string `validate:"required,ascii"`
+	Scope    string `validate:"omitempty,ascii"`
+}
+
+type serverGRPC struct {
+	gRPCAuth.UnimplementedAuthServiceServer
+	auth          Auth
+	authenticator *clientauth.Authenticator
+}
+
+// Register registers the auth service, it fails when the client CAs
+// can't be loaded so the server never runs without it.
+func Register(ctx context.Context, gRPC grpc.ServiceRegistrar, storages *storage.Storage, cfg *config.Config) error {
+	authenticator, err := clientauth.New(storages.Client, cfg)
+	if err != nil {
+		logging.L(ctx).Error("failed to load client CAs", logging.ErrAttr(err))
+		return err
+	}
+
+	a := authService.New(
+		ctx,
+		storages.User,
+		storages.AccessToken,
+		storages.RefreshToken,
+		storages.AuthToken,
+		cfg.Issuer,
+		cfg.Token,
+	)
+	gRPCAuth.RegisterAuthServiceServer(gRPC, &serverGRPC{auth: a, authenticator: authenticator})
+
+	return nil
+}
+
+func (s *serverGRPC) Register(
+	ctx context.Context,
+	req *gRPCAuth.RegisterRequest,
+) (*gRPCAuth.RegisterResponse, error) {
+	const op = "grpc-server.handler.auth.Register"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	var reg = RegisterRequest{
+		Name:     req.GetUsername(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := validator.New().Struct(reg); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	u, err := s.auth.Register(authService.Registration(reg))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.RegisterResponse{UserId: u.ID, Uuid: u.UUID}, nil
+}
+
+func (s *serverGRPC) Login(
+	ctx context.Context,
+	req *gRPCAuth.LoginRequest,
+) (*gRPCAuth.LoginResponse, error) {
+	const op = "grpc-server.handler.auth.Login"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	var login = LoginRequest{Login: req.GetLogin(), Password: req.GetPassword(), Scope: req.GetScope()}
+	if err := validator.New().Struct(login); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	c, cnf, err := s.authenticator.Authenticate(ctx, req.GetClientId(), req.GetClientSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.Login(c, login.Login, login.Password, login.Scope, cnf)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.LoginResponse{Token: toToken(tokens)}, nil
+}
+
+func (s *serverGRPC) RefreshToken(
+	ctx context.Context,
+	req *gRPCAuth.RefreshTokenRequest,
+) (*gRPCAuth.RefreshTokenResponse, error) {
+	const op = "grpc-server.handler.auth.RefreshToken"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	c, cnf, err := s.authenticator.Authenticate(ctx, req.GetClientId(), req.GetClientSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.RefreshToken(c, req.GetRefreshToken(), cnf)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.RefreshTokenResponse{Token: toToken(tokens)}, nil
+}
+
+// ValidateToken reports an invalid token as inactive rather than as an
+// error, like the introspection endpoint.
+func (s *serverGRPC) ValidateToken(
+	ctx context.Context,
+	req *gRPCAuth.ValidateTokenRequest,
+) (*gRPCAuth.ValidateTokenResponse, error) {
+	const op = "grpc-server.handler.auth.ValidateToken"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetAccessToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access token is required")
+	}
+
+	claims, aT, err := s.auth.ValidateToken(req.GetAccessToken())
+	if errors.Is(err, authService.ErrInvalidToken) {
+		return &gRPCAuth.ValidateTokenResponse{Active: false}, nil
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.ValidateTokenResponse{
+		Active:     true,
+		UserUuid:   claims.UserUUID(),
+		Email:      claims.Email,
+		ClientId:   aT.ClientId,
+		Scope:      claims.Scope,
+		ExpireTime: timestamppb.New(time.Unix(aT.ExpiresAt, 0)),
+		Audience:   claims.Audience,
+	}, nil
+}
+
+func (s *serverGRPC) Logout(
+	ctx context.Context,
+	req *gRPCAuth.LogoutRequest,
+) (*gRPCAuth.LogoutResponse, error) {
+	const op = "grpc-server.handler.auth.Logout"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetAccessToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access token is required")
+	}
+
+	if err := s.auth.Logout(req.GetAccessToken(), req.GetRefreshToken()); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.LogoutResponse{}, nil
+}
+
+func (s *serverGRPC) GetUser(
+	ctx context.Context,
+	req *gRPCAuth.GetUserRequest,
+) (*gRPCAuth.GetUserResponse, error) {
+	const op = "grpc-server.handler.auth.GetUser"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetAccessToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access token is required")
+	}
+
+	u, err := s.auth.GetUser(req.GetAccessToken())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCAuth.GetUserResponse{User: &gRPCAuth.User{
+		Id:            u.ID,
+		Uuid:          u.UUID,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt != nil && *u.EmailVerifiedAt > 0,
+		CreateTime:    timestamppb.New(time.Unix(u.CreatedAt, 0)),
+		UpdateTime:    timestamppb.New(time.Unix(u.UpdatedAt, 0)),
+	}}, nil
+}
+
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, authService.ErrInvalidCredentials),
+		errors.Is(err, authService.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, authService.ErrUnauthorizedClient):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, authService.ErrInvalidRefreshToken),
+		errors.Is(err, authService.ErrRefreshTokenExpired),
+		errors.Is(err, authService.ErrInvalidScope):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, authService.ErrUserExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toToken(tokens authService.Tokens) *gRPCAuth.Token {
+	return &gRPCAuth.Token{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokens.TokenType,
+		RefreshToken: tokens.RefreshToken,
+		ExpireTime:   timestamppb.New(time.Unix(tokens.ExpiredAt, 0)),
+	}
+}
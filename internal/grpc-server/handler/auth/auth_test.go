@@ -0,0 +1,31 @@
+package auth_test
+
+import (
+	"app/internal/config"
+	"app/internal/grpc-server/handler/auth"
+	"app/internal/storage"
+	"context"
+	"google.golang.org/grpc"
+	"path/filepath"
+	"testing"
+)
+
+// registrar records the services registered on it.
+type registrar []string
+
+func (r *registrar) RegisterService(desc *grpc.ServiceDesc, _ any) {
+	*r = append(*r, desc.ServiceName)
+}
+
+func TestRegister_ClientCAs(t *testing.T) {
+	cfg := &config.Config{Issuer: "https://sso.test"}
+	cfg.GRPC.TLS.ClientCAFile = filepath.Join(t.TempDir(), "missing-ca.pem")
+
+	var services registrar
+	if err := auth.Register(context.Background(), &services, &storage.Storage{}, cfg); err == nil {
+		t.Fatal("Register succeeded without the client CAs")
+	}
+	if len(services) != 0 {
+		t.Fatalf("services registered: %v", services)
+	}
+}
@@ -1,62 +1,386 @@
 package client
 
 import (
+	"app/internal/config"
 	"app/internal/domain/client"
-	clientService "app/internal/grpc-server/service/client"
-	clientStorage "app/internal/storage/pgsql/client"
+	clientService "app/internal/service/client"
+	"app/internal/storage"
+	"app/pkg/common/core/redirecturi"
 	"app/pkg/common/logging"
-	gRPCClient "app/pkg/grpc"
+	gRPCClient "app/pkg/grpc/sso/v1"
 	"context"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"errors"
+	"github.com/google/uuid"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
 )
 
 type Client interface {
 	GetClientByName(name string) (client.Client, error)
+	ListClients(page, limit int) ([]client.Client, int64, error)
+	CreateClient(create clientService.Create) (client.Client, string, error)
+	UpdateClient(ID string, update clientService.Update) (client.Client, error)
+	SetRevoked(ID string, revoked bool) (client.Client, error)
+	DeleteClient(ID string) error
+	RotateSecret(ID string) (client.Client, string, error)
+	VerifySecret(ID, secret string) (bool, error)
 }
 
 type serverGRPC struct {
-	//sso.UnimplementedAuthServer
+	gRPCClient.UnimplementedClientServiceServer
 	client Client
 }
 
-func Register(ctx context.Context, gRPC *grpc.Server, storage *pgxpool.Pool) {
-	storageClient, err := clientStorage.New(ctx, storage)
+func Register(ctx context.Context, gRPC grpc.ServiceRegistrar, storages *storage.Storage, cfg *config.Config) {
+	c := clientService.New(ctx, storages.Client, cfg)
+	gRPCClient.RegisterClientServiceServer(gRPC, &serverGRPC{client: c})
+}
+
+func (s *serverGRPC) GetClient(
+	ctx context.Context,
+	req *gRPCClient.GetClientRequest,
+) (*gRPCClient.GetClientResponse, error) {
+	const op = "grpc-server.handler.client.GetClient"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid app name")
+	}
+
+	c, err := s.client.GetClientByName(req.GetName())
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage client", err)
-		return
+		return nil, statusError(err)
 	}
-	c := clientService.New(ctx, storageClient)
-	gRPCClient.RegisterClientServiceServer(gRPC, &serverGRPC{client: c})
+
+	return &gRPCClient.GetClientResponse{Client: toOAuthClient(c)}, nil
 }
 
-func (s *serverGRPC) GetClientSecret(
+func (s *serverGRPC) ListClients(
 	ctx context.Context,
-	req *gRPCClient.ClientRequest,
-) (*gRPCClient.ClientResponse, error) {
-	const op = "todo"
-	logging.L(ctx).Info("op", op)
+	req *gRPCClient.ListClientsRequest,
+) (*gRPCClient.ListClientsResponse, error) {
+	const op = "grpc-server.handler.client.ListClients"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	page := int(req.GetPage())
+	if page == 0 {
+		page = 1
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultListLimit
+	}
 
-	if err := validationRequestClient(req); err != nil {
-		return &gRPCClient.ClientResponse{}, err
+	if page < 0 || limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid pagination parameters")
 	}
 
-	clientData, err := s.client.GetClientByName(req.Name)
+	clients, total, err := s.client.ListClients(page, min(limit, maxListLimit))
 	if err != nil {
-		return &gRPCClient.ClientResponse{}, err
+		return nil, statusError(err)
 	}
-	return &gRPCClient.ClientResponse{
-		Id:     clientData.ID,
-		Name:   clientData.Name,
-		Secret: clientData.Secret,
-	}, nil
+
+	var res = &gRPCClient.ListClientsResponse{
+		Clients: make([]*gRPCClient.OAuthClient, 0, len(clients)),
+		Total:   total,
+		Page:    int32(page),
+		Limit:   int32(min(limit, maxListLimit)),
+	}
+	for _, c := range clients {
+		res.Clients = append(res.Clients, toOAuthClient(c))
+	}
+
+	return res, nil
 }
 
-func validationRequestClient(req *gRPCClient.ClientRequest) error {
+func (s *serverGRPC) CreateClient(
+	ctx context.Context,
+	req *gRPCClient.CreateClientRequest,
+) (*gRPCClient.CreateClientResponse, error) {
+	const op = "grpc-server.handler.client.CreateClient"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
 	if req.GetName() == "" {
-		return status.Error(codes.InvalidArgument, "invalid app name")
+		return nil, status.Error(codes.InvalidArgument, "invalid app name")
+	}
+
+	if len(req.GetRedirectUris()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid redirect")
+	}
+
+	c, secret, err := s.client.CreateClient(clientService.Create{
+		Name:                               req.GetName(),
+		RedirectURIs:                       req.GetRedirectUris(),
+		TokenEndpointAuthMethod:            req.GetTokenEndpointAuthMethod(),
+		JWKS:                               req.Jwks,
+		GrantTypes:                         req.GetGrantTypes(),
+		Scopes:                             req.GetScopes(),
+		TokenExchange:                      fromTokenExchange(req.GetTokenExchange()),
+		RequirePushedAuthorizationRequests: req.GetRequirePushedAuthorizationRequests(),
+		TLSClientAuth:                      fromTLSClientAuth(req.GetTlsClientAuth()),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCClient.CreateClientResponse{Client: toOAuthClient(c), Secret: secret}, nil
+}
+
+func (s *serverGRPC) UpdateClient(
+	ctx context.Context,
+	req *gRPCClient.UpdateClientRequest,
+) (*gRPCClient.UpdateClientResponse, error) {
+	const op = "grpc-server.handler.client.UpdateClient"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := validationClientID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid app name")
+	}
+
+	if req.UserId != nil && req.GetUserId() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid user id")
+	}
+
+	var update = clientService.Update{
+		Name:                               req.Name,
+		RedirectURIs:                       req.GetRedirectUris(),
+		UserId:                             req.UserId,
+		PersonalAccessClient:               req.PersonalAccessClient,
+		PasswordClient:                     req.PasswordClient,
+		TokenEndpointAuthMethod:            req.TokenEndpointAuthMethod,
+		JWKS:                               req.Jwks,
+		GrantTypes:                         req.GetGrantTypes(),
+		TokenExchange:                      fromTokenExchange(req.GetTokenExchange()),
+		RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+		TLSClientAuth:                      fromTLSClientAuth(req.GetTlsClientAuth()),
+	}
+	// a set scopes message replaces the scopes even when it is empty
+	if req.Scopes != nil {
+		update.Scopes = append([]string{}, req.GetScopes().GetScopes()...)
+	}
+
+	c, err := s.client.UpdateClient(req.GetId(), update)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCClient.UpdateClientResponse{Client: toOAuthClient(c)}, nil
+}
+
+func (s *serverGRPC) RevokeClient(
+	ctx context.Context,
+	req *gRPCClient.RevokeClientRequest,
+) (*gRPCClient.RevokeClientResponse, error) {
+	c, err := s.setRevoked(ctx, "grpc-server.handler.client.RevokeClient", req.GetId(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gRPCClient.RevokeClientResponse{Client: c}, nil
+}
+
+func (s *serverGRPC) RestoreClient(
+	ctx context.Context,
+	req *gRPCClient.RestoreClientRequest,
+) (*gRPCClient.RestoreClientResponse, error) {
+	c, err := s.setRevoked(ctx, "grpc-server.handler.client.RestoreClient", req.GetId(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gRPCClient.RestoreClientResponse{Client: c}, nil
+}
+
+func (s *serverGRPC) setRevoked(
+	ctx context.Context,
+	op string,
+	ID string,
+	revoked bool,
+) (*gRPCClient.OAuthClient, error) {
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := validationClientID(ID); err != nil {
+		return nil, err
+	}
+
+	c, err := s.client.SetRevoked(ID, revoked)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toOAuthClient(c), nil
+}
+
+func (s *serverGRPC) DeleteClient(
+	ctx context.Context,
+	req *gRPCClient.DeleteClientRequest,
+) (*gRPCClient.DeleteClientResponse, error) {
+	const op = "grpc-server.handler.client.DeleteClient"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := validationClientID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.client.DeleteClient(req.GetId()); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCClient.DeleteClientResponse{}, nil
+}
+
+func (s *serverGRPC) RotateClientSecret(
+	ctx context.Context,
+	req *gRPCClient.RotateClientSecretRequest,
+) (*gRPCClient.RotateClientSecretResponse, error) {
+	const op = "grpc-server.handler.client.RotateClientSecret"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := validationClientID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	c, secret, err := s.client.RotateSecret(req.GetId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCClient.RotateClientSecretResponse{
+		Id:                       c.ID,
+		Secret:                   secret,
+		PreviousSecretExpireTime: timestamppb.New(time.Unix(c.PreviousSecretExpiresAt, 0)),
+	}, nil
+}
+
+func (s *serverGRPC) VerifyClientSecret(
+	ctx context.Context,
+	req *gRPCClient.VerifyClientSecretRequest,
+) (*gRPCClient.VerifyClientSecretResponse, error) {
+	const op = "grpc-server.handler.client.VerifyClientSecret"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := validationClientID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	valid, err := s.client.VerifySecret(req.GetId(), req.GetSecret())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &gRPCClient.VerifyClientSecretResponse{Valid: valid}, nil
+}
+
+func validationClientID(ID string) error {
+	if err := uuid.Validate(ID); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid client id")
 	}
 	return nil
 }
+
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, clientService.ErrClientNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, clientService.ErrClientExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, client.ErrInvalidAuthMethod),
+		errors.Is(err, client.ErrJWKSRequired),
+		errors.Is(err, client.ErrInvalidJWKS),
+		errors.Is(err, client.ErrNoRedirectURIs),
+		errors.Is(err, client.ErrInvalidGrantType),
+		errors.Is(err, client.ErrInvalidScopeToken),
+		errors.Is(err, client.ErrTLSClientAuth),
+		errors.Is(err, client.ErrCertificateJWKS),
+		errors.Is(err, redirecturi.ErrInvalid),
+		errors.Is(err, redirecturi.ErrFragment),
+		errors.Is(err, redirecturi.ErrInsecure):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toOAuthClient(c client.Client) *gRPCClient.OAuthClient {
+	return &gRPCClient.OAuthClient{
+		Id:                                 c.ID,
+		UserId:                             c.UserId,
+		Name:                               c.Name,
+		Provider:                           c.Provider,
+		RedirectUris:                       c.RedirectURIs,
+		PersonalAccessClient:               c.PersonalAccessClient,
+		PasswordClient:                     c.PasswordClient,
+		Revoked:                            c.Revoked,
+		TokenEndpointAuthMethod:            c.TokenEndpointAuthMethod,
+		CreateTime:                         timestamppb.New(time.Unix(c.CreatedAt, 0)),
+		UpdateTime:                         timestamppb.New(time.Unix(c.UpdatedAt, 0)),
+		GrantTypes:                         c.GrantTypes,
+		Scopes:                             c.Scopes,
+		TokenExchange:                      toTokenExchange(c.TokenExchange),
+		RequirePushedAuthorizationRequests: c.RequirePushedAuthorizationRequests,
+		TlsClientAuth:                      toTLSClientAuth(c.TLSClientAuth),
+	}
+}
+
+func fromTokenExchange(t *gRPCClient.TokenExchange) *client.TokenExchange {
+	if t == nil {
+		return nil
+	}
+	return &client.TokenExchange{
+		Audiences:     t.GetAudiences(),
+		Scopes:        t.GetScopes(),
+		Impersonation: t.GetImpersonation(),
+		Delegation:    t.GetDelegation(),
+	}
+}
+
+func toTokenExchange(t *client.TokenExchange) *gRPCClient.TokenExchange {
+	if t == nil {
+		return nil
+	}
+	return &gRPCClient.TokenExchange{
+		Audiences:     t.Audiences,
+		Scopes:        t.Scopes,
+		Impersonation: t.Impersonation,
+		Delegation:    t.Delegation,
+	}
+}
+
+func fromTLSClientAuth(t *gRPCClient.TLSClientAuth) *client.TLSClientAuth {
+	if t == nil {
+		return nil
+	}
+	return &client.TLSClientAuth{
+		SubjectDN: t.GetSubjectDn(),
+		SANDNS:    t.GetSanDns(),
+		SANURI:    t.GetSanUri(),
+		SANIP:     t.GetSanIp(),
+		SANEmail:  t.GetSanEmail(),
+	}
+}
+
+func toTLSClientAuth(t *client.TLSClientAuth) *gRPCClient.TLSClientAuth {
+	if t == nil {
+		return nil
+	}
+	return &gRPCClient.TLSClientAuth{
+		SubjectDn: t.SubjectDN,
+		SanDns:    t.SANDNS,
+		SanUri:    t.SANURI,
+		SanIp:     t.SANIP,
+		SanEmail:  t.SANEmail,
+	}
+}
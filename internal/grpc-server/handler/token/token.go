@@ -0,0 +1,271 @@
+package token
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/internal/grpc-server/clientauth"
+	introspectService "app/internal/service/introspect"
+	"app/internal/storage"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	gRPCToken "app/pkg/grpc/sso/v1"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strconv"
+	"time"
+)
+
+// watchBatchSize is how many events are read at once, a watch behind by
+// more reads the next batch without waiting for the poll interval.
+const watchBatchSize = 100
+
+type Introspect interface {
+	Introspect(c client.Client, tokenStr, hint string) *introspectService.Introspection
+}
+
+type Revocation interface {
+	GetLastEventID() (int64, error)
+	GetEventsAfter(cursor int64, limit int) ([]revocationDomain.Event, error)
+}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, ID, secret string) (client.Client, *accessTokenDomain.Confirmation, error)
+}
+
+type serverGRPC struct {
+	gRPCToken.UnimplementedTokenServiceServer
+	introspect    Introspect
+	revocation    Revocation
+	authenticator Authenticator
+	pollInterval  time.Duration
+	done          <-chan struct{}
+}
+
+// New returns the TokenService server, the watches end when done is
+// closed so they don't hold the graceful stop of the server.
+func New(
+	introspect Introspect,
+	revocation Revocation,
+	authenticator Authenticator,
+	pollInterval time.Duration,
+	done <-chan struct{},
+) gRPCToken.TokenServiceServer {
+	return &serverGRPC{
+		introspect:    introspect,
+		revocation:    revocation,
+		authenticator: authenticator,
+		pollInterval:  pollInterval,
+		done:          done,
+	}
+}
+
+// Register records the key the access tokens are signed with, the
+// watches see a changed secret as a key rotation. It fails when the
+// client CAs can't be loaded so the server never runs without the service.
+func Register(
+	ctx context.Context,
+	gRPC grpc.ServiceRegistrar,
+	storages *storage.Storage,
+	cfg *config.Config,
+	done <-chan struct{},
+) error {
+	authenticator, err := clientauth.New(storages.Client, cfg)
+	if err != nil {
+		logging.L(ctx).Error("failed to load client CAs", logging.ErrAttr(err))
+		return err
+	}
+
+	keyID := token.KeyID(cfg.Token.Secret)
+	rotated, err := storages.Revocation.CreateKeyEvent(keyID, time.Now().Unix())
+	if err != nil {
+		logging.L(ctx).Error("failed to record the access token key", logging.ErrAttr(err))
+	}
+	if rotated {
+		logging.L(ctx).Info("access token key rotated", logging.StringAttr("key_id", keyID))
+	}
+
+	introspect := introspectService.New(
+		ctx,
+		storages.AccessToken,
+		storages.RefreshToken,
+		storages.Resource,
+		cfg.Token,
+	)
+	gRPCToken.RegisterTokenServiceServer(gRPC, New(
+		introspect,
+		storages.Revocation,
+		authenticator,
+		cfg.GRPC.RevocationPollInterval,
+		done,
+	))
+
+	return nil
+}
+
+func (s *serverGRPC) Introspect(
+	ctx context.Context,
+	req *gRPCToken.IntrospectRequest,
+) (*gRPCToken.IntrospectResponse, error) {
+	const op = "grpc-server.handler.token.Introspect"
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	c, _, err := s.authenticator.Authenticate(ctx, req.GetClientId(), req.GetClientSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	return toIntrospectResponse(s.introspect.Introspect(c, req.GetToken(), req.GetTokenTypeHint())), nil
+}
+
+// WatchRevocations polls the events after the cursor, the stream only
+// ends with an error: the client went away, the server is stopping or
+// the events can't be read, the client reconnects with its last cursor.
+func (s *serverGRPC) WatchRevocations(
+	req *gRPCToken.WatchRevocationsRequest,
+	stream grpc.ServerStreamingServer[gRPCToken.WatchRevocationsResponse],
+) error {
+	const op = "grpc-server.handler.token.WatchRevocations"
+	ctx := stream.Context()
+	logging.L(ctx).Info("op", logging.StringAttr("op", op))
+
+	c, _, err := s.authenticator.Authenticate(ctx, req.GetClientId(), req.GetClientSecret())
+	if err != nil {
+		return err
+	}
+
+	// the events concern the tokens of all the clients, a public client
+	// proves nothing with its ID
+	if c.IsPublic() {
+		return status.Error(codes.PermissionDenied, "only confidential clients may watch the revocations")
+	}
+
+	cursor, err := s.cursor(req.GetCursor())
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		events, err := s.revocation.GetEventsAfter(cursor, watchBatchSize)
+		if err != nil {
+			logging.L(ctx).Error("failed get revocation events", logging.ErrAttr(err))
+			return status.Error(codes.Unavailable, "failed to read the revocations")
+		}
+
+		for _, e := range events {
+			if err := stream.Send(&gRPCToken.WatchRevocationsResponse{Event: toRevocationEvent(e)}); err != nil {
+				return err
+			}
+			cursor = e.ID
+		}
+
+		if len(events) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is stopping")
+		case <-ticker.C:
+		}
+	}
+}
+
+// cursor parses the cursor of the request, the watch starts at the last
+// event without one.
+func (s *serverGRPC) cursor(cursor string) (int64, error) {
+	if cursor == "" {
+		ID, err := s.revocation.GetLastEventID()
+		if err != nil {
+			return 0, status.Error(codes.Unavailable, "failed to read the revocations")
+		}
+		return ID, nil
+	}
+
+	ID, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || ID < 0 {
+		return 0, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+
+	return ID, nil
+}
+
+func toRevocationEvent(e revocationDomain.Event) *gRPCToken.RevocationEvent {
+	var event = &gRPCToken.RevocationEvent{
+		Cursor:     strconv.FormatInt(e.ID, 10),
+		CreateTime: timestamppb.New(time.Unix(e.CreatedAt, 0)),
+	}
+
+	switch e.Kind {
+	case revocationDomain.KindToken:
+		event.Revocation = &gRPCToken.RevocationEvent_Token{Token: &gRPCToken.TokenRevocation{
+			TokenId:    e.TokenID,
+			ExpireTime: timestamppb.New(time.Unix(e.ExpiresAt, 0)),
+		}}
+	case revocationDomain.KindUser:
+		event.Revocation = &gRPCToken.RevocationEvent_User{User: &gRPCToken.UserRevocation{
+			Subject: e.UserUUID,
+		}}
+	case revocationDomain.KindKey:
+		event.Revocation = &gRPCToken.RevocationEvent_Key{Key: &gRPCToken.KeyRotation{
+			KeyId: e.KeyID,
+		}}
+	}
+
+	return event
+}
+
+func toIntrospectResponse(res *introspectService.Introspection) *gRPCToken.IntrospectResponse {
+	if !res.Active {
+		return &gRPCToken.IntrospectResponse{Active: false}
+	}
+
+	var response = &gRPCToken.IntrospectResponse{
+		Active:    true,
+		Scope:     res.Scope,
+		Audience:  res.Aud,
+		ClientId:  res.ClientID,
+		Username:  res.Username,
+		TokenType: res.TokenType,
+		Issuer:    res.Iss,
+		Subject:   res.Sub,
+		TokenId:   res.Jti,
+		Actor:     toActor(res.Act),
+	}
+	if res.Exp > 0 {
+		response.ExpireTime = timestamppb.New(time.Unix(res.Exp, 0))
+	}
+	if res.Iat > 0 {
+		response.IssueTime = timestamppb.New(time.Unix(res.Iat, 0))
+	}
+	if res.Cnf != nil {
+		response.Confirmation = &gRPCToken.Confirmation{Jkt: res.Cnf.JKT, X5TS256: res.Cnf.X5TS256}
+	}
+
+	return response
+}
+
+func toActor(act *accessTokenDomain.Actor) *gRPCToken.Actor {
+	if act == nil {
+		return nil
+	}
+
+	return &gRPCToken.Actor{
+		Subject:  act.Sub,
+		ClientId: act.ClientID,
+		Actor:    toActor(act.Act),
+	}
+}
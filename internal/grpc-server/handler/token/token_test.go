@@ -0,0 +1,225 @@
+package token_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/internal/grpc-server/handler/token"
+	introspectService "app/internal/service/introspect"
+	"app/internal/storage"
+	gRPCToken "app/pkg/grpc/sso/v1"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type introspect struct{}
+
+func (introspect) Introspect(client.Client, string, string) *introspectService.Introspection {
+	return &introspectService.Introspection{Active: false}
+}
+
+// authenticator accepts the public client and the ones with the secret.
+type authenticator struct{}
+
+func (authenticator) Authenticate(
+	_ context.Context,
+	ID, secret string,
+) (client.Client, *accessTokenDomain.Confirmation, error) {
+	if ID == "public-app" && secret == "" {
+		return client.Client{ID: ID, TokenEndpointAuthMethod: client.AuthMethodNone}, nil, nil
+	}
+	if secret != "secret" {
+		return client.Client{}, nil, status.Error(codes.Unauthenticated, "client authentication failed")
+	}
+	return client.Client{ID: ID}, nil, nil
+}
+
+type revocations struct {
+	mu     sync.Mutex
+	events []revocationDomain.Event
+	// started is closed once a watch without a cursor has started.
+	started chan struct{}
+}
+
+func (r *revocations) add(e revocationDomain.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.ID = int64(len(r.events) + 1)
+	r.events = append(r.events, e)
+}
+
+func (r *revocations) GetLastEventID() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started != nil {
+		close(r.started)
+	}
+	return int64(len(r.events)), nil
+}
+
+func (r *revocations) GetEventsAfter(cursor int64, limit int) ([]revocationDomain.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []revocationDomain.Event
+	for _, e := range r.events {
+		if e.ID > cursor && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// stream hands the sent events over to the test.
+type stream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *gRPCToken.RevocationEvent
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) Send(res *gRPCToken.WatchRevocationsResponse) error {
+	s.events <- res.GetEvent()
+	return nil
+}
+
+func watch(t *testing.T, store *revocations, req *gRPCToken.WatchRevocationsRequest) (*stream, chan struct{}, chan error) {
+	t.Helper()
+
+	done := make(chan struct{})
+	s := &stream{ctx: context.Background(), events: make(chan *gRPCToken.RevocationEvent, 16)}
+	server := token.New(introspect{}, store, authenticator{}, time.Millisecond, done)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.WatchRevocations(req, s)
+	}()
+
+	return s, done, errs
+}
+
+func next(t *testing.T, s *stream) *gRPCToken.RevocationEvent {
+	t.Helper()
+
+	select {
+	case e := <-s.events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestWatchRevocations_ResumesAfterCursor(t *testing.T) {
+	store := &revocations{}
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindToken, TokenID: "first"})
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindToken, TokenID: "second"})
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindUser, UserUUID: "user"})
+
+	s, done, errs := watch(t, store, &gRPCToken.WatchRevocationsRequest{
+		Cursor:       "1",
+		ClientId:     "resource-server",
+		ClientSecret: "secret",
+	})
+
+	if e := next(t, s); e.GetToken().GetTokenId() != "second" || e.GetCursor() != "2" {
+		t.Fatalf("event = %v, want the second token", e)
+	}
+	if e := next(t, s); e.GetUser().GetSubject() != "user" || e.GetCursor() != "3" {
+		t.Fatalf("event = %v, want the user", e)
+	}
+
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindKey, KeyID: "key"})
+	if e := next(t, s); e.GetKey().GetKeyId() != "key" {
+		t.Fatalf("event = %v, want the key rotation", e)
+	}
+
+	close(done)
+	if err := <-errs; status.Code(err) != codes.Unavailable {
+		t.Fatalf("code = %v, want %v", status.Code(err), codes.Unavailable)
+	}
+}
+
+func TestWatchRevocations_StartsAtLastEventWithoutCursor(t *testing.T) {
+	store := &revocations{started: make(chan struct{})}
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindToken, TokenID: "old"})
+
+	s, done, errs := watch(t, store, &gRPCToken.WatchRevocationsRequest{
+		ClientId:     "resource-server",
+		ClientSecret: "secret",
+	})
+	defer func() {
+		close(done)
+		<-errs
+	}()
+
+	<-store.started
+	store.add(revocationDomain.Event{Kind: revocationDomain.KindToken, TokenID: "new"})
+	if e := next(t, s); e.GetToken().GetTokenId() != "new" {
+		t.Fatalf("event = %v, want the new token", e)
+	}
+}
+
+func TestWatchRevocations_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *gRPCToken.WatchRevocationsRequest
+		wantCode codes.Code
+	}{
+		{
+			name:     "unauthenticated client",
+			req:      &gRPCToken.WatchRevocationsRequest{ClientId: "resource-server"},
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:     "public client",
+			req:      &gRPCToken.WatchRevocationsRequest{ClientId: "public-app"},
+			wantCode: codes.PermissionDenied,
+		},
+		{
+			name:     "invalid cursor",
+			req:      &gRPCToken.WatchRevocationsRequest{Cursor: "x", ClientId: "resource-server", ClientSecret: "secret"},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, done, errs := watch(t, &revocations{}, tt.req)
+			defer close(done)
+
+			if err := <-errs; status.Code(err) != tt.wantCode {
+				t.Fatalf("code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+		})
+	}
+}
+
+// registrar records the services registered on it.
+type registrar []string
+
+func (r *registrar) RegisterService(desc *grpc.ServiceDesc, _ any) {
+	*r = append(*r, desc.ServiceName)
+}
+
+func TestRegister_ClientCAs(t *testing.T) {
+	cfg := &config.Config{Issuer: "https://sso.test"}
+	cfg.GRPC.TLS.ClientCAFile = filepath.Join(t.TempDir(), "missing-ca.pem")
+
+	var services registrar
+	if err := token.Register(context.Background(), &services, &storage.Storage{}, cfg, nil); err == nil {
+		t.Fatal("Register succeeded without the client CAs")
+	}
+	if len(services) != 0 {
+		t.Fatalf("services registered: %v", services)
+	}
+}
@@ -0,0 +1,165 @@
+package interceptor
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"slices"
+	"strings"
+)
+
+type TokenValidator interface {
+	ValidateToken(accessToken string) (*token.UserClaim, accessTokenDomain.AccessToken, error)
+}
+
+// Auth requires an access token holding the scopes listed for a method,
+// keyed by its full method name. The methods of the public services
+// authenticate their callers themselves, every other method needs the
+// admin scope. A method that needs the admin scope is only open to the
+// administrators, the scope alone is not enough. The token is sent as a
+// bearer token in the authorization metadata, DPoP-bound tokens can't be
+// presented over gRPC.
+type Auth struct {
+	validator TokenValidator
+	scopes    map[string][]string
+	admin     config.Admin
+	public    []string
+}
+
+// NewAuth denies the unlisted methods to all but the administrators,
+// public holds the full names of the public services.
+func NewAuth(validator TokenValidator, scopes map[string][]string, admin config.Admin, public ...string) *Auth {
+	return &Auth{
+		validator: validator,
+		scopes:    scopes,
+		admin:     admin,
+		public:    public,
+	}
+}
+
+func (a *Auth) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Auth) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, withContext(ss, ctx))
+	}
+}
+
+// authenticate stores the access token of a protected method in the
+// context.
+func (a *Auth) authenticate(ctx context.Context, method string) (context.Context, error) {
+	required, ok := a.scopes[method]
+	if !ok {
+		if a.isPublic(method) {
+			return ctx, nil
+		}
+		required = []string{a.admin.Scope}
+	}
+
+	tokenStr, ok := bearerToken(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "access token is required")
+	}
+
+	claims, aT, err := a.validator.ValidateToken(tokenStr)
+	if err != nil {
+		logging.L(ctx).Warn("grpc authentication failed", logging.ErrAttr(err))
+		return nil, status.Error(codes.Unauthenticated, "the access token is invalid")
+	}
+
+	if claims.Cnf != nil && claims.Cnf.JKT != "" {
+		return nil, status.Error(codes.Unauthenticated, "DPoP-bound access tokens are not accepted")
+	}
+
+	// certificate bound tokens are only accepted over mutual TLS with the
+	// bound certificate
+	if claims.Cnf != nil && claims.Cnf.X5TS256 != "" && peerThumbprint(ctx) != claims.Cnf.X5TS256 {
+		return nil, status.Error(codes.Unauthenticated, "the access token is bound to another client certificate")
+	}
+
+	granted := strings.Fields(claims.Scope)
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "the access token lacks the %s scope", scope)
+		}
+	}
+
+	if slices.Contains(required, a.admin.Scope) && !a.admin.Allows(claims.UserUUID(), claims.Scope) {
+		logging.L(ctx).Warn("grpc authentication failed, the user is not an administrator",
+			logging.StringAttr("user", claims.UserUUID()),
+		)
+		return nil, status.Error(codes.PermissionDenied, "the user is not an administrator")
+	}
+
+	return token.ContextWithAccessToken(ctx, aT), nil
+}
+
+// isPublic reports whether the method belongs to a public service.
+func (a *Auth) isPublic(method string) bool {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return slices.Contains(a.public, service)
+}
+
+func bearerToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+
+	values := md.Get("authorization")
+	if len(values) != 1 {
+		return "", false
+	}
+
+	scheme, tokenStr, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || tokenStr == "" {
+		return "", false
+	}
+
+	return tokenStr, true
+}
+
+func peerThumbprint(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return ""
+	}
+
+	return mtls.Thumbprint(info.State.PeerCertificates[0])
+}
@@ -0,0 +1,21 @@
+package interceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+)
+
+// serverStream replaces the context of a stream, the stream interceptors
+// pass the values they add to the handlers with it.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func withContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
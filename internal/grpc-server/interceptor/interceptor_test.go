@@ -0,0 +1,133 @@
+package interceptor_test
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/internal/grpc-server/interceptor"
+	"app/pkg/common/core/token"
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const (
+	protected = "/sso.v1.ClientService/CreateClient"
+	admined   = "/sso.v1.ClientService/DeleteClient"
+)
+
+type validator map[string]*token.UserClaim
+
+func (v validator) ValidateToken(accessToken string) (*token.UserClaim, accessTokenDomain.AccessToken, error) {
+	claims, ok := v[accessToken]
+	if !ok {
+		return nil, accessTokenDomain.AccessToken{}, errors.New("invalid token")
+	}
+	return claims, accessTokenDomain.AccessToken{ID: accessToken, UserId: 1}, nil
+}
+
+func withToken(tokenStr string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tokenStr))
+}
+
+func TestAuth_Unary(t *testing.T) {
+	admin := func(subject, scope string) *token.UserClaim {
+		return &token.UserClaim{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}, Scope: scope}
+	}
+
+	auth := interceptor.NewAuth(validator{
+		"writer":      {Scope: "clients:read clients:write"},
+		"reader":      {Scope: "clients:read"},
+		"admin":       admin("admin-uuid", "admin"),
+		"user":        admin("user-uuid", "admin"),
+		"admin-write": admin("user-uuid", "admin clients:write"),
+		"dpop":        {Scope: "clients:write", Cnf: &accessTokenDomain.Confirmation{JKT: "thumbprint"}},
+	}, map[string][]string{
+		protected: {"clients:write"},
+		admined:   {"admin", "clients:write"},
+	}, config.Admin{Scope: "admin", Users: []string{"admin-uuid"}}, "sso.v1.AuthService")
+
+	const unlisted = "/sso.v1.ClientService/RotateClientSecret"
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		public   bool
+		wantCode codes.Code
+	}{
+		{name: "public method", ctx: context.Background(), method: "/sso.v1.AuthService/Login", public: true},
+		{name: "token with the scope", ctx: withToken("writer"), method: protected},
+		{name: "missing token", ctx: context.Background(), method: protected, wantCode: codes.Unauthenticated},
+		{name: "unknown token", ctx: withToken("unknown"), method: protected, wantCode: codes.Unauthenticated},
+		{name: "token without the scope", ctx: withToken("reader"), method: protected, wantCode: codes.PermissionDenied},
+		{name: "DPoP-bound token", ctx: withToken("dpop"), method: protected, wantCode: codes.Unauthenticated},
+		{name: "unlisted method without token", ctx: context.Background(), method: unlisted, wantCode: codes.Unauthenticated},
+		{name: "unlisted method without the default scope", ctx: withToken("writer"), method: unlisted, wantCode: codes.PermissionDenied},
+		{name: "unlisted method with the default scope", ctx: withToken("admin"), method: unlisted},
+		{name: "unlisted method with the default scope of a user", ctx: withToken("user"), method: unlisted, wantCode: codes.PermissionDenied},
+		{name: "method listed with the admin scope for a user", ctx: withToken("admin-write"), method: admined, wantCode: codes.PermissionDenied},
+		{name: "method of a service named like a public one", ctx: context.Background(), method: "/sso.v1.AuthServiceAdmin/Login", wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called bool
+			handler := func(ctx context.Context, req any) (any, error) {
+				called = true
+				_, ok := token.AccessTokenFromContext(ctx)
+				if ok == tt.public {
+					t.Errorf("access token in context = %v", ok)
+				}
+				return nil, nil
+			}
+
+			_, err := auth.Unary()(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+			if called != (tt.wantCode == codes.OK) {
+				t.Fatalf("handler called = %v", called)
+			}
+		})
+	}
+}
+
+func TestUnaryRecovery(t *testing.T) {
+	handler := func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	}
+
+	_, err := interceptor.UnaryRecovery()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: protected}, handler)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("code = %v, want %v", status.Code(err), codes.Internal)
+	}
+}
+
+func TestUnaryRequestID(t *testing.T) {
+	var got string
+	handler := func(ctx context.Context, req any) (any, error) {
+		got = interceptor.RequestIDFromContext(ctx)
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: protected}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(interceptor.RequestIDKey, "req-1"))
+	if _, err := interceptor.UnaryRequestID()(ctx, nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if got != "req-1" {
+		t.Fatalf("request ID = %q, want the one of the metadata", got)
+	}
+
+	if _, err := interceptor.UnaryRequestID()(context.Background(), nil, info, handler); err != nil {
+		t.Fatal(err)
+	}
+	if got == "" || got == "req-1" {
+		t.Fatalf("request ID = %q, want a generated one", got)
+	}
+}
@@ -0,0 +1,59 @@
+package interceptor
+
+import (
+	"app/pkg/common/logging"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// UnaryLogging logs every call with its status code and duration.
+func UnaryLogging() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamLogging is UnaryLogging for streams.
+func StreamLogging() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+
+	logger := logging.L(ctx).With(
+		logging.StringAttr("method", method),
+		logging.StringAttr("code", code.String()),
+		logging.Int64Attr("duration_ms", time.Since(start).Milliseconds()),
+	)
+
+	switch code {
+	case codes.OK:
+		logger.Info("grpc call")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		logger.Error("grpc call", logging.ErrAttr(err))
+	default:
+		logger.Warn("grpc call", logging.ErrAttr(err))
+	}
+}
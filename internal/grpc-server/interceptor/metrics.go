@@ -0,0 +1,40 @@
+package interceptor
+
+import (
+	"app/internal/metrics"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// UnaryMetrics observes the duration of every call by method and code.
+func UnaryMetrics() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		metrics.ObserveGRPCRequest(time.Since(start), info.FullMethod, status.Code(err).String())
+		return res, err
+	}
+}
+
+// StreamMetrics is UnaryMetrics for streams, a stream is observed once it
+// ends.
+func StreamMetrics() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		metrics.ObserveGRPCRequest(time.Since(start), info.FullMethod, status.Code(err).String())
+		return err
+	}
+}
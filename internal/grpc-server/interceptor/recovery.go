@@ -0,0 +1,57 @@
+package interceptor
+
+import (
+	"app/pkg/common/logging"
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"runtime/debug"
+)
+
+// UnaryRecovery turns a panic of the handler into an Internal error, the
+// panic is logged with its stack.
+func UnaryRecovery() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (res any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, info.FullMethod, p)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery is UnaryRecovery for streams.
+func StreamRecovery() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ss.Context(), info.FullMethod, p)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, p any) error {
+	logging.L(ctx).Error("grpc handler panic",
+		logging.StringAttr("method", method),
+		logging.StringAttr("panic", fmt.Sprint(p)),
+		logging.StringAttr("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
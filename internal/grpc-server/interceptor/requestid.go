@@ -0,0 +1,63 @@
+package interceptor
+
+import (
+	"app/pkg/common/core/identity"
+	"app/pkg/common/logging"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the metadata key of the request ID, it is sent back in
+// the response header.
+const RequestIDKey = "x-request-id"
+
+type ctxRequestID struct{}
+
+// UnaryRequestID takes the request ID from the metadata or generates one,
+// the logger of the handler carries it.
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(requestID(ctx), req)
+	}
+}
+
+// StreamRequestID is UnaryRequestID for streams.
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, withContext(ss, requestID(ss.Context())))
+	}
+}
+
+// RequestIDFromContext returns the request ID of the call.
+func RequestIDFromContext(ctx context.Context) string {
+	ID, _ := ctx.Value(ctxRequestID{}).(string)
+	return ID
+}
+
+func requestID(ctx context.Context) context.Context {
+	var ID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDKey); len(values) > 0 && values[0] != "" {
+			ID = values[0]
+		}
+	}
+	if ID == "" {
+		ID = identity.UUIDv7()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, ID))
+
+	ctx = context.WithValue(ctx, ctxRequestID{}, ID)
+	return logging.ContextWithLogger(ctx, logging.L(ctx).With(logging.StringAttr("request_id", ID)))
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"app/internal/config"
+	"app/pkg/client/rabbitmq"
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Database is the database the storages are on.
+type Database interface {
+	Ping(ctx context.Context) error
+}
+
+// Check reports whether the dependencies are reachable by name, RabbitMQ
+// is only checked when a queue driver is configured. It is shared by the
+// HTTP and the gRPC health checks.
+func Check(
+	ctx context.Context,
+	cfg *config.Config,
+	db Database,
+	queueClient *rabbitmq.App,
+) map[string]bool {
+	dependencies := make(map[string]bool)
+	dependencies["database"] = databaseStatus(ctx, db)
+
+	if cfg.Queue.Driver != "" {
+		dependencies["rabbitmq"] = rabbitMQStatus(queueClient.Channel())
+	}
+
+	return dependencies
+}
+
+// Healthy reports whether every dependency is reachable.
+func Healthy(dependencies map[string]bool) bool {
+	for _, value := range dependencies {
+		if !value {
+			return false
+		}
+	}
+	return true
+}
+
+func rabbitMQStatus(channel *amqp.Channel) bool {
+	if channel == nil || channel.IsClosed() {
+		return false
+	}
+	return true
+}
+
+func databaseStatus(ctx context.Context, db Database) bool {
+	err := db.Ping(ctx)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"app/internal/grpc-server/interceptor"
+	"app/pkg/common/logging"
+	gRPCGateway "app/pkg/grpc/sso/v1"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// streamingPaths are the paths of the server-streaming methods, their
+// responses outlive the write timeout of the HTTP server.
+var streamingPaths = []string{
+	"/v1/revocations:watch",
+}
+
+type register func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+// New returns the REST gateway of the gRPC services, routed by the HTTP
+// annotations of the protos. The calls go through conn and so through
+// the interceptors of the gRPC server. The JSON fields have the names of
+// the proto fields like in the OpenAPI document.
+func New(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMetadata(requestID),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions: protojson.MarshalOptions{
+				UseProtoNames:   true,
+				EmitUnpopulated: true,
+			},
+			UnmarshalOptions: protojson.UnmarshalOptions{
+				DiscardUnknown: true,
+			},
+		}),
+	)
+
+	for _, r := range []register{
+		gRPCGateway.RegisterAuthServiceHandler,
+		gRPCGateway.RegisterClientServiceHandler,
+		gRPCGateway.RegisterTokenServiceHandler,
+	} {
+		if err := r(ctx, mux, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(streamingPaths, r.URL.Path) {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				logging.L(ctx).Warn("failed to clear the write deadline", logging.ErrAttr(err))
+			}
+		}
+
+		mux.ServeHTTP(w, r)
+	}), nil
+}
+
+// requestID passes the request ID on to the gRPC server, the logs of both
+// carry the same one.
+func requestID(_ context.Context, r *http.Request) metadata.MD {
+	return metadata.Pairs(interceptor.RequestIDKey, middleware.GetReqID(r.Context()))
+}
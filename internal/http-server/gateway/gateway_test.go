@@ -0,0 +1,118 @@
+package gateway_test
+
+import (
+	"app/internal/grpc-server/interceptor"
+	"app/internal/http-server/gateway"
+	gRPCGateway "app/pkg/grpc/sso/v1"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type clients struct {
+	gRPCGateway.UnimplementedClientServiceServer
+	requestID string
+}
+
+func (c *clients) GetClient(
+	ctx context.Context,
+	req *gRPCGateway.GetClientRequest,
+) (*gRPCGateway.GetClientResponse, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(interceptor.RequestIDKey); len(values) > 0 {
+			c.requestID = values[0]
+		}
+	}
+
+	if req.GetName() != "app" {
+		return nil, status.Error(codes.NotFound, "client not found")
+	}
+
+	return &gRPCGateway.GetClientResponse{Client: &gRPCGateway.OAuthClient{
+		Id:                      "1",
+		Name:                    req.GetName(),
+		TokenEndpointAuthMethod: "client_secret_basic",
+	}}, nil
+}
+
+func newGateway(t *testing.T, c *clients) http.Handler {
+	t.Helper()
+
+	l := bufconn.Listen(1 << 16)
+	s := grpc.NewServer()
+	gRPCGateway.RegisterClientServiceServer(s, c)
+	go func() {
+		_ = s.Serve(l)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///gateway",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	gw, err := gateway.New(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return middleware.RequestID(gw)
+}
+
+func TestGateway_GetClient(t *testing.T) {
+	c := &clients{}
+	gw := newGateway(t, c)
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/clients/app", nil)
+	r.Header.Set(middleware.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res struct {
+		Client map[string]any `json:"client"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if res.Client["token_endpoint_auth_method"] != "client_secret_basic" {
+		t.Fatalf("client = %v, want the proto field names", res.Client)
+	}
+	if c.requestID != "req-1" {
+		t.Fatalf("request ID = %q, want the one of the HTTP request", c.requestID)
+	}
+}
+
+func TestGateway_StatusError(t *testing.T) {
+	gw := newGateway(t, &clients{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/clients/unknown", nil)
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,245 @@
+package authorize
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+type Client interface {
+	GetClient(ID string) (client.Client, error)
+}
+
+type AuthorizationRequest interface {
+	GetAuthorizationRequest(ID string) (authorizationRequestDomain.AuthorizationRequest, error)
+	DeleteAuthorizationRequest(ID string) error
+}
+
+type AuthorizationCode interface {
+	CreateAuthorizationCode(aC *authorizationCodeDomain.AuthorizationCode) error
+}
+
+type Resource interface {
+	GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error)
+}
+
+// New is the authorization endpoint of the authorization code grant
+// (RFC 6749, section 4.1). The user is authenticated by the user
+// authentication middleware. The parameters are read from the query or,
+// when a request_uri is given, from a pushed authorization request
+// (RFC 9126, section 4).
+func New(
+	ctx context.Context,
+	clients Client,
+	authorizationRequest AuthorizationRequest,
+	authorizationCode AuthorizationCode,
+	resources Resource,
+	cfg *config.Config,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.authorize.New"
+
+		logging.L(ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("authorize")
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_token", "the access token is invalid")
+			return
+		}
+
+		query := r.URL.Query()
+
+		c, err := clients.GetClient(query.Get("client_id"))
+		if err != nil || c.Revoked {
+			if err != nil && !storage.IsNotFound(err) {
+				logging.L(ctx).Error("failed get client", logging.ErrAttr(err))
+			}
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "unknown client")
+			return
+		}
+
+		var params authorizationRequestDomain.Params
+
+		if requestURI := query.Get("request_uri"); requestURI != "" {
+			if params, ok = pushedParams(ctx, authorizationRequest, requestURI, c); !ok {
+				resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request_uri", "invalid or expired request_uri")
+				return
+			}
+		} else {
+			if c.RequirePushedAuthorizationRequests {
+				resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "pushed authorization request is required")
+				return
+			}
+			params = authorizationRequestDomain.ParamsFromValues(query)
+		}
+
+		redirectURI, err := params.Validate(c)
+		if err != nil {
+			authErr := &authorizationRequestDomain.Error{Code: "invalid_request", Description: err.Error()}
+			errors.As(err, &authErr)
+
+			if redirectURI == "" {
+				resp.OAuthError(w, r, http.StatusBadRequest, authErr.Code, authErr.Description)
+				return
+			}
+
+			Redirect(w, r, redirectURI, url.Values{
+				"error":             {authErr.Code},
+				"error_description": {authErr.Description},
+				"state":             {params.State},
+				"iss":               {cfg.Issuer},
+			})
+			return
+		}
+
+		var authTime int64
+		if claims, ok := token.ClaimsFromContext(r.Context()); ok {
+			authTime = claims.AuthTime
+		}
+
+		Issue(ctx, w, r, resources, authorizationCode, c, aT.UserId, authTime, params, redirectURI, cfg)
+	}
+}
+
+// Issue issues an authorization code to the client for the user and the
+// validated parameters and redirects the user-agent back to the client
+// with it. Other sign ins end their flows with it too, authTime is when
+// the user authenticated.
+func Issue(
+	ctx context.Context,
+	w http.ResponseWriter,
+	r *http.Request,
+	resources Resource,
+	authorizationCode AuthorizationCode,
+	c client.Client,
+	userID int64,
+	authTime int64,
+	params authorizationRequestDomain.Params,
+	redirectURI string,
+	cfg *config.Config,
+) {
+	// the resources are checked against the registry here, the token
+	// request may only narrow them down (RFC 8707, section 2.2)
+	var found []resourceDomain.Resource
+	if len(params.Resource) > 0 {
+		var err error
+		found, err = resources.GetResourcesByIdentifiers(params.Resource)
+		if err != nil || len(found) != len(slices.Compact(slices.Sorted(slices.Values(params.Resource)))) {
+			if err != nil {
+				logging.L(ctx).Error("failed get resources", logging.ErrAttr(err))
+			}
+			Redirect(w, r, redirectURI, url.Values{
+				"error":             {"invalid_target"},
+				"error_description": {"unknown resource"},
+				"state":             {params.State},
+				"iss":               {cfg.Issuer},
+			})
+			return
+		}
+	}
+
+	// a scope is only granted when it is registered for the client or the
+	// resources (RFC 6749, section 3.3)
+	if _, err := resourceDomain.NewTarget(found, params.Scope, c.Scopes, cfg.Token.TTL); err != nil {
+		Redirect(w, r, redirectURI, url.Values{
+			"error":             {"invalid_scope"},
+			"error_description": {err.Error()},
+			"state":             {params.State},
+			"iss":               {cfg.Issuer},
+		})
+		return
+	}
+
+	now := time.Now()
+	code := crypt.GetSecret()
+	aC := &authorizationCodeDomain.AuthorizationCode{
+		ID:                  crypt.GetSHA256Hash(code),
+		ClientId:            c.ID,
+		UserId:              userID,
+		RedirectURI:         redirectURI,
+		Scope:               params.Scope,
+		Resources:           append([]string{}, params.Resource...),
+		CodeChallenge:       params.CodeChallenge,
+		CodeChallengeMethod: params.CodeChallengeMethod,
+		AuthTime:            authTime,
+		ExpiresAt:           now.Add(cfg.Authorization.CodeTTL).Unix(),
+		CreatedAt:           now.Unix(),
+	}
+
+	if err := authorizationCode.CreateAuthorizationCode(aC); err != nil {
+		logging.L(ctx).Error("failed create authorization code", logging.ErrAttr(err))
+		Redirect(w, r, redirectURI, url.Values{
+			"error": {"server_error"},
+			"state": {params.State},
+			"iss":   {cfg.Issuer},
+		})
+		return
+	}
+
+	Redirect(w, r, redirectURI, url.Values{
+		"code":  {code},
+		"state": {params.State},
+		"iss":   {cfg.Issuer},
+	})
+}
+
+// pushedParams resolves a request_uri issued to the client. A request_uri
+// is used once, it is deleted when it is resolved.
+func pushedParams(
+	ctx context.Context,
+	authorizationRequest AuthorizationRequest,
+	requestURI string,
+	c client.Client,
+) (authorizationRequestDomain.Params, bool) {
+	aR, err := authorizationRequest.GetAuthorizationRequest(crypt.GetSHA256Hash(requestURI))
+	if err != nil || aR.ClientId != c.ID || aR.Expired(time.Now()) {
+		return authorizationRequestDomain.Params{}, false
+	}
+
+	if err := authorizationRequest.DeleteAuthorizationRequest(aR.ID); err != nil {
+		if !storage.IsNotFound(err) {
+			logging.L(ctx).Error("failed delete authorization request", logging.ErrAttr(err))
+		}
+		return authorizationRequestDomain.Params{}, false
+	}
+
+	return aR.Params, true
+}
+
+// Redirect sends the user-agent back to the client, empty parameters are
+// left out.
+func Redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	location, err := url.Parse(redirectURI)
+	if err != nil {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "invalid redirect_uri")
+		return
+	}
+
+	query := location.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	location.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, location.String(), http.StatusFound)
+}
@@ -0,0 +1,320 @@
+package authorize_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/http-server/handlers/authorize"
+	"app/internal/http-server/handlers/par"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/token"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const redirectURI = "https://web.example.com/callback"
+
+type fakeStore struct {
+	clients  map[string]client.Client
+	requests map[string]authorizationRequestDomain.AuthorizationRequest
+	codes    []authorizationCodeDomain.AuthorizationCode
+}
+
+func (s *fakeStore) GetClient(ID string) (client.Client, error) {
+	c, ok := s.clients[ID]
+	if !ok {
+		return client.Client{}, pgx.ErrNoRows
+	}
+	return c, nil
+}
+
+func (s *fakeStore) CreateAuthorizationRequest(aR *authorizationRequestDomain.AuthorizationRequest) error {
+	s.requests[aR.ID] = *aR
+	return nil
+}
+
+func (s *fakeStore) GetAuthorizationRequest(ID string) (authorizationRequestDomain.AuthorizationRequest, error) {
+	aR, ok := s.requests[ID]
+	if !ok {
+		return authorizationRequestDomain.AuthorizationRequest{}, pgx.ErrNoRows
+	}
+	return aR, nil
+}
+
+func (s *fakeStore) DeleteAuthorizationRequest(ID string) error {
+	if _, ok := s.requests[ID]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(s.requests, ID)
+	return nil
+}
+
+func (s *fakeStore) CreateAuthorizationCode(aC *authorizationCodeDomain.AuthorizationCode) error {
+	s.codes = append(s.codes, *aC)
+	return nil
+}
+
+func (s *fakeStore) GetResourcesByIdentifiers([]string) ([]resourceDomain.Resource, error) {
+	return nil, nil
+}
+
+type env struct {
+	store     *fakeStore
+	push      http.Handler
+	authorize http.Handler
+}
+
+func newEnv(t *testing.T, ttl time.Duration) *env {
+	t.Helper()
+
+	s := &fakeStore{
+		clients: map[string]client.Client{
+			"web": {
+				ID:                      "web",
+				RedirectURIs:            []string{redirectURI},
+				TokenEndpointAuthMethod: client.AuthMethodClientSecretBasic,
+				GrantTypes:              []string{client.GrantTypeAuthorizationCode},
+			},
+			"strict": {
+				ID:                                 "strict",
+				RedirectURIs:                       []string{redirectURI},
+				TokenEndpointAuthMethod:            client.AuthMethodClientSecretBasic,
+				GrantTypes:                         []string{client.GrantTypeAuthorizationCode},
+				RequirePushedAuthorizationRequests: true,
+			},
+		},
+		requests: map[string]authorizationRequestDomain.AuthorizationRequest{},
+	}
+	cfg := &config.Config{
+		Issuer:        "https://sso.test",
+		Token:         config.Token{TTL: time.Hour},
+		Authorization: config.Authorization{CodeTTL: time.Minute, RequestURITTL: ttl},
+	}
+
+	return &env{
+		store:     s,
+		push:      par.New(context.Background(), s, cfg),
+		authorize: authorize.New(context.Background(), s, s, s, s, cfg),
+	}
+}
+
+// pushRequest pushes the authorization request of the client and returns
+// the status and the pushed authorization response.
+func (e *env) pushRequest(t *testing.T, clientID string, form url.Values) (int, par.Response, resp.OAuthErrorResponse) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/oauth/par", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c, ok := e.store.clients[clientID]; ok {
+		r = r.WithContext(clientauth.ContextWithClient(r.Context(), c))
+	}
+	w := httptest.NewRecorder()
+
+	e.push.ServeHTTP(w, r)
+
+	var res par.Response
+	var oauthErr resp.OAuthErrorResponse
+	if w.Code == http.StatusCreated {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	} else if err := json.Unmarshal(w.Body.Bytes(), &oauthErr); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+
+	return w.Code, res, oauthErr
+}
+
+// authorizeRequest sends an authorization request of the signed in user.
+func (e *env) authorizeRequest(t *testing.T, query url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	r = r.WithContext(token.ContextWithAccessToken(r.Context(), accessTokenDomain.AccessToken{ID: "session", UserId: 1}))
+	w := httptest.NewRecorder()
+
+	e.authorize.ServeHTTP(w, r)
+
+	return w
+}
+
+func authorizationForm() url.Values {
+	return url.Values{
+		"response_type": {"code"},
+		"redirect_uri":  {redirectURI},
+		"state":         {"state-1"},
+	}
+}
+
+func oauthError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var oauthErr resp.OAuthErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &oauthErr); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return oauthErr.Error
+}
+
+// TestPushedAuthorizationRequest checks that a request_uri is only
+// redeemed once, by its client and before it expires (RFC 9126).
+func TestPushedAuthorizationRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		pushedBy  string
+		usedBy    string
+		redeem    int
+		wantError string
+	}{
+		{name: "redeemed", ttl: time.Minute, pushedBy: "web", usedBy: "web", redeem: 1},
+		{name: "required and redeemed", ttl: time.Minute, pushedBy: "strict", usedBy: "strict", redeem: 1},
+		{name: "redeemed twice", ttl: time.Minute, pushedBy: "web", usedBy: "web", redeem: 2, wantError: "invalid_request_uri"},
+		{name: "expired", ttl: -time.Minute, pushedBy: "web", usedBy: "web", redeem: 1, wantError: "invalid_request_uri"},
+		{name: "pushed by another client", ttl: time.Minute, pushedBy: "web", usedBy: "strict", redeem: 1, wantError: "invalid_request_uri"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t, tt.ttl)
+
+			status, pushed, oauthErr := e.pushRequest(t, tt.pushedBy, authorizationForm())
+			if status != http.StatusCreated {
+				t.Fatalf("push status = %d, error = %+v", status, oauthErr)
+			}
+			if !strings.HasPrefix(pushed.RequestURI, authorizationRequestDomain.RequestURIPrefix) ||
+				pushed.ExpiresIn != int64(tt.ttl.Seconds()) {
+				t.Fatalf("pushed %+v", pushed)
+			}
+
+			var w *httptest.ResponseRecorder
+			for range tt.redeem {
+				w = e.authorizeRequest(t, url.Values{"client_id": {tt.usedBy}, "request_uri": {pushed.RequestURI}})
+			}
+
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || oauthError(t, w) != tt.wantError {
+					t.Fatalf("status = %d, body = %s, want %s", w.Code, w.Body, tt.wantError)
+				}
+				if len(e.store.codes) != tt.redeem-1 {
+					t.Fatalf("%d codes issued, want %d", len(e.store.codes), tt.redeem-1)
+				}
+				return
+			}
+
+			if w.Code != http.StatusFound {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+			}
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if location.Query().Get("code") == "" || location.Query().Get("state") != "state-1" {
+				t.Fatalf("redirected to %s", location)
+			}
+			if len(e.store.codes) != 1 || e.store.codes[0].ClientId != tt.usedBy || len(e.store.requests) != 0 {
+				t.Fatalf("codes = %+v, requests left = %d", e.store.codes, len(e.store.requests))
+			}
+		})
+	}
+}
+
+// TestAuthorize_RequirePushedAuthorizationRequests checks that a client
+// with the mandatory flag can't send its parameters in the query.
+func TestAuthorize_RequirePushedAuthorizationRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		clientID  string
+		wantError string
+	}{
+		{name: "optional", clientID: "web"},
+		{name: "required", clientID: "strict", wantError: "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t, time.Minute)
+
+			query := authorizationForm()
+			query.Set("client_id", tt.clientID)
+			w := e.authorizeRequest(t, query)
+
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || oauthError(t, w) != tt.wantError {
+					t.Fatalf("status = %d, body = %s, want %s", w.Code, w.Body, tt.wantError)
+				}
+				if len(e.store.codes) != 0 {
+					t.Fatal("code issued without a pushed request")
+				}
+				return
+			}
+
+			if w.Code != http.StatusFound || len(e.store.codes) != 1 {
+				t.Fatalf("status = %d, %d codes issued", w.Code, len(e.store.codes))
+			}
+		})
+	}
+}
+
+func TestPushedAuthorizationRequest_Rejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientID   string
+		form       url.Values
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "unauthenticated client",
+			form:       authorizationForm(),
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "invalid_client",
+		},
+		{
+			name:       "pushed request_uri",
+			clientID:   "web",
+			form:       url.Values{"request_uri": {authorizationRequestDomain.RequestURIPrefix + "pushed"}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+		{
+			name:       "client_id of another client",
+			clientID:   "web",
+			form:       url.Values{"client_id": {"strict"}, "response_type": {"code"}, "redirect_uri": {redirectURI}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+		{
+			name:       "unregistered redirect_uri",
+			clientID:   "web",
+			form:       url.Values{"response_type": {"code"}, "redirect_uri": {"https://evil.example.com/callback"}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t, time.Minute)
+
+			status, _, oauthErr := e.pushRequest(t, tt.clientID, tt.form)
+			if status != tt.wantStatus || oauthErr.Error != tt.wantError {
+				t.Fatalf("status = %d, error = %q, want %d %q", status, oauthErr.Error, tt.wantStatus, tt.wantError)
+			}
+			if len(e.store.requests) != 0 {
+				t.Fatal("authorization request stored for a refused push")
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package client_registration
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/core/redirecturi"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	errJWKSURI    = errors.New("jwks_uri is not supported, register the keys with jwks")
+	errAdminScope = errors.New("the admin scope can't be registered dynamically")
+)
+
+type Client interface {
+	GetClient(ID string) (client.Client, error)
+	CreateClient(client *client.Client) error
+	UpdateClient(client *client.Client) error
+	UpdateSecret(client *client.Client) error
+	DeleteClient(ID string) error
+}
+
+type Registration struct {
+	ctx    context.Context
+	client Client
+	cfg    *config.Config
+}
+
+func New(ctx context.Context, client Client, cfg *config.Config) *Registration {
+	return &Registration{
+		ctx:    ctx,
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// Metadata is the client metadata of RFC 7591, section 2.
+type Metadata struct {
+	ClientID                           string          `json:"client_id,omitempty"`
+	ClientSecret                       string          `json:"client_secret,omitempty"`
+	ClientName                         string          `json:"client_name,omitempty"`
+	RedirectURIs                       []string        `json:"redirect_uris"`
+	GrantTypes                         []string        `json:"grant_types"`
+	Scope                              string          `json:"scope,omitempty"`
+	TokenEndpointAuthMethod            string          `json:"token_endpoint_auth_method"`
+	JWKS                               json.RawMessage `json:"jwks,omitempty"`
+	JWKSURI                            string          `json:"jwks_uri,omitempty"`
+	Contacts                           []string        `json:"contacts"`
+	RequirePushedAuthorizationRequests bool            `json:"require_pushed_authorization_requests"`
+	TLSClientAuthSubjectDN             string          `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientAuthSANDNS                string          `json:"tls_client_auth_san_dns,omitempty"`
+	TLSClientAuthSANURI                string          `json:"tls_client_auth_san_uri,omitempty"`
+	TLSClientAuthSANIP                 string          `json:"tls_client_auth_san_ip,omitempty"`
+	TLSClientAuthSANEmail              string          `json:"tls_client_auth_san_email,omitempty"`
+}
+
+// Response is the client information response (RFC 7591, section 3.2.1).
+// The client secret and the registration access token are only returned
+// when they are issued, the server keeps nothing but their hashes.
+type Response struct {
+	Metadata
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+}
+
+// Register creates a client from its metadata, the request must carry one
+// of the configured initial access tokens.
+func (s *Registration) Register() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client-registration.Register"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("register client")
+
+		if !s.validInitialAccessToken(r) {
+			logging.L(s.ctx).Warn("invalid initial access token")
+			invalidToken(w, r)
+			return
+		}
+
+		var md Metadata
+		if err := render.DecodeJSON(r.Body, &md); err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "failed to decode client metadata")
+			return
+		}
+
+		now := time.Now().Unix()
+		var oauthClient = &client.Client{
+			ID:        identity.UUIDv7(),
+			Provider:  "users",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		if err := s.applyMetadata(oauthClient, md); err != nil {
+			logging.L(s.ctx).Error("invalid client metadata", logging.ErrAttr(err))
+			metadataError(w, r, err)
+			return
+		}
+
+		var err error
+		var res = &Response{}
+
+		if oauthClient.UsesSecret() {
+			res.ClientSecret = crypt.GetSecret()
+			if oauthClient.Secret, err = crypt.HashSecret(res.ClientSecret); err != nil {
+				logging.L(s.ctx).Error("failed hash client secret", logging.ErrAttr(err))
+				resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed register client")
+				return
+			}
+		}
+
+		res.RegistrationAccessToken = crypt.GetSecret()
+		registrationTokenHash, err := crypt.HashSecret(res.RegistrationAccessToken)
+		if err != nil {
+			logging.L(s.ctx).Error("failed hash registration access token", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed register client")
+			return
+		}
+		oauthClient.RegistrationAccessToken = &registrationTokenHash
+
+		if err := s.client.CreateClient(oauthClient); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "client_name is already registered")
+				return
+			}
+			logging.L(s.ctx).Error("failed create client", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed register client")
+			return
+		}
+
+		s.fillResponse(res, *oauthClient)
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, res)
+	}
+}
+
+// Read returns the current client metadata (RFC 7592, section 2.1).
+func (s *Registration) Read() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client-registration.Read"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("read client registration")
+
+		oauthClient, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+
+		var res = &Response{}
+		s.fillResponse(res, oauthClient)
+
+		render.JSON(w, r, res)
+	}
+}
+
+// Update replaces the client metadata (RFC 7592, section 2.2), fields
+// left out of the request are reset to their defaults.
+func (s *Registration) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client-registration.Update"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("update client registration")
+
+		oauthClient, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+
+		var md Metadata
+		if err := render.DecodeJSON(r.Body, &md); err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "failed to decode client metadata")
+			return
+		}
+
+		if md.ClientID != oauthClient.ID {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "client_id does not match")
+			return
+		}
+
+		if md.ClientSecret != "" && !oauthClient.AcceptsSecret(md.ClientSecret, time.Now()) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "client_secret does not match")
+			return
+		}
+
+		if err := s.applyMetadata(&oauthClient, md); err != nil {
+			logging.L(s.ctx).Error("invalid client metadata", logging.ErrAttr(err))
+			metadataError(w, r, err)
+			return
+		}
+		oauthClient.UpdatedAt = time.Now().Unix()
+
+		var res = &Response{}
+
+		if err := s.client.UpdateClient(&oauthClient); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", "client_name is already registered")
+				return
+			}
+			logging.L(s.ctx).Error("failed update client", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed update client")
+			return
+		}
+
+		// a client that switches to a secret based method gets its first secret
+		if oauthClient.UsesSecret() && oauthClient.Secret == "" {
+			var err error
+			res.ClientSecret = crypt.GetSecret()
+			if oauthClient.Secret, err = crypt.HashSecret(res.ClientSecret); err != nil {
+				logging.L(s.ctx).Error("failed hash client secret", logging.ErrAttr(err))
+				resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed update client")
+				return
+			}
+			if err := s.client.UpdateSecret(&oauthClient); err != nil {
+				logging.L(s.ctx).Error("failed update client secret", logging.ErrAttr(err))
+				resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed update client")
+				return
+			}
+		}
+
+		// and one that switches away from it has its secrets dropped
+		if !oauthClient.UsesSecret() && oauthClient.DropSecret() {
+			if err := s.client.UpdateSecret(&oauthClient); err != nil {
+				logging.L(s.ctx).Error("failed drop client secret", logging.ErrAttr(err))
+				resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed update client")
+				return
+			}
+		}
+
+		s.fillResponse(res, oauthClient)
+
+		render.JSON(w, r, res)
+	}
+}
+
+// Delete deregisters the client (RFC 7592, section 2.3).
+func (s *Registration) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client-registration.Delete"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("delete client registration")
+
+		oauthClient, ok := s.authorize(w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.client.DeleteClient(oauthClient.ID); err != nil && !storage.IsNotFound(err) {
+			logging.L(s.ctx).Error("failed delete client", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed delete client")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Registration) validInitialAccessToken(r *http.Request) bool {
+	presented, ok := token.BearerToken(r)
+	if !ok {
+		return false
+	}
+
+	for _, initial := range s.cfg.Client.InitialAccessTokens {
+		if initial != "" && subtle.ConstantTimeCompare([]byte(initial), []byte(presented)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorize loads the client addressed by the {id} URL parameter and checks
+// the registration access token. An unknown client gets the same response
+// as a wrong token so that client ids can't be probed.
+func (s *Registration) authorize(w http.ResponseWriter, r *http.Request) (client.Client, bool) {
+	ID := chi.URLParam(r, "id")
+	presented, ok := token.BearerToken(r)
+
+	if !ok || uuid.Validate(ID) != nil {
+		invalidToken(w, r)
+		return client.Client{}, false
+	}
+
+	oauthClient, err := s.client.GetClient(ID)
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			logging.L(s.ctx).Error("failed get client", logging.ErrAttr(err))
+		}
+		invalidToken(w, r)
+		return client.Client{}, false
+	}
+
+	if oauthClient.RegistrationAccessToken == nil ||
+		!crypt.VerifySecret(*oauthClient.RegistrationAccessToken, presented) {
+		invalidToken(w, r)
+		return client.Client{}, false
+	}
+
+	return oauthClient, true
+}
+
+func (s *Registration) applyMetadata(oauthClient *client.Client, md Metadata) error {
+	if md.JWKSURI != "" {
+		return errJWKSURI
+	}
+
+	oauthClient.Name = md.ClientName
+	if oauthClient.Name == "" {
+		oauthClient.Name = oauthClient.ID
+	}
+
+	grantTypes := md.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{client.GrantTypeAuthorizationCode}
+	}
+	if err := oauthClient.SetGrantTypes(grantTypes); err != nil {
+		return err
+	}
+
+	// only redirect based grants need a redirect uri
+	if len(md.RedirectURIs) > 0 || oauthClient.AllowsGrant(client.GrantTypeAuthorizationCode) {
+		if err := oauthClient.SetRedirectURIs(md.RedirectURIs, s.cfg.IsLocal()); err != nil {
+			return err
+		}
+	} else {
+		oauthClient.RedirectURIs = []string{}
+	}
+
+	method := md.TokenEndpointAuthMethod
+	if method == "" {
+		method = client.AuthMethodClientSecretBasic
+	}
+
+	oauthClient.TLSClientAuth = nil
+	if method == client.AuthMethodTLSClientAuth {
+		oauthClient.TLSClientAuth = &client.TLSClientAuth{
+			SubjectDN: md.TLSClientAuthSubjectDN,
+			SANDNS:    md.TLSClientAuthSANDNS,
+			SANURI:    md.TLSClientAuthSANURI,
+			SANIP:     md.TLSClientAuthSANIP,
+			SANEmail:  md.TLSClientAuthSANEmail,
+		}
+	}
+
+	oauthClient.JWKS = nil
+	var jwks *string
+	if len(md.JWKS) > 0 && string(md.JWKS) != "null" {
+		raw := string(md.JWKS)
+		jwks = &raw
+	}
+	if err := oauthClient.SetAuthMethod(method, jwks); err != nil {
+		return err
+	}
+
+	oauthClient.Contacts = md.Contacts
+	if oauthClient.Contacts == nil {
+		oauthClient.Contacts = []string{}
+	}
+
+	oauthClient.RequirePushedAuthorizationRequests = md.RequirePushedAuthorizationRequests
+
+	// the admin scope is only granted to clients registered by an
+	// administrator
+	scopes := strings.Fields(md.Scope)
+	if slices.Contains(scopes, s.cfg.Admin.Scope) {
+		return errAdminScope
+	}
+
+	return oauthClient.SetScopes(scopes)
+}
+
+func (s *Registration) fillResponse(res *Response, oauthClient client.Client) {
+	res.ClientID = oauthClient.ID
+	res.ClientName = oauthClient.Name
+	res.RedirectURIs = oauthClient.RedirectURIs
+	res.GrantTypes = oauthClient.GrantTypes
+	res.Scope = strings.Join(oauthClient.Scopes, " ")
+	res.TokenEndpointAuthMethod = oauthClient.TokenEndpointAuthMethod
+	res.Contacts = oauthClient.Contacts
+	res.RequirePushedAuthorizationRequests = oauthClient.RequirePushedAuthorizationRequests
+	if t := oauthClient.TLSClientAuth; t != nil {
+		res.TLSClientAuthSubjectDN = t.SubjectDN
+		res.TLSClientAuthSANDNS = t.SANDNS
+		res.TLSClientAuthSANURI = t.SANURI
+		res.TLSClientAuthSANIP = t.SANIP
+		res.TLSClientAuthSANEmail = t.SANEmail
+	}
+	if oauthClient.JWKS != nil {
+		res.JWKS = json.RawMessage(*oauthClient.JWKS)
+	}
+	res.ClientIDIssuedAt = oauthClient.CreatedAt
+	res.RegistrationClientURI = strings.TrimRight(s.cfg.Issuer, "/") + "/oauth/register/" + oauthClient.ID
+}
+
+func metadataError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, client.ErrNoRedirectURIs),
+		errors.Is(err, redirecturi.ErrInvalid),
+		errors.Is(err, redirecturi.ErrFragment),
+		errors.Is(err, redirecturi.ErrInsecure):
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_redirect_uri", err.Error())
+	default:
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_client_metadata", err.Error())
+	}
+}
+
+func invalidToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_token", "the access token is invalid")
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"app/internal/config"
 	"app/internal/domain/client"
 	"app/internal/storage"
 	resp "app/pkg/common/core/api/response"
@@ -8,6 +9,7 @@ import (
 	"app/pkg/common/logging"
 	"app/pkg/utils/crypt"
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,45 +17,125 @@ import (
 	"github.com/go-playground/validator/v10"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
 type Client interface {
+	GetClient(ID string) (client.Client, error)
 	GetClientByName(name string) (client.Client, error)
+	GetClients(limit, offset int) ([]client.Client, error)
+	CountClients() (int64, error)
 	CreateClient(client *client.Client) error
+	UpdateClient(client *client.Client) error
+	UpdateRevoked(ID string, revoked bool, updatedAt int64) error
+	UpdateSecret(client *client.Client) error
+	DeleteClient(ID string) error
 }
 
 type Storage struct {
 	ctx    context.Context
 	client Client
+	cfg    *config.Config
 }
 
 type Request struct {
 	ClientName string `json:"client" validate:"required,ascii"`
 }
 
-func New(ctx context.Context, client Client) *Storage {
+func New(ctx context.Context, client Client, cfg *config.Config) *Storage {
 	return &Storage{
 		ctx:    ctx,
 		client: client,
+		cfg:    cfg,
 	}
 }
 
 type Response struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Redirect string `json:"redirect"`
+	ID                                 string                `json:"id"`
+	Name                               string                `json:"name"`
+	RedirectURIs                       []string              `json:"redirectUris"`
+	UserId                             *int64                `json:"userId,omitempty"`
+	Provider                           string                `json:"provider,omitempty"`
+	PersonalAccessClient               bool                  `json:"personalAccessClient"`
+	PasswordClient                     bool                  `json:"passwordClient"`
+	Revoked                            bool                  `json:"revoked"`
+	TokenEndpointAuthMethod            string                `json:"tokenEndpointAuthMethod,omitempty"`
+	TokenExchange                      *client.TokenExchange `json:"tokenExchange,omitempty"`
+	GrantTypes                         []string              `json:"grantTypes"`
+	Scopes                             []string              `json:"scopes"`
+	RequirePushedAuthorizationRequests bool                  `json:"requirePushedAuthorizationRequests"`
+	TLSClientAuth                      *client.TLSClientAuth `json:"tlsClientAuth,omitempty"`
+	CreatedAt                          int64                 `json:"createdAt,omitempty"`
+	UpdatedAt                          int64                 `json:"updatedAt,omitempty"`
+}
+
+type ListRequest struct {
+	Page  int `validate:"min=1"`
+	Limit int `validate:"min=1,max=100"`
+}
+
+type ListResponse struct {
+	Items []*Response `json:"items"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
 }
 
 type CreateRequest struct {
-	Name     string `json:"name" validate:"required,ascii"`
-	Redirect string `json:"redirect" validate:"required,ascii"`
+	Name                               string                `json:"name" validate:"required,ascii"`
+	RedirectURIs                       []string              `json:"redirectUris" validate:"required,min=1,dive,required"`
+	TokenEndpointAuthMethod            string                `json:"tokenEndpointAuthMethod" validate:"omitempty,oneof=client_secret_basic client_secret_post private_key_jwt none tls_client_auth self_signed_tls_client_auth"`
+	JWKS                               json.RawMessage       `json:"jwks"`
+	TokenExchange                      *client.TokenExchange `json:"tokenExchange"`
+	GrantTypes                         []string              `json:"grantTypes"`
+	Scopes                             []string              `json:"scopes"`
+	RequirePushedAuthorizationRequests bool                  `json:"requirePushedAuthorizationRequests"`
+	TLSClientAuth                      *client.TLSClientAuth `json:"tlsClientAuth"`
+}
+
+type UpdateRequest struct {
+	Name                               *string               `json:"name" validate:"omitempty,ascii"`
+	RedirectURIs                       []string              `json:"redirectUris" validate:"omitempty,dive,required"`
+	UserId                             *int64                `json:"userId" validate:"omitempty,min=0"`
+	PersonalAccessClient               *bool                 `json:"personalAccessClient"`
+	PasswordClient                     *bool                 `json:"passwordClient"`
+	TokenEndpointAuthMethod            *string               `json:"tokenEndpointAuthMethod" validate:"omitempty,oneof=client_secret_basic client_secret_post private_key_jwt none tls_client_auth self_signed_tls_client_auth"`
+	JWKS                               json.RawMessage       `json:"jwks"`
+	TokenExchange                      *client.TokenExchange `json:"tokenExchange"`
+	GrantTypes                         []string              `json:"grantTypes"`
+	Scopes                             []string              `json:"scopes"`
+	RequirePushedAuthorizationRequests *bool                 `json:"requirePushedAuthorizationRequests"`
+	TLSClientAuth                      *client.TLSClientAuth `json:"tlsClientAuth"`
+}
+
+type IDRequest struct {
+	ID string `validate:"required,uuid"`
+}
+
+// CreateResponse carries the plain client secret, it is never returned again.
+// Clients that don't authenticate with a secret get none.
+type CreateResponse struct {
+	*Response
+	Secret string `json:"secret,omitempty"`
+}
+
+// SecretResponse carries the new plain client secret, it is never returned again.
+type SecretResponse struct {
+	ID                      string `json:"id"`
+	Secret                  string `json:"secret"`
+	PreviousSecretExpiresAt int64  `json:"previousSecretExpiresAt"`
 }
 
 func (s *Storage) GetClient() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "http-server.handlers.client.GetClient"
-		logging.L(s.ctx).Info("op", op)
+		logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 		var dR = map[string]string{}
 
@@ -66,11 +148,11 @@ func (s *Storage) GetClient() http.HandlerFunc {
 			ClientName: chi.URLParam(r, "client"),
 		}
 
-		logging.L(s.ctx).Info("client name", req.ClientName)
+		logging.L(s.ctx).Info("client name", logging.StringAttr("client", req.ClientName))
 
 		if err := validator.New().Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
-			logging.L(s.ctx).Error("invalid request", err)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
 			dR := resp.ValidationError(validateErr)
 			resp.Error(w, r, dR)
 			return
@@ -85,20 +167,70 @@ func (s *Storage) GetClient() http.HandlerFunc {
 			return
 		}
 
-		var dRS = &Response{
-			ID:       clientStorage.ID,
-			Name:     clientStorage.Name,
-			Redirect: clientStorage.Redirect,
+		resp.Ok(w, r, newResponse(clientStorage))
+		return
+	}
+}
+
+func (s *Storage) ListClients() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client.ListClients"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("list clients")
+
+		var dR = map[string]string{}
+
+		req, err := listRequest(r)
+		if err != nil {
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR["message"] = "invalid pagination parameters"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		clients, err := s.client.GetClients(req.Limit, (req.Page-1)*req.Limit)
+		if err != nil {
+			dR["message"] = "failed get clients"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		total, err := s.client.CountClients()
+		if err != nil {
+			dR["message"] = "failed get clients"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		var dRS = &ListResponse{
+			Items: make([]*Response, 0, len(clients)),
+			Total: total,
+			Page:  req.Page,
+			Limit: req.Limit,
 		}
+		for _, c := range clients {
+			dRS.Items = append(dRS.Items, newResponse(c))
+		}
+
 		resp.Ok(w, r, dRS)
-		return
 	}
 }
 
 func (s *Storage) CreateClient() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "http-server.handlers.client.CreateClient"
-		logging.L(s.ctx).Info("op", op)
+		logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 		var dR = map[string]string{}
 
@@ -108,27 +240,87 @@ func (s *Storage) CreateClient() http.HandlerFunc {
 		if errors.Is(err, io.EOF) {
 			logging.L(s.ctx).Error("request body is empty")
 			dR["message"] = "empty request"
+			render.Status(r, http.StatusBadRequest)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			render.Status(r, http.StatusBadRequest)
 			resp.Error(w, r, dR)
 			return
 		}
 
 		if err := validator.New().Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
-			logging.L(s.ctx).Error("invalid request", err)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
 			dR := resp.ValidationError(validateErr)
 			resp.Error(w, r, dR)
 			return
 		}
 
 		var oauthClient = &client.Client{
-			ID:             identity.UUIDv7(),
-			Name:           req.Name,
-			Secret:         crypt.GetSecret(),
-			Redirect:       req.Redirect,
-			Provider:       "users",
-			PasswordClient: true,
-			CreatedAt:      time.Now().Unix(),
-			UpdatedAt:      time.Now().Unix(),
+			ID:                                 identity.UUIDv7(),
+			Name:                               req.Name,
+			Provider:                           "users",
+			PasswordClient:                     true,
+			GrantTypes:                         []string{client.GrantTypePassword, client.GrantTypeRefreshToken},
+			Contacts:                           []string{},
+			RequirePushedAuthorizationRequests: req.RequirePushedAuthorizationRequests,
+			TLSClientAuth:                      req.TLSClientAuth,
+			CreatedAt:                          time.Now().Unix(),
+			UpdatedAt:                          time.Now().Unix(),
+		}
+
+		if err := oauthClient.SetRedirectURIs(req.RedirectURIs, s.cfg.IsLocal()); err != nil {
+			logging.L(s.ctx).Error("invalid redirect uris", logging.ErrAttr(err))
+			dR["message"] = err.Error()
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if req.GrantTypes != nil {
+			if err := oauthClient.SetGrantTypes(req.GrantTypes); err != nil {
+				logging.L(s.ctx).Error("invalid grant types", logging.ErrAttr(err))
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+
+		if err := oauthClient.SetScopes(req.Scopes); err != nil {
+			logging.L(s.ctx).Error("invalid scopes", logging.ErrAttr(err))
+			dR["message"] = err.Error()
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if req.TokenEndpointAuthMethod == "" {
+			req.TokenEndpointAuthMethod = client.AuthMethodClientSecretBasic
+		}
+
+		if err := oauthClient.SetAuthMethod(req.TokenEndpointAuthMethod, rawJWKS(req.JWKS)); err != nil {
+			logging.L(s.ctx).Error("invalid auth method", logging.ErrAttr(err))
+			dR["message"] = err.Error()
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if req.TokenExchange != nil {
+			oauthClient.SetTokenExchange(req.TokenExchange)
+		}
+
+		var secret string
+		if oauthClient.UsesSecret() {
+			secret = crypt.GetSecret()
+			if oauthClient.Secret, err = crypt.HashSecret(secret); err != nil {
+				logging.L(s.ctx).Error("failed hash client secret", logging.ErrAttr(err))
+				dR["message"] = "failed create client"
+				resp.Error(w, r, dR)
+				return
+			}
 		}
 
 		err = s.client.CreateClient(oauthClient)
@@ -146,12 +338,336 @@ func (s *Storage) CreateClient() http.HandlerFunc {
 			return
 		}
 
-		var dRS = &Response{
-			ID:       oauthClient.ID,
-			Name:     oauthClient.Name,
-			Redirect: oauthClient.Redirect,
+		var dRS = &CreateResponse{
+			Response: &Response{
+				ID:                      oauthClient.ID,
+				Name:                    oauthClient.Name,
+				RedirectURIs:            oauthClient.RedirectURIs,
+				TokenEndpointAuthMethod: oauthClient.TokenEndpointAuthMethod,
+			},
+			Secret: secret,
 		}
 		resp.Ok(w, r, dRS)
 		return
 	}
 }
+
+func (s *Storage) UpdateClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client.UpdateClient"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("update client")
+
+		var dR = map[string]string{}
+
+		oauthClient, ok := s.findClient(w, r)
+		if !ok {
+			return
+		}
+
+		var req UpdateRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			logging.L(s.ctx).Error("request body is empty")
+			dR["message"] = "empty request"
+			render.Status(r, http.StatusBadRequest)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			render.Status(r, http.StatusBadRequest)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if req.Name != nil {
+			oauthClient.Name = *req.Name
+		}
+		if req.RedirectURIs != nil {
+			if err := oauthClient.SetRedirectURIs(req.RedirectURIs, s.cfg.IsLocal()); err != nil {
+				logging.L(s.ctx).Error("invalid redirect uris", logging.ErrAttr(err))
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+		if req.UserId != nil {
+			oauthClient.UserId = req.UserId
+		}
+		if req.PersonalAccessClient != nil {
+			oauthClient.PersonalAccessClient = *req.PersonalAccessClient
+		}
+		if req.PasswordClient != nil {
+			oauthClient.PasswordClient = *req.PasswordClient
+		}
+		if req.GrantTypes != nil {
+			if err := oauthClient.SetGrantTypes(req.GrantTypes); err != nil {
+				logging.L(s.ctx).Error("invalid grant types", logging.ErrAttr(err))
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+		if req.Scopes != nil {
+			if err := oauthClient.SetScopes(req.Scopes); err != nil {
+				logging.L(s.ctx).Error("invalid scopes", logging.ErrAttr(err))
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+		if req.RequirePushedAuthorizationRequests != nil {
+			oauthClient.RequirePushedAuthorizationRequests = *req.RequirePushedAuthorizationRequests
+		}
+		if req.TLSClientAuth != nil {
+			oauthClient.TLSClientAuth = req.TLSClientAuth
+		}
+		if req.TokenEndpointAuthMethod != nil || len(req.JWKS) > 0 || req.TLSClientAuth != nil {
+			method := oauthClient.TokenEndpointAuthMethod
+			if req.TokenEndpointAuthMethod != nil {
+				method = *req.TokenEndpointAuthMethod
+			}
+			if err := oauthClient.SetAuthMethod(method, rawJWKS(req.JWKS)); err != nil {
+				logging.L(s.ctx).Error("invalid auth method", logging.ErrAttr(err))
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+		if req.TokenExchange != nil {
+			oauthClient.SetTokenExchange(req.TokenExchange)
+		}
+		// the update query leaves the secrets alone, a client that no
+		// longer authenticates with a secret has them dropped separately
+		dropSecret := !oauthClient.UsesSecret() && oauthClient.DropSecret()
+		oauthClient.UpdatedAt = time.Now().Unix()
+
+		if err := s.client.UpdateClient(&oauthClient); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				dR["message"] = "client already exists"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed update client"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if dropSecret {
+			if err := s.client.UpdateSecret(&oauthClient); err != nil {
+				logging.L(s.ctx).Error("failed drop client secret", logging.ErrAttr(err))
+				dR["message"] = "failed update client"
+				resp.Error(w, r, dR)
+				return
+			}
+		}
+
+		resp.Ok(w, r, newResponse(oauthClient))
+	}
+}
+
+func (s *Storage) RevokeClient() http.HandlerFunc {
+	return s.setRevoked("http-server.handlers.client.RevokeClient", true)
+}
+
+func (s *Storage) RestoreClient() http.HandlerFunc {
+	return s.setRevoked("http-server.handlers.client.RestoreClient", false)
+}
+
+func (s *Storage) setRevoked(op string, revoked bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("change client revoked status")
+
+		var dR = map[string]string{}
+
+		oauthClient, ok := s.findClient(w, r)
+		if !ok {
+			return
+		}
+
+		oauthClient.Revoked = revoked
+		oauthClient.UpdatedAt = time.Now().Unix()
+
+		if err := s.client.UpdateRevoked(oauthClient.ID, oauthClient.Revoked, oauthClient.UpdatedAt); err != nil {
+			dR["message"] = "failed update client"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, newResponse(oauthClient))
+	}
+}
+
+func (s *Storage) DeleteClient() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client.DeleteClient"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("delete client")
+
+		var dR = map[string]string{}
+
+		var req = IDRequest{ID: chi.URLParam(r, "id")}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := s.client.DeleteClient(req.ID); err != nil {
+			if storage.IsNotFound(err) {
+				dR["message"] = "client not found"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed delete client"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, nil)
+	}
+}
+
+func (s *Storage) RotateSecret() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.client.RotateSecret"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("rotate client secret")
+
+		var dR = map[string]string{}
+
+		oauthClient, ok := s.findClient(w, r)
+		if !ok {
+			return
+		}
+
+		secret := crypt.GetSecret()
+		secretHash, err := crypt.HashSecret(secret)
+		if err != nil {
+			logging.L(s.ctx).Error("failed hash client secret", logging.ErrAttr(err))
+			dR["message"] = "failed rotate client secret"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		oauthClient.RotateSecret(secretHash, time.Now(), s.cfg.Client.SecretGracePeriod)
+
+		if err := s.client.UpdateSecret(&oauthClient); err != nil {
+			dR["message"] = "failed rotate client secret"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, &SecretResponse{
+			ID:                      oauthClient.ID,
+			Secret:                  secret,
+			PreviousSecretExpiresAt: oauthClient.PreviousSecretExpiresAt,
+		})
+	}
+}
+
+// findClient loads the client addressed by the {id} URL parameter and
+// writes the error response itself when it can't.
+func (s *Storage) findClient(w http.ResponseWriter, r *http.Request) (client.Client, bool) {
+	var dR = map[string]string{}
+	var req = IDRequest{ID: chi.URLParam(r, "id")}
+
+	if err := validator.New().Struct(req); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+		dR := resp.ValidationError(validateErr)
+		resp.Error(w, r, dR)
+		return client.Client{}, false
+	}
+
+	oauthClient, err := s.client.GetClient(req.ID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			dR["message"] = "client not found"
+		} else {
+			dR["message"] = "failed get client"
+		}
+		resp.Error(w, r, dR)
+		return client.Client{}, false
+	}
+
+	return oauthClient, true
+}
+
+func listRequest(r *http.Request) (ListRequest, error) {
+	var req = ListRequest{Page: 1, Limit: defaultListLimit}
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil {
+			return req, err
+		}
+		req.Page = value
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return req, err
+		}
+		req.Limit = min(value, maxListLimit)
+	}
+
+	return req, nil
+}
+
+func newResponse(c client.Client) *Response {
+	return &Response{
+		ID:                                 c.ID,
+		Name:                               c.Name,
+		RedirectURIs:                       c.RedirectURIs,
+		UserId:                             c.UserId,
+		Provider:                           c.Provider,
+		PersonalAccessClient:               c.PersonalAccessClient,
+		PasswordClient:                     c.PasswordClient,
+		Revoked:                            c.Revoked,
+		TokenEndpointAuthMethod:            c.TokenEndpointAuthMethod,
+		TokenExchange:                      c.TokenExchange,
+		GrantTypes:                         c.GrantTypes,
+		Scopes:                             c.Scopes,
+		RequirePushedAuthorizationRequests: c.RequirePushedAuthorizationRequests,
+		TLSClientAuth:                      c.TLSClientAuth,
+		CreatedAt:                          c.CreatedAt,
+		UpdatedAt:                          c.UpdatedAt,
+	}
+}
+
+func rawJWKS(data json.RawMessage) *string {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	jwks := string(data)
+	return &jwks
+}
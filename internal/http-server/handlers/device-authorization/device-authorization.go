@@ -0,0 +1,130 @@
+package device_authorization
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// userCodeAttempts bounds the retries on a user code collision.
+const userCodeAttempts = 3
+
+type DeviceCode interface {
+	CreateDeviceCode(dC *deviceCodeDomain.DeviceCode) error
+}
+
+type Request struct {
+	Scope string `json:"scope" form:"scope"`
+}
+
+// Response is the device authorization response (RFC 8628, section 3.2).
+type Response struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+func New(
+	ctx context.Context,
+	deviceCode DeviceCode,
+	cfg *config.Config,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.device-authorization.New"
+
+		logging.L(ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("device authorization")
+
+		c, ok := clientauth.FromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+
+		if !c.AllowsGrant(client.GrantTypeDeviceCode) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", "client is not allowed to use the device code grant")
+			return
+		}
+
+		var req Request
+		if err := render.Decode(r, &req); err != nil {
+			logging.L(ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "failed to decode request")
+			return
+		}
+
+		// the scope is granted as requested once the user approves, so it
+		// must be registered for the client (RFC 8628, section 3.1)
+		if _, err := resourceDomain.NewTarget(nil, req.Scope, c.Scopes, cfg.Token.TTL); err != nil {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+			return
+		}
+
+		now := time.Now()
+		deviceCodeStr := crypt.GetSecret()
+		dC := &deviceCodeDomain.DeviceCode{
+			ID:        crypt.GetSHA256Hash(deviceCodeStr),
+			ClientId:  c.ID,
+			Scopes:    strings.Join(strings.Fields(req.Scope), " "),
+			Status:    deviceCodeDomain.StatusPending,
+			Interval:  int64(cfg.Device.Interval.Seconds()),
+			ExpiresAt: now.Add(cfg.Device.TTL).Unix(),
+			CreatedAt: now.Unix(),
+		}
+
+		var err error
+		for attempt := 0; attempt < userCodeAttempts; attempt++ {
+			if dC.UserCode, err = deviceCodeDomain.NewUserCode(); err != nil {
+				break
+			}
+			err = deviceCode.CreateDeviceCode(dC)
+			if storage.ErrorCode(err) != storage.ErrCodeExists {
+				break
+			}
+		}
+
+		if err != nil {
+			logging.L(ctx).Error("failed create device code", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create device code")
+			return
+		}
+
+		userCode := deviceCodeDomain.FormatUserCode(dC.UserCode)
+		verificationURI := verificationPage(cfg)
+
+		render.JSON(w, r, &Response{
+			DeviceCode:              deviceCodeStr,
+			UserCode:                userCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+			ExpiresIn:               int64(cfg.Device.TTL.Seconds()),
+			Interval:                dC.Interval,
+		})
+	}
+}
+
+// verificationPage is the page where the user enters the user code.
+func verificationPage(cfg *config.Config) string {
+	if cfg.Device.VerificationURI != "" {
+		return cfg.Device.VerificationURI
+	}
+	return strings.TrimRight(cfg.Issuer, "/") + "/oauth/device"
+}
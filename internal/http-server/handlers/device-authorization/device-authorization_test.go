@@ -0,0 +1,83 @@
+package device_authorization_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	deviceAuthorization "app/internal/http-server/handlers/device-authorization"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type deviceCodes []deviceCodeDomain.DeviceCode
+
+func (d *deviceCodes) CreateDeviceCode(dC *deviceCodeDomain.DeviceCode) error {
+	*d = append(*d, *dC)
+	return nil
+}
+
+func TestDeviceAuthorization_Scope(t *testing.T) {
+	cfg := &config.Config{Issuer: "https://sso.test"}
+	cfg.Device.TTL = 10 * time.Minute
+	cfg.Device.Interval = 5 * time.Second
+
+	tv := client.Client{
+		ID:         "tv",
+		GrantTypes: []string{client.GrantTypeDeviceCode},
+		Scopes:     []string{"profile", "videos"},
+	}
+
+	tests := []struct {
+		name      string
+		scope     string
+		wantScope string
+		wantCode  string
+	}{
+		{name: "registered scopes", scope: "videos  profile", wantScope: "videos profile"},
+		{name: "no scope"},
+		{name: "unregistered scope", scope: "videos admin", wantCode: "invalid_scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored deviceCodes
+
+			form := url.Values{"scope": {tt.scope}}
+			r := httptest.NewRequest(http.MethodPost, "/oauth/device_authorization", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r = r.WithContext(clientauth.ContextWithClient(r.Context(), tv))
+			w := httptest.NewRecorder()
+
+			deviceAuthorization.New(context.Background(), &stored, cfg).ServeHTTP(w, r)
+
+			if tt.wantCode != "" {
+				var res resp.OAuthErrorResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if w.Code != http.StatusBadRequest || res.Error != tt.wantCode {
+					t.Fatalf("status = %d, error = %q, want %q", w.Code, res.Error, tt.wantCode)
+				}
+				if len(stored) != 0 {
+					t.Fatal("device code stored for a refused request")
+				}
+				return
+			}
+
+			if w.Code != http.StatusOK || len(stored) != 1 {
+				t.Fatalf("status = %d, %d device codes stored", w.Code, len(stored))
+			}
+			if stored[0].Scopes != tt.wantScope {
+				t.Fatalf("scopes = %q, want %q", stored[0].Scopes, tt.wantScope)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package device
+
+import (
+	"app/internal/domain/client"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"time"
+)
+
+const (
+	ActionApprove = "approve"
+	ActionDeny    = "deny"
+)
+
+type DeviceCode interface {
+	GetDeviceCodeByUserCode(userCode string) (deviceCodeDomain.DeviceCode, error)
+	UpdateStatus(dC *deviceCodeDomain.DeviceCode) error
+}
+
+type Client interface {
+	GetClient(ID string) (client.Client, error)
+}
+
+type Device struct {
+	ctx        context.Context
+	deviceCode DeviceCode
+	client     Client
+}
+
+func New(ctx context.Context, deviceCode DeviceCode, client Client) *Device {
+	return &Device{
+		ctx:        ctx,
+		deviceCode: deviceCode,
+		client:     client,
+	}
+}
+
+type VerifyRequest struct {
+	UserCode string `json:"user_code" form:"user_code" validate:"required"`
+	Action   string `json:"action" form:"action" validate:"required,oneof=approve deny"`
+}
+
+// Response describes the pending authorization so that the user can check
+// which client asks for access before approving it.
+type Response struct {
+	UserCode   string `json:"userCode"`
+	ClientID   string `json:"clientId"`
+	ClientName string `json:"clientName"`
+	Status     string `json:"status"`
+	ExpiresAt  int64  `json:"expiresAt"`
+}
+
+func (d *Device) GetDeviceCode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.device.GetDeviceCode"
+
+		logging.L(d.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("get device code")
+
+		dC, c, ok := d.findDeviceCode(w, r, r.URL.Query().Get("user_code"))
+		if !ok {
+			return
+		}
+
+		resp.Ok(w, r, newResponse(dC, c))
+	}
+}
+
+// Verify records the decision of the authenticated user for a user code.
+func (d *Device) Verify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.device.Verify"
+
+		logging.L(d.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("verify device code")
+
+		var dR = map[string]string{}
+		var req VerifyRequest
+
+		if err := render.Decode(r, &req); err != nil {
+			logging.L(d.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(d.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		if !ok {
+			dR["message"] = "unauthorized"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		dC, c, ok := d.findDeviceCode(w, r, req.UserCode)
+		if !ok {
+			return
+		}
+
+		if dC.Status != deviceCodeDomain.StatusPending {
+			dR["message"] = "user code already used"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		dC.UserId = &aT.UserId
+		if claims, ok := token.ClaimsFromContext(r.Context()); ok {
+			dC.AuthTime = claims.AuthTime
+		}
+		dC.Status = deviceCodeDomain.StatusApproved
+		if req.Action == ActionDeny {
+			dC.Status = deviceCodeDomain.StatusDenied
+		}
+
+		if err := d.deviceCode.UpdateStatus(&dC); err != nil {
+			if storage.IsNotFound(err) {
+				dR["message"] = "user code already used"
+			} else {
+				logging.L(d.ctx).Error("failed update device code", logging.ErrAttr(err))
+				dR["message"] = "failed verify user code"
+			}
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, newResponse(dC, c))
+	}
+}
+
+// findDeviceCode loads the live device code for a user code and writes the
+// error response itself when it can't.
+func (d *Device) findDeviceCode(
+	w http.ResponseWriter,
+	r *http.Request,
+	userCode string,
+) (deviceCodeDomain.DeviceCode, client.Client, bool) {
+	var dR = map[string]string{}
+
+	userCode = deviceCodeDomain.NormalizeUserCode(userCode)
+	if userCode == "" {
+		dR["message"] = "user code is required"
+		resp.Error(w, r, dR)
+		return deviceCodeDomain.DeviceCode{}, client.Client{}, false
+	}
+
+	dC, err := d.deviceCode.GetDeviceCodeByUserCode(userCode)
+	if err != nil || dC.Expired(time.Now()) {
+		dR["message"] = "invalid or expired user code"
+		resp.Error(w, r, dR)
+		return deviceCodeDomain.DeviceCode{}, client.Client{}, false
+	}
+
+	c, err := d.client.GetClient(dC.ClientId)
+	if err != nil || c.Revoked {
+		dR["message"] = "invalid or expired user code"
+		resp.Error(w, r, dR)
+		return deviceCodeDomain.DeviceCode{}, client.Client{}, false
+	}
+
+	return dC, c, true
+}
+
+func newResponse(dC deviceCodeDomain.DeviceCode, c client.Client) *Response {
+	return &Response{
+		UserCode:   deviceCodeDomain.FormatUserCode(dC.UserCode),
+		ClientID:   c.ID,
+		ClientName: c.Name,
+		Status:     dC.Status,
+		ExpiresAt:  dC.ExpiresAt,
+	}
+}
@@ -0,0 +1,371 @@
+package federation
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	federationDomain "app/internal/domain/federation"
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	"app/internal/domain/user"
+	authorizeHTTP "app/internal/http-server/handlers/authorize"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/core/oidc"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errEmailNotVerified = errors.New("the identity provider did not verify the email")
+
+type Client interface {
+	GetClient(ID string) (client.Client, error)
+}
+
+type User interface {
+	GetUser(ID int64) (user.User, error)
+	GetUserByEmail(email string) (user.User, error)
+	Registration(req *user.CreateUser) error
+}
+
+type State interface {
+	CreateState(st *federationDomain.State) error
+	GetState(ID string) (federationDomain.State, error)
+	DeleteState(ID string) error
+}
+
+type Identity interface {
+	CreateIdentity(i *federationDomain.Identity) error
+	GetIdentity(provider, subject string) (federationDomain.Identity, error)
+	GetIdentities(userID int64) ([]federationDomain.Identity, error)
+	DeleteIdentity(ID string, userID int64) (federationDomain.Identity, error)
+}
+
+type Publisher interface {
+	PublishMsg(exchangeName, routingKey string, msg []byte)
+}
+
+// Federation signs users in with the upstream OpenID Connect providers of
+// the configuration for the authorization code grant, and manages the
+// identities linked to a user.
+type Federation struct {
+	ctx               context.Context
+	providers         map[string]*oidc.Provider
+	client            Client
+	user              User
+	state             State
+	identity          Identity
+	resources         authorizeHTTP.Resource
+	authorizationCode authorizeHTTP.AuthorizationCode
+	publisher         Publisher
+	cfg               *config.Config
+}
+
+func New(
+	ctx context.Context,
+	client Client,
+	user User,
+	state State,
+	identity Identity,
+	resources authorizeHTTP.Resource,
+	authorizationCode authorizeHTTP.AuthorizationCode,
+	publisher Publisher,
+	cfg *config.Config,
+) *Federation {
+	providers := make(map[string]*oidc.Provider, len(cfg.Federation.Providers))
+	for _, p := range cfg.Federation.Providers {
+		providers[p.Name] = oidc.New(p.OIDC(cfg.Issuer))
+	}
+
+	return &Federation{
+		ctx:               ctx,
+		providers:         providers,
+		client:            client,
+		user:              user,
+		state:             state,
+		identity:          identity,
+		resources:         resources,
+		authorizationCode: authorizationCode,
+		publisher:         publisher,
+		cfg:               cfg,
+	}
+}
+
+// Authorize starts a sign in with the {provider} for the authorization
+// request of the parameters (RFC 6749, section 4.1.1) and sends the
+// user-agent to the provider. The request is checked like the ones of the
+// authorization endpoint, the sign in ends with a code sent to the
+// redirect URI of the client.
+func (f *Federation) Authorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.federation.Authorize"
+
+		logging.L(f.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("federated sign in")
+
+		name := chi.URLParam(r, "provider")
+		provider, ok := f.providers[name]
+		if !ok {
+			resp.OAuthError(w, r, http.StatusNotFound, "invalid_request", "unknown identity provider")
+			return
+		}
+
+		query := r.URL.Query()
+
+		c, err := f.client.GetClient(query.Get("client_id"))
+		if err != nil || c.Revoked {
+			if err != nil && !storage.IsNotFound(err) {
+				logging.L(f.ctx).Error("failed get client", logging.ErrAttr(err))
+			}
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "unknown client")
+			return
+		}
+
+		params := authorizationRequestDomain.ParamsFromValues(query)
+		redirectURI, err := params.Validate(c)
+		if err != nil {
+			authErr := &authorizationRequestDomain.Error{Code: "invalid_request", Description: err.Error()}
+			errors.As(err, &authErr)
+
+			if redirectURI == "" {
+				resp.OAuthError(w, r, http.StatusBadRequest, authErr.Code, authErr.Description)
+				return
+			}
+
+			authorizeHTTP.Redirect(w, r, redirectURI, url.Values{
+				"error":             {authErr.Code},
+				"error_description": {authErr.Description},
+				"state":             {params.State},
+				"iss":               {f.cfg.Issuer},
+			})
+			return
+		}
+
+		now := time.Now()
+		state := crypt.GetSecret()
+		st := &federationDomain.State{
+			ID:           crypt.GetSHA256Hash(state),
+			Provider:     name,
+			ClientId:     c.ID,
+			Params:       &params,
+			Nonce:        crypt.GetSecret(),
+			CodeVerifier: oidc.NewCodeVerifier(),
+			ExpiresAt:    now.Add(f.cfg.Federation.StateTTL).Unix(),
+			CreatedAt:    now.Unix(),
+		}
+
+		authURL, err := provider.AuthCodeURL(r.Context(), state, st.Nonce, st.CodeVerifier)
+		if err != nil {
+			logging.L(f.ctx).Error("failed discover identity provider", logging.StringAttr("provider", name), logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadGateway, "temporarily_unavailable", "identity provider is unavailable")
+			return
+		}
+
+		if err := f.state.CreateState(st); err != nil {
+			logging.L(f.ctx).Error("failed create federation state", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed start sign in")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// Callback completes a sign in when the {provider} redirects back: the
+// code is exchanged, the ID token validated and the local user found,
+// linked by verified email or provisioned. The user-agent is sent back to
+// the client with an authorization code, the client redeems it at the
+// token endpoint. A state started by Link links the identity to the user
+// instead.
+func (f *Federation) Callback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.federation.Callback"
+
+		logging.L(f.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("federated sign in callback")
+
+		name := chi.URLParam(r, "provider")
+		provider, ok := f.providers[name]
+		if !ok {
+			resp.OAuthError(w, r, http.StatusNotFound, "invalid_request", "unknown identity provider")
+			return
+		}
+
+		query := r.URL.Query()
+
+		st, ok := f.consumeState(query.Get("state"), name)
+		if !ok {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "invalid or expired state")
+			return
+		}
+
+		if errCode := query.Get("error"); errCode != "" {
+			logging.L(f.ctx).Info("identity provider denied the sign in", logging.StringAttr("error", errCode))
+			resp.OAuthError(w, r, http.StatusForbidden, "access_denied", "the identity provider denied the sign in")
+			return
+		}
+
+		tokens, err := provider.Exchange(r.Context(), query.Get("code"), st.CodeVerifier)
+		if err != nil {
+			logging.L(f.ctx).Error("failed exchange code", logging.StringAttr("provider", name), logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadGateway, "server_error", "identity provider sign in failed")
+			return
+		}
+
+		claims, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, st.Nonce)
+		if err != nil {
+			logging.L(f.ctx).Error("invalid id token", logging.StringAttr("provider", name), logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadGateway, "server_error", "identity provider sign in failed")
+			return
+		}
+
+		if st.UserId != nil {
+			f.link(w, r, name, claims, *st.UserId)
+			return
+		}
+
+		u, err := f.provision(name, claims)
+		if err != nil {
+			if errors.Is(err, errEmailNotVerified) {
+				resp.OAuthError(w, r, http.StatusForbidden, "access_denied", err.Error())
+				return
+			}
+			// deactivated users are not returned
+			if storage.IsNotFound(err) {
+				resp.OAuthError(w, r, http.StatusForbidden, "access_denied", "the user is deactivated")
+				return
+			}
+			logging.L(f.ctx).Error("failed provision user", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed sign in")
+			return
+		}
+
+		// the client is checked again, it may have changed since the sign
+		// in started
+		c, err := f.client.GetClient(st.ClientId)
+		if err != nil || c.Revoked || st.Params == nil {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "unknown client")
+			return
+		}
+
+		redirectURI, err := st.Params.Validate(c)
+		if err != nil {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "invalid authorization request")
+			return
+		}
+
+		// the user has just authenticated with the provider
+		authorizeHTTP.Issue(f.ctx, w, r, f.resources, f.authorizationCode, c, u.ID, time.Now().Unix(), *st.Params, redirectURI, f.cfg)
+	}
+}
+
+// consumeState resolves the state of the provider, a state completes one
+// sign in, it is deleted when it is resolved.
+func (f *Federation) consumeState(state, provider string) (federationDomain.State, bool) {
+	if state == "" {
+		return federationDomain.State{}, false
+	}
+
+	st, err := f.state.GetState(crypt.GetSHA256Hash(state))
+	if err != nil || st.Provider != provider {
+		return federationDomain.State{}, false
+	}
+
+	if err := f.state.DeleteState(st.ID); err != nil {
+		if !storage.IsNotFound(err) {
+			logging.L(f.ctx).Error("failed delete federation state", logging.ErrAttr(err))
+		}
+		return federationDomain.State{}, false
+	}
+
+	return st, !st.Expired(time.Now())
+}
+
+// provision returns the local user of the upstream subject. A subject
+// signing in for the first time is linked to the user with the same
+// email, or a user is created for it, only when the provider verified
+// the email.
+func (f *Federation) provision(provider string, claims *oidc.Claims) (user.User, error) {
+	i, err := f.identity.GetIdentity(provider, claims.Subject)
+	if err == nil {
+		return f.user.GetUser(i.UserId)
+	}
+	if !storage.IsNotFound(err) {
+		return user.User{}, err
+	}
+
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		return user.User{}, errEmailNotVerified
+	}
+	email := strings.ToLower(claims.Email)
+
+	now := time.Now().Unix()
+
+	u, err := f.user.GetUserByEmail(email)
+	if storage.IsNotFound(err) {
+		u, err = f.createUser(email, claims.Name, now)
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	i = federationDomain.Identity{
+		ID:        identity.UUIDv7(),
+		UserId:    u.ID,
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = f.identity.CreateIdentity(&i)
+	// a concurrent sign in of the same subject linked it first
+	if err != nil && storage.ErrorCode(err) != storage.ErrCodeExists {
+		return user.User{}, err
+	}
+	if err == nil {
+		f.publish(federationDomain.EventIdentityLinked, i)
+	}
+
+	logging.L(f.ctx).Info("linked federated identity",
+		logging.StringAttr("provider", provider),
+		logging.StringAttr("user", u.UUID),
+	)
+
+	return u, nil
+}
+
+// createUser provisions a user just in time. The user has no password and
+// signs in through the provider, so the identity is its only login
+// method.
+func (f *Federation) createUser(email, name string, now int64) (user.User, error) {
+	if name == "" {
+		name = email
+	}
+
+	err := f.user.Registration(&user.CreateUser{
+		UUID:            identity.UUIDv7(),
+		Name:            name,
+		Email:           email,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	if err != nil && storage.ErrorCode(err) != storage.ErrCodeExists {
+		return user.User{}, err
+	}
+
+	return f.user.GetUserByEmail(email)
+}
@@ -0,0 +1,530 @@
+package federation_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	federationDomain "app/internal/domain/federation"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/domain/user"
+	"app/internal/http-server/handlers/federation"
+	"app/pkg/common/core/oidc/oidctest"
+	"app/pkg/common/core/token"
+	"app/pkg/utils/crypt"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const redirectURI = "https://web.example.com/callback"
+
+type fakeStore struct {
+	mu         sync.Mutex
+	clients    map[string]client.Client
+	users      []user.User
+	states     map[string]federationDomain.State
+	identities []federationDomain.Identity
+	codes      []authorizationCodeDomain.AuthorizationCode
+	events     []federationDomain.Event
+}
+
+func (s *fakeStore) GetClient(ID string) (client.Client, error) {
+	c, ok := s.clients[ID]
+	if !ok {
+		return client.Client{}, pgx.ErrNoRows
+	}
+	return c, nil
+}
+
+func (s *fakeStore) GetUser(ID int64) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID == ID {
+			return u, nil
+		}
+	}
+	return user.User{}, pgx.ErrNoRows
+}
+
+func (s *fakeStore) GetUserByEmail(email string) (user.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return user.User{}, pgx.ErrNoRows
+}
+
+func (s *fakeStore) Registration(req *user.CreateUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = append(s.users, user.User{
+		ID:              int64(len(s.users) + 1),
+		UUID:            req.UUID,
+		Name:            req.Name,
+		Email:           req.Email,
+		EmailVerifiedAt: req.EmailVerifiedAt,
+		Password:        req.Password,
+	})
+	return nil
+}
+
+func (s *fakeStore) CreateState(st *federationDomain.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[st.ID] = *st
+	return nil
+}
+
+func (s *fakeStore) GetState(ID string) (federationDomain.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.states[ID]
+	if !ok {
+		return st, pgx.ErrNoRows
+	}
+	return st, nil
+}
+
+func (s *fakeStore) DeleteState(ID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.states[ID]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(s.states, ID)
+	return nil
+}
+
+func (s *fakeStore) CreateIdentity(i *federationDomain.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.identities = append(s.identities, *i)
+	return nil
+}
+
+func (s *fakeStore) GetIdentity(provider, subject string) (federationDomain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, i := range s.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return federationDomain.Identity{}, pgx.ErrNoRows
+}
+
+func (s *fakeStore) GetIdentities(userID int64) ([]federationDomain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identities := make([]federationDomain.Identity, 0)
+	for _, i := range s.identities {
+		if i.UserId == userID {
+			identities = append(identities, i)
+		}
+	}
+	return identities, nil
+}
+
+func (s *fakeStore) DeleteIdentity(ID string, userID int64) (federationDomain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, remaining := -1, 0
+	for k, i := range s.identities {
+		if i.UserId != userID {
+			continue
+		}
+		if i.ID == ID {
+			found = k
+		} else {
+			remaining++
+		}
+	}
+	if found < 0 {
+		return federationDomain.Identity{}, pgx.ErrNoRows
+	}
+
+	for _, u := range s.users {
+		if u.ID == userID && u.Password == "" && remaining == 0 {
+			return federationDomain.Identity{}, federationDomain.ErrLastLoginMethod
+		}
+	}
+
+	i := s.identities[found]
+	s.identities = append(s.identities[:found], s.identities[found+1:]...)
+	return i, nil
+}
+
+func (s *fakeStore) PublishMsg(_, _ string, msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var event federationDomain.Event
+	_ = json.Unmarshal(msg, &event)
+	s.events = append(s.events, event)
+}
+
+func (s *fakeStore) CreateAuthorizationCode(aC *authorizationCodeDomain.AuthorizationCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.codes = append(s.codes, *aC)
+	return nil
+}
+
+func (s *fakeStore) GetResourcesByIdentifiers([]string) ([]resourceDomain.Resource, error) {
+	return []resourceDomain.Resource{}, nil
+}
+
+type env struct {
+	upstream *oidctest.Provider
+	server   *httptest.Server
+	store    *fakeStore
+	http     *http.Client
+	// session is the access token of the identity endpoints, claims are
+	// its claims
+	session accessTokenDomain.AccessToken
+	claims  *token.UserClaim
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	e := &env{
+		upstream: oidctest.NewProvider(t),
+		store: &fakeStore{
+			clients: map[string]client.Client{
+				"web": {
+					ID:           "web",
+					Secret:       "secret",
+					RedirectURIs: []string{redirectURI},
+					GrantTypes:   []string{client.GrantTypeAuthorizationCode, client.GrantTypeRefreshToken},
+				},
+			},
+			states: map[string]federationDomain.State{},
+		},
+		http: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+
+	var router http.Handler
+	e.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(e.server.Close)
+
+	cfg := &config.Config{
+		Issuer:        e.server.URL,
+		Authorization: config.Authorization{CodeTTL: time.Minute},
+		Federation: config.Federation{
+			StateTTL:   time.Minute,
+			LinkMaxAge: time.Minute,
+			Providers: []config.FederationProvider{{
+				Name:         "corporate",
+				Issuer:       e.upstream.Issuer(),
+				ClientID:     oidctest.ClientID,
+				ClientSecret: oidctest.ClientSecret,
+			}},
+		},
+	}
+
+	handler := federation.New(context.Background(), e.store, e.store, e.store, e.store, e.store, e.store, e.store, cfg)
+
+	r := chi.NewRouter()
+	r.Get("/oauth/federation/{provider}", handler.Authorize())
+	r.Get("/oauth/federation/{provider}/callback", handler.Callback())
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := token.ContextWithAccessToken(r.Context(), e.session)
+				next.ServeHTTP(w, r.WithContext(token.ContextWithClaims(ctx, e.claims)))
+			})
+		})
+		r.Get("/oauth/identities", handler.Identities())
+		r.Post("/oauth/identities/{provider}/link", handler.Link())
+		r.Delete("/oauth/identities/{id}", handler.Unlink())
+	})
+	router = r
+
+	return e
+}
+
+// authorizeQuery is the authorization request the sign ins start with.
+var authorizeQuery = url.Values{
+	"response_type": {"code"},
+	"client_id":     {"web"},
+	"redirect_uri":  {redirectURI},
+	"state":         {"client-state"},
+}.Encode()
+
+// signIn runs the sign in through the fake provider and returns the
+// response of the callback.
+func (e *env) signIn(t *testing.T, subject oidctest.Subject) *http.Response {
+	t.Helper()
+
+	res, err := e.http.Get(e.server.URL + "/oauth/federation/corporate?" + authorizeQuery)
+	if err != nil {
+		t.Fatalf("start sign in: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("start sign in: status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	callback := e.upstream.Authorize(res.Header.Get("Location"), subject)
+
+	return e.get(t, callback.String())
+}
+
+func (e *env) get(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	res, err := e.http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	res.Body.Close()
+
+	return res
+}
+
+// code returns the authorization code the response redirected the client
+// with.
+func (e *env) code(t *testing.T, res *http.Response) authorizationCodeDomain.AuthorizationCode {
+	t.Helper()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse location: %v", err)
+	}
+	query := location.Query()
+	if location.Scheme+"://"+location.Host+location.Path != redirectURI || query.Get("state") != "client-state" {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+
+	for _, aC := range e.store.codes {
+		if aC.ID == crypt.GetSHA256Hash(query.Get("code")) {
+			return aC
+		}
+	}
+	t.Fatalf("unknown code in %s", location)
+	return authorizationCodeDomain.AuthorizationCode{}
+}
+
+func TestFederation_ProvisionsUser(t *testing.T) {
+	e := newEnv(t)
+
+	aC := e.code(t, e.signIn(t, oidctest.Subject{Sub: "u-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}))
+
+	if len(e.store.users) != 1 || e.store.users[0].Email != "alice@example.com" || e.store.users[0].Name != "Alice" {
+		t.Fatalf("unexpected users %+v", e.store.users)
+	}
+	if aC.UserId != e.store.users[0].ID || aC.ClientId != "web" || aC.RedirectURI != redirectURI {
+		t.Fatalf("unexpected authorization code %+v", aC)
+	}
+
+	// the subject signs in again with another email, it stays the same user
+	e.code(t, e.signIn(t, oidctest.Subject{Sub: "u-1", Email: "alice@corp.example.com", EmailVerified: true}))
+	if len(e.store.users) != 1 || len(e.store.identities) != 1 || len(e.store.codes) != 2 {
+		t.Fatalf("users = %d, identities = %d, codes = %d, want 1, 1, 2", len(e.store.users), len(e.store.identities), len(e.store.codes))
+	}
+}
+
+func TestFederation_RejectsUnregisteredRedirectURI(t *testing.T) {
+	e := newEnv(t)
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"web"},
+		"redirect_uri":  {"https://attacker.example.com/callback"},
+	}
+	if res := e.get(t, e.server.URL+"/oauth/federation/corporate?"+query.Encode()); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+	if len(e.store.states) != 0 {
+		t.Fatalf("states = %d, want none", len(e.store.states))
+	}
+}
+
+func TestFederation_LinksUserByVerifiedEmail(t *testing.T) {
+	e := newEnv(t)
+	e.store.users = []user.User{{ID: 7, UUID: "existing", Email: "bob@example.com"}}
+
+	aC := e.code(t, e.signIn(t, oidctest.Subject{Sub: "u-2", Email: "Bob@Example.com", EmailVerified: true}))
+	if aC.UserId != 7 {
+		t.Fatalf("authorization code of user %d, want the existing user", aC.UserId)
+	}
+	if len(e.store.users) != 1 || len(e.store.identities) != 1 || e.store.identities[0].UserId != 7 {
+		t.Fatalf("unexpected link, users %+v identities %+v", e.store.users, e.store.identities)
+	}
+}
+
+func TestFederation_RejectsUnverifiedEmail(t *testing.T) {
+	e := newEnv(t)
+	e.store.users = []user.User{{ID: 7, UUID: "existing", Email: "bob@example.com"}}
+
+	res := e.signIn(t, oidctest.Subject{Sub: "u-3", Email: "bob@example.com"})
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+	if len(e.store.identities) != 0 || len(e.store.codes) != 0 {
+		t.Fatalf("identities = %d, codes = %d, want none", len(e.store.identities), len(e.store.codes))
+	}
+}
+
+func TestFederation_StateIsUsedOnce(t *testing.T) {
+	e := newEnv(t)
+
+	res, err := e.http.Get(e.server.URL + "/oauth/federation/corporate?" + authorizeQuery)
+	if err != nil {
+		t.Fatalf("start sign in: %v", err)
+	}
+	res.Body.Close()
+
+	callback := e.upstream.Authorize(res.Header.Get("Location"), oidctest.Subject{Sub: "u-4", Email: "eve@example.com", EmailVerified: true})
+
+	e.code(t, e.get(t, callback.String()))
+	if res := e.get(t, callback.String()); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("replayed callback: status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestFederation_RejectsUnknownProviderAndClient(t *testing.T) {
+	e := newEnv(t)
+
+	if res := e.get(t, e.server.URL+"/oauth/federation/other?"+authorizeQuery); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown provider: status = %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+	if res := e.get(t, e.server.URL+"/oauth/federation/corporate?client_id=other"); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown client: status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestFederation_LinkAndUnlinkIdentities(t *testing.T) {
+	e := newEnv(t)
+
+	// a provisioned user has no password, the identity is its login method
+	e.code(t, e.signIn(t, oidctest.Subject{Sub: "u-5", Email: "carol@example.com", EmailVerified: true}))
+	first := e.store.identities[0]
+	e.session = accessTokenDomain.AccessToken{UserId: first.UserId, ClientId: "web", CreatedAt: time.Now().Unix()}
+	e.claims = &token.UserClaim{AuthTime: time.Now().Unix()}
+
+	res := e.do(t, http.MethodPost, "/oauth/identities/corporate/link")
+	var link struct {
+		Data federation.LinkResponse `json:"data"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&link)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || link.Data.AuthorizationURL == "" {
+		t.Fatalf("link: status = %d, response %+v", res.StatusCode, link)
+	}
+
+	// the subject of another user can't be linked
+	e.store.identities = append(e.store.identities, federationDomain.Identity{ID: "other", UserId: 99, Provider: "corporate", Subject: "u-9"})
+	callback := e.upstream.Authorize(link.Data.AuthorizationURL, oidctest.Subject{Sub: "u-9"})
+	if res := e.get(t, callback.String()); res.StatusCode != http.StatusConflict {
+		t.Fatalf("link of a taken subject: status = %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+
+	res = e.do(t, http.MethodPost, "/oauth/identities/corporate/link")
+	_ = json.NewDecoder(res.Body).Decode(&link)
+	res.Body.Close()
+	callback = e.upstream.Authorize(link.Data.AuthorizationURL, oidctest.Subject{Sub: "u-6"})
+	if res := e.get(t, callback.String()); res.StatusCode != http.StatusOK {
+		t.Fatalf("link: callback status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	identities, _ := e.store.GetIdentities(first.UserId)
+	if len(identities) != 2 || identities[1].Subject != "u-6" {
+		t.Fatalf("unexpected identities %+v", identities)
+	}
+
+	if res := e.do(t, http.MethodDelete, "/oauth/identities/"+first.ID); res.StatusCode != http.StatusOK {
+		t.Fatalf("unlink: status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if res := e.do(t, http.MethodDelete, "/oauth/identities/"+identities[1].ID); res.StatusCode != http.StatusConflict {
+		t.Fatalf("unlink of the last login method: status = %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+	if res := e.do(t, http.MethodDelete, "/oauth/identities/other"); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("unlink of another user's identity: status = %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	var events []string
+	for _, event := range e.store.events {
+		events = append(events, event.Event+" "+event.Subject)
+	}
+	want := []string{"identity.linked u-5", "identity.linked u-6", "identity.unlinked u-5"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestFederation_LinkRequiresRecentSignIn(t *testing.T) {
+	// the tokens are fresh, a refresh mints new ones, only the time the
+	// user authenticated tells how recent the sign in is
+	tests := []struct {
+		name   string
+		claims *token.UserClaim
+	}{
+		{name: "old sign in", claims: &token.UserClaim{AuthTime: time.Now().Add(-time.Hour).Unix()}},
+		{name: "unknown sign in time", claims: &token.UserClaim{}},
+		{name: "no claims"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+			e.session = accessTokenDomain.AccessToken{UserId: 1, ClientId: "web", CreatedAt: time.Now().Unix()}
+			e.claims = tt.claims
+
+			if res := e.do(t, http.MethodPost, "/oauth/identities/corporate/link"); res.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+			}
+			if len(e.store.states) != 0 {
+				t.Fatalf("states = %d, want none", len(e.store.states))
+			}
+		})
+	}
+}
+
+func (e *env) do(t *testing.T, method, path string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, e.server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	res, err := e.http.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
@@ -0,0 +1,255 @@
+package federation
+
+import (
+	federationDomain "app/internal/domain/federation"
+	"app/internal/queue"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/core/oidc"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"net/http"
+	"time"
+)
+
+// LinkResponse is where the user-agent continues to link an identity.
+type LinkResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
+
+// Identities lists the identities linked to the user of the access token.
+func (f *Federation) Identities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.federation.Identities"
+
+		logging.L(f.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("list identities")
+
+		var dR = map[string]string{}
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		if !ok {
+			dR["message"] = "unauthorized"
+			render.Status(r, http.StatusUnauthorized)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		identities, err := f.identity.GetIdentities(aT.UserId)
+		if err != nil {
+			logging.L(f.ctx).Error("failed get identities", logging.ErrAttr(err))
+			dR["message"] = "failed get identities"
+			render.Status(r, http.StatusInternalServerError)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, identities)
+	}
+}
+
+// Link starts linking an identity of the {provider} to the user of the
+// access token. The user must have signed in recently and authenticates
+// with the provider again, the identity is linked by the callback.
+func (f *Federation) Link() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.federation.Link"
+
+		logging.L(f.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("link identity")
+
+		var dR = map[string]string{}
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		if !ok {
+			dR["message"] = "unauthorized"
+			render.Status(r, http.StatusUnauthorized)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		name := chi.URLParam(r, "provider")
+		provider, ok := f.providers[name]
+		if !ok {
+			dR["message"] = "unknown identity provider"
+			render.Status(r, http.StatusNotFound)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		// the time the user authenticated is carried by the refreshed tokens,
+		// a token without it can't tell how recent the sign in is
+		now := time.Now()
+		claims, ok := token.ClaimsFromContext(r.Context())
+		if !ok || claims.AuthTime == 0 || now.Sub(time.Unix(claims.AuthTime, 0)) > f.cfg.Federation.LinkMaxAge {
+			dR["message"] = "sign in again to link an identity"
+			render.Status(r, http.StatusUnauthorized)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		state := crypt.GetSecret()
+		st := &federationDomain.State{
+			ID:           crypt.GetSHA256Hash(state),
+			Provider:     name,
+			ClientId:     aT.ClientId,
+			UserId:       &aT.UserId,
+			Nonce:        crypt.GetSecret(),
+			CodeVerifier: oidc.NewCodeVerifier(),
+			ExpiresAt:    now.Add(f.cfg.Federation.StateTTL).Unix(),
+			CreatedAt:    now.Unix(),
+		}
+
+		authURL, err := provider.AuthCodeURL(r.Context(), state, st.Nonce, st.CodeVerifier, oidc.WithPrompt("login"))
+		if err != nil {
+			logging.L(f.ctx).Error("failed discover identity provider", logging.StringAttr("provider", name), logging.ErrAttr(err))
+			dR["message"] = "identity provider is unavailable"
+			render.Status(r, http.StatusBadGateway)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := f.state.CreateState(st); err != nil {
+			logging.L(f.ctx).Error("failed create federation state", logging.ErrAttr(err))
+			dR["message"] = "failed link identity"
+			render.Status(r, http.StatusInternalServerError)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		resp.Ok(w, r, LinkResponse{AuthorizationURL: authURL})
+	}
+}
+
+// Unlink removes an identity of the user of the access token. The last
+// login method of a user, an identity when the user has no password,
+// can't be unlinked.
+func (f *Federation) Unlink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.federation.Unlink"
+
+		logging.L(f.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("unlink identity")
+
+		var dR = map[string]string{}
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		if !ok {
+			dR["message"] = "unauthorized"
+			render.Status(r, http.StatusUnauthorized)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		i, err := f.identity.DeleteIdentity(chi.URLParam(r, "id"), aT.UserId)
+		if err != nil {
+			switch {
+			case storage.IsNotFound(err):
+				dR["message"] = "identity not found"
+				render.Status(r, http.StatusNotFound)
+			case errors.Is(err, federationDomain.ErrLastLoginMethod):
+				dR["message"] = err.Error()
+				render.Status(r, http.StatusConflict)
+			default:
+				logging.L(f.ctx).Error("failed unlink identity", logging.ErrAttr(err))
+				dR["message"] = "failed unlink identity"
+				render.Status(r, http.StatusInternalServerError)
+			}
+			resp.Error(w, r, dR)
+			return
+		}
+
+		f.publish(federationDomain.EventIdentityUnlinked, i)
+
+		dR["message"] = "identity unlinked"
+		resp.Ok(w, r, dR)
+	}
+}
+
+// link links the upstream subject to the user who started the link. A
+// subject already linked to another user is refused, linking it again to
+// the same user is a no-op.
+func (f *Federation) link(w http.ResponseWriter, r *http.Request, provider string, claims *oidc.Claims, userID int64) {
+	var dR = map[string]string{}
+
+	existing, err := f.identity.GetIdentity(provider, claims.Subject)
+	if err == nil {
+		if existing.UserId != userID {
+			dR["message"] = "the identity is linked to another user"
+			render.Status(r, http.StatusConflict)
+			resp.Error(w, r, dR)
+			return
+		}
+		resp.Ok(w, r, existing)
+		return
+	}
+	if !storage.IsNotFound(err) {
+		logging.L(f.ctx).Error("failed get identity", logging.ErrAttr(err))
+		dR["message"] = "failed link identity"
+		render.Status(r, http.StatusInternalServerError)
+		resp.Error(w, r, dR)
+		return
+	}
+
+	now := time.Now().Unix()
+	i := federationDomain.Identity{
+		ID:        identity.UUIDv7(),
+		UserId:    userID,
+		Provider:  provider,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := f.identity.CreateIdentity(&i); err != nil {
+		if storage.ErrorCode(err) == storage.ErrCodeExists {
+			dR["message"] = "the identity is linked to another user"
+			render.Status(r, http.StatusConflict)
+		} else {
+			logging.L(f.ctx).Error("failed create identity", logging.ErrAttr(err))
+			dR["message"] = "failed link identity"
+			render.Status(r, http.StatusInternalServerError)
+		}
+		resp.Error(w, r, dR)
+		return
+	}
+
+	f.publish(federationDomain.EventIdentityLinked, i)
+
+	resp.Ok(w, r, i)
+}
+
+// publish sends the identity event, a failure is logged, the link or
+// unlink itself already happened.
+func (f *Federation) publish(event string, i federationDomain.Identity) {
+	u, err := f.user.GetUser(i.UserId)
+	if err != nil {
+		logging.L(f.ctx).Error("failed get user of identity event", logging.ErrAttr(err))
+		return
+	}
+
+	err = queue.Publish(f.publisher, "identityEvents", federationDomain.Event{
+		Event:     event,
+		UserUUID:  u.UUID,
+		Provider:  i.Provider,
+		Subject:   i.Subject,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		logging.L(f.ctx).Error("failed publish identity event", logging.StringAttr("event", event), logging.ErrAttr(err))
+	}
+}
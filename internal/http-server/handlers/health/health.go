@@ -2,13 +2,12 @@ package health
 
 import (
 	"app/internal/config"
+	healthCheck "app/internal/health"
 	"app/pkg/client/rabbitmq"
 	resp "app/pkg/common/core/api/response"
 	"app/pkg/common/logging"
 	"context"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/jackc/pgx/v5/pgxpool"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"net/http"
 	"time"
 )
@@ -24,7 +23,7 @@ var startTime = time.Now()
 func New(
 	ctx context.Context,
 	cfg *config.Config,
-	pgClient *pgxpool.Pool,
+	db healthCheck.Database,
 	queueClient *rabbitmq.App,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -36,18 +35,9 @@ func New(
 		)
 
 		status := "ok"
-		dependencies := make(map[string]bool)
-		dependencies["database"] = databaseStatus(pgClient)
-
-		if cfg.Queue.Driver != "" {
-			dependencies["rabbitmq"] = rabbitMQStatus(queueClient.Channel())
-		}
-
-		for _, value := range dependencies {
-			if !value {
-				status = "fail"
-				break
-			}
+		dependencies := healthCheck.Check(r.Context(), cfg, db, queueClient)
+		if !healthCheck.Healthy(dependencies) {
+			status = "fail"
 		}
 
 		var response = &Response{
@@ -66,15 +56,3 @@ func New(
 		return
 	}
 }
-
-func rabbitMQStatus(channel *amqp.Channel) bool {
-	if channel == nil || channel.IsClosed() {
-		return false
-	}
-	return true
-}
-
-func databaseStatus(pgClient *pgxpool.Pool) bool {
-	err := pgClient.Ping(context.Background())
-	return err == nil
-}
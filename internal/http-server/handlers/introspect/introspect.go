@@ -0,0 +1,61 @@
+package introspect
+
+import (
+	"app/internal/domain/client"
+	introspectService "app/internal/service/introspect"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/logging"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+)
+
+type Introspect interface {
+	Introspect(c client.Client, tokenStr, hint string) *introspectService.Introspection
+}
+
+type Request struct {
+	Token         string `json:"token" form:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+func New(
+	ctx context.Context,
+	introspect Introspect,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.introspect.New"
+
+		logging.L(ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("introspect token")
+
+		var req Request
+
+		if err := render.Decode(r, &req); err != nil {
+			logging.L(ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "failed to decode request")
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			logging.L(ctx).Error("invalid request", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "token is required")
+			return
+		}
+
+		c, ok := clientauth.FromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+
+		res := introspect.Introspect(c, req.Token, req.TokenTypeHint)
+
+		render.JSON(w, r, res)
+	}
+}
@@ -0,0 +1,71 @@
+package login_test
+
+import (
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/internal/http-server/handlers/login"
+	authService "app/internal/service/auth"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type auth struct {
+	err error
+}
+
+func (a auth) Login(client.Client, string, string, string, *accessTokenDomain.Confirmation) (authService.Tokens, error) {
+	return authService.Tokens{AccessToken: "access", TokenType: "Bearer", RefreshToken: "refresh"}, a.err
+}
+
+func TestLogin_OAuthErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		body       string
+		noClient   bool
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "invalid credentials", err: authService.ErrInvalidCredentials, wantStatus: http.StatusBadRequest, wantCode: "invalid_grant"},
+		{name: "unauthorized client", err: authService.ErrUnauthorizedClient, wantStatus: http.StatusBadRequest, wantCode: "unauthorized_client"},
+		{name: "unregistered scope", err: authService.ErrInvalidScope, wantStatus: http.StatusBadRequest, wantCode: "invalid_scope"},
+		{name: "token not created", err: authService.ErrCreateToken, wantStatus: http.StatusInternalServerError, wantCode: "server_error"},
+		{name: "missing password", body: `{"login":"alice"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_request"},
+		{name: "unauthenticated client", noClient: true, wantStatus: http.StatusUnauthorized, wantCode: "invalid_client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = `{"login":"alice","password":"password123"}`
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/oauth/login", strings.NewReader(body))
+			if !tt.noClient {
+				r = r.WithContext(clientauth.ContextWithClient(r.Context(), client.Client{ID: "app"}))
+			}
+			w := httptest.NewRecorder()
+
+			login.New(context.Background(), auth{err: tt.err}).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			var res resp.OAuthErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if res.Error != tt.wantCode {
+				t.Fatalf("error = %q, want %q", res.Error, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package openapi
+
+import (
+	"app/pkg/common/logging"
+	gRPCDocs "app/pkg/grpc"
+	"context"
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"net/http"
+)
+
+// New serves the OpenAPI document of the REST gateway as JSON, it is
+// converted once from the generated YAML.
+func New(ctx context.Context) http.HandlerFunc {
+	const op = "http-server.handlers.openapi.New"
+
+	document, err := toJSON(gRPCDocs.OpenAPI)
+	if err != nil {
+		logging.L(ctx).Error("failed to convert the openapi document",
+			logging.StringAttr("op", op),
+			logging.ErrAttr(err),
+		)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	}
+}
+
+func toJSON(document []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(document, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
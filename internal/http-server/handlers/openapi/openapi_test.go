@@ -0,0 +1,37 @@
+package openapi_test
+
+import (
+	"app/internal/http-server/handlers/openapi"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	openapi.New(context.Background()).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var document struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &document); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if document.OpenAPI[:2] != "3." {
+		t.Fatalf("openapi = %q, want a v3 document", document.OpenAPI)
+	}
+	for _, path := range []string{"/v1/auth/login", "/v1/clients", "/v1/tokens:introspect"} {
+		if _, ok := document.Paths[path]; !ok {
+			t.Errorf("path %s is missing", path)
+		}
+	}
+}
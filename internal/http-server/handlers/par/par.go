@@ -0,0 +1,99 @@
+package par
+
+import (
+	"app/internal/config"
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"net/http"
+	"time"
+)
+
+type AuthorizationRequest interface {
+	CreateAuthorizationRequest(aR *authorizationRequestDomain.AuthorizationRequest) error
+}
+
+// Response is the pushed authorization response (RFC 9126, section 2.2).
+type Response struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int64  `json:"expires_in"`
+}
+
+// New is the pushed authorization request endpoint. The authenticated
+// client pushes the parameters of an authorization request and gets a
+// short-lived request_uri to pass to the authorization endpoint instead.
+func New(
+	ctx context.Context,
+	authorizationRequest AuthorizationRequest,
+	cfg *config.Config,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.par.New"
+
+		logging.L(ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("pushed authorization request")
+
+		c, ok := clientauth.FromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logging.L(ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "failed to decode request")
+			return
+		}
+
+		if r.PostForm.Has("request_uri") {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "request_uri can't be pushed")
+			return
+		}
+
+		params := authorizationRequestDomain.ParamsFromValues(r.PostForm)
+		if params.ClientID == "" {
+			params.ClientID = c.ID
+		}
+
+		if _, err := params.Validate(c); err != nil {
+			var authErr *authorizationRequestDomain.Error
+			if errors.As(err, &authErr) {
+				resp.OAuthError(w, r, http.StatusBadRequest, authErr.Code, authErr.Description)
+				return
+			}
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		now := time.Now()
+		requestURI := authorizationRequestDomain.RequestURIPrefix + crypt.GetSecret()
+		aR := &authorizationRequestDomain.AuthorizationRequest{
+			ID:        crypt.GetSHA256Hash(requestURI),
+			ClientId:  c.ID,
+			Params:    params,
+			ExpiresAt: now.Add(cfg.Authorization.RequestURITTL).Unix(),
+			CreatedAt: now.Unix(),
+		}
+
+		if err := authorizationRequest.CreateAuthorizationRequest(aR); err != nil {
+			logging.L(ctx).Error("failed create authorization request", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create authorization request")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, &Response{
+			RequestURI: requestURI,
+			ExpiresIn:  int64(cfg.Authorization.RequestURITTL.Seconds()),
+		})
+	}
+}
@@ -2,11 +2,9 @@ package register
 
 import (
 	"app/internal/domain/user"
-	"app/internal/storage"
+	authService "app/internal/service/auth"
 	resp "app/pkg/common/core/api/response"
-	"app/pkg/common/core/identity"
 	"app/pkg/common/logging"
-	"app/pkg/utils/crypt"
 	"context"
 	"errors"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,11 +12,10 @@ import (
 	"github.com/go-playground/validator/v10"
 	"io"
 	"net/http"
-	"time"
 )
 
 type Auth interface {
-	Registration(req *user.CreateUser) (err error)
+	Register(reg authService.Registration) (user.User, error)
 }
 type Request struct {
 	Name            string `json:"name" validate:"required,ascii"`
@@ -53,7 +50,7 @@ func New(
 		}
 
 		if err != nil {
-			logging.L(ctx).Error("failed to decode request body", err)
+			logging.L(ctx).Error("failed to decode request body", logging.ErrAttr(err))
 			var dR = &Response{Message: "failed to decode request"}
 			resp.Error(w, r, dR)
 			return
@@ -61,41 +58,26 @@ func New(
 
 		if err := validator.New().Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
-			logging.L(ctx).Error("invalid request", err)
+			logging.L(ctx).Error("invalid request", logging.ErrAttr(err))
 			dR := resp.ValidationError(validateErr)
 			resp.Error(w, r, dR)
 
 			return
 		}
 
-		password, err := crypt.GeneratePasswordHash(req.Password)
-		if err != nil {
-			logging.L(ctx).Error("invalid generate hash password", err)
-			var dR = &Response{Message: "failed create user"}
-			resp.Error(w, r, dR)
-			return
+		var reg = authService.Registration{
+			Name:     req.Name,
+			Email:    req.Email,
+			Password: req.Password,
 		}
 
-		timeUnix := time.Now().Unix()
-
-		var usr = &user.CreateUser{
-			UUID:      identity.UUIDv7(),
-			Password:  password,
-			Email:     req.Email,
-			Name:      req.Name,
-			CreatedAt: timeUnix,
-			UpdatedAt: timeUnix,
-		}
-
-		err = auth.Registration(usr)
-		if err != nil {
-			if storage.ErrorCode(err) == storage.ErrCodeExists {
-				logging.L(ctx).Info("user already exists")
-				var dR = &Response{Message: "user already exists"}
-				resp.Error(w, r, dR)
-				return
+		if _, err := auth.Register(reg); err != nil {
+			if errors.Is(err, authService.ErrUserExists) {
+				render.Status(r, http.StatusConflict)
+			} else {
+				render.Status(r, http.StatusInternalServerError)
 			}
-			var dR = &Response{Message: "failed create user"}
+			var dR = &Response{Message: err.Error()}
 			resp.Error(w, r, dR)
 			return
 		}
@@ -0,0 +1,410 @@
+package resource
+
+import (
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type Resource interface {
+	GetResource(ID string) (resourceDomain.Resource, error)
+	GetResources(limit, offset int) ([]resourceDomain.Resource, error)
+	CountResources() (int64, error)
+	CreateResource(r *resourceDomain.Resource) error
+	UpdateResource(r *resourceDomain.Resource) error
+	DeleteResource(ID string) error
+}
+
+type Storage struct {
+	ctx      context.Context
+	resource Resource
+}
+
+func New(ctx context.Context, resource Resource) *Storage {
+	return &Storage{
+		ctx:      ctx,
+		resource: resource,
+	}
+}
+
+type Response struct {
+	ID         string   `json:"id"`
+	Identifier string   `json:"identifier"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	TokenTTL   int64    `json:"tokenTtl"`
+	ClientId   *string  `json:"clientId,omitempty"`
+	CreatedAt  int64    `json:"createdAt,omitempty"`
+	UpdatedAt  int64    `json:"updatedAt,omitempty"`
+}
+
+type ListRequest struct {
+	Page  int `validate:"min=1"`
+	Limit int `validate:"min=1,max=100"`
+}
+
+type ListResponse struct {
+	Items []*Response `json:"items"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
+
+type CreateRequest struct {
+	Identifier string   `json:"identifier" validate:"required"`
+	Name       string   `json:"name" validate:"required"`
+	Scopes     []string `json:"scopes" validate:"omitempty,dive,required,excludesall= "`
+	TokenTTL   int64    `json:"tokenTtl" validate:"min=0"`
+	ClientId   *string  `json:"clientId" validate:"omitempty,min=1"`
+}
+
+type UpdateRequest struct {
+	Identifier *string  `json:"identifier" validate:"omitempty,min=1"`
+	Name       *string  `json:"name" validate:"omitempty,min=1"`
+	Scopes     []string `json:"scopes" validate:"omitempty,dive,required,excludesall= "`
+	TokenTTL   *int64   `json:"tokenTtl" validate:"omitempty,min=0"`
+	ClientId   *string  `json:"clientId"`
+}
+
+type IDRequest struct {
+	ID string `validate:"required,uuid"`
+}
+
+func (s *Storage) ListResources() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.resource.ListResources"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("list resources")
+
+		var dR = map[string]string{}
+
+		req, err := listRequest(r)
+		if err != nil {
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR["message"] = "invalid pagination parameters"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resources, err := s.resource.GetResources(req.Limit, (req.Page-1)*req.Limit)
+		if err != nil {
+			dR["message"] = "failed get resources"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		total, err := s.resource.CountResources()
+		if err != nil {
+			dR["message"] = "failed get resources"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		var dRS = &ListResponse{
+			Items: make([]*Response, 0, len(resources)),
+			Total: total,
+			Page:  req.Page,
+			Limit: req.Limit,
+		}
+		for _, res := range resources {
+			dRS.Items = append(dRS.Items, newResponse(res))
+		}
+
+		resp.Ok(w, r, dRS)
+	}
+}
+
+func (s *Storage) GetResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.resource.GetResource"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("get resource")
+
+		res, ok := s.findResource(w, r)
+		if !ok {
+			return
+		}
+
+		resp.Ok(w, r, newResponse(res))
+	}
+}
+
+func (s *Storage) CreateResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.resource.CreateResource"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("create resource")
+
+		var dR = map[string]string{}
+
+		var req CreateRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			logging.L(s.ctx).Error("request body is empty")
+			dR["message"] = "empty request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := resourceDomain.ValidIdentifier(req.Identifier); err != nil {
+			dR["message"] = err.Error()
+			resp.Error(w, r, dR)
+			return
+		}
+
+		var res = &resourceDomain.Resource{
+			ID:         identity.UUIDv7(),
+			Identifier: req.Identifier,
+			Name:       req.Name,
+			Scopes:     append([]string{}, req.Scopes...),
+			TokenTTL:   req.TokenTTL,
+			ClientId:   req.ClientId,
+			CreatedAt:  time.Now().Unix(),
+			UpdatedAt:  time.Now().Unix(),
+		}
+
+		if err := s.resource.CreateResource(res); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				dR["message"] = "resource already exists"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed create resource"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, newResponse(*res))
+	}
+}
+
+func (s *Storage) UpdateResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.resource.UpdateResource"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("update resource")
+
+		var dR = map[string]string{}
+
+		res, ok := s.findResource(w, r)
+		if !ok {
+			return
+		}
+
+		var req UpdateRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			logging.L(s.ctx).Error("request body is empty")
+			dR["message"] = "empty request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if req.Identifier != nil {
+			if err := resourceDomain.ValidIdentifier(*req.Identifier); err != nil {
+				dR["message"] = err.Error()
+				resp.Error(w, r, dR)
+				return
+			}
+			res.Identifier = *req.Identifier
+		}
+		if req.Name != nil {
+			res.Name = *req.Name
+		}
+		if req.Scopes != nil {
+			res.Scopes = req.Scopes
+		}
+		if req.TokenTTL != nil {
+			res.TokenTTL = *req.TokenTTL
+		}
+		// an empty clientId detaches the resource server
+		if req.ClientId != nil {
+			res.ClientId = req.ClientId
+			if *req.ClientId == "" {
+				res.ClientId = nil
+			}
+		}
+		res.UpdatedAt = time.Now().Unix()
+
+		if err := s.resource.UpdateResource(&res); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				dR["message"] = "resource already exists"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed update resource"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, newResponse(res))
+	}
+}
+
+func (s *Storage) DeleteResource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.resource.DeleteResource"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("delete resource")
+
+		var dR = map[string]string{}
+
+		var req = IDRequest{ID: chi.URLParam(r, "id")}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := s.resource.DeleteResource(req.ID); err != nil {
+			if storage.IsNotFound(err) {
+				dR["message"] = "resource not found"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed delete resource"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, nil)
+	}
+}
+
+// findResource loads the resource addressed by the {id} URL parameter and
+// writes the error response itself when it can't.
+func (s *Storage) findResource(w http.ResponseWriter, r *http.Request) (resourceDomain.Resource, bool) {
+	var dR = map[string]string{}
+	var req = IDRequest{ID: chi.URLParam(r, "id")}
+
+	if err := validator.New().Struct(req); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+		dR := resp.ValidationError(validateErr)
+		resp.Error(w, r, dR)
+		return resourceDomain.Resource{}, false
+	}
+
+	res, err := s.resource.GetResource(req.ID)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			dR["message"] = "resource not found"
+		} else {
+			dR["message"] = "failed get resource"
+		}
+		resp.Error(w, r, dR)
+		return resourceDomain.Resource{}, false
+	}
+
+	return res, true
+}
+
+func listRequest(r *http.Request) (ListRequest, error) {
+	var req = ListRequest{Page: 1, Limit: defaultListLimit}
+
+	if page := r.URL.Query().Get("page"); page != "" {
+		value, err := strconv.Atoi(page)
+		if err != nil {
+			return req, err
+		}
+		req.Page = value
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			return req, err
+		}
+		req.Limit = min(value, maxListLimit)
+	}
+
+	return req, nil
+}
+
+func newResponse(r resourceDomain.Resource) *Response {
+	return &Response{
+		ID:         r.ID,
+		Identifier: r.Identifier,
+		Name:       r.Name,
+		Scopes:     r.Scopes,
+		TokenTTL:   r.TokenTTL,
+		ClientId:   r.ClientId,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
@@ -0,0 +1,156 @@
+package revoke
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+)
+
+const hintRefreshToken = "refresh_token"
+
+var errOtherClient = errors.New("token was issued to another client")
+
+type AccessToken interface {
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+	UpdateToken(aT *accessTokenDomain.AccessToken) (bool, error)
+}
+
+type RefreshToken interface {
+	UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
+}
+
+type Request struct {
+	Token         string `json:"token" form:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// New revokes an access or refresh token (RFC 7009). Unknown and invalid
+// tokens are not an error: the response is the same as for a revoked token.
+func New(
+	ctx context.Context,
+	accessToken AccessToken,
+	refreshToken RefreshToken,
+	cfg config.Token,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.revoke.New"
+
+		logging.L(ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("revoke token")
+
+		var req Request
+
+		if err := render.Decode(r, &req); err != nil {
+			logging.L(ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "failed to decode request")
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			logging.L(ctx).Error("invalid request", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "token is required")
+			return
+		}
+
+		c, ok := clientauth.FromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+
+		var revoked bool
+		var err error
+		if req.TokenTypeHint == hintRefreshToken {
+			revoked, err = revokeRefreshToken(req.Token, c.ID, accessToken, refreshToken)
+			if !revoked && err == nil {
+				revoked, err = revokeAccessToken(req.Token, c.ID, accessToken, cfg)
+			}
+		} else {
+			revoked, err = revokeAccessToken(req.Token, c.ID, accessToken, cfg)
+			if !revoked && err == nil {
+				revoked, err = revokeRefreshToken(req.Token, c.ID, accessToken, refreshToken)
+			}
+		}
+
+		if errors.Is(err, errOtherClient) {
+			logging.L(ctx).Warn("token was issued to another client", logging.StringAttr("client_id", c.ID))
+			resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", err.Error())
+			return
+		}
+
+		if err != nil {
+			logging.L(ctx).Error("failed to revoke token", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// revokeAccessToken reports false without an error when tokenStr is not
+// an access token known to the server.
+func revokeAccessToken(tokenStr, clientID string, accessToken AccessToken, cfg config.Token) (bool, error) {
+	claims, err := token.ParseAccessToken(tokenStr, cfg.Secret)
+	if err != nil || claims.ID == "" {
+		return false, nil
+	}
+
+	aT, err := accessToken.GetToken(claims.ID)
+	if err != nil {
+		return false, nil
+	}
+
+	if aT.ClientId != clientID {
+		return false, errOtherClient
+	}
+
+	return accessToken.UpdateToken(&aT)
+}
+
+// revokeRefreshToken revokes the refresh token together with the access
+// token it was issued with.
+func revokeRefreshToken(
+	tokenStr, clientID string,
+	accessToken AccessToken,
+	refreshToken RefreshToken,
+) (bool, error) {
+	payload, err := token.ParseRefreshToken(tokenStr)
+	if err != nil {
+		return false, nil
+	}
+
+	if payload.ClientId != clientID {
+		return false, errOtherClient
+	}
+
+	revoked, err := refreshToken.UpdateToken(&refreshTokenDomain.RefreshToken{
+		ID:            payload.TokenRefreshId,
+		AccessTokenId: payload.TokenAccessId,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := accessToken.UpdateToken(&accessTokenDomain.AccessToken{
+		ID:       payload.TokenAccessId,
+		UserId:   payload.UserId,
+		ClientId: payload.ClientId,
+	}); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,352 @@
+package saml_idp
+
+import (
+	"app/internal/config"
+	"app/internal/domain/user"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/crewjam/saml"
+	"github.com/go-chi/chi/v5/middleware"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const attributeNameFormat = "urn:oasis:names:tc:SAML:2.0:attrname-format:unspecified"
+
+var nameIDFormats = map[string]saml.NameIDFormat{
+	"email":       saml.EmailAddressNameIDFormat,
+	"persistent":  saml.PersistentNameIDFormat,
+	"transient":   saml.TransientNameIDFormat,
+	"unspecified": saml.UnspecifiedNameIDFormat,
+}
+
+var userFields = map[string]func(u user.User) string{
+	"uuid":  func(u user.User) string { return u.UUID },
+	"email": func(u user.User) string { return u.Email },
+	"name":  func(u user.User) string { return u.Name },
+}
+
+type User interface {
+	GetUser(ID int64) (user.User, error)
+}
+
+type serviceProvider struct {
+	metadata     *saml.EntityDescriptor
+	nameIDFormat saml.NameIDFormat
+	attributes   map[string]string
+}
+
+// IdP is the SAML 2.0 identity provider. It answers the AuthnRequests of
+// the configured service providers for the user signed in with an access
+// token, the same session the authorization endpoint uses. Browsers keep
+// the token in the session cookie, the ones without it are sent to the
+// login page.
+type IdP struct {
+	ctx              context.Context
+	idp              *saml.IdentityProvider
+	user             User
+	serviceProviders map[string]serviceProvider
+	loginURL         *url.URL
+	secure           bool
+}
+
+func New(ctx context.Context, user User, cfg *config.Config) (*IdP, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.SAML.CertFile, cfg.SAML.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load saml key pair: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse saml certificate: %w", err)
+	}
+
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	metadataURL, err := url.Parse(issuer + "/saml/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	ssoURL, _ := url.Parse(issuer + "/saml/sso")
+
+	i := &IdP{
+		ctx:              ctx,
+		user:             user,
+		serviceProviders: make(map[string]serviceProvider, len(cfg.SAML.ServiceProviders)),
+		secure:           metadataURL.Scheme == "https",
+	}
+
+	if cfg.SAML.LoginURL != "" {
+		if i.loginURL, err = url.Parse(cfg.SAML.LoginURL); err != nil || !i.loginURL.IsAbs() {
+			return nil, fmt.Errorf("invalid saml login_url %q", cfg.SAML.LoginURL)
+		}
+	}
+
+	for _, sp := range cfg.SAML.ServiceProviders {
+		if err := i.addServiceProvider(sp); err != nil {
+			return nil, err
+		}
+	}
+
+	i.idp = &saml.IdentityProvider{
+		Key:                     keyPair.PrivateKey,
+		Certificate:             cert,
+		Logger:                  logging.NewLogLogger(logging.L(ctx).Handler(), logging.LevelWarn),
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: i,
+		SessionProvider:         i,
+	}
+
+	return i, nil
+}
+
+func (i *IdP) addServiceProvider(sp config.SAMLServiceProvider) error {
+	if sp.EntityID == "" || sp.ACSURL == "" {
+		return errors.New("saml service provider needs an entity_id and an acs_url")
+	}
+
+	format := sp.NameIDFormat
+	if format == "" {
+		format = "email"
+	}
+	nameIDFormat, ok := nameIDFormats[format]
+	if !ok {
+		return fmt.Errorf("saml service provider %s: unknown name_id_format %q", sp.EntityID, sp.NameIDFormat)
+	}
+
+	for name, field := range sp.Attributes {
+		if _, ok := userFields[field]; !ok {
+			return fmt.Errorf("saml service provider %s: attribute %s maps unknown user field %q", sp.EntityID, name, field)
+		}
+	}
+
+	i.serviceProviders[sp.EntityID] = serviceProvider{
+		metadata: &saml.EntityDescriptor{
+			EntityID: sp.EntityID,
+			SPSSODescriptors: []saml.SPSSODescriptor{{
+				SSODescriptor: saml.SSODescriptor{
+					RoleDescriptor: saml.RoleDescriptor{
+						ProtocolSupportEnumeration: "urn:oasis:names:tc:SAML:2.0:protocol",
+					},
+					NameIDFormats: []saml.NameIDFormat{nameIDFormat},
+				},
+				AssertionConsumerServices: []saml.IndexedEndpoint{{
+					Binding:  saml.HTTPPostBinding,
+					Location: sp.ACSURL,
+					Index:    1,
+				}},
+			}},
+		},
+		nameIDFormat: nameIDFormat,
+		attributes:   sp.Attributes,
+	}
+
+	return nil
+}
+
+// Metadata publishes the metadata of the identity provider, its entity
+// ID is the metadata URL.
+func (i *IdP) Metadata() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.saml-idp.Metadata"
+
+		logging.L(i.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("saml metadata")
+
+		i.idp.ServeMetadata(w, r)
+	}
+}
+
+// SSO answers an SP-initiated AuthnRequest of the Redirect or the POST
+// binding with a signed assertion posted to the ACS URL of the service
+// provider.
+func (i *IdP) SSO() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.saml-idp.SSO"
+
+		logging.L(i.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("saml single sign on")
+
+		i.idp.ServeSSO(w, r)
+	}
+}
+
+// Session starts the browser session of the user of the access token,
+// the login page calls it with the credentials of the browser before it
+// returns to the single sign on. Only bearer tokens make a session.
+func (i *IdP) Session() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.saml-idp.Session"
+
+		logging.L(i.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("saml session")
+
+		aT, ok := token.AccessTokenFromContext(r.Context())
+		tokenStr, bearer := token.BearerToken(r)
+		if !ok || !bearer {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "the session needs a bearer access token")
+			return
+		}
+
+		http.SetCookie(w, i.cookie(tokenStr, time.Unix(aT.ExpiresAt, 0)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// EndSession removes the session cookie of the browser.
+func (i *IdP) EndSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.saml-idp.EndSession"
+
+		logging.L(i.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("saml session end")
+
+		c := i.cookie("", time.Unix(0, 0))
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// cookie returns the session cookie, it is only sent to the SAML
+// endpoints. The login page may live on another site, over TLS the
+// cookie is sent with the cross-site requests too.
+func (i *IdP) cookie(value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     token.SessionCookie,
+		Value:    value,
+		Path:     "/saml",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if i.secure {
+		c.Secure = true
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+// GetServiceProvider returns the metadata of a configured service
+// provider, os.ErrNotExist for the others.
+func (i *IdP) GetServiceProvider(_ *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	sp, ok := i.serviceProviders[serviceProviderID]
+	if !ok {
+		logging.L(i.ctx).Warn("unknown saml service provider", logging.StringAttr("entity_id", serviceProviderID))
+		return nil, os.ErrNotExist
+	}
+
+	return sp.metadata, nil
+}
+
+// GetSession returns the session of the user of the access token with
+// the name ID and the attributes the service provider is configured
+// with. It sends the browser to the login page itself when there is no
+// user.
+func (i *IdP) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	aT, ok := token.AccessTokenFromContext(r.Context())
+	if !ok {
+		i.login(w, r, req)
+		return nil
+	}
+
+	u, err := i.user.GetUser(aT.UserId)
+	if err != nil {
+		logging.L(i.ctx).Error("failed get user", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusUnauthorized, "login_required", "the user is not signed in")
+		return nil
+	}
+
+	sp := i.serviceProviders[req.ServiceProviderMetadata.EntityID]
+
+	var nameID string
+	switch sp.nameIDFormat {
+	case saml.EmailAddressNameIDFormat:
+		nameID = u.Email
+	case saml.TransientNameIDFormat:
+		nameID = crypt.GetSecret()
+	default:
+		nameID = u.UUID
+	}
+
+	session := &saml.Session{
+		ID:           identity.UUIDv7(),
+		CreateTime:   time.Unix(aT.CreatedAt, 0),
+		ExpireTime:   time.Unix(aT.ExpiresAt, 0),
+		Index:        crypt.GetSHA256Hash(aT.ID),
+		NameID:       nameID,
+		NameIDFormat: string(sp.nameIDFormat),
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(sp.attributes)) {
+		field := sp.attributes[name]
+		session.CustomAttributes = append(session.CustomAttributes, saml.Attribute{
+			Name:       name,
+			NameFormat: attributeNameFormat,
+			Values: []saml.AttributeValue{{
+				Type:  "xs:string",
+				Value: userFields[field](u),
+			}},
+		})
+	}
+
+	logging.L(i.ctx).Info("saml assertion",
+		logging.StringAttr("service_provider", req.ServiceProviderMetadata.EntityID),
+		logging.StringAttr("user", u.UUID),
+	)
+
+	return session
+}
+
+// login redirects to the login page with the SSO request to return to,
+// the AuthnRequest of both bindings comes back with the Redirect binding.
+// Without a login page it answers 401.
+func (i *IdP) login(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) {
+	if i.loginURL == nil {
+		resp.OAuthError(w, r, http.StatusUnauthorized, "login_required", "the user is not signed in")
+		return
+	}
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	_, _ = fw.Write(req.RequestBuffer)
+	_ = fw.Close()
+
+	query := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(buf.Bytes())}}
+	if req.RelayState != "" {
+		query.Set("RelayState", req.RelayState)
+	}
+	returnTo := i.idp.SSOURL
+	returnTo.RawQuery = query.Encode()
+
+	loginURL := *i.loginURL
+	params := loginURL.Query()
+	params.Set("return_to", returnTo.String())
+	loginURL.RawQuery = params.Encode()
+
+	http.Redirect(w, r, loginURL.String(), http.StatusFound)
+}
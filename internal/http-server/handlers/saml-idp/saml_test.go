@@ -0,0 +1,345 @@
+package saml_idp_test
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/internal/domain/user"
+	samlIdp "app/internal/http-server/handlers/saml-idp"
+	"app/pkg/common/core/token"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"github.com/crewjam/saml"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var samlFormValue = regexp.MustCompile(`name="(SAMLRequest|SAMLResponse)" value="([^"]*)"`)
+
+type users map[int64]user.User
+
+func (u users) GetUser(ID int64) (user.User, error) {
+	usr, ok := u[ID]
+	if !ok {
+		return user.User{}, pgx.ErrNoRows
+	}
+	return usr, nil
+}
+
+type env struct {
+	server *httptest.Server
+	// session is the access token of the SSO endpoint, none when zero
+	session accessTokenDomain.AccessToken
+}
+
+func newEnv(t *testing.T, serviceProviders ...config.SAMLServiceProvider) *env {
+	t.Helper()
+
+	e := &env{}
+
+	var router http.Handler
+	e.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(e.server.Close)
+
+	certFile, keyFile := writeKeyPair(t)
+	cfg := &config.Config{
+		Issuer: e.server.URL,
+		SAML: config.SAML{
+			CertFile:         certFile,
+			KeyFile:          keyFile,
+			ServiceProviders: serviceProviders,
+		},
+	}
+
+	idp, err := samlIdp.New(context.Background(), users{
+		1: {ID: 1, UUID: "0190f1a2-user", Name: "Alice", Email: "alice@example.com"},
+	}, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/saml/metadata", idp.Metadata())
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if e.session.ID != "" {
+					r = r.WithContext(token.ContextWithAccessToken(r.Context(), e.session))
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		r.Get("/saml/sso", idp.SSO())
+		r.Post("/saml/sso", idp.SSO())
+	})
+	router = r
+
+	now := time.Now()
+	e.session = accessTokenDomain.AccessToken{
+		ID:        "access-token",
+		UserId:    1,
+		ClientId:  "web",
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+
+	return e
+}
+
+// serviceProvider returns the in-process service provider of the entity
+// ID, it trusts the metadata the identity provider publishes.
+func (e *env) serviceProvider(t *testing.T, entityID string) *saml.ServiceProvider {
+	t.Helper()
+
+	res, err := http.Get(e.server.URL + "/saml/metadata")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	defer res.Body.Close()
+
+	var metadata saml.EntityDescriptor
+	if err := xml.NewDecoder(res.Body).Decode(&metadata); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if metadata.EntityID != e.server.URL+"/saml/metadata" {
+		t.Fatalf("metadata entity ID = %q", metadata.EntityID)
+	}
+
+	acsURL, _ := url.Parse(entityID + "/acs")
+	metadataURL, _ := url.Parse(entityID)
+
+	return &saml.ServiceProvider{
+		EntityID:    entityID,
+		AcsURL:      *acsURL,
+		MetadataURL: *metadataURL,
+		IDPMetadata: &metadata,
+	}
+}
+
+// assertion validates the response the identity provider posts to the
+// ACS URL as the service provider does.
+func assertion(t *testing.T, sp *saml.ServiceProvider, res *http.Response, requestID string) *saml.Assertion {
+	t.Helper()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("sso: status = %d, body %s", res.StatusCode, body)
+	}
+
+	match := samlFormValue.FindSubmatch(body)
+	if match == nil || string(match[1]) != "SAMLResponse" {
+		t.Fatalf("no SAMLResponse in %s", body)
+	}
+
+	form := url.Values{"SAMLResponse": {html.UnescapeString(string(match[2]))}}
+	req := httptest.NewRequest(http.MethodPost, sp.AcsURL.String(), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.ParseForm(); err != nil {
+		t.Fatalf("parse form: %v", err)
+	}
+
+	a, err := sp.ParseResponse(req, []string{requestID})
+	if err != nil {
+		// the service provider hides why the response is invalid
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		t.Fatalf("ParseResponse: %v", err)
+	}
+
+	return a
+}
+
+func attributes(a *saml.Assertion) map[string]string {
+	values := map[string]string{}
+	for _, statement := range a.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if len(attr.Values) == 1 {
+				values[attr.Name] = attr.Values[0].Value
+			}
+		}
+	}
+	return values
+}
+
+func TestSSO_RedirectBinding(t *testing.T) {
+	e := newEnv(t, config.SAMLServiceProvider{
+		EntityID:   "https://sp.example.com",
+		ACSURL:     "https://sp.example.com/acs",
+		Attributes: map[string]string{"mail": "email", "displayName": "name"},
+	})
+	sp := e.serviceProvider(t, "https://sp.example.com")
+
+	authnRequest, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatalf("MakeAuthenticationRequest: %v", err)
+	}
+	redirect, err := authnRequest.Redirect("relay", sp)
+	if err != nil {
+		t.Fatalf("Redirect: %v", err)
+	}
+
+	res, err := http.Get(redirect.String())
+	if err != nil {
+		t.Fatalf("sso: %v", err)
+	}
+	defer res.Body.Close()
+
+	a := assertion(t, sp, res, authnRequest.ID)
+
+	if a.Issuer.Value != e.server.URL+"/saml/metadata" {
+		t.Fatalf("issuer = %q", a.Issuer.Value)
+	}
+	if a.Subject.NameID.Value != "alice@example.com" || a.Subject.NameID.Format != string(saml.EmailAddressNameIDFormat) {
+		t.Fatalf("name ID = %+v", a.Subject.NameID)
+	}
+	attrs := attributes(a)
+	if attrs["mail"] != "alice@example.com" || attrs["displayName"] != "Alice" || len(attrs) != 2 {
+		t.Fatalf("attributes = %v", attrs)
+	}
+}
+
+func TestSSO_PostBinding(t *testing.T) {
+	e := newEnv(t, config.SAMLServiceProvider{
+		EntityID:     "https://crm.example.com",
+		ACSURL:       "https://crm.example.com/acs",
+		NameIDFormat: "persistent",
+		Attributes:   map[string]string{"uid": "uuid"},
+	})
+	sp := e.serviceProvider(t, "https://crm.example.com")
+
+	authnRequest, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPPostBinding), saml.HTTPPostBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatalf("MakeAuthenticationRequest: %v", err)
+	}
+	match := samlFormValue.FindSubmatch(authnRequest.Post("relay"))
+	if match == nil {
+		t.Fatal("no SAMLRequest in the post form")
+	}
+
+	res, err := http.PostForm(e.server.URL+"/saml/sso", url.Values{
+		"SAMLRequest": {html.UnescapeString(string(match[2]))},
+		"RelayState":  {"relay"},
+	})
+	if err != nil {
+		t.Fatalf("sso: %v", err)
+	}
+	defer res.Body.Close()
+
+	a := assertion(t, sp, res, authnRequest.ID)
+
+	if a.Subject.NameID.Value != "0190f1a2-user" || a.Subject.NameID.Format != string(saml.PersistentNameIDFormat) {
+		t.Fatalf("name ID = %+v", a.Subject.NameID)
+	}
+	if attrs := attributes(a); attrs["uid"] != "0190f1a2-user" {
+		t.Fatalf("attributes = %v", attrs)
+	}
+}
+
+func TestSSO_RejectsUnknownServiceProviderAndNoSession(t *testing.T) {
+	e := newEnv(t, config.SAMLServiceProvider{EntityID: "https://sp.example.com", ACSURL: "https://sp.example.com/acs"})
+
+	unknown := e.serviceProvider(t, "https://other.example.com")
+	redirect, err := unknown.MakeRedirectAuthenticationRequest("")
+	if err != nil {
+		t.Fatalf("MakeRedirectAuthenticationRequest: %v", err)
+	}
+	res, err := http.Get(redirect.String())
+	if err != nil {
+		t.Fatalf("sso: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown service provider: status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	e.session = accessTokenDomain.AccessToken{}
+	sp := e.serviceProvider(t, "https://sp.example.com")
+	redirect, err = sp.MakeRedirectAuthenticationRequest("")
+	if err != nil {
+		t.Fatalf("MakeRedirectAuthenticationRequest: %v", err)
+	}
+	res, err = http.Get(redirect.String())
+	if err != nil {
+		t.Fatalf("sso: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("no session: status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestNew_InvalidServiceProvider(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t)
+
+	for name, sp := range map[string]config.SAMLServiceProvider{
+		"no acs url":       {EntityID: "https://sp.example.com"},
+		"name id format":   {EntityID: "https://sp.example.com", ACSURL: "https://sp.example.com/acs", NameIDFormat: "kerberos"},
+		"attribute mapped": {EntityID: "https://sp.example.com", ACSURL: "https://sp.example.com/acs", Attributes: map[string]string{"pw": "password"}},
+	} {
+		cfg := &config.Config{
+			Issuer: "https://sso.example.com",
+			SAML:   config.SAML{CertFile: certFile, KeyFile: keyFile, ServiceProviders: []config.SAMLServiceProvider{sp}},
+		}
+		if _, err := samlIdp.New(context.Background(), users{}, cfg); err == nil {
+			t.Errorf("%s: New succeeded, want an error", name)
+		}
+	}
+}
+
+// writeKeyPair writes the RSA key and the self-signed certificate
+// assertions are signed with.
+func writeKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sso"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sso"},
+	}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "saml.crt")
+	keyFile := filepath.Join(dir, "saml.key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
@@ -0,0 +1,172 @@
+package scim_token
+
+import (
+	scimDomain "app/internal/domain/scim"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Token interface {
+	CreateToken(t *scimDomain.Token) error
+	GetTokens() ([]scimDomain.Token, error)
+	DeleteToken(ID string) error
+}
+
+type Storage struct {
+	ctx   context.Context
+	token Token
+}
+
+func New(ctx context.Context, token Token) *Storage {
+	return &Storage{
+		ctx:   ctx,
+		token: token,
+	}
+}
+
+type Response struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Token     string `json:"token,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type CreateRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type IDRequest struct {
+	ID string `validate:"required,uuid"`
+}
+
+func (s *Storage) ListTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim-token.ListTokens"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("list scim tokens")
+
+		var dR = map[string]string{}
+
+		tokens, err := s.token.GetTokens()
+		if err != nil {
+			dR["message"] = "failed get tokens"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		var dRS = make([]*Response, 0, len(tokens))
+		for _, t := range tokens {
+			dRS = append(dRS, &Response{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt})
+		}
+
+		resp.Ok(w, r, dRS)
+	}
+}
+
+// CreateToken issues a token for a provisioning client, the token is only
+// returned in this response.
+func (s *Storage) CreateToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim-token.CreateToken"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("create scim token")
+
+		var dR = map[string]string{}
+
+		var req CreateRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			logging.L(s.ctx).Error("request body is empty")
+			dR["message"] = "empty request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err != nil {
+			logging.L(s.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			dR["message"] = "failed to decode request"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		secret := crypt.GetSecret()
+
+		var t = &scimDomain.Token{
+			ID:        identity.UUIDv7(),
+			Name:      req.Name,
+			TokenHash: crypt.GetSHA256Hash(secret),
+			CreatedAt: time.Now().Unix(),
+		}
+
+		if err := s.token.CreateToken(t); err != nil {
+			dR["message"] = "failed create token"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, &Response{ID: t.ID, Name: t.Name, Token: secret, CreatedAt: t.CreatedAt})
+	}
+}
+
+func (s *Storage) DeleteToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim-token.DeleteToken"
+
+		logging.L(s.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("delete scim token")
+
+		var dR = map[string]string{}
+
+		var req = IDRequest{ID: chi.URLParam(r, "id")}
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			logging.L(s.ctx).Error("invalid request", logging.ErrAttr(err))
+			dR := resp.ValidationError(validateErr)
+			resp.Error(w, r, dR)
+			return
+		}
+
+		if err := s.token.DeleteToken(req.ID); err != nil {
+			if storage.IsNotFound(err) {
+				dR["message"] = "token not found"
+				resp.Error(w, r, dR)
+				return
+			}
+			dR["message"] = "failed delete token"
+			resp.Error(w, r, dR)
+			return
+		}
+
+		resp.Ok(w, r, nil)
+	}
+}
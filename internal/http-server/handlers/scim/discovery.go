@@ -0,0 +1,276 @@
+package scim
+
+import (
+	coreScim "app/pkg/common/core/scim"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// Attribute is an attribute definition of a schema (RFC 7643, section 7).
+type Attribute struct {
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	MultiValued    bool        `json:"multiValued"`
+	Description    string      `json:"description,omitempty"`
+	Required       bool        `json:"required"`
+	CaseExact      bool        `json:"caseExact"`
+	Mutability     string      `json:"mutability"`
+	Returned       string      `json:"returned"`
+	Uniqueness     string      `json:"uniqueness"`
+	ReferenceTypes []string    `json:"referenceTypes,omitempty"`
+	SubAttributes  []Attribute `json:"subAttributes,omitempty"`
+}
+
+type Schema struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Attributes  []Attribute   `json:"attributes"`
+	Meta        coreScim.Meta `json:"meta"`
+}
+
+type ResourceType struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Endpoint    string        `json:"endpoint"`
+	Description string        `json:"description"`
+	Schema      string        `json:"schema"`
+	Meta        coreScim.Meta `json:"meta"`
+}
+
+type supported struct {
+	Supported bool `json:"supported"`
+}
+
+type filterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type bulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 supported              `json:"patch"`
+	Bulk                  bulkSupport            `json:"bulk"`
+	Filter                filterSupport          `json:"filter"`
+	ChangePassword        supported              `json:"changePassword"`
+	Sort                  supported              `json:"sort"`
+	ETag                  supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  coreScim.Meta          `json:"meta"`
+}
+
+func attr(name, typ, mutability string) Attribute {
+	return Attribute{Name: name, Type: typ, Mutability: mutability, Returned: "default", Uniqueness: "none"}
+}
+
+func (a Attribute) required() Attribute {
+	a.Required = true
+	return a
+}
+
+func (a Attribute) unique() Attribute {
+	a.Uniqueness = "server"
+	return a
+}
+
+func (a Attribute) caseExact() Attribute {
+	a.CaseExact = true
+	return a
+}
+
+func (a Attribute) never() Attribute {
+	a.Returned = "never"
+	return a
+}
+
+func (a Attribute) describe(description string) Attribute {
+	a.Description = description
+	return a
+}
+
+func (a Attribute) references(types ...string) Attribute {
+	a.ReferenceTypes = types
+	return a
+}
+
+func (a Attribute) multiValued(sub ...Attribute) Attribute {
+	a.MultiValued = true
+	a.SubAttributes = sub
+	return a
+}
+
+var userAttributes = []Attribute{
+	attr("userName", "string", "readWrite").required().unique().
+		describe("Unique identifier of the user, compared case-insensitively."),
+	attr("externalId", "string", "readWrite").caseExact(),
+	attr("displayName", "string", "readWrite"),
+	attr("password", "string", "writeOnly").never(),
+	attr("active", "boolean", "readWrite"),
+	attr("emails", "complex", "readWrite").required().
+		describe("The primary email is the email the user signs in with, it is unique.").
+		multiValued(
+			attr("value", "string", "readWrite"),
+			attr("type", "string", "readWrite"),
+			attr("primary", "boolean", "readWrite"),
+		),
+	attr("groups", "complex", "readOnly").multiValued(
+		attr("value", "string", "readOnly"),
+		attr("$ref", "reference", "readOnly").references("Group"),
+		attr("display", "string", "readOnly"),
+	),
+}
+
+var groupAttributes = []Attribute{
+	attr("displayName", "string", "readWrite").required().unique(),
+	attr("externalId", "string", "readWrite").caseExact(),
+	attr("members", "complex", "readWrite").multiValued(
+		attr("value", "string", "immutable").describe("The id of the user."),
+		attr("$ref", "reference", "immutable").references("User"),
+		attr("display", "string", "readOnly"),
+	),
+}
+
+func (s *SCIM) schemas() []Schema {
+	return []Schema{
+		{
+			Schemas:     []string{coreScim.SchemaSchema},
+			ID:          coreScim.SchemaUser,
+			Name:        "User",
+			Description: "User Account",
+			Attributes:  userAttributes,
+			Meta:        coreScim.Meta{ResourceType: "Schema", Location: s.location("/Schemas", coreScim.SchemaUser)},
+		},
+		{
+			Schemas:     []string{coreScim.SchemaSchema},
+			ID:          coreScim.SchemaGroup,
+			Name:        "Group",
+			Description: "Group",
+			Attributes:  groupAttributes,
+			Meta:        coreScim.Meta{ResourceType: "Schema", Location: s.location("/Schemas", coreScim.SchemaGroup)},
+		},
+	}
+}
+
+func (s *SCIM) resourceTypes() []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{coreScim.SchemaResourceType},
+			ID:          "User",
+			Name:        "User",
+			Endpoint:    "/Users",
+			Description: "User Account",
+			Schema:      coreScim.SchemaUser,
+			Meta:        coreScim.Meta{ResourceType: "ResourceType", Location: s.location("/ResourceTypes", "User")},
+		},
+		{
+			Schemas:     []string{coreScim.SchemaResourceType},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Group",
+			Schema:      coreScim.SchemaGroup,
+			Meta:        coreScim.Meta{ResourceType: "ResourceType", Location: s.location("/ResourceTypes", "Group")},
+		},
+	}
+}
+
+func (s *SCIM) ServiceProviderConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ServiceProviderConfig"
+		s.log(r, op).Info("get service provider config")
+
+		coreScim.Write(w, http.StatusOK, ServiceProviderConfig{
+			Schemas:        []string{coreScim.SchemaServiceProviderConfig},
+			Patch:          supported{Supported: true},
+			Filter:         filterSupport{Supported: true, MaxResults: s.maxResults},
+			ChangePassword: supported{Supported: true},
+			ETag:           supported{Supported: true},
+			AuthenticationSchemes: []AuthenticationScheme{{
+				Type:        "oauthbearertoken",
+				Name:        "Bearer Token",
+				Description: "Authentication with a bearer token issued to the provisioning client.",
+				Primary:     true,
+			}},
+			Meta: coreScim.Meta{ResourceType: "ServiceProviderConfig", Location: s.baseURL + "/ServiceProviderConfig"},
+		})
+	}
+}
+
+func (s *SCIM) Schemas() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.Schemas"
+		s.log(r, op).Info("list schemas")
+
+		schemas := s.schemas()
+		resources := make([]any, 0, len(schemas))
+		for _, schema := range schemas {
+			resources = append(resources, schema)
+		}
+
+		coreScim.Write(w, http.StatusOK, coreScim.NewListResponse(int64(len(resources)), 1, resources))
+	}
+}
+
+func (s *SCIM) Schema() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.Schema"
+		s.log(r, op).Info("get schema")
+
+		ID := chi.URLParam(r, "id")
+		for _, schema := range s.schemas() {
+			if schema.ID == ID {
+				coreScim.Write(w, http.StatusOK, schema)
+				return
+			}
+		}
+
+		coreScim.WriteError(w, notFound("schema", ID))
+	}
+}
+
+func (s *SCIM) ResourceTypes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ResourceTypes"
+		s.log(r, op).Info("list resource types")
+
+		resourceTypes := s.resourceTypes()
+		resources := make([]any, 0, len(resourceTypes))
+		for _, resourceType := range resourceTypes {
+			resources = append(resources, resourceType)
+		}
+
+		coreScim.Write(w, http.StatusOK, coreScim.NewListResponse(int64(len(resources)), 1, resources))
+	}
+}
+
+func (s *SCIM) ResourceType() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ResourceType"
+		s.log(r, op).Info("get resource type")
+
+		ID := chi.URLParam(r, "id")
+		for _, resourceType := range s.resourceTypes() {
+			if resourceType.ID == ID {
+				coreScim.Write(w, http.StatusOK, resourceType)
+				return
+			}
+		}
+
+		coreScim.WriteError(w, notFound("resource type", ID))
+	}
+}
@@ -0,0 +1,307 @@
+package scim
+
+import (
+	groupDomain "app/internal/domain/group"
+	"app/internal/storage"
+	"app/pkg/common/core/identity"
+	coreScim "app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type MemberRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// GroupResource is the representation of a group (RFC 7643, section
+// 4.2), its members are users.
+type GroupResource struct {
+	Schemas     []string       `json:"schemas"`
+	ID          string         `json:"id,omitempty"`
+	ExternalID  *string        `json:"externalId,omitempty"`
+	DisplayName string         `json:"displayName"`
+	Members     []MemberRef    `json:"members,omitempty"`
+	Meta        *coreScim.Meta `json:"meta,omitempty"`
+}
+
+func (s *SCIM) newGroupResource(g groupDomain.Group) GroupResource {
+	meta := coreScim.NewMeta("Group", s.location("/Groups", g.UUID), g.CreatedAt, g.UpdatedAt, g.Version)
+
+	res := GroupResource{
+		Schemas:     []string{coreScim.SchemaGroup},
+		ID:          g.UUID,
+		ExternalID:  g.ExternalId,
+		DisplayName: g.DisplayName,
+		Meta:        &meta,
+	}
+
+	for _, m := range g.Members {
+		res.Members = append(res.Members, MemberRef{
+			Value:   m.UserUUID,
+			Ref:     s.location("/Users", m.UserUUID),
+			Display: m.Display,
+			Type:    "User",
+		})
+	}
+
+	return res
+}
+
+func (s *SCIM) ListGroups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ListGroups"
+		s.log(r, op).Info("list groups")
+
+		filter, page, err := s.query(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		groups, total, err := s.group.FindGroups(filter, page)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		resources := make([]any, 0, len(groups))
+		for _, g := range groups {
+			resources = append(resources, s.newGroupResource(g))
+		}
+
+		coreScim.Write(w, http.StatusOK, coreScim.NewListResponse(total, page.StartIndex, resources))
+	}
+}
+
+func (s *SCIM) GetGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.GetGroup"
+		s.log(r, op).Info("get group")
+
+		g, err := s.findGroup(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if notModified(w, r, g.Version) {
+			return
+		}
+
+		writeResource(w, http.StatusOK, s.newGroupResource(g), g.Version)
+	}
+}
+
+func (s *SCIM) CreateGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.CreateGroup"
+		log := s.log(r, op)
+		log.Info("create group")
+
+		var res GroupResource
+		if err := decode(r, &res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		now := time.Now().Unix()
+		g := groupDomain.Group{
+			UUID:      identity.UUIDv7(),
+			CreatedAt: now,
+		}
+
+		if err := applyGroup(&g, res, now); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.group.CreateGroup(&g); err != nil {
+			coreScim.WriteError(w, groupError(err))
+			return
+		}
+
+		log.Info("group provisioned", logging.StringAttr("uuid", g.UUID))
+
+		s.writeGroup(w, http.StatusCreated, g.UUID)
+	}
+}
+
+func (s *SCIM) ReplaceGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ReplaceGroup"
+		s.log(r, op).Info("replace group")
+
+		g, err := s.findGroup(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, g.Version) {
+			return
+		}
+
+		var res GroupResource
+		if err := decode(r, &res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.updateGroup(&g, res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		s.writeGroup(w, http.StatusOK, g.UUID)
+	}
+}
+
+func (s *SCIM) PatchGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.PatchGroup"
+		s.log(r, op).Info("patch group")
+
+		g, err := s.findGroup(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, g.Version) {
+			return
+		}
+
+		var req coreScim.PatchRequest
+		if err := decode(r, &req); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		res, err := patch(req, s.newGroupResource(g), func(res *GroupResource) {
+			res.Meta = nil
+		})
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.updateGroup(&g, res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		s.writeGroup(w, http.StatusOK, g.UUID)
+	}
+}
+
+func (s *SCIM) DeleteGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.DeleteGroup"
+		s.log(r, op).Info("delete group")
+
+		g, err := s.findGroup(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, g.Version) {
+			return
+		}
+
+		if err := s.group.DeleteGroup(g.UUID); err != nil {
+			if storage.IsNotFound(err) {
+				err = notFound("group", g.UUID)
+			}
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// findGroup loads the group addressed by the {id} URL parameter.
+func (s *SCIM) findGroup(r *http.Request) (groupDomain.Group, error) {
+	ID := resourceID(r)
+	if uuid.Validate(ID) != nil {
+		return groupDomain.Group{}, notFound("group", ID)
+	}
+
+	g, err := s.group.GetGroup(ID)
+	if storage.IsNotFound(err) {
+		return g, notFound("group", ID)
+	}
+
+	return g, err
+}
+
+func (s *SCIM) updateGroup(g *groupDomain.Group, res GroupResource) error {
+	if err := applyGroup(g, res, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if err := s.group.UpdateGroup(g); err != nil {
+		if storage.IsNotFound(err) {
+			return errModified
+		}
+		return groupError(err)
+	}
+
+	return nil
+}
+
+// writeGroup writes the stored group, the members are displayed as
+// stored.
+func (s *SCIM) writeGroup(w http.ResponseWriter, status int, UUID string) {
+	g, err := s.group.GetGroup(UUID)
+	if err != nil {
+		coreScim.WriteError(w, err)
+		return
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", s.location("/Groups", g.UUID))
+	}
+
+	writeResource(w, status, s.newGroupResource(g), g.Version)
+}
+
+// applyGroup sets the attributes of the representation to the group.
+func applyGroup(g *groupDomain.Group, res GroupResource, now int64) error {
+	if strings.TrimSpace(res.DisplayName) == "" {
+		return coreScim.BadRequest(coreScim.TypeInvalidValue, "displayName is required")
+	}
+
+	g.DisplayName = strings.TrimSpace(res.DisplayName)
+	g.ExternalId = res.ExternalID
+	g.UpdatedAt = now
+
+	g.Members = make([]groupDomain.Member, 0, len(res.Members))
+	for _, m := range res.Members {
+		ID := strings.ToLower(strings.TrimSpace(m.Value))
+		if uuid.Validate(ID) != nil {
+			return coreScim.BadRequest(coreScim.TypeInvalidValue, "member %q is not a user", m.Value)
+		}
+		g.Members = append(g.Members, groupDomain.Member{UserUUID: ID})
+	}
+
+	return nil
+}
+
+func groupError(err error) error {
+	switch {
+	case errors.Is(err, groupDomain.ErrUnknownMember):
+		return coreScim.BadRequest(coreScim.TypeInvalidValue, "%s", err.Error())
+	case storage.ErrorCode(err) == storage.ErrCodeExists:
+		return conflict("a group with the displayName already exists")
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package scim
+
+import (
+	"app/internal/config"
+	groupDomain "app/internal/domain/group"
+	"app/internal/domain/user"
+	coreScim "app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type User interface {
+	GetUserByUUID(UUID string) (user.User, error)
+	FindUsers(filter coreScim.Expr, page coreScim.Page) ([]user.User, int64, error)
+	ExistsUserName(name string, exceptID int64) (bool, error)
+	CreateUser(u *user.User) error
+	UpdateUser(u *user.User) error
+	DeleteUser(ID int64, now int64) error
+}
+
+type Group interface {
+	CreateGroup(g *groupDomain.Group) error
+	GetGroup(UUID string) (groupDomain.Group, error)
+	FindGroups(filter coreScim.Expr, page coreScim.Page) ([]groupDomain.Group, int64, error)
+	UpdateGroup(g *groupDomain.Group) error
+	DeleteGroup(UUID string) error
+	GetGroupsOfUsers(userIDs []int64) (map[int64][]groupDomain.Membership, error)
+}
+
+type AccessToken interface {
+	RevokeUserTokens(userID int64) error
+}
+
+// SCIM serves the SCIM 2.0 provisioning API (RFC 7644) of the users and
+// the groups.
+type SCIM struct {
+	ctx         context.Context
+	user        User
+	group       Group
+	accessToken AccessToken
+	baseURL     string
+	maxResults  int
+}
+
+func New(
+	ctx context.Context,
+	user User,
+	group Group,
+	accessToken AccessToken,
+	cfg *config.Config,
+) *SCIM {
+	return &SCIM{
+		ctx:         ctx,
+		user:        user,
+		group:       group,
+		accessToken: accessToken,
+		baseURL:     strings.TrimRight(cfg.Issuer, "/") + "/scim/v2",
+		maxResults:  cfg.SCIM.MaxResults,
+	}
+}
+
+// log returns the logger of a request handled by the operation.
+func (s *SCIM) log(r *http.Request, op string) *logging.Logger {
+	return logging.L(s.ctx).With(
+		logging.StringAttr("op", op),
+		logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+	)
+}
+
+func (s *SCIM) location(endpoint, ID string) string {
+	return s.baseURL + endpoint + "/" + ID
+}
+
+// query parses the filter and the pagination of a list request.
+func (s *SCIM) query(r *http.Request) (coreScim.Expr, coreScim.Page, error) {
+	page, err := coreScim.ParsePage(r.URL.Query(), s.maxResults)
+	if err != nil {
+		return nil, page, err
+	}
+
+	filter := r.URL.Query().Get("filter")
+	if filter == "" {
+		return nil, page, nil
+	}
+
+	expr, err := coreScim.ParseFilter(filter)
+	return expr, page, err
+}
+
+// decode decodes the resource of a request body.
+func decode(r *http.Request, v any) error {
+	err := render.DecodeJSON(r.Body, v)
+	if errors.Is(err, io.EOF) {
+		return coreScim.BadRequest(coreScim.TypeInvalidSyntax, "empty request")
+	}
+	if err != nil {
+		return coreScim.BadRequest(coreScim.TypeInvalidSyntax, "failed to decode request: %s", err.Error())
+	}
+
+	return nil
+}
+
+// writeResource writes a single resource with its entity tag.
+func writeResource(w http.ResponseWriter, status int, v any, version int64) {
+	w.Header().Set("ETag", coreScim.ETag(version))
+	coreScim.Write(w, status, v)
+}
+
+// notModified answers a GET request whose If-None-Match header lists the
+// current version of the resource.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !coreScim.MatchesETag(header, version) {
+		return false
+	}
+
+	w.Header().Set("ETag", coreScim.ETag(version))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func notFound(resourceType, ID string) error {
+	return &coreScim.Error{Status: http.StatusNotFound, Detail: resourceType + " " + ID + " not found"}
+}
+
+func conflict(format string, args ...any) error {
+	err := coreScim.BadRequest(coreScim.TypeUniqueness, format, args...)
+	err.Status = http.StatusConflict
+	return err
+}
+
+var errModified = &coreScim.Error{Status: http.StatusPreconditionFailed, Detail: "the resource has been modified"}
+
+func resourceID(r *http.Request) string {
+	return strings.ToLower(chi.URLParam(r, "id"))
+}
@@ -0,0 +1,406 @@
+package scim
+
+import (
+	groupDomain "app/internal/domain/group"
+	"app/internal/domain/user"
+	"app/internal/storage"
+	"app/pkg/common/core/identity"
+	coreScim "app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Email struct {
+	Value   string        `json:"value"`
+	Type    string        `json:"type,omitempty"`
+	Primary coreScim.Bool `json:"primary,omitempty"`
+}
+
+type GroupRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// UserResource is the representation of a user (RFC 7643, section 4.1).
+// The primary email is the email the user signs in with.
+type UserResource struct {
+	Schemas     []string       `json:"schemas"`
+	ID          string         `json:"id,omitempty"`
+	ExternalID  *string        `json:"externalId,omitempty"`
+	UserName    string         `json:"userName"`
+	DisplayName *string        `json:"displayName,omitempty"`
+	Password    string         `json:"password,omitempty"`
+	Active      *coreScim.Bool `json:"active,omitempty"`
+	Emails      []Email        `json:"emails,omitempty"`
+	Groups      []GroupRef     `json:"groups,omitempty"`
+	Meta        *coreScim.Meta `json:"meta,omitempty"`
+}
+
+// primaryEmail returns the email marked primary, or the first one.
+func (res UserResource) primaryEmail() string {
+	for _, e := range res.Emails {
+		if e.Primary {
+			return strings.TrimSpace(e.Value)
+		}
+	}
+	if len(res.Emails) > 0 {
+		return strings.TrimSpace(res.Emails[0].Value)
+	}
+	return ""
+}
+
+func (res UserResource) validate() error {
+	if strings.TrimSpace(res.UserName) == "" {
+		return coreScim.BadRequest(coreScim.TypeInvalidValue, "userName is required")
+	}
+	if err := validator.New().Var(res.primaryEmail(), "required,email"); err != nil {
+		return coreScim.BadRequest(coreScim.TypeInvalidValue, "a valid email is required")
+	}
+	return nil
+}
+
+func (s *SCIM) newUserResource(u user.User, groups []groupDomain.Membership) UserResource {
+	active := coreScim.Bool(u.Active())
+	meta := coreScim.NewMeta("User", s.location("/Users", u.UUID), u.CreatedAt, u.UpdatedAt, u.Version)
+
+	res := UserResource{
+		Schemas:     []string{coreScim.SchemaUser},
+		ID:          u.UUID,
+		ExternalID:  u.ExternalId,
+		UserName:    u.Name,
+		DisplayName: u.DisplayName,
+		Active:      &active,
+		Emails:      []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Meta:        &meta,
+	}
+
+	for _, g := range groups {
+		res.Groups = append(res.Groups, GroupRef{
+			Value:   g.GroupUUID,
+			Ref:     s.location("/Groups", g.GroupUUID),
+			Display: g.DisplayName,
+		})
+	}
+
+	return res
+}
+
+func (s *SCIM) ListUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ListUsers"
+		s.log(r, op).Info("list users")
+
+		filter, page, err := s.query(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		users, total, err := s.user.FindUsers(filter, page)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		IDs := make([]int64, 0, len(users))
+		for _, u := range users {
+			IDs = append(IDs, u.ID)
+		}
+
+		groups, err := s.group.GetGroupsOfUsers(IDs)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		resources := make([]any, 0, len(users))
+		for _, u := range users {
+			resources = append(resources, s.newUserResource(u, groups[u.ID]))
+		}
+
+		coreScim.Write(w, http.StatusOK, coreScim.NewListResponse(total, page.StartIndex, resources))
+	}
+}
+
+func (s *SCIM) GetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.GetUser"
+		s.log(r, op).Info("get user")
+
+		u, err := s.findUser(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if notModified(w, r, u.Version) {
+			return
+		}
+
+		s.writeUser(w, http.StatusOK, u)
+	}
+}
+
+func (s *SCIM) CreateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.CreateUser"
+		log := s.log(r, op)
+		log.Info("create user")
+
+		var res UserResource
+		if err := decode(r, &res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		now := time.Now().Unix()
+		u := user.User{
+			UUID:      identity.UUIDv7(),
+			CreatedAt: now,
+		}
+
+		if err := s.applyUser(&u, res, now); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.user.CreateUser(&u); err != nil {
+			if storage.ErrorCode(err) == storage.ErrCodeExists {
+				err = conflict("a user with the email already exists")
+			}
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		log.Info("user provisioned", logging.StringAttr("uuid", u.UUID))
+
+		w.Header().Set("Location", s.location("/Users", u.UUID))
+		s.writeUser(w, http.StatusCreated, u)
+	}
+}
+
+func (s *SCIM) ReplaceUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.ReplaceUser"
+		s.log(r, op).Info("replace user")
+
+		u, err := s.findUser(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, u.Version) {
+			return
+		}
+
+		var res UserResource
+		if err := decode(r, &res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.updateUser(r, &u, res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		s.writeUser(w, http.StatusOK, u)
+	}
+}
+
+func (s *SCIM) PatchUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.PatchUser"
+		s.log(r, op).Info("patch user")
+
+		u, err := s.findUser(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, u.Version) {
+			return
+		}
+
+		var req coreScim.PatchRequest
+		if err := decode(r, &req); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		res, err := patch(req, s.newUserResource(u, nil), func(res *UserResource) {
+			res.Meta = nil
+		})
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.updateUser(r, &u, res); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		s.writeUser(w, http.StatusOK, u)
+	}
+}
+
+// DeleteUser deprovisions the user: it is deactivated, its tokens are
+// revoked and it is no longer returned.
+func (s *SCIM) DeleteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.scim.DeleteUser"
+		log := s.log(r, op)
+		log.Info("delete user")
+
+		u, err := s.findUser(r)
+		if err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if !coreScim.CheckPrecondition(w, r, u.Version) {
+			return
+		}
+
+		if err := s.user.DeleteUser(u.ID, time.Now().Unix()); err != nil {
+			if storage.IsNotFound(err) {
+				err = notFound("user", u.UUID)
+			}
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		if err := s.accessToken.RevokeUserTokens(u.ID); err != nil {
+			coreScim.WriteError(w, err)
+			return
+		}
+
+		log.Info("user deprovisioned", logging.StringAttr("uuid", u.UUID))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// findUser loads the user addressed by the {id} URL parameter.
+func (s *SCIM) findUser(r *http.Request) (user.User, error) {
+	ID := resourceID(r)
+	if uuid.Validate(ID) != nil {
+		return user.User{}, notFound("user", ID)
+	}
+
+	u, err := s.user.GetUserByUUID(ID)
+	if storage.IsNotFound(err) {
+		return u, notFound("user", ID)
+	}
+
+	return u, err
+}
+
+// applyUser sets the attributes of the representation to the user.
+func (s *SCIM) applyUser(u *user.User, res UserResource, now int64) error {
+	if err := res.validate(); err != nil {
+		return err
+	}
+
+	exists, err := s.user.ExistsUserName(res.UserName, u.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return conflict("userName %s is already taken", res.UserName)
+	}
+
+	u.Name = strings.TrimSpace(res.UserName)
+	u.Email = res.primaryEmail()
+	u.ExternalId = res.ExternalID
+	u.DisplayName = res.DisplayName
+	u.UpdatedAt = now
+
+	// the current password is kept when none is given
+	u.Password = ""
+	if res.Password != "" {
+		if u.Password, err = crypt.GeneratePasswordHash(res.Password); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case res.Active == nil:
+	case !bool(*res.Active) && u.DeactivatedAt == nil:
+		u.DeactivatedAt = &now
+	case bool(*res.Active):
+		u.DeactivatedAt = nil
+	}
+
+	return nil
+}
+
+// updateUser replaces the user with the representation, the tokens of a
+// deactivated user are revoked.
+func (s *SCIM) updateUser(r *http.Request, u *user.User, res UserResource) error {
+	if err := s.applyUser(u, res, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	if err := s.user.UpdateUser(u); err != nil {
+		switch {
+		case storage.IsNotFound(err):
+			return errModified
+		case storage.ErrorCode(err) == storage.ErrCodeExists:
+			return conflict("a user with the email already exists")
+		}
+		return err
+	}
+
+	// revoking again is harmless and retries a revocation that failed
+	if !u.Active() {
+		s.log(r, "http-server.handlers.scim.updateUser").Info("revoke tokens of deactivated user",
+			logging.StringAttr("uuid", u.UUID),
+		)
+		return s.accessToken.RevokeUserTokens(u.ID)
+	}
+
+	return nil
+}
+
+func (s *SCIM) writeUser(w http.ResponseWriter, status int, u user.User) {
+	groups, err := s.group.GetGroupsOfUsers([]int64{u.ID})
+	if err != nil {
+		coreScim.WriteError(w, err)
+		return
+	}
+
+	writeResource(w, status, s.newUserResource(u, groups[u.ID]), u.Version)
+}
+
+// patch applies the operations of the request to the representation of a
+// resource, omit removes the attributes that can't be patched.
+func patch[T any](req coreScim.PatchRequest, current T, omit func(*T)) (T, error) {
+	var patched T
+
+	if err := req.Validate(); err != nil {
+		return patched, err
+	}
+
+	omit(&current)
+	doc, err := coreScim.Document(current)
+	if err != nil {
+		return patched, err
+	}
+
+	if err := req.Apply(doc); err != nil {
+		return patched, err
+	}
+
+	err = coreScim.Decode(doc, &patched)
+	return patched, err
+}
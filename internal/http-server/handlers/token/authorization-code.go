@@ -0,0 +1,68 @@
+package token
+
+import (
+	"app/internal/domain/client"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"net/http"
+	"time"
+)
+
+// authorizationCodeGrant exchanges an authorization code for tokens
+// (RFC 6749, section 4.1.3), the PKCE code verifier is checked when the
+// code was issued with a challenge (RFC 7636, section 4.5).
+func (t *Token) authorizationCodeGrant(w http.ResponseWriter, r *http.Request, c client.Client, req Request) {
+	if req.Code == "" || req.RedirectURI == "" {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "code and redirect_uri are required")
+		return
+	}
+
+	aC, err := t.authorizationCode.GetAuthorizationCode(crypt.GetSHA256Hash(req.Code))
+	if err != nil || aC.ClientId != c.ID {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+
+	target, ok := t.target(w, r, c, req.Resource, aC.Resources, aC.Scope)
+	if !ok {
+		return
+	}
+
+	// the code is deleted before tokens are issued so that concurrent
+	// requests can't exchange it twice
+	if err := t.authorizationCode.DeleteAuthorizationCode(aC.ID); err != nil {
+		if storage.IsNotFound(err) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+			return
+		}
+		logging.L(t.ctx).Error("failed delete authorization code", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	if aC.Expired(time.Now()) {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "authorization code expired")
+		return
+	}
+
+	if aC.RedirectURI != req.RedirectURI {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match")
+		return
+	}
+
+	if !aC.VerifyCodeVerifier(req.CodeVerifier) {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid code_verifier")
+		return
+	}
+
+	u, err := t.user.GetUser(aC.UserId)
+	if err != nil {
+		logging.L(t.ctx).Error("failed get user", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid authorization code")
+		return
+	}
+
+	t.issue(w, r, u, c, target, aC.AuthTime)
+}
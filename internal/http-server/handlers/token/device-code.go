@@ -0,0 +1,90 @@
+package token
+
+import (
+	"app/internal/domain/client"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	"app/internal/storage"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"net/http"
+	"time"
+)
+
+// deviceCodeGrant exchanges an approved device code for tokens
+// (RFC 8628, section 3.4).
+func (t *Token) deviceCodeGrant(w http.ResponseWriter, r *http.Request, c client.Client, req Request) {
+	if req.DeviceCode == "" {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "device_code is required")
+		return
+	}
+
+	dC, err := t.deviceCode.GetDeviceCode(crypt.GetSHA256Hash(req.DeviceCode))
+	if err != nil || dC.ClientId != c.ID {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid device code")
+		return
+	}
+
+	now := time.Now()
+
+	if dC.Expired(now) {
+		_ = t.deviceCode.DeleteDeviceCode(dC.ID)
+		resp.OAuthError(w, r, http.StatusBadRequest, "expired_token", "device code expired")
+		return
+	}
+
+	if dC.PolledTooFast(now) {
+		dC.Interval += deviceCodeDomain.SlowDownStep
+		dC.LastPolledAt = now.Unix()
+		if err := t.deviceCode.UpdatePolling(&dC); err != nil {
+			logging.L(t.ctx).Error("failed update device code polling", logging.ErrAttr(err))
+		}
+		resp.OAuthError(w, r, http.StatusBadRequest, "slow_down", "polling too fast")
+		return
+	}
+
+	switch dC.Status {
+	case deviceCodeDomain.StatusPending:
+		dC.LastPolledAt = now.Unix()
+		if err := t.deviceCode.UpdatePolling(&dC); err != nil {
+			logging.L(t.ctx).Error("failed update device code polling", logging.ErrAttr(err))
+		}
+		resp.OAuthError(w, r, http.StatusBadRequest, "authorization_pending", "authorization pending")
+		return
+	case deviceCodeDomain.StatusDenied:
+		_ = t.deviceCode.DeleteDeviceCode(dC.ID)
+		resp.OAuthError(w, r, http.StatusBadRequest, "access_denied", "authorization denied")
+		return
+	}
+
+	target, ok := t.target(w, r, c, req.Resource, nil, dC.Scopes)
+	if !ok {
+		return
+	}
+
+	// the code is deleted before tokens are issued so that concurrent
+	// requests can't exchange it twice
+	if err := t.deviceCode.DeleteDeviceCode(dC.ID); err != nil {
+		if storage.IsNotFound(err) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid device code")
+			return
+		}
+		logging.L(t.ctx).Error("failed delete device code", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	if dC.UserId == nil {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid device code")
+		return
+	}
+
+	u, err := t.user.GetUser(*dC.UserId)
+	if err != nil {
+		logging.L(t.ctx).Error("failed get user", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid device code")
+		return
+	}
+
+	t.issue(w, r, u, c, target, dC.AuthTime)
+}
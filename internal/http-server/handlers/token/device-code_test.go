@@ -0,0 +1,158 @@
+package token_test
+
+import (
+	"app/internal/domain/client"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	coreToken "app/pkg/common/core/token"
+	"app/pkg/utils/crypt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const deviceCode = "device-code"
+
+var tv = client.Client{
+	ID:         "tv",
+	GrantTypes: []string{client.GrantTypeDeviceCode, client.GrantTypeRefreshToken},
+}
+
+// TestDeviceCodeGrant checks the answers to a polling device
+// (RFC 8628, section 3.5).
+func TestDeviceCodeGrant(t *testing.T) {
+	userID := int64(1)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name         string
+		code         deviceCodeDomain.DeviceCode
+		wantError    string
+		wantKept     bool
+		wantInterval int64
+		wantPolled   bool
+	}{
+		{
+			name:         "pending on the first poll",
+			code:         deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusPending},
+			wantError:    "authorization_pending",
+			wantKept:     true,
+			wantInterval: 5,
+			wantPolled:   true,
+		},
+		{
+			name:         "pending after the interval",
+			code:         deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusPending, LastPolledAt: now - 10},
+			wantError:    "authorization_pending",
+			wantKept:     true,
+			wantInterval: 5,
+			wantPolled:   true,
+		},
+		{
+			name:         "polled too fast",
+			code:         deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusPending, LastPolledAt: now},
+			wantError:    "slow_down",
+			wantKept:     true,
+			wantInterval: 5 + deviceCodeDomain.SlowDownStep,
+			wantPolled:   true,
+		},
+		{
+			name:         "approved code polled too fast",
+			code:         deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusApproved, UserId: &userID, LastPolledAt: now},
+			wantError:    "slow_down",
+			wantKept:     true,
+			wantInterval: 5 + deviceCodeDomain.SlowDownStep,
+			wantPolled:   true,
+		},
+		{
+			name:      "expired",
+			code:      deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusPending, ExpiresAt: now - 1},
+			wantError: "expired_token",
+		},
+		{
+			name:      "denied",
+			code:      deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusDenied},
+			wantError: "access_denied",
+		},
+		{
+			name:         "code of another client",
+			code:         deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusApproved, UserId: &userID, ClientId: "other"},
+			wantError:    "invalid_grant",
+			wantKept:     true,
+			wantInterval: 5,
+		},
+		{
+			name: "approved",
+			code: deviceCodeDomain.DeviceCode{Status: deviceCodeDomain.StatusApproved, UserId: &userID, AuthTime: now - 60},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+
+			dC := tt.code
+			dC.ID = crypt.GetSHA256Hash(deviceCode)
+			dC.Interval = 5
+			if dC.ClientId == "" {
+				dC.ClientId = tv.ID
+			}
+			if dC.ExpiresAt == 0 {
+				dC.ExpiresAt = now + 600
+			}
+			e.store.deviceCodes[dC.ID] = dC
+
+			w, res, oauthErr := e.request(t, tv, url.Values{
+				"grant_type":  {client.GrantTypeDeviceCode},
+				"device_code": {deviceCode},
+			}, nil)
+
+			stored, kept := e.store.deviceCodes[dC.ID]
+			if kept != tt.wantKept {
+				t.Fatalf("device code kept = %v, want %v", kept, tt.wantKept)
+			}
+			if kept {
+				if stored.Interval != tt.wantInterval {
+					t.Fatalf("interval = %d, want %d", stored.Interval, tt.wantInterval)
+				}
+				if polled := stored.LastPolledAt >= now; polled != tt.wantPolled {
+					t.Fatalf("poll recorded = %v, want %v", polled, tt.wantPolled)
+				}
+			}
+
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || oauthErr.Error != tt.wantError {
+					t.Fatalf("status = %d, error = %q, want %q", w.Code, oauthErr.Error, tt.wantError)
+				}
+				return
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, error = %+v", w.Code, oauthErr)
+			}
+			if res.RefreshToken == "" {
+				t.Fatal("refresh token missing")
+			}
+
+			claims, err := coreToken.ParseAccessToken(res.AccessToken, e.cfg.Token.Secret)
+			if err != nil {
+				t.Fatalf("parse issued token: %v", err)
+			}
+			if claims.UserUUID() != e.store.users[userID].UUID || claims.AuthTime != dC.AuthTime {
+				t.Fatalf("token of %s signed in at %d, want %s at %d", claims.UserUUID(), claims.AuthTime, e.store.users[userID].UUID, dC.AuthTime)
+			}
+		})
+	}
+}
+
+func TestDeviceCodeGrant_Unknown(t *testing.T) {
+	e := newEnv(t)
+
+	w, _, oauthErr := e.request(t, tv, url.Values{
+		"grant_type":  {client.GrantTypeDeviceCode},
+		"device_code": {deviceCode},
+	}, nil)
+	if w.Code != http.StatusBadRequest || oauthErr.Error != "invalid_grant" {
+		t.Fatalf("status = %d, error = %q, want invalid_grant", w.Code, oauthErr.Error)
+	}
+}
@@ -0,0 +1,81 @@
+package token
+
+import (
+	"app/internal/domain/client"
+	resourceDomain "app/internal/domain/oauth/resource"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/logging"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// target resolves the resource parameters of a token request against the
+// registry of protected resources (RFC 8707, section 2.2). A grant that
+// was authorized for a set of resources can only narrow it down, without
+// resource parameters the token is minted for all of them. The scope is
+// checked again here, the grant may have been issued before the client
+// or the resources changed.
+func (t *Token) target(
+	w http.ResponseWriter,
+	r *http.Request,
+	c client.Client,
+	requested []string,
+	authorized []string,
+	scope string,
+) (resourceDomain.Target, bool) {
+	identifiers := requested
+	if len(identifiers) == 0 {
+		identifiers = authorized
+	}
+	identifiers = slices.Compact(slices.Sorted(slices.Values(identifiers)))
+
+	var resources []resourceDomain.Resource
+	if len(identifiers) > 0 {
+		var ok bool
+		if resources, ok = t.resources(w, r, identifiers, authorized); !ok {
+			return resourceDomain.Target{}, false
+		}
+	}
+
+	target, err := resourceDomain.NewTarget(resources, scope, c.Scopes, t.cfg.Token.TTL)
+	if errors.Is(err, resourceDomain.ErrInvalidScope) {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return resourceDomain.Target{}, false
+	}
+
+	return target, true
+}
+
+// resources loads the resources of the identifiers, they must be
+// registered and authorized for the grant.
+func (t *Token) resources(
+	w http.ResponseWriter,
+	r *http.Request,
+	identifiers []string,
+	authorized []string,
+) ([]resourceDomain.Resource, bool) {
+	for _, identifier := range identifiers {
+		if err := resourceDomain.ValidIdentifier(identifier); err != nil {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_target", err.Error())
+			return nil, false
+		}
+		if len(authorized) > 0 && !slices.Contains(authorized, identifier) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_target", "resource was not authorized")
+			return nil, false
+		}
+	}
+
+	resources, err := t.resource.GetResourcesByIdentifiers(identifiers)
+	if err != nil {
+		logging.L(t.ctx).Error("failed get resources", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return nil, false
+	}
+	if len(resources) != len(identifiers) {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_target", "unknown resource")
+		return nil, false
+	}
+
+	return resources, true
+}
@@ -0,0 +1,208 @@
+package token
+
+import (
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	tokenExchangeDomain "app/internal/domain/oauth/token-exchange"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/identity"
+	coreToken "app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"encoding/json"
+	"github.com/go-chi/render"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenTypeAccessToken is the only token type accepted and issued by the
+// token exchange grant (RFC 8693, section 3).
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+type exchangeToken struct {
+	claims *coreToken.UserClaim
+	row    accessTokenDomain.AccessToken
+}
+
+// tokenExchangeGrant exchanges a user access token for a token narrowed to
+// the audiences and scopes allowed by the token exchange rules of the
+// client (RFC 8693). With an actor token the issued token is delegated and
+// names the actor in its act claim, every exchange is recorded.
+func (t *Token) tokenExchangeGrant(w http.ResponseWriter, r *http.Request, c client.Client, req Request) {
+	policy := c.TokenExchange
+	if policy == nil {
+		resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", "token exchange is not configured for the client")
+		return
+	}
+
+	if req.SubjectToken == "" || req.SubjectTokenType != TokenTypeAccessToken {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "subject_token must be an access token")
+		return
+	}
+
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeAccessToken {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "requested_token_type is not supported")
+		return
+	}
+
+	// a bound token is only exchanged with a proof of its key, the issued
+	// token is bound to the same key so the binding is carried over
+	cnf := coreToken.ConfirmationFromContext(r.Context())
+
+	subject, ok := t.exchangeToken(req.SubjectToken, cnf)
+	if !ok {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid subject_token")
+		return
+	}
+
+	act := subject.claims.Act
+	var actor *exchangeToken
+
+	if req.ActorToken != "" {
+		if req.ActorTokenType != TokenTypeAccessToken {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "actor_token must be an access token")
+			return
+		}
+		if !policy.Delegation {
+			resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", "delegation is not allowed for the client")
+			return
+		}
+
+		actor, ok = t.exchangeToken(req.ActorToken, cnf)
+		if !ok {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_grant", "invalid actor_token")
+			return
+		}
+
+		act = &accessTokenDomain.Actor{
+			Sub:      actor.claims.UserUUID(),
+			ClientID: actor.row.ClientId,
+			Act:      subject.claims.Act,
+		}
+	} else if !policy.Impersonation {
+		resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", "impersonation is not allowed for the client")
+		return
+	}
+
+	audience, err := policy.Audience(req.Audience)
+	if err != nil {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_target", err.Error())
+		return
+	}
+
+	var subjectScopes []string
+	if subject.claims.Scope != "" {
+		subjectScopes = strings.Fields(subject.claims.Scope)
+	}
+
+	scopes, err := policy.Scope(strings.Fields(req.Scope), subjectScopes)
+	if err != nil {
+		resp.OAuthError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	// the exchanged token never outlives the subject token
+	now := time.Now()
+	ttl := min(t.cfg.Token.TTL, time.Unix(subject.row.ExpiresAt, 0).Sub(now))
+
+	accessTokenID := crypt.GetMD5Hash(identity.UUIDv7())
+	scope := strings.Join(scopes, " ")
+
+	opts := t.cfg.Token.AccessToken(t.cfg.Issuer)
+	opts.TTL = ttl
+
+	accessTokenStr, err := coreToken.GenerateAccessToken(&accessTokenDomain.Payload{
+		ID:       accessTokenID,
+		UUID:     subject.claims.UserUUID(),
+		Email:    subject.claims.Email,
+		ClientID: c.ID,
+		Audience: audience,
+		Scope:    scope,
+		AuthTime: subject.claims.AuthTime,
+		Act:      act,
+		Cnf:      cnf,
+	}, opts)
+	if err != nil {
+		logging.L(t.ctx).Error("failed generate access token", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	scopesJSON, _ := json.Marshal(scopes)
+	aToken := &accessTokenDomain.AccessToken{
+		ID:        accessTokenID,
+		UserId:    subject.row.UserId,
+		ClientId:  c.ID,
+		Name:      "token-exchange",
+		Scopes:    string(scopesJSON),
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+
+	if _, err := t.accessToken.CreateToken(aToken); err != nil {
+		logging.L(t.ctx).Error("failed create access token", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	audit := &tokenExchangeDomain.TokenExchange{
+		ID:            identity.UUIDv7(),
+		ClientId:      c.ID,
+		SubjectUserId: subject.row.UserId,
+		AccessTokenId: accessTokenID,
+		Audience:      audience,
+		Scopes:        scopes,
+		CreatedAt:     now.Unix(),
+	}
+	if actor != nil {
+		audit.ActorUserId = &actor.row.UserId
+		audit.ActorClientId = &actor.row.ClientId
+	}
+
+	if err := t.tokenExchange.CreateTokenExchange(audit); err != nil {
+		logging.L(t.ctx).Error("failed record token exchange", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	logging.L(t.ctx).Info("token exchanged",
+		logging.StringAttr("client_id", c.ID),
+		logging.StringAttr("subject", subject.claims.UserUUID()),
+		logging.StringAttr("access_token_id", accessTokenID),
+		logging.BoolAttr("delegated", actor != nil),
+	)
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, r, &Response{
+		AccessToken:     accessTokenStr,
+		IssuedTokenType: TokenTypeAccessToken,
+		TokenType:       coreToken.TokenType(cnf),
+		ExpiresIn:       int64(ttl.Seconds()),
+		Scope:           scope,
+	})
+}
+
+// exchangeToken validates an access token presented in an exchange, it
+// must be live, issued by the issuer and to a user. Its audience may be
+// a resource, the exchange is how it gets a token for another one. A
+// bound token must be presented with the key it is bound to in cnf.
+func (t *Token) exchangeToken(tokenStr string, cnf *accessTokenDomain.Confirmation) (*exchangeToken, bool) {
+	claims, err := coreToken.ParseAccessToken(tokenStr, t.cfg.Token.Secret)
+	if err != nil || claims.ID == "" || claims.Issuer != t.cfg.Issuer {
+		return nil, false
+	}
+
+	if !claims.Cnf.SatisfiedBy(cnf) {
+		logging.L(t.ctx).Error("exchanged token is bound to another key", logging.StringAttr("access_token_id", claims.ID))
+		return nil, false
+	}
+
+	row, err := t.accessToken.GetToken(claims.ID)
+	if err != nil || row.Revoked || row.UserId == 0 || row.ExpiresAt <= time.Now().Unix() {
+		return nil, false
+	}
+
+	return &exchangeToken{claims: claims, row: row}, true
+}
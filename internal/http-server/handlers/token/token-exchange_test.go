@@ -0,0 +1,300 @@
+package token_test
+
+import (
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/internal/http-server/handlers/token"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/mtls"
+	coreToken "app/pkg/common/core/token"
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+var exchanger = client.Client{
+	ID:         "gateway",
+	GrantTypes: []string{client.GrantTypeTokenExchange},
+	TokenExchange: &client.TokenExchange{
+		Audiences:     []string{"https://api.test"},
+		Scopes:        []string{"read", "write"},
+		Impersonation: true,
+		Delegation:    true,
+	},
+}
+
+func exchangeForm(subjectToken string) url.Values {
+	return url.Values{
+		"grant_type":         {client.GrantTypeTokenExchange},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {token.TokenTypeAccessToken},
+	}
+}
+
+func withDPoP(jkt string) func(context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		return dpop.ContextWithThumbprint(ctx, jkt)
+	}
+}
+
+// TestTokenExchange_Binding checks that a bound token is only exchanged
+// with a proof of its key and that the issued token keeps the binding.
+func TestTokenExchange_Binding(t *testing.T) {
+	tests := []struct {
+		name      string
+		subject   *accessTokenDomain.Confirmation
+		actor     *accessTokenDomain.Confirmation
+		ctx       func(context.Context) context.Context
+		wantError string
+		wantCnf   *accessTokenDomain.Confirmation
+	}{
+		{name: "bearer token"},
+		{
+			name:      "dpop bound token without a proof",
+			subject:   &accessTokenDomain.Confirmation{JKT: "key-1"},
+			wantError: "invalid_grant",
+		},
+		{
+			name:      "dpop bound token with a proof of another key",
+			subject:   &accessTokenDomain.Confirmation{JKT: "key-1"},
+			ctx:       withDPoP("key-2"),
+			wantError: "invalid_grant",
+		},
+		{
+			name:    "dpop bound token with a proof of its key",
+			subject: &accessTokenDomain.Confirmation{JKT: "key-1"},
+			ctx:     withDPoP("key-1"),
+			wantCnf: &accessTokenDomain.Confirmation{JKT: "key-1"},
+		},
+		{
+			name:    "certificate bound token over its certificate",
+			subject: &accessTokenDomain.Confirmation{X5TS256: "cert-1"},
+			ctx: func(ctx context.Context) context.Context {
+				return mtls.ContextWithThumbprint(ctx, "cert-1")
+			},
+			wantCnf: &accessTokenDomain.Confirmation{X5TS256: "cert-1"},
+		},
+		{
+			name:      "certificate bound token without a certificate",
+			subject:   &accessTokenDomain.Confirmation{X5TS256: "cert-1"},
+			wantError: "invalid_grant",
+		},
+		{
+			name:      "dpop bound actor token without a proof",
+			actor:     &accessTokenDomain.Confirmation{JKT: "key-1"},
+			wantError: "invalid_grant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+
+			form := exchangeForm(e.accessToken(t, 1, "web", "read", tt.subject))
+			if tt.actor != nil {
+				form.Set("actor_token", e.accessToken(t, 2, "agent", "read", tt.actor))
+				form.Set("actor_token_type", token.TokenTypeAccessToken)
+			}
+
+			w, res, oauthErr := e.request(t, exchanger, form, tt.ctx)
+
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || oauthErr.Error != tt.wantError {
+					t.Fatalf("status = %d, error = %q, want %q", w.Code, oauthErr.Error, tt.wantError)
+				}
+				if len(e.store.exchanges) != 0 {
+					t.Fatal("exchange recorded for a refused request")
+				}
+				return
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, error = %+v", w.Code, oauthErr)
+			}
+			if res.TokenType != coreToken.TokenType(tt.wantCnf) {
+				t.Fatalf("token_type = %q, want %q", res.TokenType, coreToken.TokenType(tt.wantCnf))
+			}
+
+			claims, err := coreToken.ParseAccessToken(res.AccessToken, e.cfg.Token.Secret)
+			if err != nil {
+				t.Fatalf("parse issued token: %v", err)
+			}
+			if tt.wantCnf == nil {
+				if claims.Cnf != nil {
+					t.Fatalf("cnf = %+v, want none", claims.Cnf)
+				}
+				return
+			}
+			if claims.Cnf == nil || *claims.Cnf != *tt.wantCnf {
+				t.Fatalf("cnf = %+v, want %+v", claims.Cnf, tt.wantCnf)
+			}
+		})
+	}
+}
+
+// TestTokenExchange_Rules checks that exchanges follow the token exchange
+// rules of the client and never widen the subject token.
+func TestTokenExchange_Rules(t *testing.T) {
+	impersonator := exchanger
+	impersonator.TokenExchange = &client.TokenExchange{Audiences: exchanger.TokenExchange.Audiences, Scopes: []string{"read"}, Impersonation: true}
+
+	delegate := exchanger
+	delegate.TokenExchange = &client.TokenExchange{Audiences: exchanger.TokenExchange.Audiences, Scopes: []string{"read"}, Delegation: true}
+
+	withoutRules := exchanger
+	withoutRules.TokenExchange = nil
+
+	tests := []struct {
+		name         string
+		client       client.Client
+		subjectScope string
+		actor        bool
+		form         url.Values
+		wantError    string
+		wantAudience []string
+		wantScope    string
+		wantActor    string
+	}{
+		{
+			name:         "default audience and scopes held by the subject",
+			subjectScope: "read profile",
+			wantAudience: []string{"https://api.test"},
+			wantScope:    "read",
+		},
+		{
+			name:         "narrowed scope",
+			subjectScope: "read write",
+			form:         url.Values{"scope": {"write"}},
+			wantAudience: []string{"https://api.test"},
+			wantScope:    "write",
+		},
+		{
+			name:         "scope the subject does not hold",
+			subjectScope: "read",
+			form:         url.Values{"scope": {"write"}},
+			wantError:    "invalid_scope",
+		},
+		{
+			name:         "scope outside the rules",
+			subjectScope: "read profile",
+			form:         url.Values{"scope": {"profile"}},
+			wantError:    "invalid_scope",
+		},
+		{
+			name:         "audience outside the rules",
+			subjectScope: "read",
+			form:         url.Values{"audience": {"https://other.test"}},
+			wantError:    "invalid_target",
+		},
+		{
+			name:         "unsupported requested token type",
+			subjectScope: "read",
+			form:         url.Values{"requested_token_type": {"urn:ietf:params:oauth:token-type:refresh_token"}},
+			wantError:    "invalid_request",
+		},
+		{
+			name:         "subject token of another type",
+			subjectScope: "read",
+			form:         url.Values{"subject_token_type": {"urn:ietf:params:oauth:token-type:id_token"}},
+			wantError:    "invalid_request",
+		},
+		{
+			name:         "delegation",
+			client:       delegate,
+			subjectScope: "read",
+			actor:        true,
+			wantAudience: []string{"https://api.test"},
+			wantScope:    "read",
+			wantActor:    "0190a0b4-7c4e-7d2f-8a4b-3c1f2e5d6a7c",
+		},
+		{
+			name:         "impersonation not allowed",
+			client:       delegate,
+			subjectScope: "read",
+			wantError:    "unauthorized_client",
+		},
+		{
+			name:         "delegation not allowed",
+			client:       impersonator,
+			subjectScope: "read",
+			actor:        true,
+			wantError:    "unauthorized_client",
+		},
+		{
+			name:         "client without rules",
+			client:       withoutRules,
+			subjectScope: "read",
+			wantError:    "unauthorized_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEnv(t)
+
+			c := tt.client
+			if c.ID == "" {
+				c = exchanger
+			}
+
+			form := exchangeForm(e.accessToken(t, 1, "web", tt.subjectScope, nil))
+			if tt.actor {
+				form.Set("actor_token", e.accessToken(t, 2, "agent", "read", nil))
+				form.Set("actor_token_type", token.TokenTypeAccessToken)
+			}
+			for k, v := range tt.form {
+				form[k] = v
+			}
+
+			w, res, oauthErr := e.request(t, c, form, nil)
+
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || oauthErr.Error != tt.wantError {
+					t.Fatalf("status = %d, error = %q, want %q", w.Code, oauthErr.Error, tt.wantError)
+				}
+				if len(e.store.exchanges) != 0 {
+					t.Fatal("exchange recorded for a refused request")
+				}
+				return
+			}
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, error = %+v", w.Code, oauthErr)
+			}
+			if res.Scope != tt.wantScope || res.IssuedTokenType != token.TokenTypeAccessToken {
+				t.Fatalf("scope = %q, issued_token_type = %q, want %q", res.Scope, res.IssuedTokenType, tt.wantScope)
+			}
+
+			claims, err := coreToken.ParseAccessToken(res.AccessToken, e.cfg.Token.Secret)
+			if err != nil {
+				t.Fatalf("parse issued token: %v", err)
+			}
+			if !slices.Equal(claims.Audience, tt.wantAudience) || claims.Scope != tt.wantScope || claims.ClientID != c.ID {
+				t.Fatalf("aud = %v, scope = %q, client_id = %q", claims.Audience, claims.Scope, claims.ClientID)
+			}
+			if claims.UserUUID() != e.store.users[1].UUID {
+				t.Fatalf("sub = %q, want the subject", claims.UserUUID())
+			}
+
+			if len(e.store.exchanges) != 1 {
+				t.Fatalf("%d exchanges recorded, want 1", len(e.store.exchanges))
+			}
+			audit := e.store.exchanges[0]
+
+			if tt.wantActor == "" {
+				if claims.Act != nil || audit.ActorUserId != nil {
+					t.Fatalf("act = %+v, recorded actor = %v, want none", claims.Act, audit.ActorUserId)
+				}
+				return
+			}
+			if claims.Act == nil || claims.Act.Sub != tt.wantActor || claims.Act.ClientID != "agent" {
+				t.Fatalf("act = %+v, want %s of agent", claims.Act, tt.wantActor)
+			}
+			if audit.ActorUserId == nil || *audit.ActorUserId != 2 || audit.ActorClientId == nil || *audit.ActorClientId != "agent" {
+				t.Fatalf("recorded actor = %v of %v, want 2 of agent", audit.ActorUserId, audit.ActorClientId)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package token
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	resourceDomain "app/internal/domain/oauth/resource"
+	tokenExchangeDomain "app/internal/domain/oauth/token-exchange"
+	"app/internal/domain/user"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/identity"
+	coreToken "app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"mime"
+	"net/http"
+	"time"
+)
+
+type User interface {
+	GetUser(ID int64) (user.User, error)
+}
+
+type AuthToken interface {
+	Create(aT *accessTokenDomain.AccessToken, rT *refreshTokenDomain.RefreshToken) error
+}
+
+type AccessToken interface {
+	CreateToken(aT *accessTokenDomain.AccessToken) (string, error)
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+}
+
+type DeviceCode interface {
+	GetDeviceCode(ID string) (deviceCodeDomain.DeviceCode, error)
+	UpdatePolling(dC *deviceCodeDomain.DeviceCode) error
+	DeleteDeviceCode(ID string) error
+}
+
+type AuthorizationCode interface {
+	GetAuthorizationCode(ID string) (authorizationCodeDomain.AuthorizationCode, error)
+	DeleteAuthorizationCode(ID string) error
+}
+
+type TokenExchange interface {
+	CreateTokenExchange(tE *tokenExchangeDomain.TokenExchange) error
+}
+
+type Resource interface {
+	GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error)
+}
+
+type Token struct {
+	ctx               context.Context
+	user              User
+	authToken         AuthToken
+	accessToken       AccessToken
+	deviceCode        DeviceCode
+	authorizationCode AuthorizationCode
+	tokenExchange     TokenExchange
+	resource          Resource
+	cfg               *config.Config
+}
+
+func New(
+	ctx context.Context,
+	user User,
+	authToken AuthToken,
+	accessToken AccessToken,
+	deviceCode DeviceCode,
+	authorizationCode AuthorizationCode,
+	tokenExchange TokenExchange,
+	resource Resource,
+	cfg *config.Config,
+) *Token {
+	return &Token{
+		ctx:               ctx,
+		user:              user,
+		authToken:         authToken,
+		accessToken:       accessToken,
+		deviceCode:        deviceCode,
+		authorizationCode: authorizationCode,
+		tokenExchange:     tokenExchange,
+		resource:          resource,
+		cfg:               cfg,
+	}
+}
+
+type Request struct {
+	GrantType          string   `json:"grant_type"`
+	Code               string   `json:"code"`
+	RedirectURI        string   `json:"redirect_uri"`
+	CodeVerifier       string   `json:"code_verifier"`
+	DeviceCode         string   `json:"device_code"`
+	SubjectToken       string   `json:"subject_token"`
+	SubjectTokenType   string   `json:"subject_token_type"`
+	ActorToken         string   `json:"actor_token"`
+	ActorTokenType     string   `json:"actor_token_type"`
+	RequestedTokenType string   `json:"requested_token_type"`
+	Audience           []string `json:"audience"`
+	Resource           []string `json:"resource"`
+	Scope              string   `json:"scope"`
+}
+
+// Response is the access token response (RFC 6749, section 5.1).
+type Response struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// Issue is the token endpoint, it dispatches on the grant type. The client
+// is authenticated by the client authentication middleware.
+func (t *Token) Issue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "http-server.handlers.token.Issue"
+
+		logging.L(t.ctx).With(
+			logging.StringAttr("op", op),
+			logging.StringAttr("request_id", middleware.GetReqID(r.Context())),
+		).Info("token request")
+
+		c, ok := clientauth.FromContext(r.Context())
+		if !ok {
+			resp.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+
+		req, err := decodeRequest(r)
+		if err != nil {
+			logging.L(t.ctx).Error("failed to decode request body", logging.ErrAttr(err))
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "failed to decode request")
+			return
+		}
+
+		if req.GrantType == "" {
+			resp.OAuthError(w, r, http.StatusBadRequest, "invalid_request", "grant_type is required")
+			return
+		}
+
+		if !c.AllowsGrant(req.GrantType) {
+			resp.OAuthError(w, r, http.StatusBadRequest, "unauthorized_client", "client is not allowed to use the grant type")
+			return
+		}
+
+		switch req.GrantType {
+		case client.GrantTypeAuthorizationCode:
+			t.authorizationCodeGrant(w, r, c, req)
+		case client.GrantTypeDeviceCode:
+			t.deviceCodeGrant(w, r, c, req)
+		case client.GrantTypeTokenExchange:
+			t.tokenExchangeGrant(w, r, c, req)
+		default:
+			resp.OAuthError(w, r, http.StatusBadRequest, "unsupported_grant_type", "grant type is not supported")
+		}
+	}
+}
+
+// issue creates an access and a refresh token for the user and writes the
+// token response, the access token is minted for the target resources.
+// authTime is when the user authenticated for the grant.
+func (t *Token) issue(
+	w http.ResponseWriter,
+	r *http.Request,
+	u user.User,
+	c client.Client,
+	target resourceDomain.Target,
+	authTime int64,
+) {
+	now := time.Now()
+	cnf := coreToken.ConfirmationFromContext(r.Context())
+	accessTokenID := crypt.GetMD5Hash(identity.UUIDv7())
+
+	opts := t.cfg.Token.AccessToken(t.cfg.Issuer)
+	opts.TTL = target.TTL
+
+	accessTokenStr, err := coreToken.GenerateAccessToken(&accessTokenDomain.Payload{
+		ID:       accessTokenID,
+		UUID:     u.UUID,
+		Email:    u.Email,
+		ClientID: c.ID,
+		Scopes:   "[*]",
+		Audience: target.Audience,
+		Scope:    target.Scope,
+		AuthTime: authTime,
+		Cnf:      cnf,
+	}, opts)
+	if err != nil {
+		logging.L(t.ctx).Error("failed generate access token", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	refreshTokenID := crypt.GetMD5Hash(identity.UUIDv7())
+	refreshExp := now.Add(t.cfg.Token.Refresh).Unix()
+
+	refreshTokenStr, err := coreToken.GenerateRefreshToken(&refreshTokenDomain.Payload{
+		UUID:           u.UUID,
+		Email:          u.Email,
+		TokenAccessId:  accessTokenID,
+		TokenRefreshId: refreshTokenID,
+		ClientId:       c.ID,
+		UserId:         u.ID,
+		ExpiresAt:      refreshExp,
+		Scopes:         "[*]",
+		Audience:       target.Audience,
+		Scope:          target.Scope,
+		AccessTokenTTL: int64(target.TTL.Seconds()),
+		AuthTime:       authTime,
+		Cnf:            cnf,
+	})
+	if err != nil {
+		logging.L(t.ctx).Error("failed generate refresh token", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	aToken := &accessTokenDomain.AccessToken{
+		ID:        accessTokenID,
+		UserId:    u.ID,
+		ClientId:  c.ID,
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
+		ExpiresAt: now.Add(target.TTL).Unix(),
+	}
+	rToken := &refreshTokenDomain.RefreshToken{
+		ID:            refreshTokenID,
+		AccessTokenId: accessTokenID,
+		ExpiresAt:     refreshExp,
+	}
+
+	if err := t.authToken.Create(aToken, rToken); err != nil {
+		logging.L(t.ctx).Error("failed create token", logging.ErrAttr(err))
+		resp.OAuthError(w, r, http.StatusInternalServerError, "server_error", "failed create token")
+		return
+	}
+
+	res := &Response{
+		AccessToken: accessTokenStr,
+		TokenType:   coreToken.TokenType(cnf),
+		ExpiresIn:   int64(target.TTL.Seconds()),
+		Scope:       target.Scope,
+	}
+	if c.AllowsGrant(client.GrantTypeRefreshToken) {
+		res.RefreshToken = refreshTokenStr
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	render.JSON(w, r, res)
+}
+
+// decodeRequest reads a form encoded token request, the repeatable
+// parameters such as audience can't be decoded by render.Decode. JSON
+// bodies are accepted as well.
+func decodeRequest(r *http.Request) (Request, error) {
+	var req Request
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		err := render.DecodeJSON(r.Body, &req)
+		return req, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return req, err
+	}
+
+	req.GrantType = r.PostForm.Get("grant_type")
+	req.Code = r.PostForm.Get("code")
+	req.RedirectURI = r.PostForm.Get("redirect_uri")
+	req.CodeVerifier = r.PostForm.Get("code_verifier")
+	req.DeviceCode = r.PostForm.Get("device_code")
+	req.SubjectToken = r.PostForm.Get("subject_token")
+	req.SubjectTokenType = r.PostForm.Get("subject_token_type")
+	req.ActorToken = r.PostForm.Get("actor_token")
+	req.ActorTokenType = r.PostForm.Get("actor_token_type")
+	req.RequestedTokenType = r.PostForm.Get("requested_token_type")
+	req.Audience = r.PostForm["audience"]
+	req.Resource = r.PostForm["resource"]
+	req.Scope = r.PostForm.Get("scope")
+
+	return req, nil
+}
@@ -0,0 +1,252 @@
+package token_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	resourceDomain "app/internal/domain/oauth/resource"
+	tokenExchangeDomain "app/internal/domain/oauth/token-exchange"
+	"app/internal/domain/user"
+	"app/internal/http-server/handlers/token"
+	resp "app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	coreToken "app/pkg/common/core/token"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const issuer = "https://sso.test"
+
+type fakeStore struct {
+	users              map[int64]user.User
+	accessTokens       map[string]accessTokenDomain.AccessToken
+	deviceCodes        map[string]deviceCodeDomain.DeviceCode
+	authorizationCodes map[string]authorizationCodeDomain.AuthorizationCode
+	exchanges          []tokenExchangeDomain.TokenExchange
+}
+
+func (s *fakeStore) GetUser(ID int64) (user.User, error) {
+	u, ok := s.users[ID]
+	if !ok {
+		return user.User{}, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (s *fakeStore) Create(aT *accessTokenDomain.AccessToken, _ *refreshTokenDomain.RefreshToken) error {
+	s.accessTokens[aT.ID] = *aT
+	return nil
+}
+
+func (s *fakeStore) CreateToken(aT *accessTokenDomain.AccessToken) (string, error) {
+	s.accessTokens[aT.ID] = *aT
+	return aT.ID, nil
+}
+
+func (s *fakeStore) GetToken(ID string) (accessTokenDomain.AccessToken, error) {
+	aT, ok := s.accessTokens[ID]
+	if !ok {
+		return accessTokenDomain.AccessToken{}, pgx.ErrNoRows
+	}
+	return aT, nil
+}
+
+func (s *fakeStore) GetDeviceCode(ID string) (deviceCodeDomain.DeviceCode, error) {
+	dC, ok := s.deviceCodes[ID]
+	if !ok {
+		return deviceCodeDomain.DeviceCode{}, pgx.ErrNoRows
+	}
+	return dC, nil
+}
+
+func (s *fakeStore) UpdatePolling(dC *deviceCodeDomain.DeviceCode) error {
+	s.deviceCodes[dC.ID] = *dC
+	return nil
+}
+
+func (s *fakeStore) DeleteDeviceCode(ID string) error {
+	if _, ok := s.deviceCodes[ID]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(s.deviceCodes, ID)
+	return nil
+}
+
+func (s *fakeStore) GetAuthorizationCode(ID string) (authorizationCodeDomain.AuthorizationCode, error) {
+	aC, ok := s.authorizationCodes[ID]
+	if !ok {
+		return authorizationCodeDomain.AuthorizationCode{}, pgx.ErrNoRows
+	}
+	return aC, nil
+}
+
+func (s *fakeStore) DeleteAuthorizationCode(ID string) error {
+	if _, ok := s.authorizationCodes[ID]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(s.authorizationCodes, ID)
+	return nil
+}
+
+func (s *fakeStore) CreateTokenExchange(tE *tokenExchangeDomain.TokenExchange) error {
+	s.exchanges = append(s.exchanges, *tE)
+	return nil
+}
+
+func (s *fakeStore) GetResourcesByIdentifiers([]string) ([]resourceDomain.Resource, error) {
+	return nil, nil
+}
+
+type env struct {
+	store   *fakeStore
+	cfg     *config.Config
+	handler http.Handler
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	writeRefreshTokenKeys(t)
+
+	e := &env{
+		store: &fakeStore{
+			users: map[int64]user.User{
+				1: {ID: 1, UUID: "0190a0b4-7c4e-7d2f-8a4b-3c1f2e5d6a7b", Email: "alice@example.com"},
+				2: {ID: 2, UUID: "0190a0b4-7c4e-7d2f-8a4b-3c1f2e5d6a7c", Email: "bob@example.com"},
+			},
+			accessTokens:       map[string]accessTokenDomain.AccessToken{},
+			deviceCodes:        map[string]deviceCodeDomain.DeviceCode{},
+			authorizationCodes: map[string]authorizationCodeDomain.AuthorizationCode{},
+		},
+		cfg: &config.Config{
+			Issuer: issuer,
+			Token:  config.Token{TTL: time.Hour, Refresh: 24 * time.Hour, Secret: "secret"},
+		},
+	}
+	s := e.store
+	e.handler = token.New(context.Background(), s, s, s, s, s, s, s, e.cfg).Issue()
+
+	return e
+}
+
+// accessToken mints a live access token of the user for the client.
+func (e *env) accessToken(t *testing.T, userID int64, clientID, scope string, cnf *accessTokenDomain.Confirmation) string {
+	t.Helper()
+
+	u := e.store.users[userID]
+	ID := clientID + "-" + u.UUID + "-" + scope
+	if cnf != nil {
+		ID += "-" + cnf.JKT + cnf.X5TS256
+	}
+
+	tokenStr, err := coreToken.GenerateAccessToken(&accessTokenDomain.Payload{
+		ID:       ID,
+		UUID:     u.UUID,
+		Email:    u.Email,
+		ClientID: clientID,
+		Scope:    scope,
+		Cnf:      cnf,
+	}, e.cfg.Token.AccessToken(issuer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.store.accessTokens[ID] = accessTokenDomain.AccessToken{
+		ID:        ID,
+		UserId:    userID,
+		ClientId:  clientID,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+
+	return tokenStr
+}
+
+// request sends a token request of the authenticated client, ctx adds the
+// key bindings the middlewares would have verified.
+func (e *env) request(
+	t *testing.T,
+	c client.Client,
+	form url.Values,
+	ctx func(context.Context) context.Context,
+) (*httptest.ResponseRecorder, token.Response, resp.OAuthErrorResponse) {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	reqCtx := clientauth.ContextWithClient(r.Context(), c)
+	if ctx != nil {
+		reqCtx = ctx(reqCtx)
+	}
+	w := httptest.NewRecorder()
+
+	e.handler.ServeHTTP(w, r.WithContext(reqCtx))
+
+	var res token.Response
+	var oauthErr resp.OAuthErrorResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	} else if err := json.Unmarshal(w.Body.Bytes(), &oauthErr); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+
+	return w, res, oauthErr
+}
+
+// refreshTokenKey is shared by the tests, the refresh token payload only
+// fits into the chunks of a 4096-bit key.
+var refreshTokenKey = sync.OnceValues(func() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 4096)
+})
+
+// writeRefreshTokenKeys creates the refresh token keys in a temporary
+// working directory, they are read from a path relative to it.
+func writeRefreshTokenKeys(t *testing.T) {
+	t.Helper()
+
+	key, err := refreshTokenKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "storage", "secret")
+	if err := os.MkdirAll(keyDir, 0o700); err != nil {
+		t.Fatalf("create key dir: %v", err)
+	}
+
+	public := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-public.key"), public, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-private.key"), private, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"app/internal/config"
+	"app/pkg/common/core/api/response"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/logging"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// AdminAuthentication requires an access token granted the admin scope
+// to one of the administrators and stores it in the request context, it
+// protects the management APIs. A token without the scope is refused
+// with insufficient_scope (RFC 6750, section 3.1).
+func AdminAuthentication(
+	ctx context.Context,
+	accessTokens AccessTokens,
+	verifier *dpop.Verifier,
+	issuer string,
+	cfg config.Token,
+	admin config.Admin,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			aT, claims, scheme, err := userAccessToken(r, accessTokens, verifier, issuer, cfg)
+			if err != nil {
+				logging.L(ctx).Warn("admin authentication failed", logging.ErrAttr(err))
+				unauthorized(w, r, verifier, scheme, err)
+				return
+			}
+
+			if !slices.Contains(strings.Fields(claims.Scope), admin.Scope) {
+				logging.L(ctx).Warn("admin authentication failed, the access token lacks the scope",
+					logging.StringAttr("scope", admin.Scope),
+				)
+				w.Header().Set("WWW-Authenticate", scheme+` error="insufficient_scope", scope="`+admin.Scope+`"`)
+				response.OAuthError(w, r, http.StatusForbidden, "insufficient_scope", "the access token lacks the "+admin.Scope+" scope")
+				return
+			}
+
+			if !admin.Allows(claims.UserUUID(), claims.Scope) {
+				logging.L(ctx).Warn("admin authentication failed, the user is not an administrator",
+					logging.StringAttr("user", claims.UserUUID()),
+				)
+				response.OAuthError(w, r, http.StatusForbidden, "access_denied", "the user is not an administrator")
+				return
+			}
+
+			next.ServeHTTP(w, withAccessToken(r, aT, claims))
+		})
+	}
+}
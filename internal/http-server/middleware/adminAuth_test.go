@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	scimDomain "app/internal/domain/scim"
+	scimToken "app/internal/http-server/handlers/scim-token"
+	"app/internal/http-server/middleware"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/token"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const issuer = "https://sso.test"
+
+type accessTokens map[string]accessTokenDomain.AccessToken
+
+func (a accessTokens) GetToken(ID string) (accessTokenDomain.AccessToken, error) {
+	aT, ok := a[ID]
+	if !ok {
+		return accessTokenDomain.AccessToken{}, errors.New("not found")
+	}
+	return aT, nil
+}
+
+type scimTokens struct{}
+
+func (scimTokens) CreateToken(*scimDomain.Token) error    { return nil }
+func (scimTokens) GetTokens() ([]scimDomain.Token, error) { return []scimDomain.Token{}, nil }
+func (scimTokens) DeleteToken(string) error               { return nil }
+
+// TestAdminAuthentication_SCIMTokens checks that the SCIM tokens, which
+// grant the whole provisioning API, are only managed by the
+// administrators, the admin scope alone is not enough.
+func TestAdminAuthentication_SCIMTokens(t *testing.T) {
+	cfg := config.Token{TTL: time.Hour, Secret: "secret"}
+	admin := config.Admin{Scope: "admin", Users: []string{"admin-uuid"}}
+	stored := accessTokens{}
+
+	mint := func(payload *accessTokenDomain.Payload, iss string) string {
+		t.Helper()
+
+		payload.ID = payload.UUID + payload.Scope + iss + strings.Join(payload.Audience, " ")
+		tokenStr, err := token.GenerateAccessToken(payload, cfg.AccessToken(iss))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored[payload.ID] = accessTokenDomain.AccessToken{ID: payload.ID, UserId: 1, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+		return tokenStr
+	}
+	issue := func(userUUID, scope string) string {
+		return mint(&accessTokenDomain.Payload{UUID: userUUID, ClientID: "app", Scope: scope}, issuer)
+	}
+
+	handler := middleware.AdminAuthentication(context.Background(), stored, dpop.New(dpop.Options{}), issuer, cfg, admin)(
+		scimToken.New(context.Background(), scimTokens{}).ListTokens(),
+	)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "administrator", token: issue("admin-uuid", "admin"), status: http.StatusOK},
+		{name: "user granted the admin scope", token: issue("user-uuid", "admin"), status: http.StatusForbidden},
+		{name: "administrator without the admin scope", token: issue("admin-uuid", "profile"), status: http.StatusForbidden},
+		{
+			name:   "administrator token restricted to a resource",
+			token:  mint(&accessTokenDomain.Payload{UUID: "admin-uuid", ClientID: "app", Scope: "admin", Audience: []string{"https://api.test"}}, issuer),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "administrator token of another issuer",
+			token:  mint(&accessTokenDomain.Payload{UUID: "admin-uuid", ClientID: "app", Scope: "admin"}, "https://other.test"),
+			status: http.StatusUnauthorized,
+		},
+		{name: "anonymous", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/scim/tokens", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
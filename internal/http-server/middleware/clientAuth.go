@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"app/internal/domain/client"
+	"app/pkg/common/core/api/response"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type clientCredentialsBody struct {
+	ClientID            string `json:"client_id"`
+	ClientSecret        string `json:"client_secret"`
+	ClientAssertionType string `json:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion"`
+}
+
+// ClientAuthentication authenticates the OAuth client of the request with
+// the method it is registered with and stores it in the request context.
+func ClientAuthentication(
+	ctx context.Context,
+	authenticator *clientauth.Authenticator,
+	issuer string,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			creds, err := ClientCredentials(r)
+			if err == nil {
+				var c client.Client
+				c, err = authenticator.Authenticate(creds, strings.TrimRight(issuer, "/")+r.URL.Path)
+				if err == nil {
+					reqCtx := clientauth.ContextWithClient(r.Context(), c)
+					// tokens issued over mutual TLS are bound to the certificate
+					if len(creds.Certificates) > 0 {
+						reqCtx = mtls.ContextWithThumbprint(reqCtx, mtls.Thumbprint(creds.Certificates[0]))
+					}
+					next.ServeHTTP(w, r.WithContext(reqCtx))
+					return
+				}
+			}
+
+			logging.L(ctx).Warn("client authentication failed",
+				logging.StringAttr("client_id", creds.ClientID),
+				logging.StringAttr("method", creds.Method),
+				logging.ErrAttr(err),
+			)
+
+			if creds.Method == client.AuthMethodClientSecretBasic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			}
+
+			response.OAuthError(w, r, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		})
+	}
+}
+
+// ClientCredentials extracts client credentials from the Authorization
+// header and from a form or JSON encoded request body.
+func ClientCredentials(r *http.Request) (clientauth.Credentials, error) {
+	var creds clientauth.Credentials
+
+	body, err := credentialsBody(r)
+	if err != nil {
+		return creds, err
+	}
+
+	methods := 0
+
+	if r.TLS != nil {
+		creds.Certificates = r.TLS.PeerCertificates
+	}
+
+	if basicID, basicSecret, ok := r.BasicAuth(); ok {
+		methods++
+		creds.Method = client.AuthMethodClientSecretBasic
+
+		if creds.ClientID, err = url.QueryUnescape(basicID); err != nil {
+			return creds, clientauth.ErrInvalidClient
+		}
+		if creds.ClientSecret, err = url.QueryUnescape(basicSecret); err != nil {
+			return creds, clientauth.ErrInvalidClient
+		}
+		if body.ClientID != "" && body.ClientID != creds.ClientID {
+			return creds, clientauth.ErrInvalidClient
+		}
+	}
+
+	if body.ClientSecret != "" {
+		methods++
+		creds.Method = client.AuthMethodClientSecretPost
+		creds.ClientID = body.ClientID
+		creds.ClientSecret = body.ClientSecret
+	}
+
+	if body.ClientAssertion != "" || body.ClientAssertionType != "" {
+		methods++
+		creds.Method = client.AuthMethodPrivateKeyJWT
+		creds.ClientID = body.ClientID
+		creds.AssertionType = body.ClientAssertionType
+		creds.Assertion = body.ClientAssertion
+	}
+
+	switch {
+	case methods > 1:
+		return creds, clientauth.ErrMultipleMethods
+	case methods == 0:
+		creds.Method = client.AuthMethodNone
+		creds.ClientID = body.ClientID
+	}
+
+	return creds, nil
+}
+
+func credentialsBody(r *http.Request) (clientCredentialsBody, error) {
+	var body clientCredentialsBody
+
+	if r.Body == nil {
+		return body, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return body, err
+	}
+	r.Body = io.NopCloser(bytes.NewBuffer(data))
+
+	if len(data) == 0 {
+		return body, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return body, err
+		}
+		body.ClientID = values.Get("client_id")
+		body.ClientSecret = values.Get("client_secret")
+		body.ClientAssertionType = values.Get("client_assertion_type")
+		body.ClientAssertion = values.Get("client_assertion")
+		return body, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	if err := json.Unmarshal(data, &body); errors.As(err, &syntaxErr) {
+		return body, err
+	}
+
+	return body, nil
+}
@@ -0,0 +1,110 @@
+package middleware_test
+
+import (
+	"app/internal/domain/client"
+	"app/internal/http-server/middleware"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/mtls/mtlstest"
+	"app/pkg/common/core/token"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type clients map[string]client.Client
+
+func (c clients) GetClient(ID string) (client.Client, error) {
+	oauthClient, ok := c[ID]
+	if !ok {
+		return client.Client{}, errors.New("not found")
+	}
+	return oauthClient, nil
+}
+
+// TestClientAuthentication_CertificateBound authenticates a tls_client_auth
+// client over a TLS listener and checks that the tokens issued to the
+// request are bound to the client certificate.
+func TestClientAuthentication_CertificateBound(t *testing.T) {
+	serverCA := mtlstest.NewCA(t, "server ca")
+	clientCA := mtlstest.NewCA(t, "client ca")
+	clientCert := clientCA.IssueClient(t, "svc", "svc.internal")
+
+	oauthClient := client.Client{ID: "svc", TLSClientAuth: &client.TLSClientAuth{SANDNS: "svc.internal"}}
+	if err := oauthClient.SetAuthMethod(client.AuthMethodTLSClientAuth, nil); err != nil {
+		t.Fatalf("SetAuthMethod: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.Cert)
+
+	authenticator := clientauth.New(clients{"svc": oauthClient})
+	authenticator.TrustClientCAs(clientCAs)
+
+	handler := middleware.ClientAuthentication(context.Background(), authenticator, "https://sso.test")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cnf := token.ConfirmationFromContext(r.Context())
+			if cnf != nil {
+				_, _ = io.WriteString(w, cnf.X5TS256)
+			}
+		}),
+	)
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCA.IssueServer(t).TLSCertificate()},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.Cert)
+
+	post := func(cert *mtlstest.Certificate) (int, string) {
+		cfg := &tls.Config{RootCAs: roots}
+		if cert != nil {
+			cfg.Certificates = []tls.Certificate{cert.TLSCertificate()}
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		defer httpClient.CloseIdleConnections()
+
+		res, err := httpClient.Post(
+			srv.URL+"/oauth/token",
+			"application/x-www-form-urlencoded",
+			strings.NewReader(url.Values{"client_id": {"svc"}}.Encode()),
+		)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	status, body := post(clientCert)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", status, http.StatusOK, body)
+	}
+	if want := mtls.Thumbprint(clientCert.Cert); body != want {
+		t.Fatalf("x5t#S256 = %q, want %q", body, want)
+	}
+
+	if status, _ := post(nil); status != http.StatusUnauthorized {
+		t.Fatalf("without certificate: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	if status, _ := post(mtlstest.SelfSigned(t, "svc")); status != http.StatusUnauthorized {
+		t.Fatalf("with a self-signed certificate: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
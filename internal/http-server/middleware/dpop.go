@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"app/pkg/common/core/api/response"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DPoPProof verifies the DPoP proof sent to the token endpoints and
+// stores the thumbprint of its key in the request context, the issued
+// tokens are then bound to that key. Requests without a proof pass
+// through unchanged.
+func DPoPProof(
+	ctx context.Context,
+	verifier *dpop.Verifier,
+	issuer string,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if verifier.RequiresNonce() {
+				w.Header().Set(dpop.NonceHeader, verifier.Nonce())
+			}
+
+			proofs := r.Header.Values(dpop.Header)
+			if len(proofs) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(proofs) > 1 {
+				response.OAuthError(w, r, http.StatusBadRequest, "invalid_dpop_proof", "exactly one DPoP proof is required")
+				return
+			}
+
+			jkt, err := verifier.Verify(proofs[0], r.Method, strings.TrimRight(issuer, "/")+r.URL.Path, "")
+			if err != nil {
+				logging.L(ctx).Warn("dpop proof rejected", logging.ErrAttr(err))
+
+				if errors.Is(err, dpop.ErrUseNonce) {
+					response.OAuthError(w, r, http.StatusBadRequest, "use_dpop_nonce", "a DPoP nonce is required")
+					return
+				}
+
+				response.OAuthError(w, r, http.StatusBadRequest, "invalid_dpop_proof", "the DPoP proof is invalid")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(dpop.ContextWithThumbprint(r.Context(), jkt)))
+		})
+	}
+}
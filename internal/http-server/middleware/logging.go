@@ -38,7 +38,7 @@ func Logging(
 			if r.Body != nil {
 				bodyBytes, err := io.ReadAll(r.Body)
 				if err != nil {
-					logging.L(ctx).Error("Failed to read request body: %v", err)
+					logging.L(ctx).Error("Failed to read request body", logging.ErrAttr(err))
 				} else {
 					requestContent = string(bodyBytes)
 				}
@@ -58,7 +58,7 @@ func Logging(
 
 			body, err := json.Marshal(logEntry)
 			if err != nil {
-				logging.L(ctx).Error("Error when serializing the log record: %v", err)
+				logging.L(ctx).Error("Error when serializing the log record", logging.ErrAttr(err))
 				next.ServeHTTP(wrappedWriter, r)
 				return
 			}
@@ -88,3 +88,14 @@ func (rw *ResponseWriterWrapper) Write(data []byte) (int, error) {
 	rw.Body.Write(data)
 	return rw.ResponseWriter.Write(data)
 }
+
+// Flush lets the streamed responses of the gateway through the wrapper.
+func (rw *ResponseWriterWrapper) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *ResponseWriterWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
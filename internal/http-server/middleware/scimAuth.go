@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	scimDomain "app/internal/domain/scim"
+	"app/pkg/common/core/scim"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"net/http"
+)
+
+type ScimTokens interface {
+	GetTokenByHash(hash string) (scimDomain.Token, error)
+}
+
+// SCIMAuthentication requires one of the bearer tokens issued to the
+// provisioning clients, they are looked up by their hash.
+func SCIMAuthentication(ctx context.Context, tokens ScimTokens) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, tokenStr, ok := token.AuthorizationToken(r)
+			if ok && scheme == "Bearer" {
+				t, err := tokens.GetTokenByHash(crypt.GetSHA256Hash(tokenStr))
+				if err == nil {
+					logging.L(ctx).Info("scim client", logging.StringAttr("token", t.Name))
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			logging.L(ctx).Warn("scim authentication failed")
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			scim.WriteError(w, &scim.Error{Status: http.StatusUnauthorized, Detail: "authentication failed"})
+		})
+	}
+}
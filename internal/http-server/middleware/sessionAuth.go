@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"app/internal/config"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"net/http"
+)
+
+// SessionAuthentication stores the access token of the user in the
+// request context when there is one, read from the Authorization header
+// or else from the session cookie of a browser. The cookie only carries
+// bearer tokens, a browser navigation can't sign a DPoP proof. Requests
+// without a valid token go on unauthenticated, the handler decides how
+// the user signs in.
+func SessionAuthentication(
+	ctx context.Context,
+	accessTokens AccessTokens,
+	verifier *dpop.Verifier,
+	issuer string,
+	cfg config.Token,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, tokenStr, ok := token.AuthorizationToken(r)
+			if !ok {
+				if tokenStr, ok = token.SessionToken(r); !ok {
+					next.ServeHTTP(w, r)
+					return
+				}
+				scheme = "Bearer"
+			}
+
+			aT, claims, _, err := verifyAccessToken(r, scheme, tokenStr, accessTokens, verifier, issuer, cfg)
+			if err != nil {
+				logging.L(ctx).Warn("session authentication failed", logging.ErrAttr(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, withAccessToken(r, aT, claims))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"app/internal/config"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	"app/pkg/common/core/api/response"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidToken     = errors.New("the access token is invalid")
+	errCertificateBound = errors.New("the access token is bound to another client certificate")
+)
+
+type AccessTokens interface {
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+}
+
+// UserAuthentication requires a valid access token issued to a user and
+// stores it with its claims in the request context. DPoP-bound tokens must be presented
+// with the DPoP scheme and a proof signed with the bound key, certificate
+// bound tokens over mutual TLS with the bound certificate, so resource
+// servers can mount it to enforce the binding.
+func UserAuthentication(
+	ctx context.Context,
+	accessTokens AccessTokens,
+	verifier *dpop.Verifier,
+	issuer string,
+	cfg config.Token,
+) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			aT, claims, scheme, err := userAccessToken(r, accessTokens, verifier, issuer, cfg)
+			if err != nil {
+				logging.L(ctx).Warn("user authentication failed", logging.ErrAttr(err))
+				unauthorized(w, r, verifier, scheme, err)
+				return
+			}
+
+			next.ServeHTTP(w, withAccessToken(r, aT, claims))
+		})
+	}
+}
+
+// withAccessToken stores the access token the request was authenticated
+// with and its claims in the request context.
+func withAccessToken(r *http.Request, aT accessTokenDomain.AccessToken, claims *token.UserClaim) *http.Request {
+	ctx := token.ContextWithAccessToken(r.Context(), aT)
+	return r.WithContext(token.ContextWithClaims(ctx, claims))
+}
+
+func userAccessToken(
+	r *http.Request,
+	accessTokens AccessTokens,
+	verifier *dpop.Verifier,
+	issuer string,
+	cfg config.Token,
+) (accessTokenDomain.AccessToken, *token.UserClaim, string, error) {
+	scheme, tokenStr, ok := token.AuthorizationToken(r)
+	if !ok {
+		return accessTokenDomain.AccessToken{}, nil, "Bearer", errInvalidToken
+	}
+
+	return verifyAccessToken(r, scheme, tokenStr, accessTokens, verifier, issuer, cfg)
+}
+
+// verifyAccessToken checks the access token sent with the scheme, that
+// it was issued for the endpoints of the issuer, its binding to the DPoP
+// key or the client certificate of the request and that it is a live
+// token of a user.
+func verifyAccessToken(
+	r *http.Request,
+	scheme, tokenStr string,
+	accessTokens AccessTokens,
+	verifier *dpop.Verifier,
+	issuer string,
+	cfg config.Token,
+) (accessTokenDomain.AccessToken, *token.UserClaim, string, error) {
+	claims, err := token.ParseAccessToken(tokenStr, cfg.Secret)
+	if err != nil || claims.ID == "" || !claims.IntendedFor(issuer) {
+		return accessTokenDomain.AccessToken{}, nil, scheme, errInvalidToken
+	}
+
+	if token.TokenType(claims.Cnf) != scheme {
+		return accessTokenDomain.AccessToken{}, nil, scheme, errInvalidToken
+	}
+
+	if scheme == dpop.TokenType {
+		proof := r.Header.Values(dpop.Header)
+		if len(proof) != 1 {
+			return accessTokenDomain.AccessToken{}, nil, scheme, dpop.ErrInvalidProof
+		}
+
+		uri := strings.TrimRight(issuer, "/") + r.URL.Path
+		if err := verifier.VerifyBound(proof[0], r.Method, uri, tokenStr, claims.Cnf.JKT); err != nil {
+			return accessTokenDomain.AccessToken{}, nil, scheme, err
+		}
+	}
+
+	if claims.Cnf != nil && claims.Cnf.X5TS256 != "" {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 ||
+			mtls.Thumbprint(r.TLS.PeerCertificates[0]) != claims.Cnf.X5TS256 {
+			return accessTokenDomain.AccessToken{}, nil, scheme, errCertificateBound
+		}
+	}
+
+	aT, err := accessTokens.GetToken(claims.ID)
+	if err != nil || aT.Revoked || aT.UserId == 0 || aT.ExpiresAt < time.Now().Unix() {
+		return accessTokenDomain.AccessToken{}, nil, scheme, errInvalidToken
+	}
+
+	return aT, claims, scheme, nil
+}
+
+// unauthorized writes the challenge of the scheme the token was sent with
+// (RFC 6750, section 3 and RFC 9449, section 7.1).
+func unauthorized(w http.ResponseWriter, r *http.Request, verifier *dpop.Verifier, scheme string, err error) {
+	code := "invalid_token"
+	switch {
+	case errors.Is(err, dpop.ErrUseNonce):
+		code = "use_dpop_nonce"
+		w.Header().Set(dpop.NonceHeader, verifier.Nonce())
+	case scheme == dpop.TokenType && !errors.Is(err, errInvalidToken) && !errors.Is(err, errCertificateBound):
+		code = "invalid_dpop_proof"
+	}
+
+	w.Header().Set("WWW-Authenticate", scheme+` error="`+code+`"`)
+	response.OAuthError(w, r, http.StatusUnauthorized, code, err.Error())
+}
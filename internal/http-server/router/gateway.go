@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"app/internal/http-server/gateway"
+	"app/internal/http-server/handlers/openapi"
+	"context"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+)
+
+func RegisterGatewayRoutes(
+	r chi.Router,
+	ctx context.Context,
+	gatewayConn *grpc.ClientConn,
+) {
+	gw, err := gateway.New(ctx, gatewayConn)
+	if err != nil {
+		panic(err)
+	}
+
+	r.Handle("/v1/*", gw)
+	r.Get("/openapi.json", openapi.New(ctx))
+}
@@ -3,18 +3,18 @@ package routes
 import (
 	"app/internal/config"
 	"app/internal/http-server/handlers/health"
+	"app/internal/storage"
 	"app/pkg/client/rabbitmq"
 	"context"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func RegisterHealthRoutes(
 	r chi.Router,
 	ctx context.Context,
 	cfg *config.Config,
-	pgClient *pgxpool.Pool,
+	storages *storage.Storage,
 	queueClient *rabbitmq.App,
 ) {
-	r.Get("/health", health.New(ctx, cfg, pgClient, queueClient))
+	r.Get("/health", health.New(ctx, cfg, storages, queueClient))
 }
@@ -2,11 +2,29 @@ package routes
 
 import (
 	"app/internal/config"
+	authorizeHTTP "app/internal/http-server/handlers/authorize"
 	clientHTTP "app/internal/http-server/handlers/client"
+	clientRegistrationHTTP "app/internal/http-server/handlers/client-registration"
+	deviceHTTP "app/internal/http-server/handlers/device"
+	deviceAuthorizationHTTP "app/internal/http-server/handlers/device-authorization"
+	federationHTTP "app/internal/http-server/handlers/federation"
+	introspectHTTP "app/internal/http-server/handlers/introspect"
 	loginHTTP "app/internal/http-server/handlers/login"
+	parHTTP "app/internal/http-server/handlers/par"
 	refreshHTTP "app/internal/http-server/handlers/refresh-token"
 	registerHTTP "app/internal/http-server/handlers/register"
+	resourceHTTP "app/internal/http-server/handlers/resource"
+	revokeHTTP "app/internal/http-server/handlers/revoke"
+	samlIdpHTTP "app/internal/http-server/handlers/saml-idp"
+	tokenHTTP "app/internal/http-server/handlers/token"
+	httpMiddleware "app/internal/http-server/middleware"
+	authService "app/internal/service/auth"
+	introspectService "app/internal/service/introspect"
 	"app/internal/storage"
+	"app/pkg/client/rabbitmq"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/mtls"
 	"context"
 	"github.com/go-chi/chi/v5"
 )
@@ -15,33 +33,192 @@ func RegisterOAuthRoutes(
 	r chi.Router,
 	ctx context.Context,
 	storages *storage.Storage,
+	queueClient *rabbitmq.App,
+	dpopVerifier *dpop.Verifier,
 	cfg *config.Config,
 ) {
+	auth := authService.New(
+		ctx,
+		storages.User,
+		storages.AccessToken,
+		storages.RefreshToken,
+		storages.AuthToken,
+		cfg.Issuer,
+		cfg.Token,
+	)
+
+	introspect := introspectService.New(
+		ctx,
+		storages.AccessToken,
+		storages.RefreshToken,
+		storages.Resource,
+		cfg.Token,
+	)
+
 	r.Post("/oauth/registration",
-		registerHTTP.New(ctx, storages.User),
+		registerHTTP.New(ctx, auth),
 	)
 
-	r.Post("/oauth/login",
-		loginHTTP.New(
+	authenticator := clientauth.New(storages.Client, cfg.Issuer)
+	if cfg.HTTP.TLS.ClientCAFile != "" {
+		clientCAs, err := mtls.LoadCertPool(cfg.HTTP.TLS.ClientCAFile)
+		if err != nil {
+			panic(err)
+		}
+		authenticator.TrustClientCAs(clientCAs)
+	}
+	r.Group(func(r chi.Router) {
+		r.Use(httpMiddleware.ClientAuthentication(ctx, authenticator, cfg.Issuer))
+		r.Use(httpMiddleware.DPoPProof(ctx, dpopVerifier, cfg.Issuer))
+
+		r.Post("/oauth/login",
+			loginHTTP.New(ctx, auth),
+		)
+
+		r.Post("/oauth/refresh-token",
+			refreshHTTP.New(ctx, auth),
+		)
+
+		r.Post("/oauth/introspect",
+			introspectHTTP.New(ctx, introspect),
+		)
+
+		r.Post("/oauth/revoke",
+			revokeHTTP.New(
+				ctx,
+				storages.AccessToken,
+				storages.RefreshToken,
+				cfg.Token,
+			),
+		)
+
+		if !storages.Full() {
+			return
+		}
+
+		r.Post("/oauth/par",
+			parHTTP.New(ctx, storages.AuthorizationRequest, cfg),
+		)
+
+		r.Post("/oauth/device_authorization",
+			deviceAuthorizationHTTP.New(ctx, storages.DeviceCode, cfg),
+		)
+
+		token := tokenHTTP.New(
 			ctx,
 			storages.User,
 			storages.AuthToken,
-			storages.Client,
-			cfg.Token,
-		),
-	)
-
-	r.Post("/oauth/refresh-token",
-		refreshHTTP.New(
-			ctx,
 			storages.AccessToken,
-			storages.RefreshToken,
-			storages.Client,
-			cfg.Token,
-		),
+			storages.DeviceCode,
+			storages.AuthorizationCode,
+			storages.TokenExchange,
+			storages.Resource,
+			cfg,
+		)
+		r.Post("/oauth/token", token.Issue())
+	})
+
+	adminAuthentication := adminAuthentication(ctx, storages, dpopVerifier, cfg)
+
+	client := clientHTTP.New(ctx, storages.Client, cfg)
+	r.Group(func(r chi.Router) {
+		r.Use(adminAuthentication)
+
+		r.Get("/oauth/clients", client.ListClients())
+		r.Get("/oauth/client/{client:[a-z]{1,20}}", client.GetClient())
+		r.Post("/oauth/client", client.CreateClient())
+		r.Patch("/oauth/client/{id}", client.UpdateClient())
+		r.Delete("/oauth/client/{id}", client.DeleteClient())
+		r.Post("/oauth/client/{id}/revoke", client.RevokeClient())
+		r.Post("/oauth/client/{id}/restore", client.RestoreClient())
+		r.Post("/oauth/client/{id}/secret", client.RotateSecret())
+	})
+
+	if cfg.SAML.CertFile != "" {
+		idp, err := samlIdpHTTP.New(ctx, storages.User, cfg)
+		if err != nil {
+			panic(err)
+		}
+
+		r.Get("/saml/metadata", idp.Metadata())
+		r.Delete("/saml/session", idp.EndSession())
+		r.Group(func(r chi.Router) {
+			r.Use(httpMiddleware.UserAuthentication(ctx, storages.AccessToken, dpopVerifier, cfg.Issuer, cfg.Token))
+
+			r.Post("/saml/session", idp.Session())
+		})
+		// browsers come with the session cookie, the ones without it are
+		// sent to the login page
+		r.Group(func(r chi.Router) {
+			r.Use(httpMiddleware.SessionAuthentication(ctx, storages.AccessToken, dpopVerifier, cfg.Issuer, cfg.Token))
+
+			r.Get("/saml/sso", idp.SSO())
+			r.Post("/saml/sso", idp.SSO())
+		})
+	}
+
+	// the resources set the audiences and the lifetimes of the tokens
+	resource := resourceHTTP.New(ctx, storages.Resource)
+	r.Group(func(r chi.Router) {
+		r.Use(adminAuthentication)
+
+		r.Get("/oauth/resources", resource.ListResources())
+		r.Get("/oauth/resource/{id}", resource.GetResource())
+		r.Post("/oauth/resource", resource.CreateResource())
+		r.Patch("/oauth/resource/{id}", resource.UpdateResource())
+		r.Delete("/oauth/resource/{id}", resource.DeleteResource())
+	})
+
+	registration := clientRegistrationHTTP.New(ctx, storages.Client, cfg)
+	r.Post("/oauth/register", registration.Register())
+	r.Get("/oauth/register/{id}", registration.Read())
+	r.Put("/oauth/register/{id}", registration.Update())
+	r.Delete("/oauth/register/{id}", registration.Delete())
+
+	// The authorization and device flows and the federation keep their
+	// state in the storages only PostgreSQL provides.
+	if !storages.Full() {
+		return
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(httpMiddleware.UserAuthentication(ctx, storages.AccessToken, dpopVerifier, cfg.Issuer, cfg.Token))
+
+		r.Get("/oauth/authorize",
+			authorizeHTTP.New(
+				ctx,
+				storages.Client,
+				storages.AuthorizationRequest,
+				storages.AuthorizationCode,
+				storages.Resource,
+				cfg,
+			),
+		)
+
+		device := deviceHTTP.New(ctx, storages.DeviceCode, storages.Client)
+		r.Get("/oauth/device", device.GetDeviceCode())
+		r.Post("/oauth/device", device.Verify())
+	})
+
+	federation := federationHTTP.New(
+		ctx,
+		storages.Client,
+		storages.User,
+		storages.Federation,
+		storages.Federation,
+		storages.Resource,
+		storages.AuthorizationCode,
+		queueClient,
+		cfg,
 	)
+	r.Get("/oauth/federation/{provider}", federation.Authorize())
+	r.Get("/oauth/federation/{provider}/callback", federation.Callback())
+
+	r.Group(func(r chi.Router) {
+		r.Use(httpMiddleware.UserAuthentication(ctx, storages.AccessToken, dpopVerifier, cfg.Issuer, cfg.Token))
 
-	client := clientHTTP.New(ctx, storages.Client)
-	r.Get("/oauth/client/{client:[a-z]{1,20}}", client.GetClient())
-	r.Post("/oauth/client", client.CreateClient())
+		r.Get("/oauth/identities", federation.Identities())
+		r.Post("/oauth/identities/{provider}/link", federation.Link())
+		r.Delete("/oauth/identities/{id}", federation.Unlink())
+	})
 }
@@ -2,11 +2,14 @@ package routes
 
 import (
 	"app/internal/config"
+	httpMiddleware "app/internal/http-server/middleware"
 	"app/internal/storage"
 	"app/pkg/client/rabbitmq"
+	"app/pkg/common/core/dpop"
 	"context"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"net/http"
 )
 
 func RegisterRoutes(
@@ -14,9 +17,37 @@ func RegisterRoutes(
 	ctx context.Context,
 	cfg *config.Config,
 	storages *storage.Storage,
-	pgClient *pgxpool.Pool,
 	queueClient *rabbitmq.App,
+	gatewayConn *grpc.ClientConn,
 ) {
-	RegisterOAuthRoutes(r, ctx, storages, cfg)
-	RegisterHealthRoutes(r, ctx, cfg, pgClient, queueClient)
+	// the verifier is shared so the nonces and the replayed proofs are
+	// tracked across all the routes
+	dpopVerifier := dpop.New(dpop.Options{
+		Lifetime:      cfg.DPoP.ProofLifetime,
+		RequireNonce:  cfg.DPoP.RequireNonce,
+		NonceLifetime: cfg.DPoP.NonceLifetime,
+		NonceSecret:   cfg.DPoP.NonceKey(cfg.Token.Secret),
+	})
+
+	RegisterOAuthRoutes(r, ctx, storages, queueClient, dpopVerifier, cfg)
+	RegisterSCIMRoutes(r, ctx, storages, dpopVerifier, cfg)
+	RegisterHealthRoutes(r, ctx, cfg, storages, queueClient)
+	RegisterGatewayRoutes(r, ctx, gatewayConn)
+}
+
+// adminAuthentication protects the management APIs.
+func adminAuthentication(
+	ctx context.Context,
+	storages *storage.Storage,
+	dpopVerifier *dpop.Verifier,
+	cfg *config.Config,
+) func(next http.Handler) http.Handler {
+	return httpMiddleware.AdminAuthentication(
+		ctx,
+		storages.AccessToken,
+		dpopVerifier,
+		cfg.Issuer,
+		cfg.Token,
+		cfg.Admin,
+	)
 }
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"app/internal/config"
+	scimHTTP "app/internal/http-server/handlers/scim"
+	scimTokenHTTP "app/internal/http-server/handlers/scim-token"
+	httpMiddleware "app/internal/http-server/middleware"
+	"app/internal/storage"
+	"app/pkg/common/core/dpop"
+	"context"
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterSCIMRoutes(
+	r chi.Router,
+	ctx context.Context,
+	storages *storage.Storage,
+	dpopVerifier *dpop.Verifier,
+	cfg *config.Config,
+) {
+	if !storages.Full() {
+		return
+	}
+
+	// the tokens grant the whole provisioning API, only admins issue them
+	scimToken := scimTokenHTTP.New(ctx, storages.Scim)
+	r.Group(func(r chi.Router) {
+		r.Use(adminAuthentication(ctx, storages, dpopVerifier, cfg))
+
+		r.Get("/scim/tokens", scimToken.ListTokens())
+		r.Post("/scim/tokens", scimToken.CreateToken())
+		r.Delete("/scim/tokens/{id}", scimToken.DeleteToken())
+	})
+
+	scim := scimHTTP.New(ctx, storages.User, storages.Group, storages.AccessToken, cfg)
+
+	r.Route("/scim/v2", func(r chi.Router) {
+		r.Use(httpMiddleware.SCIMAuthentication(ctx, storages.Scim))
+
+		r.Get("/ServiceProviderConfig", scim.ServiceProviderConfig())
+		r.Get("/Schemas", scim.Schemas())
+		r.Get("/Schemas/{id}", scim.Schema())
+		r.Get("/ResourceTypes", scim.ResourceTypes())
+		r.Get("/ResourceTypes/{id}", scim.ResourceType())
+
+		r.Get("/Users", scim.ListUsers())
+		r.Post("/Users", scim.CreateUser())
+		r.Get("/Users/{id}", scim.GetUser())
+		r.Put("/Users/{id}", scim.ReplaceUser())
+		r.Patch("/Users/{id}", scim.PatchUser())
+		r.Delete("/Users/{id}", scim.DeleteUser())
+
+		r.Get("/Groups", scim.ListGroups())
+		r.Post("/Groups", scim.CreateGroup())
+		r.Get("/Groups/{id}", scim.GetGroup())
+		r.Put("/Groups/{id}", scim.ReplaceGroup())
+		r.Patch("/Groups/{id}", scim.PatchGroup())
+		r.Delete("/Groups/{id}", scim.DeleteGroup())
+	})
+}
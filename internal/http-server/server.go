@@ -9,26 +9,21 @@ import (
 	"app/pkg/common/logging"
 	"context"
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
 )
 
 func New(
 	ctx context.Context,
-	pgClient *pgxpool.Pool,
+	storages *storage.Storage,
 	cfg *config.Config,
 	queueClient *rabbitmq.App,
+	gatewayConn *grpc.ClientConn,
 ) (*chi.Mux, error) {
 	r := chi.NewRouter()
 
-	storages, err := storage.New(ctx, pgClient)
-	if err != nil {
-		logging.L(ctx).Error("failed to initialize storage", err)
-		return nil, err
-	}
-
 	httpMiddleware.RegisterMiddlewares(r, ctx, cfg, queueClient)
 
-	routes.RegisterRoutes(r, ctx, cfg, storages, pgClient, queueClient)
+	routes.RegisterRoutes(r, ctx, cfg, storages, queueClient, gatewayConn)
 
 	logging.L(ctx).Info("server prepared successfully")
 
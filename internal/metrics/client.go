@@ -19,12 +19,14 @@ var requestGRPCMetrics = promauto.NewSummaryVec(prometheus.SummaryOpts{
 	Subsystem:  "grpc",
 	Name:       "request",
 	Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-}, []string{"status"})
+}, []string{"method", "code"})
 
 func ObserveHttpRequest(d time.Duration, status int) {
 	requestHttpMetrics.WithLabelValues(strconv.Itoa(status)).Observe(d.Seconds())
 }
 
-func ObserveGRPCRequest(d time.Duration, status int) {
-	requestGRPCMetrics.WithLabelValues(strconv.Itoa(status)).Observe(d.Seconds())
+// ObserveGRPCRequest records a call by its full method name and status
+// code name.
+func ObserveGRPCRequest(d time.Duration, method, code string) {
+	requestGRPCMetrics.WithLabelValues(method, code).Observe(d.Seconds())
 }
@@ -9,24 +9,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/jackc/pgx/v5/pgxpool"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"time"
 )
 
 type HandleRegistration struct {
-	dbClient    *pgxpool.Pool
 	queueClient *rabbitmq.App
 	storages    *storage.Storage
 }
 
 func NewHandleRegistration(
-	dbClient *pgxpool.Pool,
 	queueClient *rabbitmq.App,
 	storages *storage.Storage,
 ) *HandleRegistration {
 	return &HandleRegistration{
-		dbClient:    dbClient,
 		queueClient: queueClient,
 		storages:    storages,
 	}
@@ -52,10 +48,10 @@ func (h *HandleRegistration) Process(
 	userData.CreatedAt = timeUnix
 	userData.UpdatedAt = timeUnix
 
-	logging.L(ctx).Info("user", userData)
+	logging.L(ctx).Info("user", logging.StringAttr("email", userData.Email))
 	if userData.Name == "" || userData.Email == "" || userData.Password == "" {
 		err := fmt.Errorf("missing required user fields: Name or Email or Possword")
-		logging.L(ctx).Error("invalid user data", "error", err, "user", userData)
+		logging.L(ctx).Error("invalid user data", logging.ErrAttr(err), logging.StringAttr("email", userData.Email))
 		return err
 	}
 
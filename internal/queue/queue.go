@@ -1,5 +1,12 @@
 package queue
 
+import "encoding/json"
+
+// Publisher publishes messages to an exchange.
+type Publisher interface {
+	PublishMsg(exchangeName, routingKey string, msg []byte)
+}
+
 type QueueConfig struct {
 	Exchange   string
 	Queue      string
@@ -17,4 +24,20 @@ var List = map[string]QueueConfig{
 		Queue:      "register:user-registration-signal",
 		RoutingKey: "cCI6IkpXVC",
 	},
+	"identityEvents": {
+		Exchange:   "amq.direct",
+		Queue:      "sso:identity-events",
+		RoutingKey: "aWRlbnRpdH",
+	},
+}
+
+// Publish sends v encoded as JSON to the queue of the list.
+func Publish(publisher Publisher, name string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	publisher.PublishMsg(List[name].Exchange, List[name].RoutingKey, body)
+	return nil
 }
@@ -0,0 +1,414 @@
+package auth
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/internal/domain/oauth/resource"
+	"app/internal/domain/user"
+	"app/internal/storage"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"time"
+)
+
+// The messages of the errors are returned to the clients of both
+// transports.
+var (
+	ErrInvalidCredentials  = errors.New("incorrect login or password")
+	ErrUnauthorizedClient  = errors.New("unauthorized client")
+	ErrInvalidScope        = errors.New("scope is not allowed for the client")
+	ErrInvalidRefreshToken = errors.New("refresh token invalid")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrInvalidToken        = errors.New("the access token is invalid")
+	ErrUserExists          = errors.New("user already exists")
+	ErrCreateUser          = errors.New("failed create user")
+	ErrCreateToken         = errors.New("failed create token")
+)
+
+type User interface {
+	Registration(req *user.CreateUser) error
+	Login(req *user.User) (user.User, error)
+	GetUserByUUID(UUID string) (user.User, error)
+}
+
+type AccessToken interface {
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+	ExistsToken(aT *accessTokenDomain.AccessToken) (bool, error)
+	UpdateToken(aT *accessTokenDomain.AccessToken) (bool, error)
+	RevokeToken(ID string) error
+}
+
+type RefreshToken interface {
+	GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error)
+	GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error)
+	UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
+}
+
+type AuthToken interface {
+	Create(aT *accessTokenDomain.AccessToken, rT *refreshTokenDomain.RefreshToken) error
+}
+
+// Auth holds the sign in logic shared by the HTTP and the gRPC
+// transports, the clients are authenticated by the transports.
+type Auth struct {
+	ctx          context.Context
+	user         User
+	accessToken  AccessToken
+	refreshToken RefreshToken
+	authToken    AuthToken
+	issuer       string
+	cfg          config.Token
+}
+
+// Registration holds the fields of a new user, they are validated by the
+// transports.
+type Registration struct {
+	Name     string
+	Email    string
+	Password string
+}
+
+type Tokens struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	ExpiredAt    int64
+}
+
+func New(
+	ctx context.Context,
+	user User,
+	accessToken AccessToken,
+	refreshToken RefreshToken,
+	authToken AuthToken,
+	issuer string,
+	cfg config.Token,
+) *Auth {
+	return &Auth{
+		ctx:          ctx,
+		user:         user,
+		accessToken:  accessToken,
+		refreshToken: refreshToken,
+		authToken:    authToken,
+		issuer:       issuer,
+		cfg:          cfg,
+	}
+}
+
+func (a *Auth) Register(reg Registration) (user.User, error) {
+	const op = "service.auth.Register"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	password, err := crypt.GeneratePasswordHash(reg.Password)
+	if err != nil {
+		logging.L(a.ctx).Error("invalid generate hash password", logging.ErrAttr(err))
+		return user.User{}, ErrCreateUser
+	}
+
+	now := time.Now().Unix()
+
+	var usr = &user.CreateUser{
+		UUID:      identity.UUIDv7(),
+		Password:  password,
+		Email:     reg.Email,
+		Name:      reg.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := a.user.Registration(usr); err != nil {
+		if storage.ErrorCode(err) == storage.ErrCodeExists {
+			logging.L(a.ctx).Info("user already exists")
+			return user.User{}, ErrUserExists
+		}
+		return user.User{}, ErrCreateUser
+	}
+
+	u, err := a.user.GetUserByUUID(usr.UUID)
+	if err != nil {
+		return user.User{}, ErrCreateUser
+	}
+
+	return u, nil
+}
+
+// Login issues tokens to the client for the user signing in with the
+// password grant, the scope must be registered for the client and cnf
+// binds the tokens to a key.
+func (a *Auth) Login(
+	c client.Client,
+	login, password, scope string,
+	cnf *accessTokenDomain.Confirmation,
+) (Tokens, error) {
+	const op = "service.auth.Login"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	u, err := a.user.Login(&user.User{Email: login, Name: login})
+	if err != nil || crypt.VerifyPassword(u.Password, password) != nil {
+		logging.L(a.ctx).Error("authentication failed")
+		return Tokens{}, ErrInvalidCredentials
+	}
+
+	if !c.AllowsGrant(client.GrantTypePassword) {
+		logging.L(a.ctx).Error("client is not allowed to use the password grant")
+		return Tokens{}, ErrUnauthorizedClient
+	}
+
+	if _, err := resource.NewTarget(nil, scope, c.Scopes, a.cfg.TTL); err != nil {
+		logging.L(a.ctx).Error("scope is not registered for the client", logging.StringAttr("scope", scope))
+		return Tokens{}, ErrInvalidScope
+	}
+
+	return a.issue(&accessTokenDomain.Payload{
+		UUID:     u.UUID,
+		Email:    u.Email,
+		ClientID: c.ID,
+		Scopes:   "[*]",
+		Scope:    scope,
+		AuthTime: time.Now().Unix(),
+		Cnf:      cnf,
+	}, u.ID, a.cfg.AccessToken(a.issuer), 0)
+}
+
+// RefreshToken rotates the refresh token issued to the client, the old
+// tokens are revoked, the new ones keep the time the user authenticated.
+func (a *Auth) RefreshToken(
+	c client.Client,
+	refreshToken string,
+	cnf *accessTokenDomain.Confirmation,
+) (Tokens, error) {
+	const op = "service.auth.RefreshToken"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	payload, err := token.ParseRefreshToken(refreshToken)
+	if err != nil {
+		logging.L(a.ctx).Error("refresh token invalid")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	if payload.ExpiresAt < time.Now().Unix() {
+		logging.L(a.ctx).Error("refresh token expired")
+		return Tokens{}, ErrRefreshTokenExpired
+	}
+
+	if c.ID != payload.ClientId {
+		logging.L(a.ctx).Error("refresh token was issued to another client")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	if !c.AllowsGrant(client.GrantTypeRefreshToken) {
+		logging.L(a.ctx).Error("client is not allowed to use the refresh token grant")
+		return Tokens{}, ErrUnauthorizedClient
+	}
+
+	// a bound refresh token is only accepted with a proof of the same key,
+	// the new tokens keep the binding
+	if !payload.Cnf.SatisfiedBy(cnf) {
+		logging.L(a.ctx).Error("refresh token is bound to another key")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	var rT = &refreshTokenDomain.RefreshToken{
+		AccessTokenId: payload.TokenAccessId,
+		ID:            payload.TokenRefreshId,
+	}
+
+	if err := a.lastReceived(rT); err != nil {
+		return Tokens{}, err
+	}
+
+	var aT = &accessTokenDomain.AccessToken{
+		ClientId: payload.ClientId,
+		UserId:   payload.UserId,
+		ID:       payload.TokenAccessId,
+	}
+
+	exists, err := a.accessToken.ExistsToken(aT)
+	if err != nil || !exists {
+		logging.L(a.ctx).Error("not fount access token in database")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	if updated, err := a.accessToken.UpdateToken(aT); err != nil || !updated {
+		logging.L(a.ctx).Error("access token was not revoked")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	if updated, err := a.refreshToken.UpdateToken(rT); err != nil || !updated {
+		logging.L(a.ctx).Error("refresh token was not revoked")
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	// the new access token keeps the audience, the scope and the lifetime
+	// of the resources the grant was issued for
+	opts := a.cfg.AccessToken(a.issuer)
+	if payload.AccessTokenTTL > 0 {
+		opts.TTL = time.Duration(payload.AccessTokenTTL) * time.Second
+	}
+
+	return a.issue(&accessTokenDomain.Payload{
+		UUID:     payload.UUID,
+		Email:    payload.Email,
+		ClientID: c.ID,
+		Scopes:   "[*]",
+		Audience: payload.Audience,
+		Scope:    payload.Scope,
+		AuthTime: payload.AuthTime,
+		Cnf:      cnf,
+	}, payload.UserId, opts, payload.AccessTokenTTL)
+}
+
+// ValidateToken returns the claims and the stored access token of an
+// active access token issued to a user for the endpoints of the issuer.
+func (a *Auth) ValidateToken(accessToken string) (*token.UserClaim, accessTokenDomain.AccessToken, error) {
+	const op = "service.auth.ValidateToken"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	claims, err := token.ParseAccessToken(accessToken, a.cfg.Secret)
+	if err != nil || claims.ID == "" || !claims.IntendedFor(a.issuer) {
+		return nil, accessTokenDomain.AccessToken{}, ErrInvalidToken
+	}
+
+	aT, err := a.accessToken.GetToken(claims.ID)
+	if err != nil || aT.Revoked || aT.UserId == 0 || aT.ExpiresAt < time.Now().Unix() {
+		return nil, accessTokenDomain.AccessToken{}, ErrInvalidToken
+	}
+
+	return claims, aT, nil
+}
+
+// Logout revokes the access token and the refresh tokens issued with it,
+// a refresh token that was rotated from it is revoked as well.
+func (a *Auth) Logout(accessToken, refreshToken string) error {
+	const op = "service.auth.Logout"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	_, aT, err := a.ValidateToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		payload, err := token.ParseRefreshToken(refreshToken)
+		if err != nil || payload.ClientId != aT.ClientId || payload.UserId != aT.UserId {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := a.accessToken.RevokeToken(payload.TokenAccessId); err != nil {
+			return err
+		}
+	}
+
+	return a.accessToken.RevokeToken(aT.ID)
+}
+
+// GetUser returns the active user the access token was issued to.
+func (a *Auth) GetUser(accessToken string) (user.User, error) {
+	const op = "service.auth.GetUser"
+	logging.L(a.ctx).Info("op", logging.StringAttr("op", op))
+
+	claims, _, err := a.ValidateToken(accessToken)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	u, err := a.user.GetUserByUUID(claims.UserUUID())
+	if storage.IsNotFound(err) || (err == nil && !u.Active()) {
+		return user.User{}, ErrInvalidToken
+	}
+
+	return u, err
+}
+
+// lastReceived checks that the refresh token is known, not revoked and
+// the last one received by the user.
+func (a *Auth) lastReceived(rT *refreshTokenDomain.RefreshToken) error {
+	stored, err := a.refreshToken.GetToken(rT)
+	if err != nil || stored.Revoked {
+		logging.L(a.ctx).Error("refresh token is unknown or revoked")
+		return ErrInvalidRefreshToken
+	}
+
+	last, err := a.refreshToken.GetLastReceivedToken(rT)
+	if err != nil || last.Revoked || last.ID != rT.ID {
+		logging.L(a.ctx).Error("refresh token mismatch with the last received token")
+		return ErrInvalidRefreshToken
+	}
+
+	return nil
+}
+
+// issue stores and signs a new access token with its refresh token,
+// accessTokenTTL is carried by the refresh token when the grant set it.
+func (a *Auth) issue(
+	payload *accessTokenDomain.Payload,
+	userID int64,
+	opts token.Options,
+	accessTokenTTL int64,
+) (Tokens, error) {
+	payload.ID = crypt.GetMD5Hash(identity.UUIDv7())
+
+	accessTokenStr, err := token.GenerateAccessToken(payload, opts)
+	if err != nil {
+		logging.L(a.ctx).Error("failed generate access token", logging.ErrAttr(err))
+		return Tokens{}, ErrCreateToken
+	}
+
+	now := time.Now()
+	expAt := now.Add(opts.TTL).Unix()
+
+	var aT = &accessTokenDomain.AccessToken{
+		ID:        payload.ID,
+		UserId:    userID,
+		ClientId:  payload.ClientID,
+		Revoked:   false,
+		CreatedAt: now.Unix(),
+		UpdatedAt: now.Unix(),
+		ExpiresAt: expAt,
+	}
+
+	var rT = &refreshTokenDomain.RefreshToken{
+		ID:            crypt.GetMD5Hash(identity.UUIDv7()),
+		AccessTokenId: aT.ID,
+		Revoked:       false,
+		ExpiresAt:     now.Add(a.cfg.Refresh).Unix(),
+	}
+
+	refreshTokenStr, err := token.GenerateRefreshToken(&refreshTokenDomain.Payload{
+		UUID:           payload.UUID,
+		Email:          payload.Email,
+		TokenAccessId:  aT.ID,
+		TokenRefreshId: rT.ID,
+		ClientId:       payload.ClientID,
+		UserId:         userID,
+		ExpiresAt:      rT.ExpiresAt,
+		Scopes:         payload.Scopes,
+		Audience:       payload.Audience,
+		Scope:          payload.Scope,
+		AccessTokenTTL: accessTokenTTL,
+		AuthTime:       payload.AuthTime,
+		Cnf:            payload.Cnf,
+	})
+	if err != nil {
+		logging.L(a.ctx).Error("failed generate refresh token", logging.ErrAttr(err))
+		return Tokens{}, ErrCreateToken
+	}
+
+	if err := a.authToken.Create(aT, rT); err != nil {
+		logging.L(a.ctx).Error("failed create token", logging.ErrAttr(err))
+		return Tokens{}, ErrCreateToken
+	}
+
+	return Tokens{
+		AccessToken:  accessTokenStr,
+		TokenType:    token.TokenType(payload.Cnf),
+		RefreshToken: refreshTokenStr,
+		ExpiredAt:    expAt,
+	}, nil
+}
@@ -0,0 +1,398 @@
+package auth_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/internal/domain/user"
+	"app/internal/service/auth"
+	"app/pkg/utils/crypt"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const password = "password123"
+
+type users struct {
+	users    []user.User
+	register error
+}
+
+func (u *users) Registration(req *user.CreateUser) error {
+	if u.register != nil {
+		return u.register
+	}
+	u.users = append(u.users, user.User{
+		ID:       int64(len(u.users) + 1),
+		UUID:     req.UUID,
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	return nil
+}
+
+func (u *users) Login(req *user.User) (user.User, error) {
+	for _, usr := range u.users {
+		if usr.Email == req.Email || usr.Name == req.Name {
+			return usr, nil
+		}
+	}
+	return user.User{}, pgx.ErrNoRows
+}
+
+func (u *users) GetUserByUUID(UUID string) (user.User, error) {
+	for _, usr := range u.users {
+		if usr.UUID == UUID {
+			return usr, nil
+		}
+	}
+	return user.User{}, pgx.ErrNoRows
+}
+
+// tokens stores the access tokens and the refresh tokens issued with them.
+type tokens struct {
+	access  map[string]accessTokenDomain.AccessToken
+	refresh []refreshTokenDomain.RefreshToken
+}
+
+func newTokens() *tokens {
+	return &tokens{access: map[string]accessTokenDomain.AccessToken{}}
+}
+
+func (s *tokens) Create(aT *accessTokenDomain.AccessToken, rT *refreshTokenDomain.RefreshToken) error {
+	s.access[aT.ID] = *aT
+	s.refresh = append(s.refresh, *rT)
+	return nil
+}
+
+func (s *tokens) GetToken(ID string) (accessTokenDomain.AccessToken, error) {
+	aT, ok := s.access[ID]
+	if !ok {
+		return accessTokenDomain.AccessToken{}, pgx.ErrNoRows
+	}
+	return aT, nil
+}
+
+func (s *tokens) ExistsToken(aT *accessTokenDomain.AccessToken) (bool, error) {
+	stored, ok := s.access[aT.ID]
+	return ok && stored.ClientId == aT.ClientId && stored.UserId == aT.UserId, nil
+}
+
+func (s *tokens) UpdateToken(aT *accessTokenDomain.AccessToken) (bool, error) {
+	return s.revoke(aT.ID), nil
+}
+
+func (s *tokens) RevokeToken(ID string) error {
+	s.revoke(ID)
+	return nil
+}
+
+func (s *tokens) revoke(ID string) bool {
+	aT, ok := s.access[ID]
+	if !ok {
+		return false
+	}
+	aT.Revoked = true
+	s.access[ID] = aT
+
+	for i := range s.refresh {
+		if s.refresh[i].AccessTokenId == ID {
+			s.refresh[i].Revoked = true
+		}
+	}
+	return true
+}
+
+// refreshTokens is the refresh token storage view of tokens, its methods
+// clash with the access token ones.
+type refreshTokens struct {
+	*tokens
+}
+
+func (s refreshTokens) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	for _, stored := range s.refresh {
+		if stored.ID == rT.ID && stored.AccessTokenId == rT.AccessTokenId {
+			return stored, nil
+		}
+	}
+	return refreshTokenDomain.RefreshToken{}, pgx.ErrNoRows
+}
+
+func (s refreshTokens) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	userID := s.access[rT.AccessTokenId].UserId
+	for i := len(s.refresh) - 1; i >= 0; i-- {
+		if s.access[s.refresh[i].AccessTokenId].UserId == userID {
+			return s.refresh[i], nil
+		}
+	}
+	return refreshTokenDomain.RefreshToken{}, pgx.ErrNoRows
+}
+
+func (s refreshTokens) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
+	for i := range s.refresh {
+		if s.refresh[i].ID == rT.ID && s.refresh[i].AccessTokenId == rT.AccessTokenId {
+			s.refresh[i].Revoked = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type env struct {
+	auth   *auth.Auth
+	users  *users
+	tokens *tokens
+	client client.Client
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	writeRefreshTokenKeys(t)
+
+	hash, err := crypt.GeneratePasswordHash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &env{
+		users: &users{users: []user.User{{
+			ID:       1,
+			UUID:     "0190a0b4-7c4e-7d2f-8a4b-3c1f2e5d6a7b",
+			Name:     "alice",
+			Email:    "alice@example.com",
+			Password: hash,
+		}}},
+		tokens: newTokens(),
+		client: client.Client{
+			ID:         "app",
+			GrantTypes: []string{client.GrantTypePassword, client.GrantTypeRefreshToken},
+		},
+	}
+	e.auth = auth.New(
+		context.Background(),
+		e.users,
+		e.tokens,
+		refreshTokens{e.tokens},
+		e.tokens,
+		"https://sso.example.com",
+		config.Token{TTL: time.Hour, Refresh: 24 * time.Hour, Secret: "secret"},
+	)
+	return e
+}
+
+func TestAuth_Register(t *testing.T) {
+	e := newEnv(t)
+
+	u, err := e.auth.Register(auth.Registration{Name: "bob", Email: "bob@example.com", Password: password})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if u.UUID == "" || u.Email != "bob@example.com" {
+		t.Fatalf("unexpected user %+v", u)
+	}
+	if err := crypt.VerifyPassword(u.Password, password); err != nil {
+		t.Fatalf("password is not hashed: %v", err)
+	}
+
+	e.users.register = &pgconn.PgError{Code: "23505"}
+	_, err = e.auth.Register(auth.Registration{Name: "bob", Email: "bob@example.com", Password: password})
+	if !errors.Is(err, auth.ErrUserExists) {
+		t.Fatalf("err = %v, want %v", err, auth.ErrUserExists)
+	}
+}
+
+func TestAuth_Login(t *testing.T) {
+	e := newEnv(t)
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		scope    string
+		client   client.Client
+		wantErr  error
+	}{
+		{name: "email", login: "alice@example.com", password: password, client: e.client},
+		{name: "name", login: "alice", password: password, client: e.client},
+		{name: "unknown user", login: "bob", password: password, client: e.client, wantErr: auth.ErrInvalidCredentials},
+		{name: "wrong password", login: "alice", password: "wrong", client: e.client, wantErr: auth.ErrInvalidCredentials},
+		{
+			name:     "client without the password grant",
+			login:    "alice",
+			password: password,
+			client:   client.Client{ID: "app", GrantTypes: []string{client.GrantTypeAuthorizationCode}},
+			wantErr:  auth.ErrUnauthorizedClient,
+		},
+		{
+			name:     "registered scope",
+			login:    "alice",
+			password: password,
+			scope:    "profile",
+			client:   client.Client{ID: "app", GrantTypes: e.client.GrantTypes, Scopes: []string{"profile", "admin"}},
+		},
+		{
+			name:     "unregistered scope",
+			login:    "alice",
+			password: password,
+			scope:    "profile admin",
+			client:   client.Client{ID: "app", GrantTypes: e.client.GrantTypes, Scopes: []string{"profile"}},
+			wantErr:  auth.ErrInvalidScope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := e.auth.Login(tt.client, tt.login, tt.password, tt.scope, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+				t.Fatalf("unexpected tokens %+v", tokens)
+			}
+
+			claims, aT, err := e.auth.ValidateToken(tokens.AccessToken)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserUUID() != e.users.users[0].UUID || aT.ClientId != "app" {
+				t.Fatalf("token issued to %q for %q", claims.UserUUID(), aT.ClientId)
+			}
+			if claims.Scope != tt.scope {
+				t.Fatalf("scope = %q, want %q", claims.Scope, tt.scope)
+			}
+		})
+	}
+}
+
+func TestAuth_RefreshToken(t *testing.T) {
+	e := newEnv(t)
+
+	issued, err := e.auth.Login(e.client, "alice", password, "", nil)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	if _, err := e.auth.RefreshToken(client.Client{ID: "other", GrantTypes: e.client.GrantTypes}, issued.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		t.Fatalf("refresh by another client: err = %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+
+	if _, err := e.auth.RefreshToken(e.client, "garbage", nil); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		t.Fatalf("refresh with garbage: err = %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+
+	first, _, err := e.auth.ValidateToken(issued.AccessToken)
+	if err != nil {
+		t.Fatalf("issued access token: %v", err)
+	}
+
+	rotated, err := e.auth.RefreshToken(e.client, issued.RefreshToken, nil)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+
+	if _, _, err := e.auth.ValidateToken(issued.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("rotated access token: err = %v, want %v", err, auth.ErrInvalidToken)
+	}
+	claims, _, err := e.auth.ValidateToken(rotated.AccessToken)
+	if err != nil {
+		t.Fatalf("new access token: %v", err)
+	}
+	// the sign in is not renewed by a refresh
+	if first.AuthTime == 0 || claims.AuthTime != first.AuthTime {
+		t.Fatalf("auth_time = %d, want %d", claims.AuthTime, first.AuthTime)
+	}
+
+	if _, err := e.auth.RefreshToken(e.client, issued.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		t.Fatalf("reused refresh token: err = %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+}
+
+func TestAuth_Logout(t *testing.T) {
+	e := newEnv(t)
+
+	issued, err := e.auth.Login(e.client, "alice", password, "", nil)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	u, err := e.auth.GetUser(issued.AccessToken)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if u.ID != 1 {
+		t.Fatalf("GetUser returned user %d", u.ID)
+	}
+
+	if err := e.auth.Logout(issued.AccessToken, issued.RefreshToken); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+
+	if _, err := e.auth.GetUser(issued.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("GetUser after logout: err = %v, want %v", err, auth.ErrInvalidToken)
+	}
+	if _, err := e.auth.RefreshToken(e.client, issued.RefreshToken, nil); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		t.Fatalf("refresh after logout: err = %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+	if err := e.auth.Logout(issued.AccessToken, ""); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("second logout: err = %v, want %v", err, auth.ErrInvalidToken)
+	}
+}
+
+// refreshTokenKey is shared by the tests, the refresh token encryption
+// expects a key of the size cmd/pemKeys generates.
+var refreshTokenKey = sync.OnceValues(func() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 4096)
+})
+
+// writeRefreshTokenKeys writes the key pair refresh tokens are encrypted
+// with, it is read from the working directory.
+func writeRefreshTokenKeys(t *testing.T) {
+	t.Helper()
+
+	key, err := refreshTokenKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "storage", "secret")
+	if err := os.MkdirAll(keyDir, 0o700); err != nil {
+		t.Fatalf("create key dir: %v", err)
+	}
+
+	public := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-public.key"), public, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-private.key"), private, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
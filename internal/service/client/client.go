@@ -0,0 +1,321 @@
+package client
+
+import (
+	"app/internal/config"
+	clientDomain "app/internal/domain/client"
+	"app/internal/storage"
+	"app/pkg/common/core/identity"
+	"app/pkg/common/logging"
+	"app/pkg/utils/crypt"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+)
+
+type Client struct {
+	ctx            context.Context
+	clientProvider Provider
+	cfg            *config.Config
+}
+
+type Provider interface {
+	GetClient(ID string) (clientDomain.Client, error)
+	GetClientByName(name string) (clientDomain.Client, error)
+	GetClients(limit, offset int) ([]clientDomain.Client, error)
+	CountClients() (int64, error)
+	CreateClient(client *clientDomain.Client) error
+	UpdateClient(client *clientDomain.Client) error
+	UpdateRevoked(ID string, revoked bool, updatedAt int64) error
+	UpdateSecret(client *clientDomain.Client) error
+	DeleteClient(ID string) error
+}
+
+// Create holds the fields of a new client, an empty auth method means
+// client_secret_basic and no grant types the password and refresh token
+// grants.
+type Create struct {
+	Name                               string
+	RedirectURIs                       []string
+	TokenEndpointAuthMethod            string
+	JWKS                               *string
+	GrantTypes                         []string
+	Scopes                             []string
+	TokenExchange                      *clientDomain.TokenExchange
+	RequirePushedAuthorizationRequests bool
+	TLSClientAuth                      *clientDomain.TLSClientAuth
+}
+
+// Update holds the client fields to change; nil fields are left untouched.
+type Update struct {
+	Name                               *string
+	RedirectURIs                       []string
+	UserId                             *int64
+	PersonalAccessClient               *bool
+	PasswordClient                     *bool
+	TokenEndpointAuthMethod            *string
+	JWKS                               *string
+	GrantTypes                         []string
+	Scopes                             []string
+	TokenExchange                      *clientDomain.TokenExchange
+	RequirePushedAuthorizationRequests *bool
+	TLSClientAuth                      *clientDomain.TLSClientAuth
+}
+
+func New(
+	ctx context.Context,
+	clientProvider Provider,
+	cfg *config.Config,
+) *Client {
+	return &Client{
+		ctx:            ctx,
+		clientProvider: clientProvider,
+		cfg:            cfg,
+	}
+}
+
+func (c *Client) GetClientByName(name string) (clientDomain.Client, error) {
+	const op = "service.client.GetClientByName"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	client, err := c.clientProvider.GetClientByName(name)
+	if err != nil {
+		return clientDomain.Client{}, storageError(err)
+	}
+
+	return client, nil
+}
+
+func (c *Client) ListClients(page, limit int) ([]clientDomain.Client, int64, error) {
+	const op = "service.client.ListClients"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	clients, err := c.clientProvider.GetClients(limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := c.clientProvider.CountClients()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return clients, total, nil
+}
+
+// CreateClient stores a new client and returns it together with its plain
+// secret, which is empty when the client doesn't authenticate with one.
+func (c *Client) CreateClient(create Create) (clientDomain.Client, string, error) {
+	const op = "service.client.CreateClient"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	now := time.Now().Unix()
+	var oauthClient = clientDomain.Client{
+		ID:                                 identity.UUIDv7(),
+		Name:                               create.Name,
+		Provider:                           "users",
+		PasswordClient:                     true,
+		GrantTypes:                         []string{clientDomain.GrantTypePassword, clientDomain.GrantTypeRefreshToken},
+		Contacts:                           []string{},
+		RequirePushedAuthorizationRequests: create.RequirePushedAuthorizationRequests,
+		TLSClientAuth:                      create.TLSClientAuth,
+		CreatedAt:                          now,
+		UpdatedAt:                          now,
+	}
+
+	if err := oauthClient.SetRedirectURIs(create.RedirectURIs, c.cfg.IsLocal()); err != nil {
+		return clientDomain.Client{}, "", err
+	}
+
+	if len(create.GrantTypes) > 0 {
+		if err := oauthClient.SetGrantTypes(create.GrantTypes); err != nil {
+			return clientDomain.Client{}, "", err
+		}
+	}
+
+	if err := oauthClient.SetScopes(create.Scopes); err != nil {
+		return clientDomain.Client{}, "", err
+	}
+
+	method := create.TokenEndpointAuthMethod
+	if method == "" {
+		method = clientDomain.AuthMethodClientSecretBasic
+	}
+
+	if err := oauthClient.SetAuthMethod(method, create.JWKS); err != nil {
+		return clientDomain.Client{}, "", err
+	}
+
+	if create.TokenExchange != nil {
+		oauthClient.SetTokenExchange(create.TokenExchange)
+	}
+
+	var secret string
+	if oauthClient.UsesSecret() {
+		var err error
+		secret = crypt.GetSecret()
+		if oauthClient.Secret, err = crypt.HashSecret(secret); err != nil {
+			return clientDomain.Client{}, "", err
+		}
+	}
+
+	if err := c.clientProvider.CreateClient(&oauthClient); err != nil {
+		return clientDomain.Client{}, "", storageError(err)
+	}
+
+	return oauthClient, secret, nil
+}
+
+func (c *Client) UpdateClient(ID string, update Update) (clientDomain.Client, error) {
+	const op = "service.client.UpdateClient"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	oauthClient, err := c.clientProvider.GetClient(ID)
+	if err != nil {
+		return clientDomain.Client{}, storageError(err)
+	}
+
+	if update.Name != nil {
+		oauthClient.Name = *update.Name
+	}
+	if update.RedirectURIs != nil {
+		if err := oauthClient.SetRedirectURIs(update.RedirectURIs, c.cfg.IsLocal()); err != nil {
+			return clientDomain.Client{}, err
+		}
+	}
+	if update.UserId != nil {
+		oauthClient.UserId = update.UserId
+	}
+	if update.PersonalAccessClient != nil {
+		oauthClient.PersonalAccessClient = *update.PersonalAccessClient
+	}
+	if update.PasswordClient != nil {
+		oauthClient.PasswordClient = *update.PasswordClient
+	}
+	if len(update.GrantTypes) > 0 {
+		if err := oauthClient.SetGrantTypes(update.GrantTypes); err != nil {
+			return clientDomain.Client{}, err
+		}
+	}
+	if update.Scopes != nil {
+		if err := oauthClient.SetScopes(update.Scopes); err != nil {
+			return clientDomain.Client{}, err
+		}
+	}
+	if update.RequirePushedAuthorizationRequests != nil {
+		oauthClient.RequirePushedAuthorizationRequests = *update.RequirePushedAuthorizationRequests
+	}
+	if update.TLSClientAuth != nil {
+		oauthClient.TLSClientAuth = update.TLSClientAuth
+	}
+	if update.TokenEndpointAuthMethod != nil || update.JWKS != nil || update.TLSClientAuth != nil {
+		method := oauthClient.TokenEndpointAuthMethod
+		if update.TokenEndpointAuthMethod != nil {
+			method = *update.TokenEndpointAuthMethod
+		}
+		if err := oauthClient.SetAuthMethod(method, update.JWKS); err != nil {
+			return clientDomain.Client{}, err
+		}
+	}
+	if update.TokenExchange != nil {
+		oauthClient.SetTokenExchange(update.TokenExchange)
+	}
+	// the update query leaves the secrets alone, a client that no longer
+	// authenticates with a secret has them dropped separately
+	dropSecret := !oauthClient.UsesSecret() && oauthClient.DropSecret()
+	oauthClient.UpdatedAt = time.Now().Unix()
+
+	if err := c.clientProvider.UpdateClient(&oauthClient); err != nil {
+		return clientDomain.Client{}, storageError(err)
+	}
+
+	if dropSecret {
+		if err := c.clientProvider.UpdateSecret(&oauthClient); err != nil {
+			return clientDomain.Client{}, storageError(err)
+		}
+	}
+
+	return oauthClient, nil
+}
+
+func (c *Client) SetRevoked(ID string, revoked bool) (clientDomain.Client, error) {
+	const op = "service.client.SetRevoked"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	oauthClient, err := c.clientProvider.GetClient(ID)
+	if err != nil {
+		return clientDomain.Client{}, storageError(err)
+	}
+
+	oauthClient.Revoked = revoked
+	oauthClient.UpdatedAt = time.Now().Unix()
+
+	if err := c.clientProvider.UpdateRevoked(oauthClient.ID, oauthClient.Revoked, oauthClient.UpdatedAt); err != nil {
+		return clientDomain.Client{}, storageError(err)
+	}
+
+	return oauthClient, nil
+}
+
+func (c *Client) DeleteClient(ID string) error {
+	const op = "service.client.DeleteClient"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	if err := c.clientProvider.DeleteClient(ID); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+// RotateSecret issues a new secret for the client and returns it in plain form.
+func (c *Client) RotateSecret(ID string) (clientDomain.Client, string, error) {
+	const op = "service.client.RotateSecret"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	oauthClient, err := c.clientProvider.GetClient(ID)
+	if err != nil {
+		return clientDomain.Client{}, "", storageError(err)
+	}
+
+	secret := crypt.GetSecret()
+	secretHash, err := crypt.HashSecret(secret)
+	if err != nil {
+		return clientDomain.Client{}, "", err
+	}
+
+	oauthClient.RotateSecret(secretHash, time.Now(), c.cfg.Client.SecretGracePeriod)
+
+	if err := c.clientProvider.UpdateSecret(&oauthClient); err != nil {
+		return clientDomain.Client{}, "", storageError(err)
+	}
+
+	return oauthClient, secret, nil
+}
+
+func (c *Client) VerifySecret(ID, secret string) (bool, error) {
+	const op = "service.client.VerifySecret"
+	logging.L(c.ctx).Info("op", logging.StringAttr("op", op))
+
+	oauthClient, err := c.clientProvider.GetClient(ID)
+	if err != nil {
+		return false, storageError(err)
+	}
+
+	return !oauthClient.Revoked && oauthClient.AcceptsSecret(secret, time.Now()), nil
+}
+
+func storageError(err error) error {
+	switch {
+	case storage.IsNotFound(err):
+		return ErrClientNotFound
+	case storage.ErrorCode(err) == storage.ErrCodeExists:
+		return ErrClientExists
+	default:
+		return err
+	}
+}
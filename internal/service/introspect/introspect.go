@@ -0,0 +1,178 @@
+package introspect
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/pkg/common/core/token"
+	"app/pkg/common/logging"
+	"context"
+	"slices"
+	"time"
+)
+
+const (
+	HintRefreshToken = "refresh_token"
+)
+
+type AccessToken interface {
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+}
+
+type RefreshToken interface {
+	GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error)
+}
+
+type Resource interface {
+	GetResourcesByClient(clientID string) ([]resourceDomain.Resource, error)
+}
+
+// Introspection is the introspection response defined by RFC 7662,
+// section 2.2.
+type Introspection struct {
+	Active    bool                            `json:"active"`
+	Scope     string                          `json:"scope,omitempty"`
+	Aud       []string                        `json:"aud,omitempty"`
+	Act       *accessTokenDomain.Actor        `json:"act,omitempty"`
+	Cnf       *accessTokenDomain.Confirmation `json:"cnf,omitempty"`
+	ClientID  string                          `json:"client_id,omitempty"`
+	Username  string                          `json:"username,omitempty"`
+	TokenType string                          `json:"token_type,omitempty"`
+	Exp       int64                           `json:"exp,omitempty"`
+	Iat       int64                           `json:"iat,omitempty"`
+	Iss       string                          `json:"iss,omitempty"`
+	Sub       string                          `json:"sub,omitempty"`
+	Jti       string                          `json:"jti,omitempty"`
+}
+
+// Introspect holds the introspection logic shared by the HTTP and the
+// gRPC transports, the clients are authenticated by the transports.
+type Introspect struct {
+	ctx          context.Context
+	accessToken  AccessToken
+	refreshToken RefreshToken
+	resources    Resource
+	cfg          config.Token
+}
+
+func New(
+	ctx context.Context,
+	accessToken AccessToken,
+	refreshToken RefreshToken,
+	resources Resource,
+	cfg config.Token,
+) *Introspect {
+	return &Introspect{
+		ctx:          ctx,
+		accessToken:  accessToken,
+		refreshToken: refreshToken,
+		resources:    resources,
+		cfg:          cfg,
+	}
+}
+
+// Introspect tries the token as the hinted type first, an unknown or
+// invalid token is reported as inactive.
+func (i *Introspect) Introspect(c client.Client, tokenStr, hint string) *Introspection {
+	const op = "service.introspect.Introspect"
+	logging.L(i.ctx).Info("op", logging.StringAttr("op", op))
+
+	if hint == HintRefreshToken {
+		if res := i.introspectRefreshToken(c, tokenStr); res.Active {
+			return res
+		}
+		return i.introspectAccessToken(c, tokenStr)
+	}
+
+	if res := i.introspectAccessToken(c, tokenStr); res.Active {
+		return res
+	}
+	return i.introspectRefreshToken(c, tokenStr)
+}
+
+// introspectAccessToken reports an access token as active only to a
+// resource server in its audience, tokens minted without a resource
+// have the client they were issued to as their audience.
+func (i *Introspect) introspectAccessToken(c client.Client, tokenStr string) *Introspection {
+	claims, err := token.ParseAccessToken(tokenStr, i.cfg.Secret)
+	if err != nil || claims.ID == "" {
+		return &Introspection{Active: false}
+	}
+
+	aT, err := i.accessToken.GetToken(claims.ID)
+	if err != nil || aT.Revoked || aT.ExpiresAt < time.Now().Unix() {
+		return &Introspection{Active: false}
+	}
+
+	aud := []string(claims.Audience)
+	if len(aud) == 0 {
+		aud = []string{aT.ClientId}
+	}
+	if !i.inAudience(c, aud) {
+		return &Introspection{Active: false}
+	}
+
+	return &Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		Aud:       claims.Audience,
+		Act:       claims.Act,
+		Cnf:       claims.Cnf,
+		ClientID:  aT.ClientId,
+		Username:  claims.Email,
+		TokenType: token.TokenType(claims.Cnf),
+		Exp:       aT.ExpiresAt,
+		Iat:       aT.CreatedAt,
+		Iss:       claims.Issuer,
+		Sub:       claims.UserUUID(),
+		Jti:       aT.ID,
+	}
+}
+
+// introspectRefreshToken reports a refresh token as active only to the
+// client it was issued to, no resource server redeems it.
+func (i *Introspect) introspectRefreshToken(c client.Client, tokenStr string) *Introspection {
+	payload, err := token.ParseRefreshToken(tokenStr)
+	if err != nil || payload.ExpiresAt < time.Now().Unix() || payload.ClientId != c.ID {
+		return &Introspection{Active: false}
+	}
+
+	rT, err := i.refreshToken.GetToken(&refreshTokenDomain.RefreshToken{
+		ID:            payload.TokenRefreshId,
+		AccessTokenId: payload.TokenAccessId,
+	})
+	if err != nil || rT.Revoked {
+		return &Introspection{Active: false}
+	}
+
+	return &Introspection{
+		Active:    true,
+		Cnf:       payload.Cnf,
+		ClientID:  payload.ClientId,
+		Username:  payload.Email,
+		TokenType: HintRefreshToken,
+		Exp:       rT.ExpiresAt,
+		Sub:       payload.UUID,
+		Jti:       rT.ID,
+	}
+}
+
+// inAudience reports whether the calling client, or a resource it serves,
+// is in the audience.
+func (i *Introspect) inAudience(c client.Client, aud []string) bool {
+	if slices.Contains(aud, c.ID) {
+		return true
+	}
+
+	served, err := i.resources.GetResourcesByClient(c.ID)
+	if err != nil {
+		logging.L(i.ctx).Error("failed get resources", logging.ErrAttr(err))
+		return false
+	}
+
+	return slices.ContainsFunc(served, func(r resourceDomain.Resource) bool {
+		return slices.Contains(aud, r.Identifier)
+	})
+}
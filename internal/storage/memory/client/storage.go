@@ -0,0 +1,227 @@
+package client
+
+import (
+	"app/internal/domain/client"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) GetClient(ID string) (client.Client, error) {
+	const op = "storage.memory.oauth.client.GetClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	c, ok := s.db.Clients[ID]
+	if !ok {
+		return client.Client{}, memory.ErrNotFound
+	}
+
+	return clone(c), nil
+}
+
+func (s *Storage) CreateClient(oauthClient *client.Client) error {
+	const op = "storage.memory.oauth.client.CreateClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.Clients[oauthClient.ID]; ok || s.taken(*oauthClient) {
+		return memory.ErrExists
+	}
+
+	s.db.Clients[oauthClient.ID] = clone(*oauthClient)
+
+	return nil
+}
+
+func (s *Storage) GetClientByName(name string) (client.Client, error) {
+	const op = "storage.memory.oauth.client.GetClientByName"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	for _, c := range s.sorted() {
+		if c.Name == name {
+			return clone(c), nil
+		}
+	}
+
+	return client.Client{}, memory.ErrNotFound
+}
+
+func (s *Storage) GetClients(limit, offset int) ([]client.Client, error) {
+	const op = "storage.memory.oauth.client.GetClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	clients := make([]client.Client, 0)
+	for _, c := range page(s.sorted(), limit, offset) {
+		clients = append(clients, clone(c))
+	}
+
+	return clients, nil
+}
+
+func (s *Storage) CountClients() (int64, error) {
+	const op = "storage.memory.oauth.client.CountClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	return int64(len(s.db.Clients)), nil
+}
+
+func (s *Storage) UpdateClient(oauthClient *client.Client) error {
+	const op = "storage.memory.oauth.client.UpdateClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	return s.update(oauthClient.ID, func(c *client.Client) error {
+		updated := clone(*oauthClient)
+
+		c.UserId = updated.UserId
+		c.Name = updated.Name
+		c.RedirectURIs = updated.RedirectURIs
+		c.PersonalAccessClient = updated.PersonalAccessClient
+		c.PasswordClient = updated.PasswordClient
+		c.TokenEndpointAuthMethod = updated.TokenEndpointAuthMethod
+		c.JWKS = updated.JWKS
+		c.GrantTypes = updated.GrantTypes
+		c.Contacts = updated.Contacts
+		c.TokenExchange = updated.TokenExchange
+		c.RequirePushedAuthorizationRequests = updated.RequirePushedAuthorizationRequests
+		c.TLSClientAuth = updated.TLSClientAuth
+		c.Scopes = updated.Scopes
+		c.UpdatedAt = updated.UpdatedAt
+
+		if s.taken(*c) {
+			return memory.ErrExists
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) UpdateRevoked(ID string, revoked bool, updatedAt int64) error {
+	const op = "storage.memory.oauth.client.UpdateRevoked"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	return s.update(ID, func(c *client.Client) error {
+		c.Revoked = revoked
+		c.UpdatedAt = updatedAt
+		return nil
+	})
+}
+
+func (s *Storage) UpdateSecret(oauthClient *client.Client) error {
+	const op = "storage.memory.oauth.client.UpdateSecret"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	return s.update(oauthClient.ID, func(c *client.Client) error {
+		c.Secret = oauthClient.Secret
+		c.PreviousSecret = oauthClient.PreviousSecret
+		c.PreviousSecretExpiresAt = oauthClient.PreviousSecretExpiresAt
+		c.UpdatedAt = oauthClient.UpdatedAt
+		return nil
+	})
+}
+
+func (s *Storage) DeleteClient(ID string) error {
+	const op = "storage.memory.oauth.client.DeleteClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.Clients[ID]; !ok {
+		return memory.ErrNotFound
+	}
+	delete(s.db.Clients, ID)
+
+	return nil
+}
+
+// update applies fn to a copy of the client and stores it unless fn
+// fails, it reports memory.ErrNotFound for an unknown client.
+func (s *Storage) update(ID string, fn func(c *client.Client) error) error {
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	c, ok := s.db.Clients[ID]
+	if !ok {
+		return memory.ErrNotFound
+	}
+
+	if err := fn(&c); err != nil {
+		return err
+	}
+	s.db.Clients[ID] = c
+
+	return nil
+}
+
+// taken reports whether another client has the name, the provider and
+// the user of c, like the unique index of the database. The lock must be
+// held.
+func (s *Storage) taken(c client.Client) bool {
+	for _, other := range s.db.Clients {
+		if other.ID != c.ID && other.Name == c.Name && other.Provider == c.Provider && sameUser(other.UserId, c.UserId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sameUser(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sorted returns the clients newest first, the lock must be held.
+func (s *Storage) sorted() []client.Client {
+	return slices.SortedFunc(maps.Values(s.db.Clients), func(a, b client.Client) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+}
+
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	return rows[offset:min(offset+limit, len(rows))]
+}
+
+// clone copies the slices of the client so the stored one isn't changed
+// through the caller's.
+func clone(c client.Client) client.Client {
+	c.RedirectURIs = slices.Clone(c.RedirectURIs)
+	c.GrantTypes = slices.Clone(c.GrantTypes)
+	c.Contacts = slices.Clone(c.Contacts)
+	c.Scopes = slices.Clone(c.Scopes)
+	return c
+}
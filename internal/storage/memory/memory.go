@@ -0,0 +1,76 @@
+// Package memory keeps the tables of the storages in the process, for the
+// tests and the deployments that can lose their data on a restart.
+package memory
+
+import (
+	"app/internal/domain/client"
+	accessToken "app/internal/domain/oauth/access-token"
+	refreshToken "app/internal/domain/oauth/refresh-token"
+	"app/internal/domain/oauth/resource"
+	"app/internal/domain/oauth/revocation"
+	"app/internal/domain/user"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned when no row matches, like pgx.ErrNoRows.
+	ErrNotFound = errors.New("memory: no rows in result set")
+	// ErrExists is returned when a unique constraint would be violated.
+	ErrExists = errors.New("memory: duplicate key value")
+)
+
+// UserRow is a stored user, deleted users are kept like in the database.
+type UserRow struct {
+	user.User
+	DeletedAt *int64
+}
+
+// DB holds the tables. A single lock guards all of them so the writes
+// spanning several tables are atomic like a transaction, the storages
+// take it for the whole of every call.
+type DB struct {
+	sync.RWMutex
+	Users         map[int64]*UserRow
+	Clients       map[string]client.Client
+	AccessTokens  map[string]accessToken.AccessToken
+	RefreshTokens map[string]refreshToken.RefreshToken
+	Resources     map[string]resource.Resource
+	// Events are ordered by ID, the IDs are never reused.
+	Events []revocation.Event
+
+	lastUserID  int64
+	lastEventID int64
+}
+
+func New() *DB {
+	return &DB{
+		Users:         make(map[int64]*UserRow),
+		Clients:       make(map[string]client.Client),
+		AccessTokens:  make(map[string]accessToken.AccessToken),
+		RefreshTokens: make(map[string]refreshToken.RefreshToken),
+		Resources:     make(map[string]resource.Resource),
+	}
+}
+
+// NextUserID returns the ID of a new user, the lock must be held.
+func (db *DB) NextUserID() int64 {
+	db.lastUserID++
+	return db.lastUserID
+}
+
+// InsertEvent records the event with the next ID, the lock must be held.
+func (db *DB) InsertEvent(e revocation.Event) {
+	db.lastEventID++
+	e.ID = db.lastEventID
+	db.Events = append(db.Events, e)
+}
+
+// LastEventID returns the ID of the last recorded event, 0 without
+// events. The lock must be held.
+func (db *DB) LastEventID() int64 {
+	if len(db.Events) == 0 {
+		return 0
+	}
+	return db.Events[len(db.Events)-1].ID
+}
@@ -0,0 +1,162 @@
+package access_token
+
+import (
+	accessToken "app/internal/domain/oauth/access-token"
+	"app/internal/domain/oauth/revocation"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"context"
+	"time"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
+	const op = "storage.memory.oauth.access-token.CreateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.AccessTokens[aT.ID]; ok {
+		return "", memory.ErrExists
+	}
+	s.db.AccessTokens[aT.ID] = *aT
+
+	return aT.ID, nil
+}
+
+func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.memory.oauth.access-token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	stored, ok := s.db.AccessTokens[aT.ID]
+
+	return ok && stored.UserId == aT.UserId && stored.ClientId == aT.ClientId, nil
+}
+
+// UpdateToken revokes the access token and records the revocation.
+func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.memory.oauth.access-token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	stored, ok := s.db.AccessTokens[aT.ID]
+	if ok && stored.UserId == aT.UserId && stored.ClientId == aT.ClientId {
+		s.revoke(stored)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) GetToken(ID string) (accessToken.AccessToken, error) {
+	const op = "storage.memory.oauth.access-token.GetToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	aT, ok := s.db.AccessTokens[ID]
+	if !ok {
+		return accessToken.AccessToken{}, memory.ErrNotFound
+	}
+
+	return aT, nil
+}
+
+// RevokeUserTokens revokes the access tokens of the user and their
+// refresh tokens, a revocation of the user is recorded when it had any.
+func (s *Storage) RevokeUserTokens(userID int64) error {
+	const op = "storage.memory.oauth.access-token.RevokeUserTokens"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	var (
+		revoked   bool
+		expiresAt int64
+	)
+	for ID, aT := range s.db.AccessTokens {
+		if aT.UserId != userID || aT.Revoked {
+			continue
+		}
+
+		aT.Revoked = true
+		s.db.AccessTokens[ID] = aT
+		s.revokeRefreshTokens(ID)
+
+		revoked = true
+		expiresAt = max(expiresAt, aT.ExpiresAt)
+	}
+
+	if revoked {
+		s.db.InsertEvent(revocation.Event{
+			Kind:      revocation.KindUser,
+			UserID:    userID,
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	return nil
+}
+
+// RevokeToken revokes the access token and the refresh tokens issued
+// with it, and records the revocation.
+func (s *Storage) RevokeToken(ID string) error {
+	const op = "storage.memory.oauth.access-token.RevokeToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if aT, ok := s.db.AccessTokens[ID]; ok {
+		s.revoke(aT)
+	}
+	s.revokeRefreshTokens(ID)
+
+	return nil
+}
+
+// revoke revokes the access token and records a token revocation, even
+// for a token revoked already like the SQL storages do. The lock must be
+// held.
+func (s *Storage) revoke(aT accessToken.AccessToken) {
+	aT.Revoked = true
+	s.db.AccessTokens[aT.ID] = aT
+
+	s.db.InsertEvent(revocation.Event{
+		Kind:      revocation.KindToken,
+		TokenID:   aT.ID,
+		UserID:    aT.UserId,
+		CreatedAt: time.Now().Unix(),
+		ExpiresAt: aT.ExpiresAt,
+	})
+}
+
+// revokeRefreshTokens revokes the refresh tokens of the access token, the
+// lock must be held.
+func (s *Storage) revokeRefreshTokens(accessTokenID string) {
+	for ID, rT := range s.db.RefreshTokens {
+		if rT.AccessTokenId == accessTokenID && !rT.Revoked {
+			rT.Revoked = true
+			s.db.RefreshTokens[ID] = rT
+		}
+	}
+}
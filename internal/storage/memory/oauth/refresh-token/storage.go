@@ -0,0 +1,123 @@
+package refresh_token
+
+import (
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"context"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (string, error) {
+	const op = "storage.memory.oauth.refresh-token.CreateRefreshToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.RefreshTokens[rT.ID]; ok {
+		return "", memory.ErrExists
+	}
+	s.db.RefreshTokens[rT.ID] = *rT
+
+	return rT.ID, nil
+}
+
+func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
+	const op = "storage.memory.oauth.refresh-token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	_, ok := s.get(rT)
+
+	return ok, nil
+}
+
+func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	const op = "storage.memory.oauth.refresh-token.GetToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	stored, ok := s.get(rT)
+	if !ok {
+		return refreshTokenDomain.RefreshToken{}, memory.ErrNotFound
+	}
+
+	return stored, nil
+}
+
+func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
+	const op = "storage.memory.oauth.refresh-token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if stored, ok := s.get(rT); ok {
+		stored.Revoked = true
+		s.db.RefreshTokens[stored.ID] = stored
+	}
+
+	return true, nil
+}
+
+// GetLastReceivedToken returns the refresh token of the user of rT that
+// expires last.
+func (s *Storage) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	const op = "storage.memory.oauth.refresh-token.GetLastReceivedToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	stored, ok := s.get(rT)
+	if !ok {
+		return refreshTokenDomain.RefreshToken{}, memory.ErrNotFound
+	}
+	aT, ok := s.db.AccessTokens[stored.AccessTokenId]
+	if !ok {
+		return refreshTokenDomain.RefreshToken{}, memory.ErrNotFound
+	}
+
+	var (
+		last  refreshTokenDomain.RefreshToken
+		found bool
+	)
+	for _, other := range s.db.RefreshTokens {
+		owner, ok := s.db.AccessTokens[other.AccessTokenId]
+		if !ok || owner.UserId != aT.UserId {
+			continue
+		}
+		if !found || other.ExpiresAt > last.ExpiresAt || (other.ExpiresAt == last.ExpiresAt && other.ID < last.ID) {
+			last, found = other, true
+		}
+	}
+
+	return last, nil
+}
+
+// get returns the refresh token with the ID and the access token of rT,
+// the lock must be held.
+func (s *Storage) get(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, bool) {
+	stored, ok := s.db.RefreshTokens[rT.ID]
+	if !ok || stored.AccessTokenId != rT.AccessTokenId {
+		return refreshTokenDomain.RefreshToken{}, false
+	}
+
+	return stored, true
+}
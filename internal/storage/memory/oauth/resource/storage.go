@@ -0,0 +1,178 @@
+package resource
+
+import (
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateResource(r *resourceDomain.Resource) error {
+	const op = "storage.memory.oauth.resource.CreateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.Resources[r.ID]; ok || s.taken(*r) {
+		return memory.ErrExists
+	}
+	s.db.Resources[r.ID] = clone(*r)
+
+	return nil
+}
+
+func (s *Storage) GetResource(ID string) (resourceDomain.Resource, error) {
+	const op = "storage.memory.oauth.resource.GetResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	r, ok := s.db.Resources[ID]
+	if !ok {
+		return resourceDomain.Resource{}, memory.ErrNotFound
+	}
+
+	return clone(r), nil
+}
+
+// GetResourcesByIdentifiers returns the registered resources among the
+// identifiers, unknown identifiers are left out.
+func (s *Storage) GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error) {
+	const op = "storage.memory.oauth.resource.GetResourcesByIdentifiers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	return s.filter(func(r resourceDomain.Resource) bool {
+		return slices.Contains(identifiers, r.Identifier)
+	}), nil
+}
+
+// GetResourcesByClient returns the resources served by the resource
+// server that authenticates as the client.
+func (s *Storage) GetResourcesByClient(clientID string) ([]resourceDomain.Resource, error) {
+	const op = "storage.memory.oauth.resource.GetResourcesByClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	return s.filter(func(r resourceDomain.Resource) bool {
+		return r.ClientId != nil && *r.ClientId == clientID
+	}), nil
+}
+
+func (s *Storage) GetResources(limit, offset int) ([]resourceDomain.Resource, error) {
+	const op = "storage.memory.oauth.resource.GetResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	sorted := slices.SortedFunc(maps.Values(s.db.Resources), func(a, b resourceDomain.Resource) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+
+	resources := make([]resourceDomain.Resource, 0)
+	if offset < len(sorted) {
+		for _, r := range sorted[offset:min(offset+limit, len(sorted))] {
+			resources = append(resources, clone(r))
+		}
+	}
+
+	return resources, nil
+}
+
+func (s *Storage) CountResources() (int64, error) {
+	const op = "storage.memory.oauth.resource.CountResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	return int64(len(s.db.Resources)), nil
+}
+
+func (s *Storage) UpdateResource(r *resourceDomain.Resource) error {
+	const op = "storage.memory.oauth.resource.UpdateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	stored, ok := s.db.Resources[r.ID]
+	if !ok {
+		return memory.ErrNotFound
+	}
+	if s.taken(*r) {
+		return memory.ErrExists
+	}
+
+	updated := clone(*r)
+	updated.CreatedAt = stored.CreatedAt
+	s.db.Resources[r.ID] = updated
+
+	return nil
+}
+
+func (s *Storage) DeleteResource(ID string) error {
+	const op = "storage.memory.oauth.resource.DeleteResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.Resources[ID]; !ok {
+		return memory.ErrNotFound
+	}
+	delete(s.db.Resources, ID)
+
+	return nil
+}
+
+// filter returns the resources matching, ordered by identifier.
+func (s *Storage) filter(match func(r resourceDomain.Resource) bool) []resourceDomain.Resource {
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	resources := make([]resourceDomain.Resource, 0)
+	for _, r := range s.db.Resources {
+		if match(r) {
+			resources = append(resources, clone(r))
+		}
+	}
+	slices.SortFunc(resources, func(a, b resourceDomain.Resource) int {
+		return cmp.Compare(a.Identifier, b.Identifier)
+	})
+
+	return resources
+}
+
+// taken reports whether another resource has the identifier of r, the
+// lock must be held.
+func (s *Storage) taken(r resourceDomain.Resource) bool {
+	for _, other := range s.db.Resources {
+		if other.ID != r.ID && other.Identifier == r.Identifier {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clone(r resourceDomain.Resource) resourceDomain.Resource {
+	r.Scopes = slices.Clone(r.Scopes)
+	return r
+}
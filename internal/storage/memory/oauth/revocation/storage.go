@@ -0,0 +1,107 @@
+package revocation
+
+import (
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"context"
+)
+
+// Storage reads the revocation events, the token and user revocations are
+// recorded by the access token storage along with the revocation itself.
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+// CreateKeyEvent records the key unless it is the last one recorded, it
+// reports whether the key was rotated.
+func (s *Storage) CreateKeyEvent(keyID string, createdAt int64) (bool, error) {
+	const op = "storage.memory.oauth.revocation.CreateKeyEvent"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	for i := len(s.db.Events) - 1; i >= 0; i-- {
+		if e := s.db.Events[i]; e.Kind == revocationDomain.KindKey {
+			if e.KeyID == keyID {
+				return false, nil
+			}
+			break
+		}
+	}
+
+	s.db.InsertEvent(revocationDomain.Event{
+		Kind:      revocationDomain.KindKey,
+		KeyID:     keyID,
+		CreatedAt: createdAt,
+	})
+
+	return true, nil
+}
+
+// GetLastEventID returns the cursor of the last event, 0 without events.
+func (s *Storage) GetLastEventID() (int64, error) {
+	const op = "storage.memory.oauth.revocation.GetLastEventID"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	return s.db.LastEventID(), nil
+}
+
+// GetEventsAfter returns up to limit events recorded after the cursor, in
+// the order they were recorded, with the UUID of the revoked user.
+func (s *Storage) GetEventsAfter(cursor int64, limit int) ([]revocationDomain.Event, error) {
+	const op = "storage.memory.oauth.revocation.GetEventsAfter"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	events := make([]revocationDomain.Event, 0)
+	for _, e := range s.db.Events {
+		if len(events) == limit {
+			break
+		}
+		if e.ID <= cursor {
+			continue
+		}
+		if u, ok := s.db.Users[e.UserID]; ok {
+			e.UserUUID = u.UUID
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// DeleteExpired removes the revocations of the tokens that have expired,
+// the key events are kept to tell a rotation apart from a restart.
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.memory.oauth.revocation.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	kept := s.db.Events[:0]
+	for _, e := range s.db.Events {
+		if e.Kind == revocationDomain.KindKey || e.ExpiresAt >= now {
+			kept = append(kept, e)
+		}
+	}
+	deleted := int64(len(s.db.Events) - len(kept))
+	s.db.Events = kept
+
+	return deleted, nil
+}
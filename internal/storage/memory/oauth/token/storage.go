@@ -0,0 +1,77 @@
+package token
+
+import (
+	accessToken "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"context"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+// Create stores the access token and its refresh token under the lock,
+// neither is stored when the other can't be.
+func (s *Storage) Create(
+	aT *accessToken.AccessToken,
+	rT *refreshTokenDomain.RefreshToken,
+) error {
+	const op = "storage.memory.oauth.token.Create"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	if _, ok := s.db.AccessTokens[aT.ID]; ok {
+		return memory.ErrExists
+	}
+	if _, ok := s.db.RefreshTokens[rT.ID]; ok {
+		return memory.ErrExists
+	}
+
+	refresh := *rT
+	refresh.AccessTokenId = aT.ID
+
+	s.db.AccessTokens[aT.ID] = *aT
+	s.db.RefreshTokens[rT.ID] = refresh
+
+	return nil
+}
+
+func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.memory.oauth.token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	stored, ok := s.db.AccessTokens[aT.ID]
+
+	return ok && stored.UserId == aT.UserId && stored.ClientId == aT.ClientId, nil
+}
+
+func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.memory.oauth.token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	stored, ok := s.db.AccessTokens[aT.ID]
+	if ok && stored.UserId == aT.UserId && stored.ClientId == aT.ClientId {
+		stored.Revoked = true
+		s.db.AccessTokens[aT.ID] = stored
+	}
+
+	return true, nil
+}
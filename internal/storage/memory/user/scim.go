@@ -0,0 +1,344 @@
+package user
+
+import (
+	"app/internal/domain/user"
+	"app/internal/storage/memory"
+	"app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"strings"
+	"time"
+)
+
+type attribute struct {
+	Type scim.Type
+	// value returns the attribute of the row, nil when it is NULL
+	value func(row *memory.UserRow) any
+}
+
+// scimFilterAttributes are the attributes the SCIM filters of users are
+// evaluated on, the same the SQL storages translate.
+var scimFilterAttributes = map[string]attribute{
+	"id":                {scim.CaseExactString, func(row *memory.UserRow) any { return row.UUID }},
+	"username":          {scim.String, func(row *memory.UserRow) any { return row.Name }},
+	"externalid":        {scim.CaseExactString, func(row *memory.UserRow) any { return deref(row.ExternalId) }},
+	"displayname":       {scim.String, func(row *memory.UserRow) any { return deref(row.DisplayName) }},
+	"emails":            {scim.String, func(row *memory.UserRow) any { return row.Email }},
+	"emails.value":      {scim.String, func(row *memory.UserRow) any { return row.Email }},
+	"active":            {scim.Boolean, func(row *memory.UserRow) any { return row.DeactivatedAt == nil }},
+	"meta.created":      {scim.DateTime, func(row *memory.UserRow) any { return row.CreatedAt }},
+	"meta.lastmodified": {scim.DateTime, func(row *memory.UserRow) any { return row.UpdatedAt }},
+}
+
+func deref(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+// GetUserByUUID returns a user that is not deleted, deactivated users
+// included.
+func (s *Storage) GetUserByUUID(UUID string) (user.User, error) {
+	const op = "storage.memory.user.GetUserByUUID"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	row, ok := s.find(func(row *memory.UserRow) bool {
+		return row.UUID == UUID && row.DeletedAt == nil
+	})
+	if !ok {
+		return user.User{}, memory.ErrNotFound
+	}
+
+	return scimUser(row), nil
+}
+
+// FindUsers returns the page of the users matching the filter, a nil
+// filter matches every user, and their total.
+func (s *Storage) FindUsers(filter scim.Expr, page scim.Page) ([]user.User, int64, error) {
+	const op = "storage.memory.user.FindUsers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	match := func(*memory.UserRow) bool { return true }
+	if filter != nil {
+		var err error
+		if match, err = compile(filter, ""); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	var total int64
+	users := make([]user.User, 0, page.Count)
+	s.find(func(row *memory.UserRow) bool {
+		if row.DeletedAt != nil || !match(row) {
+			return false
+		}
+		if total >= int64(page.Offset()) && len(users) < page.Count {
+			users = append(users, scimUser(row))
+		}
+		total++
+		return false
+	})
+
+	return users, total, nil
+}
+
+// ExistsUserName reports whether another user than exceptID, which is 0
+// for a new user, has the name. Names are compared case-insensitively.
+func (s *Storage) ExistsUserName(name string, exceptID int64) (bool, error) {
+	const op = "storage.memory.user.ExistsUserName"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	_, exists := s.find(func(row *memory.UserRow) bool {
+		return strings.EqualFold(row.Name, name) && row.ID != exceptID && row.DeletedAt == nil
+	})
+
+	return exists, nil
+}
+
+// CreateUser stores a provisioned user and sets its ID.
+func (s *Storage) CreateUser(u *user.User) error {
+	const op = "storage.memory.user.CreateUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	row := &memory.UserRow{User: *u}
+	if row.EmailVerifiedAt == nil {
+		row.EmailVerifiedAt = new(int64)
+	}
+	row.Version = 1
+
+	if err := s.insert(row); err != nil {
+		return err
+	}
+
+	u.ID, u.Version = row.ID, row.Version
+
+	return nil
+}
+
+// UpdateUser stores the provisioned attributes of the user when it is
+// still at u.Version and sets the new version, it reports
+// memory.ErrNotFound otherwise. An empty password keeps the current one.
+func (s *Storage) UpdateUser(u *user.User) error {
+	const op = "storage.memory.user.UpdateUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	row, ok := s.db.Users[u.ID]
+	if !ok || row.Version != u.Version || row.DeletedAt != nil {
+		return memory.ErrNotFound
+	}
+
+	_, taken := s.find(func(other *memory.UserRow) bool {
+		return other.ID != row.ID && other.Email == u.Email
+	})
+	if taken {
+		return memory.ErrExists
+	}
+
+	row.Name = u.Name
+	row.Email = u.Email
+	if u.Password != "" {
+		row.Password = u.Password
+	}
+	row.ExternalId = u.ExternalId
+	row.DisplayName = u.DisplayName
+	row.DeactivatedAt = u.DeactivatedAt
+	row.UpdatedAt = u.UpdatedAt
+	row.Version++
+
+	u.Version = row.Version
+
+	return nil
+}
+
+// DeleteUser deprovisions a user: it is deactivated and no longer
+// returned by SCIM, the row is kept. It reports memory.ErrNotFound when
+// the user was already deleted.
+func (s *Storage) DeleteUser(ID int64, now int64) error {
+	const op = "storage.memory.user.DeleteUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	row, ok := s.db.Users[ID]
+	if !ok || row.DeletedAt != nil {
+		return memory.ErrNotFound
+	}
+
+	row.DeletedAt = &now
+	if row.DeactivatedAt == nil {
+		row.DeactivatedAt = &now
+	}
+	row.UpdatedAt = now
+	row.Version++
+
+	return nil
+}
+
+// scimUser returns the columns the SQL storages select for SCIM.
+func scimUser(row *memory.UserRow) user.User {
+	return user.User{
+		ID:            row.ID,
+		UUID:          row.UUID,
+		Name:          row.Name,
+		Email:         row.Email,
+		ExternalId:    row.ExternalId,
+		DisplayName:   row.DisplayName,
+		DeactivatedAt: row.DeactivatedAt,
+		Version:       row.Version,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+}
+
+// compile turns the filter into a predicate with the semantics of the SQL
+// condition scim.SQL translates it to, except that the negation of a
+// comparison with NULL holds where SQL leaves it unknown.
+func compile(e scim.Expr, prefix string) (func(row *memory.UserRow) bool, error) {
+	switch e := e.(type) {
+	case scim.Logical:
+		left, err := compile(e.Left, prefix)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compile(e.Right, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == "and" {
+			return func(row *memory.UserRow) bool { return left(row) && right(row) }, nil
+		}
+		return func(row *memory.UserRow) bool { return left(row) || right(row) }, nil
+	case scim.Not:
+		x, err := compile(e.X, prefix)
+		if err != nil {
+			return nil, err
+		}
+		return func(row *memory.UserRow) bool { return !x(row) }, nil
+	case scim.ValuePath:
+		if prefix != "" {
+			return nil, scim.BadRequest(scim.TypeInvalidFilter, "nested value paths are not supported")
+		}
+		return compile(e.Filter, e.Attr)
+	case scim.Compare:
+		return compare(e, prefix)
+	}
+
+	return nil, scim.BadRequest(scim.TypeInvalidFilter, "unsupported filter")
+}
+
+func compare(c scim.Compare, prefix string) (func(row *memory.UserRow) bool, error) {
+	path := c.Path
+	if prefix != "" {
+		path = scim.Path{Attr: prefix, Sub: c.Path.String()}
+	}
+
+	attr, ok := scimFilterAttributes[strings.ToLower(path.String())]
+	if !ok {
+		return nil, scim.BadRequest(scim.TypeInvalidFilter, "attribute %s can't be filtered", path)
+	}
+
+	if c.Op == "pr" {
+		return func(row *memory.UserRow) bool {
+			v := attr.value(row)
+			return v != nil && v != ""
+		}, nil
+	}
+
+	if c.Value == nil {
+		switch c.Op {
+		case "eq":
+			return func(row *memory.UserRow) bool { return attr.value(row) == nil }, nil
+		case "ne":
+			return func(row *memory.UserRow) bool { return attr.value(row) != nil }, nil
+		}
+		return nil, scim.BadRequest(scim.TypeInvalidFilter, "null can only be compared with eq and ne")
+	}
+
+	switch attr.Type {
+	case scim.Boolean:
+		want, ok := c.Value.(bool)
+		if !ok || (c.Op != "eq" && c.Op != "ne") {
+			return nil, scim.BadRequest(scim.TypeInvalidFilter, "%s is compared with eq or ne and a boolean", path)
+		}
+		return func(row *memory.UserRow) bool {
+			got, ok := attr.value(row).(bool)
+			return ok && (got == want) == (c.Op == "eq")
+		}, nil
+	case scim.DateTime:
+		s, _ := c.Value.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, scim.BadRequest(scim.TypeInvalidFilter, "%s is compared with a dateTime", path)
+		}
+		if c.Op == "co" || c.Op == "sw" || c.Op == "ew" {
+			return nil, scim.BadRequest(scim.TypeInvalidFilter, "%s can't be compared with %s", path, c.Op)
+		}
+		return func(row *memory.UserRow) bool {
+			got, ok := attr.value(row).(int64)
+			return ok && ordered(c.Op, got, t.Unix())
+		}, nil
+	}
+
+	want, ok := c.Value.(string)
+	if !ok {
+		return nil, scim.BadRequest(scim.TypeInvalidFilter, "%s is compared with a string", path)
+	}
+	if attr.Type == scim.String {
+		want = strings.ToLower(want)
+	}
+
+	return func(row *memory.UserRow) bool {
+		got, ok := attr.value(row).(string)
+		if !ok {
+			// NULL is only distinct from the value
+			return c.Op == "ne"
+		}
+		if attr.Type == scim.String {
+			got = strings.ToLower(got)
+		}
+
+		switch c.Op {
+		case "co":
+			return strings.Contains(got, want)
+		case "sw":
+			return strings.HasPrefix(got, want)
+		case "ew":
+			return strings.HasSuffix(got, want)
+		}
+		return ordered(c.Op, got, want)
+	}, nil
+}
+
+func ordered[T int64 | string](op string, got, want T) bool {
+	switch op {
+	case "eq":
+		return got == want
+	case "ne":
+		return got != want
+	case "gt":
+		return got > want
+	case "ge":
+		return got >= want
+	case "lt":
+		return got < want
+	case "le":
+		return got <= want
+	}
+	return false
+}
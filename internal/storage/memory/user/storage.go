@@ -0,0 +1,127 @@
+package user
+
+import (
+	"app/internal/domain/user"
+	"app/internal/storage/memory"
+	"app/pkg/common/logging"
+	"context"
+	"maps"
+	"slices"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *memory.DB
+}
+
+func New(ctx context.Context, db *memory.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) Registration(req *user.CreateUser) error {
+	const op = "storage.memory.user.Registration"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.Lock()
+	defer s.db.Unlock()
+
+	var emailVerifiedAt int64
+	if req.EmailVerifiedAt != nil {
+		emailVerifiedAt = *req.EmailVerifiedAt
+	}
+
+	return s.insert(&memory.UserRow{User: user.User{
+		UUID:            req.UUID,
+		Name:            req.Name,
+		Email:           req.Email,
+		EmailVerifiedAt: &emailVerifiedAt,
+		Password:        req.Password,
+		Version:         1,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.UpdatedAt,
+	}})
+}
+
+func (s *Storage) Login(req *user.User) (user.User, error) {
+	const op = "storage.memory.user.Login"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	u, ok := s.find(func(row *memory.UserRow) bool {
+		return (row.Name == req.Name || row.Email == req.Email) && row.DeactivatedAt == nil
+	})
+	if !ok {
+		return user.User{}, memory.ErrNotFound
+	}
+
+	return user.User{ID: u.ID, UUID: u.UUID, Name: u.Name, Email: u.Email, Password: u.Password}, nil
+}
+
+func (s *Storage) GetUser(ID int64) (user.User, error) {
+	const op = "storage.memory.user.GetUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	return s.getUser(func(row *memory.UserRow) bool {
+		return row.ID == ID
+	})
+}
+
+func (s *Storage) GetUserByEmail(email string) (user.User, error) {
+	const op = "storage.memory.user.GetUserByEmail"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	s.db.RLock()
+	defer s.db.RUnlock()
+
+	return s.getUser(func(row *memory.UserRow) bool {
+		return row.Email == email
+	})
+}
+
+// getUser returns the active user matching, with the columns the SQL
+// storages select.
+func (s *Storage) getUser(match func(row *memory.UserRow) bool) (user.User, error) {
+	u, ok := s.find(func(row *memory.UserRow) bool {
+		return match(row) && row.DeactivatedAt == nil
+	})
+	if !ok {
+		return user.User{}, memory.ErrNotFound
+	}
+
+	return user.User{ID: u.ID, UUID: u.UUID, Name: u.Name, Email: u.Email, IsActive: u.IsActive}, nil
+}
+
+// find returns the first row by ID that matches, the lock must be held.
+func (s *Storage) find(match func(row *memory.UserRow) bool) (*memory.UserRow, bool) {
+	for _, ID := range slices.Sorted(maps.Keys(s.db.Users)) {
+		if row := s.db.Users[ID]; match(row) {
+			return row, true
+		}
+	}
+
+	return nil, false
+}
+
+// insert stores the row with a new ID unless its UUID or its email is
+// taken, the lock must be held.
+func (s *Storage) insert(row *memory.UserRow) error {
+	_, taken := s.find(func(other *memory.UserRow) bool {
+		return other.UUID == row.UUID || other.Email == row.Email
+	})
+	if taken {
+		return memory.ErrExists
+	}
+
+	row.ID = s.db.NextUserID()
+	s.db.Users[row.ID] = row
+
+	return nil
+}
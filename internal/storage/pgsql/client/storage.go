@@ -7,9 +7,17 @@ import (
 	"app/pkg/utils/loop"
 	"context"
 	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const selectColumns = `
+	id, user_id, name, secret, previous_secret, previous_secret_expires_at, provider, redirect_uris,
+	personal_access_client, password_client, revoked, token_endpoint_auth_method, jwks, grant_types, contacts,
+	registration_access_token, token_exchange, require_pushed_authorization_requests, tls_client_auth,
+	scopes, created_at, updated_at
+`
+
 type Storage struct {
 	ctx context.Context
 	db  *pgxpool.Pool
@@ -24,36 +32,20 @@ func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 
 func (s *Storage) GetClient(ID string) (client.Client, error) {
 	const op = "storage.pgsql.oauth.client.GetClient"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
-		SELECT id, user_id, name, secret, provider, redirect, personal_access_client, password_client, revoked
+		SELECT %s
 		FROM %s
 		WHERE id = $1
 	`
-	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthClient)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("prepare query", querySQL)
-
-	var c client.Client
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
 
-	err := s.db.QueryRow(
-		s.ctx,
-		querySQL,
-		ID,
-	).Scan(
-		&c.ID,
-		&c.UserId,
-		&c.Name,
-		&c.Secret,
-		&c.Provider,
-		&c.Redirect,
-		&c.PersonalAccessClient,
-		&c.PasswordClient,
-		&c.Revoked,
-	)
+	c, err := scanClient(s.db.QueryRow(s.ctx, querySQL, ID))
 	if err != nil {
-		logging.L(s.ctx).Error("error query db", err)
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
 		return c, err
 	}
 
@@ -62,16 +54,19 @@ func (s *Storage) GetClient(ID string) (client.Client, error) {
 
 func (s *Storage) CreateClient(oauthClient *client.Client) error {
 	const op = "storage.pgsql.oauth.client.CreateClient"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
-		INSERT INTO %s (id, user_id, name, secret, provider, redirect, personal_access_client, password_client, revoked, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO %s (id, user_id, name, secret, provider, redirect_uris, personal_access_client, password_client, revoked,
+		                token_endpoint_auth_method, jwks, grant_types, contacts, registration_access_token,
+		                token_exchange, require_pushed_authorization_requests, tls_client_auth, scopes, created_at,
+		                updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 	`
 
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("prepare query", querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
 
 	_, err := s.db.Exec(
 		s.ctx,
@@ -81,16 +76,25 @@ func (s *Storage) CreateClient(oauthClient *client.Client) error {
 		oauthClient.Name,
 		oauthClient.Secret,
 		oauthClient.Provider,
-		oauthClient.Redirect,
+		oauthClient.RedirectURIs,
 		oauthClient.PersonalAccessClient,
 		oauthClient.PasswordClient,
 		oauthClient.Revoked,
+		oauthClient.TokenEndpointAuthMethod,
+		oauthClient.JWKS,
+		oauthClient.GrantTypes,
+		oauthClient.Contacts,
+		oauthClient.RegistrationAccessToken,
+		oauthClient.TokenExchange,
+		oauthClient.RequirePushedAuthorizationRequests,
+		oauthClient.TLSClientAuth,
+		oauthClient.Scopes,
 		oauthClient.CreatedAt,
 		oauthClient.UpdatedAt,
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query db", err)
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
 		return err
 	}
 
@@ -99,40 +103,226 @@ func (s *Storage) CreateClient(oauthClient *client.Client) error {
 
 func (s *Storage) GetClientByName(name string) (client.Client, error) {
 	const op = "storage.pgsql.oauth.client.GetClientByName"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
-		SELECT id, user_id, name, secret, provider, redirect, personal_access_client, password_client, revoked
+		SELECT %s
 		FROM %s
 		WHERE name = $1
 	`
 
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	c, err := scanClient(s.db.QueryRow(s.ctx, querySQL, name))
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return c, err
+	}
+
+	return c, nil
+}
+
+func (s *Storage) GetClients(limit, offset int) ([]client.Client, error) {
+	const op = "storage.pgsql.oauth.client.GetClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT %s
+		FROM %s
+		ORDER BY created_at DESC, id
+		LIMIT $1 OFFSET $2
+	`
+
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	rows, err := s.db.Query(s.ctx, querySQL, limit, offset)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := make([]client.Client, 0, limit)
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan row", logging.ErrAttr(err))
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (s *Storage) CountClients() (int64, error) {
+	const op = "storage.pgsql.oauth.client.CountClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, migrations.TableOauthClient)
+
+	var total int64
+	if err := s.db.QueryRow(s.ctx, querySQL).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (s *Storage) UpdateClient(oauthClient *client.Client) error {
+	const op = "storage.pgsql.oauth.client.UpdateClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET user_id = $2,
+			name = $3,
+			redirect_uris = $4,
+			personal_access_client = $5,
+			password_client = $6,
+			token_endpoint_auth_method = $7,
+			jwks = $8,
+			grant_types = $9,
+			contacts = $10,
+			token_exchange = $11,
+			require_pushed_authorization_requests = $12,
+			tls_client_auth = $13,
+			scopes = $14,
+			updated_at = $15
+		WHERE id = $1
+	`
+
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("prepare query", querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
 
-	var c client.Client
+	return s.exec(
+		querySQL,
+		oauthClient.ID,
+		oauthClient.UserId,
+		oauthClient.Name,
+		oauthClient.RedirectURIs,
+		oauthClient.PersonalAccessClient,
+		oauthClient.PasswordClient,
+		oauthClient.TokenEndpointAuthMethod,
+		oauthClient.JWKS,
+		oauthClient.GrantTypes,
+		oauthClient.Contacts,
+		oauthClient.TokenExchange,
+		oauthClient.RequirePushedAuthorizationRequests,
+		oauthClient.TLSClientAuth,
+		oauthClient.Scopes,
+		oauthClient.UpdatedAt,
+	)
+}
 
-	err := s.db.QueryRow(
-		s.ctx,
+func (s *Storage) UpdateRevoked(ID string, revoked bool, updatedAt int64) error {
+	const op = "storage.pgsql.oauth.client.UpdateRevoked"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET revoked = $2, updated_at = $3
+		WHERE id = $1
+	`
+
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(querySQL, ID, revoked, updatedAt)
+}
+
+func (s *Storage) UpdateSecret(oauthClient *client.Client) error {
+	const op = "storage.pgsql.oauth.client.UpdateSecret"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET secret = $2,
+			previous_secret = $3,
+			previous_secret_expires_at = $4,
+			updated_at = $5
+		WHERE id = $1
+	`
+
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(
 		querySQL,
-		name,
-	).Scan(
+		oauthClient.ID,
+		oauthClient.Secret,
+		oauthClient.PreviousSecret,
+		oauthClient.PreviousSecretExpiresAt,
+		oauthClient.UpdatedAt,
+	)
+}
+
+func (s *Storage) DeleteClient(ID string) error {
+	const op = "storage.pgsql.oauth.client.DeleteClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableOauthClient)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(querySQL, ID)
+}
+
+// exec runs a statement that targets a single client and reports
+// pgx.ErrNoRows when nothing was affected.
+func (s *Storage) exec(querySQL string, args ...any) error {
+	tag, err := s.db.Exec(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanClient(row pgx.Row) (client.Client, error) {
+	var c client.Client
+
+	err := row.Scan(
 		&c.ID,
 		&c.UserId,
 		&c.Name,
 		&c.Secret,
+		&c.PreviousSecret,
+		&c.PreviousSecretExpiresAt,
 		&c.Provider,
-		&c.Redirect,
+		&c.RedirectURIs,
 		&c.PersonalAccessClient,
 		&c.PasswordClient,
 		&c.Revoked,
+		&c.TokenEndpointAuthMethod,
+		&c.JWKS,
+		&c.GrantTypes,
+		&c.Contacts,
+		&c.RegistrationAccessToken,
+		&c.TokenExchange,
+		&c.RequirePushedAuthorizationRequests,
+		&c.TLSClientAuth,
+		&c.Scopes,
+		&c.CreatedAt,
+		&c.UpdatedAt,
 	)
 
-	if err != nil {
-		logging.L(s.ctx).Error("error query db", err)
-		return c, err
-	}
-
-	return c, nil
+	return c, err
 }
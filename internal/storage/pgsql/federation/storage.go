@@ -0,0 +1,297 @@
+package federation
+
+import (
+	federationDomain "app/internal/domain/federation"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateState(st *federationDomain.State) error {
+	const op = "storage.pgsql.federation.CreateState"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, provider, client_id, user_id, params, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableFederationState)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		st.ID,
+		st.Provider,
+		st.ClientId,
+		st.UserId,
+		st.Params,
+		st.Nonce,
+		st.CodeVerifier,
+		st.ExpiresAt,
+		st.CreatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetState(ID string) (federationDomain.State, error) {
+	const op = "storage.pgsql.federation.GetState"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, provider, client_id, user_id, params, nonce, code_verifier, expires_at, created_at
+		FROM %s
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableFederationState)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var st federationDomain.State
+	err := s.db.QueryRow(s.ctx, querySQL, ID).Scan(
+		&st.ID,
+		&st.Provider,
+		&st.ClientId,
+		&st.UserId,
+		&st.Params,
+		&st.Nonce,
+		&st.CodeVerifier,
+		&st.ExpiresAt,
+		&st.CreatedAt,
+	)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return st, err
+	}
+
+	return st, nil
+}
+
+// DeleteState removes a state and reports pgx.ErrNoRows when it was
+// already gone, so a state completes a sign in at most once.
+func (s *Storage) DeleteState(ID string) error {
+	const op = "storage.pgsql.federation.DeleteState"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableFederationState)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.pgsql.federation.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, migrations.TableFederationState)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *Storage) CreateIdentity(i *federationDomain.Identity) error {
+	const op = "storage.pgsql.federation.CreateIdentity"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, user_id, provider, subject, email, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableFederatedIdentity)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		i.ID,
+		i.UserId,
+		i.Provider,
+		i.Subject,
+		i.Email,
+		i.CreatedAt,
+		i.UpdatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetIdentity(provider, subject string) (federationDomain.Identity, error) {
+	const op = "storage.pgsql.federation.GetIdentity"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, user_id, provider, subject, email, created_at, updated_at
+		FROM %s
+		WHERE provider = $1 AND subject = $2
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableFederatedIdentity)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var i federationDomain.Identity
+	err := s.db.QueryRow(s.ctx, querySQL, provider, subject).Scan(
+		&i.ID,
+		&i.UserId,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return i, err
+	}
+
+	return i, nil
+}
+
+func (s *Storage) GetIdentities(userID int64) ([]federationDomain.Identity, error) {
+	const op = "storage.pgsql.federation.GetIdentities"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, user_id, provider, subject, email, created_at, updated_at
+		FROM %s
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableFederatedIdentity)
+	querySQL = loop.FormatQuery(querySQL)
+
+	rows, err := s.db.Query(s.ctx, querySQL, userID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]federationDomain.Identity, 0)
+	for rows.Next() {
+		var i federationDomain.Identity
+		err := rows.Scan(
+			&i.ID,
+			&i.UserId,
+			&i.Provider,
+			&i.Subject,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+
+	return identities, rows.Err()
+}
+
+// DeleteIdentity unlinks an identity of the user and returns it. It reports
+// pgx.ErrNoRows when the user has no such identity and
+// ErrLastLoginMethod when the user would be left with neither a password
+// nor an identity. The user row is locked so concurrent unlinks can't
+// remove the last two login methods together.
+func (s *Storage) DeleteIdentity(ID string, userID int64) (federationDomain.Identity, error) {
+	const op = "storage.pgsql.federation.DeleteIdentity"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	var i federationDomain.Identity
+
+	err := pgx.BeginFunc(s.ctx, s.db, func(tx pgx.Tx) error {
+		var hasPassword bool
+		err := tx.QueryRow(
+			s.ctx,
+			fmt.Sprintf(`SELECT password <> '' FROM %s WHERE id = $1 FOR UPDATE`, migrations.TableUsers),
+			userID,
+		).Scan(&hasPassword)
+		if err != nil {
+			return err
+		}
+
+		querySQL := `
+			DELETE FROM %s
+			WHERE id = $1 AND user_id = $2
+			RETURNING id, user_id, provider, subject, email, created_at, updated_at
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableFederatedIdentity)
+		querySQL = loop.FormatQuery(querySQL)
+
+		err = tx.QueryRow(s.ctx, querySQL, ID, userID).Scan(
+			&i.ID,
+			&i.UserId,
+			&i.Provider,
+			&i.Subject,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		var remaining int64
+		err = tx.QueryRow(
+			s.ctx,
+			fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE user_id = $1`, migrations.TableFederatedIdentity),
+			userID,
+		).Scan(&remaining)
+		if err != nil {
+			return err
+		}
+
+		if remaining == 0 && !hasPassword {
+			return federationDomain.ErrLastLoginMethod
+		}
+
+		return nil
+	})
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return federationDomain.Identity{}, err
+	}
+
+	return i, nil
+}
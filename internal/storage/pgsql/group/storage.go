@@ -0,0 +1,363 @@
+package group
+
+import (
+	groupDomain "app/internal/domain/group"
+	"app/migrations"
+	"app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+// ScimFilterColumns are the columns the SCIM filters of groups are
+// translated for.
+var ScimFilterColumns = map[string]scim.Column{
+	"id":                {SQL: "uuid::text", Type: scim.CaseExactString},
+	"displayname":       {SQL: "display_name", Type: scim.String},
+	"externalid":        {SQL: "external_id", Type: scim.CaseExactString},
+	"meta.created":      {SQL: "created_at", Type: scim.DateTime},
+	"meta.lastmodified": {SQL: "updated_at", Type: scim.DateTime},
+}
+
+const groupColumns = `id, uuid, display_name, external_id, version, created_at, updated_at`
+
+func scanGroup(row pgx.Row, g *groupDomain.Group) error {
+	return row.Scan(
+		&g.ID,
+		&g.UUID,
+		&g.DisplayName,
+		&g.ExternalId,
+		&g.Version,
+		&g.CreatedAt,
+		&g.UpdatedAt,
+	)
+}
+
+// CreateGroup stores the group and its members and sets its ID, it
+// reports ErrUnknownMember when a member is not a user.
+func (s *Storage) CreateGroup(g *groupDomain.Group) error {
+	const op = "storage.pgsql.group.CreateGroup"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	err := pgx.BeginFunc(s.ctx, s.db, func(tx pgx.Tx) error {
+		querySQL := `
+			INSERT INTO %s (uuid, display_name, external_id, version, created_at, updated_at)
+			VALUES ($1, $2, $3, 1, $4, $5)
+			RETURNING id, version
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableGroup)
+		querySQL = loop.FormatQuery(querySQL)
+		logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+		err := tx.QueryRow(
+			s.ctx,
+			querySQL,
+			g.UUID,
+			g.DisplayName,
+			g.ExternalId,
+			g.CreatedAt,
+			g.UpdatedAt,
+		).Scan(&g.ID, &g.Version)
+		if err != nil {
+			return err
+		}
+
+		return s.setMembers(tx, g)
+	})
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetGroup returns the group with its members.
+func (s *Storage) GetGroup(UUID string) (groupDomain.Group, error) {
+	const op = "storage.pgsql.group.GetGroup"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE uuid = $1`, groupColumns, migrations.TableGroup)
+
+	var g groupDomain.Group
+	if err := scanGroup(s.db.QueryRow(s.ctx, querySQL, UUID), &g); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return g, err
+	}
+
+	members, err := s.getMembers([]int64{g.ID})
+	if err != nil {
+		return g, err
+	}
+	g.Members = members[g.ID]
+
+	return g, nil
+}
+
+// FindGroups returns the page of the groups matching the filter, a nil
+// filter matches every group, and their total.
+func (s *Storage) FindGroups(filter scim.Expr, page scim.Page) ([]groupDomain.Group, int64, error) {
+	const op = "storage.pgsql.group.FindGroups"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	where := "true"
+	var args []any
+	if filter != nil {
+		cond, condArgs, err := scim.SQL(filter, ScimFilterColumns, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		where = cond
+		args = condArgs
+	}
+
+	var total int64
+	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, migrations.TableGroup, where)
+	if err := s.db.QueryRow(s.ctx, countSQL, args...).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, 0, err
+	}
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s ORDER BY id LIMIT %d OFFSET %d`,
+		groupColumns, migrations.TableGroup, where, page.Count, page.Offset(),
+	)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	rows, err := s.db.Query(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, 0, err
+	}
+
+	groups := make([]groupDomain.Group, 0, page.Count)
+	IDs := make([]int64, 0, page.Count)
+	for rows.Next() {
+		var g groupDomain.Group
+		if err := scanGroup(rows, &g); err != nil {
+			rows.Close()
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, 0, err
+		}
+		groups = append(groups, g)
+		IDs = append(IDs, g.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, 0, err
+	}
+
+	members, err := s.getMembers(IDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range groups {
+		groups[i].Members = members[groups[i].ID]
+	}
+
+	return groups, total, nil
+}
+
+// UpdateGroup replaces the attributes and the members of the group when it
+// is still at g.Version and sets the new version, it reports
+// pgx.ErrNoRows otherwise.
+func (s *Storage) UpdateGroup(g *groupDomain.Group) error {
+	const op = "storage.pgsql.group.UpdateGroup"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	err := pgx.BeginFunc(s.ctx, s.db, func(tx pgx.Tx) error {
+		querySQL := `
+			UPDATE %s
+			SET display_name = $3,
+			    external_id  = $4,
+			    updated_at   = $5,
+			    version      = version + 1
+			WHERE id = $1 AND version = $2
+			RETURNING version
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableGroup)
+		querySQL = loop.FormatQuery(querySQL)
+		logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+		err := tx.QueryRow(
+			s.ctx,
+			querySQL,
+			g.ID,
+			g.Version,
+			g.DisplayName,
+			g.ExternalId,
+			g.UpdatedAt,
+		).Scan(&g.Version)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			s.ctx,
+			fmt.Sprintf(`DELETE FROM %s WHERE group_id = $1`, migrations.TableGroupMember),
+			g.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		return s.setMembers(tx, g)
+	})
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes the group, it reports pgx.ErrNoRows when there is
+// no such group.
+func (s *Storage) DeleteGroup(UUID string) error {
+	const op = "storage.pgsql.group.DeleteGroup"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	tag, err := s.db.Exec(s.ctx, fmt.Sprintf(`DELETE FROM %s WHERE uuid = $1`, migrations.TableGroup), UUID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetGroupsOfUsers returns the groups of each of the users.
+func (s *Storage) GetGroupsOfUsers(userIDs []int64) (map[int64][]groupDomain.Membership, error) {
+	const op = "storage.pgsql.group.GetGroupsOfUsers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT m.user_id, g.uuid, g.display_name
+		FROM %s m
+		JOIN %s g ON g.id = m.group_id
+		WHERE m.user_id = ANY($1)
+		ORDER BY g.id
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableGroupMember, migrations.TableGroup)
+	querySQL = loop.FormatQuery(querySQL)
+
+	rows, err := s.db.Query(s.ctx, querySQL, userIDs)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make(map[int64][]groupDomain.Membership, len(userIDs))
+	for rows.Next() {
+		var m groupDomain.Membership
+		if err := rows.Scan(&m.UserId, &m.GroupUUID, &m.DisplayName); err != nil {
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, err
+		}
+		groups[m.UserId] = append(groups[m.UserId], m)
+	}
+
+	return groups, rows.Err()
+}
+
+// setMembers adds the members of the group, deleted users are unknown.
+func (s *Storage) setMembers(tx pgx.Tx, g *groupDomain.Group) error {
+	uuids := g.MemberUUIDs()
+	if len(uuids) == 0 {
+		return nil
+	}
+
+	querySQL := `
+		INSERT INTO %s (group_id, user_id)
+		SELECT $1, id FROM %s WHERE uuid::text = ANY($2) AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableGroupMember, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+
+	if _, err := tx.Exec(s.ctx, querySQL, g.ID, uuids); err != nil {
+		return err
+	}
+
+	var count int
+	err := tx.QueryRow(
+		s.ctx,
+		fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE group_id = $1`, migrations.TableGroupMember),
+		g.ID,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count != len(unique(uuids)) {
+		return groupDomain.ErrUnknownMember
+	}
+
+	return nil
+}
+
+// getMembers returns the members of each of the groups.
+func (s *Storage) getMembers(groupIDs []int64) (map[int64][]groupDomain.Member, error) {
+	members := make(map[int64][]groupDomain.Member, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+
+	querySQL := `
+		SELECT m.group_id, u.uuid, COALESCE(u.display_name, u.name)
+		FROM %s m
+		JOIN %s u ON u.id = m.user_id
+		WHERE m.group_id = ANY($1) AND u.deleted_at IS NULL
+		ORDER BY u.id
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableGroupMember, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+
+	rows, err := s.db.Query(s.ctx, querySQL, groupIDs)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID int64
+		var m groupDomain.Member
+		if err := rows.Scan(&groupID, &m.UserUUID, &m.Display); err != nil {
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, err
+		}
+		members[groupID] = append(members[groupID], m)
+	}
+
+	return members, rows.Err()
+}
+
+func unique(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
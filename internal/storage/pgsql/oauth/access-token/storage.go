@@ -2,12 +2,14 @@ package access_token
 
 import (
 	accessToken "app/internal/domain/oauth/access-token"
+	"app/internal/domain/oauth/revocation"
 	"app/migrations"
 	"app/pkg/common/logging"
 	"app/pkg/utils/loop"
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type Storage struct {
@@ -24,7 +26,7 @@ func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 
 func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
 	const op = "storage.pgsql.oauth.access-token.CreateToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
 			INSERT INTO %s (id, user_id, client_id, name, scopes, revoked, created_at, updated_at, expires_at)
@@ -33,7 +35,7 @@ func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
 			`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	var AccessTokenStorage accessToken.AccessToken
 
@@ -54,7 +56,7 @@ func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return "", err
 	}
 
@@ -63,7 +65,7 @@ func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
 
 func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	const op = "storage.pgsql.oauth.access-token.ExistsToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	var isExists bool
 	querySQL := `
 		SELECT (COUNT(*) > 0) as isExists
@@ -72,7 +74,7 @@ func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	err := s.db.QueryRow(
 		s.ctx,
@@ -83,37 +85,159 @@ func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	).Scan(&isExists)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
 	return isExists, nil
 }
 
+// UpdateToken revokes the access token and records the revocation.
 func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
 	const op = "storage.pgsql.oauth.access-token.UpdateToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
-		UPDATE %s
-		SET revoked = true
-		WHERE id = $1 AND user_id = $2 AND client_id = $3
+		WITH revoked AS (
+			UPDATE %s
+			SET revoked = true
+			WHERE id = $1 AND user_id = $2 AND client_id = $3
+			RETURNING id, user_id, expires_at
+		)
+		INSERT INTO %s (kind, token_id, user_id, created_at, expires_at)
+		SELECT $4, id, user_id, $5, expires_at FROM revoked
 	`
-	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken, migrations.TableOauthRevocationEvent)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 	_, err := s.db.Exec(
 		s.ctx,
 		querySQL,
 		aT.ID,
 		aT.UserId,
 		aT.ClientId,
+		revocation.KindToken,
+		time.Now().Unix(),
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
 	return true, nil
 }
+
+func (s *Storage) GetToken(ID string) (accessToken.AccessToken, error) {
+	const op = "storage.pgsql.oauth.access-token.GetToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, COALESCE(user_id, 0), client_id, COALESCE(name, ''), scopes, revoked, created_at, updated_at, expires_at
+		FROM %s
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	var aT accessToken.AccessToken
+
+	err := s.db.QueryRow(
+		s.ctx,
+		querySQL,
+		ID,
+	).Scan(
+		&aT.ID,
+		&aT.UserId,
+		&aT.ClientId,
+		&aT.Name,
+		&aT.Scopes,
+		&aT.Revoked,
+		&aT.CreatedAt,
+		&aT.UpdatedAt,
+		&aT.ExpiresAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return accessToken.AccessToken{}, err
+	}
+
+	return aT, nil
+}
+
+// RevokeUserTokens revokes the access tokens of the user and their
+// refresh tokens, a revocation of the user is recorded when it had any.
+func (s *Storage) RevokeUserTokens(userID int64) error {
+	const op = "storage.pgsql.oauth.access-token.RevokeUserTokens"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		WITH revoked AS (
+			UPDATE %s
+			SET revoked = true
+			WHERE user_id = $1 AND revoked = false
+			RETURNING id, expires_at
+		), refresh AS (
+			UPDATE %s
+			SET revoked = true
+			WHERE revoked = false AND access_token_id IN (SELECT id FROM revoked)
+		)
+		INSERT INTO %s (kind, user_id, created_at, expires_at)
+		SELECT $2, $1, $3, MAX(expires_at) FROM revoked
+		HAVING COUNT(*) > 0
+	`
+	querySQL = fmt.Sprintf(
+		querySQL,
+		migrations.TableOauthAccessToken,
+		migrations.TableOauthRefreshToken,
+		migrations.TableOauthRevocationEvent,
+	)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	if _, err := s.db.Exec(s.ctx, querySQL, userID, revocation.KindUser, time.Now().Unix()); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeToken revokes the access token and the refresh tokens issued
+// with it, and records the revocation.
+func (s *Storage) RevokeToken(ID string) error {
+	const op = "storage.pgsql.oauth.access-token.RevokeToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		WITH revoked AS (
+			UPDATE %s
+			SET revoked = true
+			WHERE id = $1
+			RETURNING id, user_id, expires_at
+		), refresh AS (
+			UPDATE %s
+			SET revoked = true
+			WHERE revoked = false AND access_token_id IN (SELECT id FROM revoked)
+		)
+		INSERT INTO %s (kind, token_id, user_id, created_at, expires_at)
+		SELECT $2, id, user_id, $3, expires_at FROM revoked
+	`
+	querySQL = fmt.Sprintf(
+		querySQL,
+		migrations.TableOauthAccessToken,
+		migrations.TableOauthRefreshToken,
+		migrations.TableOauthRevocationEvent,
+	)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	if _, err := s.db.Exec(s.ctx, querySQL, ID, revocation.KindToken, time.Now().Unix()); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
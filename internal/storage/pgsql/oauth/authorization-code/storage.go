@@ -0,0 +1,133 @@
+package authorization_code
+
+import (
+	authorizationCodeDomain "app/internal/domain/oauth/authorization-code"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateAuthorizationCode(aC *authorizationCodeDomain.AuthorizationCode) error {
+	const op = "storage.pgsql.oauth.authorization-code.CreateAuthorizationCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, client_id, user_id, redirect_uri, scope, resources, code_challenge, code_challenge_method,
+		                auth_time, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAuthorizationCode)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		aC.ID,
+		aC.ClientId,
+		aC.UserId,
+		aC.RedirectURI,
+		aC.Scope,
+		aC.Resources,
+		aC.CodeChallenge,
+		aC.CodeChallengeMethod,
+		aC.AuthTime,
+		aC.ExpiresAt,
+		aC.CreatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetAuthorizationCode(ID string) (authorizationCodeDomain.AuthorizationCode, error) {
+	const op = "storage.pgsql.oauth.authorization-code.GetAuthorizationCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, client_id, user_id, redirect_uri, scope, resources, code_challenge, code_challenge_method, auth_time,
+		       expires_at, created_at
+		FROM %s
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAuthorizationCode)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var aC authorizationCodeDomain.AuthorizationCode
+	err := s.db.QueryRow(s.ctx, querySQL, ID).Scan(
+		&aC.ID,
+		&aC.ClientId,
+		&aC.UserId,
+		&aC.RedirectURI,
+		&aC.Scope,
+		&aC.Resources,
+		&aC.CodeChallenge,
+		&aC.CodeChallengeMethod,
+		&aC.AuthTime,
+		&aC.ExpiresAt,
+		&aC.CreatedAt,
+	)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return aC, err
+	}
+
+	return aC, nil
+}
+
+// DeleteAuthorizationCode removes an authorization code and reports
+// pgx.ErrNoRows when it was already gone, so a code is exchanged for
+// tokens at most once.
+func (s *Storage) DeleteAuthorizationCode(ID string) error {
+	const op = "storage.pgsql.oauth.authorization-code.DeleteAuthorizationCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableOauthAuthorizationCode)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.pgsql.oauth.authorization-code.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, migrations.TableOauthAuthorizationCode)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
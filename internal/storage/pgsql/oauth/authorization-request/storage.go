@@ -0,0 +1,110 @@
+package authorization_request
+
+import (
+	authorizationRequestDomain "app/internal/domain/oauth/authorization-request"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateAuthorizationRequest(aR *authorizationRequestDomain.AuthorizationRequest) error {
+	const op = "storage.pgsql.oauth.authorization-request.CreateAuthorizationRequest"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, client_id, params, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAuthorizationRequest)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(s.ctx, querySQL, aR.ID, aR.ClientId, aR.Params, aR.ExpiresAt, aR.CreatedAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetAuthorizationRequest(ID string) (authorizationRequestDomain.AuthorizationRequest, error) {
+	const op = "storage.pgsql.oauth.authorization-request.GetAuthorizationRequest"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, client_id, params, expires_at, created_at
+		FROM %s
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAuthorizationRequest)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var aR authorizationRequestDomain.AuthorizationRequest
+	err := s.db.QueryRow(s.ctx, querySQL, ID).Scan(
+		&aR.ID,
+		&aR.ClientId,
+		&aR.Params,
+		&aR.ExpiresAt,
+		&aR.CreatedAt,
+	)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return aR, err
+	}
+
+	return aR, nil
+}
+
+// DeleteAuthorizationRequest removes a pushed authorization request and
+// reports pgx.ErrNoRows when it was already gone, so a request_uri is used
+// at most once.
+func (s *Storage) DeleteAuthorizationRequest(ID string) error {
+	const op = "storage.pgsql.oauth.authorization-request.DeleteAuthorizationRequest"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableOauthAuthorizationRequest)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.pgsql.oauth.authorization-request.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, migrations.TableOauthAuthorizationRequest)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,203 @@
+package device_code
+
+import (
+	deviceCodeDomain "app/internal/domain/oauth/device-code"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectColumns = `
+	id, user_code, client_id, scopes, user_id, auth_time, status, poll_interval, last_polled_at, expires_at, created_at
+`
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateDeviceCode(dC *deviceCodeDomain.DeviceCode) error {
+	const op = "storage.pgsql.oauth.device-code.CreateDeviceCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, user_code, client_id, scopes, user_id, auth_time, status, poll_interval, last_polled_at,
+		                expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthDeviceCode)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		dC.ID,
+		dC.UserCode,
+		dC.ClientId,
+		dC.Scopes,
+		dC.UserId,
+		dC.AuthTime,
+		dC.Status,
+		dC.Interval,
+		dC.LastPolledAt,
+		dC.ExpiresAt,
+		dC.CreatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetDeviceCode(ID string) (deviceCodeDomain.DeviceCode, error) {
+	const op = "storage.pgsql.oauth.device-code.GetDeviceCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, selectColumns, migrations.TableOauthDeviceCode)
+	querySQL = loop.FormatQuery(querySQL)
+
+	dC, err := scanDeviceCode(s.db.QueryRow(s.ctx, querySQL, ID))
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return dC, err
+	}
+
+	return dC, nil
+}
+
+func (s *Storage) GetDeviceCodeByUserCode(userCode string) (deviceCodeDomain.DeviceCode, error) {
+	const op = "storage.pgsql.oauth.device-code.GetDeviceCodeByUserCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE user_code = $1`, selectColumns, migrations.TableOauthDeviceCode)
+	querySQL = loop.FormatQuery(querySQL)
+
+	dC, err := scanDeviceCode(s.db.QueryRow(s.ctx, querySQL, userCode))
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return dC, err
+	}
+
+	return dC, nil
+}
+
+// UpdateStatus records the decision of the user, only a pending code can
+// be approved or denied.
+func (s *Storage) UpdateStatus(dC *deviceCodeDomain.DeviceCode) error {
+	const op = "storage.pgsql.oauth.device-code.UpdateStatus"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET status = $2, user_id = $3, auth_time = $4
+		WHERE id = $1 AND status = $5
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthDeviceCode)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	tag, err := s.db.Exec(s.ctx, querySQL, dC.ID, dC.Status, dC.UserId, dC.AuthTime, deviceCodeDomain.StatusPending)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) UpdatePolling(dC *deviceCodeDomain.DeviceCode) error {
+	const op = "storage.pgsql.oauth.device-code.UpdatePolling"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET last_polled_at = $2, poll_interval = $3
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthDeviceCode)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(s.ctx, querySQL, dC.ID, dC.LastPolledAt, dC.Interval)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteDeviceCode removes a device code and reports pgx.ErrNoRows when
+// it was already gone, so a code is exchanged for tokens at most once.
+func (s *Storage) DeleteDeviceCode(ID string) error {
+	const op = "storage.pgsql.oauth.device-code.DeleteDeviceCode"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableOauthDeviceCode)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.pgsql.oauth.device-code.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE expires_at < $1`, migrations.TableOauthDeviceCode)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanDeviceCode(row pgx.Row) (deviceCodeDomain.DeviceCode, error) {
+	var dC deviceCodeDomain.DeviceCode
+
+	err := row.Scan(
+		&dC.ID,
+		&dC.UserCode,
+		&dC.ClientId,
+		&dC.Scopes,
+		&dC.UserId,
+		&dC.AuthTime,
+		&dC.Status,
+		&dC.Interval,
+		&dC.LastPolledAt,
+		&dC.ExpiresAt,
+		&dC.CreatedAt,
+	)
+
+	return dC, err
+}
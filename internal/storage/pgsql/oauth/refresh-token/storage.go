@@ -24,7 +24,7 @@ func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 
 func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (string, error) {
 	const op = "storage.pgsql.oauth.refresh-token.CreateRefreshToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
 			INSERT INTO %s (id, access_token_id, revoked, expires_at)
@@ -32,7 +32,7 @@ func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (strin
 			`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	_, err := s.db.Exec(
 		s.ctx,
@@ -44,7 +44,7 @@ func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (strin
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return "", err
 	}
 
@@ -53,7 +53,7 @@ func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (strin
 
 func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
 	const op = "storage.pgsql.oauth.refresh-token.ExistsToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	var isExists bool
 	querySQL := `
 		SELECT (COUNT(*) > 0) as isExists
@@ -62,7 +62,7 @@ func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	err := s.db.QueryRow(
 		s.ctx,
@@ -72,7 +72,7 @@ func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 	).Scan(&isExists)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
@@ -81,7 +81,7 @@ func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 
 func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
 	const op = "storage.pgsql.oauth.refresh-token.ExistsToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	var rTQ = refreshTokenDomain.RefreshToken{}
 
 	querySQL := `
@@ -92,7 +92,7 @@ func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDom
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	err := s.db.QueryRow(
 		s.ctx,
@@ -107,7 +107,7 @@ func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDom
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return refreshTokenDomain.RefreshToken{}, err
 	}
 
@@ -116,7 +116,7 @@ func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDom
 
 func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
 	const op = "storage.pgsql.oauth.refresh-token.UpdateToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
 		UPDATE %s
@@ -125,7 +125,7 @@ func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 	_, err := s.db.Exec(
 		s.ctx,
 		querySQL,
@@ -134,7 +134,7 @@ func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
@@ -143,7 +143,7 @@ func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
 
 func (s *Storage) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
 	const op = "storage.pgsql.oauth.refresh-token.GetLastReceivedToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	var rTQ = refreshTokenDomain.RefreshToken{}
 
 	querySQL := `
@@ -163,7 +163,7 @@ func (s *Storage) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (ref
 		LIMIT 1
 	`
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	err := s.db.QueryRow(
 		s.ctx,
@@ -178,7 +178,7 @@ func (s *Storage) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (ref
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return refreshTokenDomain.RefreshToken{}, err
 	}
 
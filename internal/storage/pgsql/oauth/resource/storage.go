@@ -0,0 +1,226 @@
+package resource
+
+import (
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectColumns = `id, identifier, name, scopes, token_ttl, client_id, created_at, updated_at`
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateResource(r *resourceDomain.Resource) error {
+	const op = "storage.pgsql.oauth.resource.CreateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, identifier, name, scopes, token_ttl, client_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		r.ID,
+		r.Identifier,
+		r.Name,
+		r.Scopes,
+		r.TokenTTL,
+		r.ClientId,
+		r.CreatedAt,
+		r.UpdatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetResource(ID string) (resourceDomain.Resource, error) {
+	const op = "storage.pgsql.oauth.resource.GetResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE id = $1`, selectColumns, migrations.TableOauthResource)
+
+	r, err := scanResource(s.db.QueryRow(s.ctx, querySQL, ID))
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return r, err
+	}
+
+	return r, nil
+}
+
+// GetResourcesByIdentifiers returns the registered resources among the
+// identifiers, unknown identifiers are left out.
+func (s *Storage) GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error) {
+	const op = "storage.pgsql.oauth.resource.GetResourcesByIdentifiers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE identifier = ANY($1) ORDER BY identifier`,
+		selectColumns,
+		migrations.TableOauthResource,
+	)
+
+	return s.query(querySQL, identifiers)
+}
+
+// GetResourcesByClient returns the resources served by the resource
+// server that authenticates as the client.
+func (s *Storage) GetResourcesByClient(clientID string) ([]resourceDomain.Resource, error) {
+	const op = "storage.pgsql.oauth.resource.GetResourcesByClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE client_id = $1 ORDER BY identifier`,
+		selectColumns,
+		migrations.TableOauthResource,
+	)
+
+	return s.query(querySQL, clientID)
+}
+
+func (s *Storage) GetResources(limit, offset int) ([]resourceDomain.Resource, error) {
+	const op = "storage.pgsql.oauth.resource.GetResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT %s
+		FROM %s
+		ORDER BY created_at DESC, id
+		LIMIT $1 OFFSET $2
+	`
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.query(querySQL, limit, offset)
+}
+
+func (s *Storage) CountResources() (int64, error) {
+	const op = "storage.pgsql.oauth.resource.CountResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, migrations.TableOauthResource)
+
+	var total int64
+	if err := s.db.QueryRow(s.ctx, querySQL).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (s *Storage) UpdateResource(r *resourceDomain.Resource) error {
+	const op = "storage.pgsql.oauth.resource.UpdateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET identifier = $2,
+			name = $3,
+			scopes = $4,
+			token_ttl = $5,
+			client_id = $6,
+			updated_at = $7
+		WHERE id = $1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(querySQL, r.ID, r.Identifier, r.Name, r.Scopes, r.TokenTTL, r.ClientId, r.UpdatedAt)
+}
+
+func (s *Storage) DeleteResource(ID string) error {
+	const op = "storage.pgsql.oauth.resource.DeleteResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableOauthResource)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(querySQL, ID)
+}
+
+func (s *Storage) query(querySQL string, args ...any) ([]resourceDomain.Resource, error) {
+	rows, err := s.db.Query(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := make([]resourceDomain.Resource, 0)
+	for rows.Next() {
+		r, err := scanResource(rows)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan row", logging.ErrAttr(err))
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// exec runs a statement that targets a single resource and reports
+// pgx.ErrNoRows when nothing was affected.
+func (s *Storage) exec(querySQL string, args ...any) error {
+	tag, err := s.db.Exec(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanResource(row pgx.Row) (resourceDomain.Resource, error) {
+	var r resourceDomain.Resource
+
+	err := row.Scan(
+		&r.ID,
+		&r.Identifier,
+		&r.Name,
+		&r.Scopes,
+		&r.TokenTTL,
+		&r.ClientId,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+
+	return r, err
+}
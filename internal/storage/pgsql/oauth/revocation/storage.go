@@ -0,0 +1,149 @@
+package revocation
+
+import (
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectColumns = `e.id, e.kind, COALESCE(e.token_id, ''), COALESCE(e.user_id, 0), COALESCE(u.uuid::text, ''),
+	COALESCE(e.key_id, ''), e.created_at, e.expires_at`
+
+// Storage reads the revocation events, the token and user revocations are
+// recorded by the access token storage along with the revocation itself.
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+// CreateKeyEvent records the key unless it is the last one recorded, it
+// reports whether the key was rotated.
+func (s *Storage) CreateKeyEvent(keyID string, createdAt int64) (bool, error) {
+	const op = "storage.pgsql.oauth.revocation.CreateKeyEvent"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (kind, key_id, created_at)
+		SELECT $1, $2, $3
+		WHERE COALESCE((SELECT key_id FROM %s WHERE kind = $1 ORDER BY id DESC LIMIT 1), '') <> $2
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRevocationEvent, migrations.TableOauthRevocationEvent)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	tag, err := s.db.Exec(s.ctx, querySQL, revocationDomain.KindKey, keyID, createdAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetLastEventID returns the cursor of the last event, 0 without events.
+func (s *Storage) GetLastEventID() (int64, error) {
+	const op = "storage.pgsql.oauth.revocation.GetLastEventID"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %s`, migrations.TableOauthRevocationEvent)
+
+	var ID int64
+	if err := s.db.QueryRow(s.ctx, querySQL).Scan(&ID); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return ID, nil
+}
+
+// GetEventsAfter returns up to limit events recorded after the cursor, in
+// the order they were recorded, with the UUID of the revoked user.
+func (s *Storage) GetEventsAfter(cursor int64, limit int) ([]revocationDomain.Event, error) {
+	const op = "storage.pgsql.oauth.revocation.GetEventsAfter"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT %s
+		FROM %s e
+		LEFT JOIN %s u ON u.id = e.user_id
+		WHERE e.id > $1
+		ORDER BY e.id
+		LIMIT $2
+	`
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthRevocationEvent, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	rows, err := s.db.Query(s.ctx, querySQL, cursor, limit)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]revocationDomain.Event, 0)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan row", logging.ErrAttr(err))
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// DeleteExpired removes the revocations of the tokens that have expired,
+// the key events are kept to tell a rotation apart from a restart.
+func (s *Storage) DeleteExpired(now int64) (int64, error) {
+	const op = "storage.pgsql.oauth.revocation.DeleteExpired"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(
+		`DELETE FROM %s WHERE kind <> $1 AND expires_at < $2`,
+		migrations.TableOauthRevocationEvent,
+	)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, revocationDomain.KindKey, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanEvent(row pgx.Row) (revocationDomain.Event, error) {
+	var e revocationDomain.Event
+
+	err := row.Scan(
+		&e.ID,
+		&e.Kind,
+		&e.TokenID,
+		&e.UserID,
+		&e.UserUUID,
+		&e.KeyID,
+		&e.CreatedAt,
+		&e.ExpiresAt,
+	)
+
+	return e, err
+}
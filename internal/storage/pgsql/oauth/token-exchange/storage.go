@@ -0,0 +1,58 @@
+package token_exchange
+
+import (
+	tokenExchangeDomain "app/internal/domain/oauth/token-exchange"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateTokenExchange(tE *tokenExchangeDomain.TokenExchange) error {
+	const op = "storage.pgsql.oauth.token-exchange.CreateTokenExchange"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, client_id, subject_user_id, actor_user_id, actor_client_id, access_token_id, audience,
+		                scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthTokenExchange)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(
+		s.ctx,
+		querySQL,
+		tE.ID,
+		tE.ClientId,
+		tE.SubjectUserId,
+		tE.ActorUserId,
+		tE.ActorClientId,
+		tE.AccessTokenId,
+		tE.Audience,
+		tE.Scopes,
+		tE.CreatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
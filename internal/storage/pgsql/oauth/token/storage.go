@@ -28,7 +28,7 @@ func (s *Storage) Create(
 	rT *refreshTokenDomain.RefreshToken,
 ) error {
 	const op = "storage.pgsql.oauth.token.Create"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	querySQL := `
 		WITH inserted_access AS (
 			INSERT INTO %s (id, user_id, client_id, NAME, scopes, revoked, created_at, updated_at, expires_at)
@@ -43,7 +43,7 @@ func (s *Storage) Create(
 
 	query := fmt.Sprintf(querySQL, migrations.TableOauthAccessToken, migrations.TableOauthRefreshToken)
 	query = loop.FormatQuery(query)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	var accessID string
 
@@ -65,7 +65,7 @@ func (s *Storage) Create(
 	).Scan(&accessID)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return err
 	}
 
@@ -74,7 +74,7 @@ func (s *Storage) Create(
 
 func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	const op = "storage.pgsql.oauth.access-token.ExistsToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 	var isExists bool
 	querySQL := `
 		SELECT (COUNT(*) > 0) as isExists
@@ -83,7 +83,7 @@ func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 
 	err := s.db.QueryRow(
 		s.ctx,
@@ -94,7 +94,7 @@ func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 	).Scan(&isExists)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
@@ -103,7 +103,7 @@ func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
 
 func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
 	const op = "storage.pgsql.oauth.access-token.UpdateToken"
-	logging.L(s.ctx).Info("op", op)
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
 
 	querySQL := `
 		UPDATE %s
@@ -112,7 +112,7 @@ func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
 	`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
 	querySQL = loop.FormatQuery(querySQL)
-	logging.L(s.ctx).Info("query", querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
 	_, err := s.db.Exec(
 		s.ctx,
 		querySQL,
@@ -122,7 +122,7 @@ func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return false, err
 	}
 
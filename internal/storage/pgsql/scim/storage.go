@@ -0,0 +1,110 @@
+package scim
+
+import (
+	scimDomain "app/internal/domain/scim"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *pgxpool.Pool
+}
+
+func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  pgClient,
+	}, nil
+}
+
+func (s *Storage) CreateToken(t *scimDomain.Token) error {
+	const op = "storage.pgsql.scim.CreateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, name, token_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableScimToken)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.Exec(s.ctx, querySQL, t.ID, t.Name, t.TokenHash, t.CreatedAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetTokenByHash(hash string) (scimDomain.Token, error) {
+	const op = "storage.pgsql.scim.GetTokenByHash"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, name, token_hash, created_at FROM %s WHERE token_hash = $1`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableScimToken)
+
+	var t scimDomain.Token
+	err := s.db.QueryRow(s.ctx, querySQL, hash).Scan(&t.ID, &t.Name, &t.TokenHash, &t.CreatedAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return scimDomain.Token{}, err
+	}
+
+	return t, nil
+}
+
+func (s *Storage) GetTokens() ([]scimDomain.Token, error) {
+	const op = "storage.pgsql.scim.GetTokens"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, name, token_hash, created_at FROM %s ORDER BY created_at`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableScimToken)
+
+	rows, err := s.db.Query(s.ctx, querySQL)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]scimDomain.Token, 0)
+	for rows.Next() {
+		var t scimDomain.Token
+		if err := rows.Scan(&t.ID, &t.Name, &t.TokenHash, &t.CreatedAt); err != nil {
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// DeleteToken deletes the token, it reports pgx.ErrNoRows when there is
+// no such token.
+func (s *Storage) DeleteToken(ID string) error {
+	const op = "storage.pgsql.scim.DeleteToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, migrations.TableScimToken)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
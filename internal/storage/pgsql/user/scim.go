@@ -0,0 +1,243 @@
+package user
+
+import (
+	"app/internal/domain/user"
+	"app/migrations"
+	"app/pkg/common/core/scim"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+const scimColumns = `id, uuid, name, email, external_id, display_name, deactivated_at, version, created_at, updated_at`
+
+// ScimFilterColumns are the columns the SCIM filters of users are
+// translated for.
+var ScimFilterColumns = map[string]scim.Column{
+	"id":                {SQL: "uuid::text", Type: scim.CaseExactString},
+	"username":          {SQL: "name", Type: scim.String},
+	"externalid":        {SQL: "external_id", Type: scim.CaseExactString},
+	"displayname":       {SQL: "display_name", Type: scim.String},
+	"emails":            {SQL: "email", Type: scim.String},
+	"emails.value":      {SQL: "email", Type: scim.String},
+	"active":            {SQL: "(deactivated_at IS NULL)", Type: scim.Boolean},
+	"meta.created":      {SQL: "created_at", Type: scim.DateTime},
+	"meta.lastmodified": {SQL: "updated_at", Type: scim.DateTime},
+}
+
+func scanScimUser(row pgx.Row, u *user.User) error {
+	return row.Scan(
+		&u.ID,
+		&u.UUID,
+		&u.Name,
+		&u.Email,
+		&u.ExternalId,
+		&u.DisplayName,
+		&u.DeactivatedAt,
+		&u.Version,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+}
+
+// GetUserByUUID returns a user that is not deleted, deactivated users
+// included.
+func (s *Storage) GetUserByUUID(UUID string) (user.User, error) {
+	const op = "storage.pgsql.user.GetUserByUUID"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT ` + scimColumns + ` FROM %s WHERE uuid = $1 AND deleted_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+
+	var u user.User
+	if err := scanScimUser(s.db.QueryRow(s.ctx, querySQL, UUID), &u); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return u, err
+	}
+
+	return u, nil
+}
+
+// FindUsers returns the page of the users matching the filter, a nil
+// filter matches every user, and their total.
+func (s *Storage) FindUsers(filter scim.Expr, page scim.Page) ([]user.User, int64, error) {
+	const op = "storage.pgsql.user.FindUsers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	where := "deleted_at IS NULL"
+	var args []any
+	if filter != nil {
+		cond, condArgs, err := scim.SQL(filter, ScimFilterColumns, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		where += " AND " + cond
+		args = condArgs
+	}
+
+	var total int64
+	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, migrations.TableUsers, where)
+	if err := s.db.QueryRow(s.ctx, countSQL, args...).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, 0, err
+	}
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s ORDER BY id LIMIT %d OFFSET %d`,
+		scimColumns, migrations.TableUsers, where, page.Count, page.Offset(),
+	)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	rows, err := s.db.Query(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]user.User, 0, page.Count)
+	for rows.Next() {
+		var u user.User
+		if err := scanScimUser(rows, &u); err != nil {
+			logging.L(s.ctx).Error("error scan", logging.ErrAttr(err))
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+// ExistsUserName reports whether another user than exceptID, which is 0
+// for a new user, has the name. Names are compared case-insensitively.
+func (s *Storage) ExistsUserName(name string, exceptID int64) (bool, error) {
+	const op = "storage.pgsql.user.ExistsUserName"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT EXISTS (SELECT 1 FROM %s WHERE lower(name) = lower($1) AND id <> $2 AND deleted_at IS NULL)`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+
+	var exists bool
+	if err := s.db.QueryRow(s.ctx, querySQL, name, exceptID).Scan(&exists); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// CreateUser stores a provisioned user and sets its ID.
+func (s *Storage) CreateUser(u *user.User) error {
+	const op = "storage.pgsql.user.CreateUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (uuid, name, email, email_verified_at, password, external_id, display_name,
+		                deactivated_at, version, created_at, updated_at)
+		VALUES ($1, $2, $3, COALESCE($4, 0), $5, $6, $7, $8, 1, $9, $10)
+		RETURNING id, version
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	err := s.db.QueryRow(
+		s.ctx,
+		querySQL,
+		u.UUID,
+		u.Name,
+		u.Email,
+		u.EmailVerifiedAt,
+		u.Password,
+		u.ExternalId,
+		u.DisplayName,
+		u.DeactivatedAt,
+		u.CreatedAt,
+		u.UpdatedAt,
+	).Scan(&u.ID, &u.Version)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// UpdateUser stores the provisioned attributes of the user when it is
+// still at u.Version and sets the new version, it reports pgx.ErrNoRows
+// otherwise. An empty password keeps the current one.
+func (s *Storage) UpdateUser(u *user.User) error {
+	const op = "storage.pgsql.user.UpdateUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET name           = $3,
+		    email          = $4,
+		    password       = CASE WHEN $5 = '' THEN password ELSE $5 END,
+		    external_id    = $6,
+		    display_name   = $7,
+		    deactivated_at = $8,
+		    updated_at     = $9,
+		    version        = version + 1
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		RETURNING version
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	err := s.db.QueryRow(
+		s.ctx,
+		querySQL,
+		u.ID,
+		u.Version,
+		u.Name,
+		u.Email,
+		u.Password,
+		u.ExternalId,
+		u.DisplayName,
+		u.DeactivatedAt,
+		u.UpdatedAt,
+	).Scan(&u.Version)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUser deprovisions a user: it is deactivated and no longer
+// returned by SCIM, the row is kept. It reports pgx.ErrNoRows when the
+// user was already deleted.
+func (s *Storage) DeleteUser(ID int64, now int64) error {
+	const op = "storage.pgsql.user.DeleteUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET deleted_at     = $2,
+		    deactivated_at = COALESCE(deactivated_at, $2),
+		    updated_at     = $2,
+		    version        = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+
+	tag, err := s.db.Exec(s.ctx, querySQL, ID, now)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
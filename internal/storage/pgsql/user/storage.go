@@ -26,7 +26,10 @@ func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 func (s *Storage) Registration(req *user.CreateUser) (error error) {
 	const op = "storage.pgsql.user.Registration"
 
-	querySQL := `INSERT INTO users (uuid, name, email, password, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	querySQL := `
+		INSERT INTO users (uuid, name, email, email_verified_at, password, created_at, updated_at)
+		VALUES ($1, $2, $3, COALESCE($4, 0), $5, $6, $7)
+	`
 	querySQL = loop.FormatQuery(querySQL)
 
 	logging.L(s.ctx).With(
@@ -40,13 +43,14 @@ func (s *Storage) Registration(req *user.CreateUser) (error error) {
 		req.UUID,
 		req.Name,
 		req.Email,
+		req.EmailVerifiedAt,
 		req.Password,
 		req.CreatedAt,
 		req.UpdatedAt,
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return err
 	}
 
@@ -56,7 +60,7 @@ func (s *Storage) Registration(req *user.CreateUser) (error error) {
 func (s *Storage) Login(req *user.User) (user.User, error) {
 	const op = "storage.pgsql.user.login"
 
-	querySQL := `SELECT id, uuid, name, email, password FROM %s WHERE name = $1 OR email = $2`
+	querySQL := `SELECT id, uuid, name, email, password FROM %s WHERE (name = $1 OR email = $2) AND deactivated_at IS NULL`
 	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
 	querySQL = loop.FormatQuery(querySQL)
 
@@ -81,7 +85,59 @@ func (s *Storage) Login(req *user.User) (user.User, error) {
 	)
 
 	if err != nil {
-		logging.L(s.ctx).Error("error query", err)
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return usrStorage, err
+	}
+
+	return usrStorage, nil
+}
+
+func (s *Storage) GetUser(ID int64) (user.User, error) {
+	const op = "storage.pgsql.user.GetUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, uuid, name, email, is_active FROM %s WHERE id = $1 AND deactivated_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var usrStorage user.User
+
+	err := s.db.QueryRow(s.ctx, querySQL, ID).Scan(
+		&usrStorage.ID,
+		&usrStorage.UUID,
+		&usrStorage.Name,
+		&usrStorage.Email,
+		&usrStorage.IsActive,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return usrStorage, err
+	}
+
+	return usrStorage, nil
+}
+
+func (s *Storage) GetUserByEmail(email string) (user.User, error) {
+	const op = "storage.pgsql.user.GetUserByEmail"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, uuid, name, email, is_active FROM %s WHERE email = $1 AND deactivated_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var usrStorage user.User
+
+	err := s.db.QueryRow(s.ctx, querySQL, email).Scan(
+		&usrStorage.ID,
+		&usrStorage.UUID,
+		&usrStorage.Name,
+		&usrStorage.Email,
+		&usrStorage.IsActive,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
 		return usrStorage, err
 	}
 
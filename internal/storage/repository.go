@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	resourceDomain "app/internal/domain/oauth/resource"
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/internal/domain/user"
+	"app/pkg/common/core/scim"
+)
+
+// UserRepository stores the users, the registered ones and the ones
+// provisioned by SCIM.
+type UserRepository interface {
+	Registration(req *user.CreateUser) error
+	Login(req *user.User) (user.User, error)
+	GetUser(ID int64) (user.User, error)
+	GetUserByEmail(email string) (user.User, error)
+	GetUserByUUID(UUID string) (user.User, error)
+	FindUsers(filter scim.Expr, page scim.Page) ([]user.User, int64, error)
+	ExistsUserName(name string, exceptID int64) (bool, error)
+	CreateUser(u *user.User) error
+	UpdateUser(u *user.User) error
+	DeleteUser(ID int64, now int64) error
+}
+
+type ClientRepository interface {
+	GetClient(ID string) (client.Client, error)
+	CreateClient(oauthClient *client.Client) error
+	GetClientByName(name string) (client.Client, error)
+	GetClients(limit, offset int) ([]client.Client, error)
+	CountClients() (int64, error)
+	UpdateClient(oauthClient *client.Client) error
+	UpdateRevoked(ID string, revoked bool, updatedAt int64) error
+	UpdateSecret(oauthClient *client.Client) error
+	DeleteClient(ID string) error
+}
+
+// AccessTokenRepository stores the access tokens, their revocations are
+// recorded for the RevocationRepository.
+type AccessTokenRepository interface {
+	CreateToken(aT *accessTokenDomain.AccessToken) (string, error)
+	ExistsToken(aT *accessTokenDomain.AccessToken) (bool, error)
+	UpdateToken(aT *accessTokenDomain.AccessToken) (bool, error)
+	GetToken(ID string) (accessTokenDomain.AccessToken, error)
+	RevokeUserTokens(userID int64) error
+	RevokeToken(ID string) error
+}
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (string, error)
+	ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
+	GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error)
+	UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error)
+	GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error)
+}
+
+// AuthTokenRepository stores the tokens issued together, Create stores
+// the access token and its refresh token atomically.
+type AuthTokenRepository interface {
+	Create(aT *accessTokenDomain.AccessToken, rT *refreshTokenDomain.RefreshToken) error
+	ExistsToken(aT *accessTokenDomain.AccessToken) (bool, error)
+	UpdateToken(aT *accessTokenDomain.AccessToken) (bool, error)
+}
+
+// ResourceRepository stores the resources the access tokens are minted
+// for.
+type ResourceRepository interface {
+	CreateResource(r *resourceDomain.Resource) error
+	GetResource(ID string) (resourceDomain.Resource, error)
+	GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error)
+	GetResourcesByClient(clientID string) ([]resourceDomain.Resource, error)
+	GetResources(limit, offset int) ([]resourceDomain.Resource, error)
+	CountResources() (int64, error)
+	UpdateResource(r *resourceDomain.Resource) error
+	DeleteResource(ID string) error
+}
+
+// RevocationRepository reads the revocation events of the tokens and
+// records the rotations of their key.
+type RevocationRepository interface {
+	CreateKeyEvent(keyID string, createdAt int64) (bool, error)
+	GetLastEventID() (int64, error)
+	GetEventsAfter(cursor int64, limit int) ([]revocationDomain.Event, error)
+	DeleteExpired(now int64) (int64, error)
+}
@@ -0,0 +1,311 @@
+package client
+
+import (
+	"app/internal/domain/client"
+	"app/migrations"
+	"app/pkg/client/sqlite"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const selectColumns = `
+	id, user_id, name, secret, previous_secret, previous_secret_expires_at, provider, redirect_uris,
+	personal_access_client, password_client, revoked, token_endpoint_auth_method, jwks, grant_types, contacts,
+	registration_access_token, token_exchange, require_pushed_authorization_requests, tls_client_auth,
+	scopes, created_at, updated_at
+`
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) GetClient(ID string) (client.Client, error) {
+	const op = "storage.sqlite.oauth.client.GetClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE id = ?`, selectColumns, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+
+	c, err := scanClient(s.db.QueryRowContext(s.ctx, querySQL, ID))
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return c, err
+	}
+
+	return c, nil
+}
+
+func (s *Storage) CreateClient(oauthClient *client.Client) error {
+	const op = "storage.sqlite.oauth.client.CreateClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, user_id, name, secret, provider, redirect_uris, personal_access_client, password_client, revoked,
+		                token_endpoint_auth_method, jwks, grant_types, contacts, registration_access_token,
+		                token_exchange, require_pushed_authorization_requests, tls_client_auth, scopes, created_at,
+		                updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.ExecContext(
+		s.ctx,
+		querySQL,
+		oauthClient.ID,
+		oauthClient.UserId,
+		oauthClient.Name,
+		oauthClient.Secret,
+		oauthClient.Provider,
+		sqlite.JSON{V: oauthClient.RedirectURIs},
+		oauthClient.PersonalAccessClient,
+		oauthClient.PasswordClient,
+		oauthClient.Revoked,
+		oauthClient.TokenEndpointAuthMethod,
+		oauthClient.JWKS,
+		sqlite.JSON{V: oauthClient.GrantTypes},
+		sqlite.JSON{V: oauthClient.Contacts},
+		oauthClient.RegistrationAccessToken,
+		sqlite.JSON{V: oauthClient.TokenExchange},
+		oauthClient.RequirePushedAuthorizationRequests,
+		sqlite.JSON{V: oauthClient.TLSClientAuth},
+		sqlite.JSON{V: oauthClient.Scopes},
+		oauthClient.CreatedAt,
+		oauthClient.UpdatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetClientByName(name string) (client.Client, error) {
+	const op = "storage.sqlite.oauth.client.GetClientByName"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE name = ?`, selectColumns, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+
+	c, err := scanClient(s.db.QueryRowContext(s.ctx, querySQL, name))
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return c, err
+	}
+
+	return c, nil
+}
+
+func (s *Storage) GetClients(limit, offset int) ([]client.Client, error) {
+	const op = "storage.sqlite.oauth.client.GetClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT %s
+		FROM %s
+		ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?
+	`
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	rows, err := s.db.QueryContext(s.ctx, querySQL, limit, offset)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := make([]client.Client, 0, limit)
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan row", logging.ErrAttr(err))
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+func (s *Storage) CountClients() (int64, error) {
+	const op = "storage.sqlite.oauth.client.CountClients"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, migrations.TableOauthClient)
+
+	var total int64
+	if err := s.db.QueryRowContext(s.ctx, querySQL).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (s *Storage) UpdateClient(oauthClient *client.Client) error {
+	const op = "storage.sqlite.oauth.client.UpdateClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET user_id = ?2,
+			name = ?3,
+			redirect_uris = ?4,
+			personal_access_client = ?5,
+			password_client = ?6,
+			token_endpoint_auth_method = ?7,
+			jwks = ?8,
+			grant_types = ?9,
+			contacts = ?10,
+			token_exchange = ?11,
+			require_pushed_authorization_requests = ?12,
+			tls_client_auth = ?13,
+			scopes = ?14,
+			updated_at = ?15
+		WHERE id = ?1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(
+		querySQL,
+		oauthClient.ID,
+		oauthClient.UserId,
+		oauthClient.Name,
+		sqlite.JSON{V: oauthClient.RedirectURIs},
+		oauthClient.PersonalAccessClient,
+		oauthClient.PasswordClient,
+		oauthClient.TokenEndpointAuthMethod,
+		oauthClient.JWKS,
+		sqlite.JSON{V: oauthClient.GrantTypes},
+		sqlite.JSON{V: oauthClient.Contacts},
+		sqlite.JSON{V: oauthClient.TokenExchange},
+		oauthClient.RequirePushedAuthorizationRequests,
+		sqlite.JSON{V: oauthClient.TLSClientAuth},
+		sqlite.JSON{V: oauthClient.Scopes},
+		oauthClient.UpdatedAt,
+	)
+}
+
+func (s *Storage) UpdateRevoked(ID string, revoked bool, updatedAt int64) error {
+	const op = "storage.sqlite.oauth.client.UpdateRevoked"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`UPDATE %s SET revoked = ?2, updated_at = ?3 WHERE id = ?1`, migrations.TableOauthClient)
+
+	return s.exec(querySQL, ID, revoked, updatedAt)
+}
+
+func (s *Storage) UpdateSecret(oauthClient *client.Client) error {
+	const op = "storage.sqlite.oauth.client.UpdateSecret"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET secret = ?2,
+			previous_secret = ?3,
+			previous_secret_expires_at = ?4,
+			updated_at = ?5
+		WHERE id = ?1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthClient)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(
+		querySQL,
+		oauthClient.ID,
+		oauthClient.Secret,
+		oauthClient.PreviousSecret,
+		oauthClient.PreviousSecretExpiresAt,
+		oauthClient.UpdatedAt,
+	)
+}
+
+func (s *Storage) DeleteClient(ID string) error {
+	const op = "storage.sqlite.oauth.client.DeleteClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, migrations.TableOauthClient)
+
+	return s.exec(querySQL, ID)
+}
+
+// exec runs a statement that targets a single client and reports
+// sql.ErrNoRows when nothing was affected.
+func (s *Storage) exec(querySQL string, args ...any) error {
+	res, err := s.db.ExecContext(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+func scanClient(row row) (client.Client, error) {
+	var c client.Client
+
+	err := row.Scan(
+		&c.ID,
+		&c.UserId,
+		&c.Name,
+		&c.Secret,
+		&c.PreviousSecret,
+		&c.PreviousSecretExpiresAt,
+		&c.Provider,
+		sqlite.JSON{V: &c.RedirectURIs},
+		&c.PersonalAccessClient,
+		&c.PasswordClient,
+		&c.Revoked,
+		&c.TokenEndpointAuthMethod,
+		&c.JWKS,
+		sqlite.JSON{V: &c.GrantTypes},
+		sqlite.JSON{V: &c.Contacts},
+		&c.RegistrationAccessToken,
+		sqlite.JSON{V: &c.TokenExchange},
+		&c.RequirePushedAuthorizationRequests,
+		sqlite.JSON{V: &c.TLSClientAuth},
+		sqlite.JSON{V: &c.Scopes},
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+
+	return c, err
+}
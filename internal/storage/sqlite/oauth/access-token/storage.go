@@ -0,0 +1,307 @@
+package access_token
+
+import (
+	accessToken "app/internal/domain/oauth/access-token"
+	"app/internal/domain/oauth/revocation"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateToken(aT *accessToken.AccessToken) (string, error) {
+	const op = "storage.sqlite.oauth.access-token.CreateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, user_id, client_id, name, scopes, revoked, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.ExecContext(
+		s.ctx,
+		querySQL,
+		aT.ID,
+		aT.UserId,
+		aT.ClientId,
+		aT.Name,
+		aT.Scopes,
+		aT.Revoked,
+		aT.CreatedAt,
+		aT.UpdatedAt,
+		aT.ExpiresAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return "", err
+	}
+
+	return aT.ID, nil
+}
+
+func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.sqlite.oauth.access-token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT COUNT(*) > 0 FROM %s WHERE id = ? AND user_id = ? AND client_id = ?`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+
+	var isExists bool
+	err := s.db.QueryRowContext(s.ctx, querySQL, aT.ID, aT.UserId, aT.ClientId).Scan(&isExists)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return isExists, nil
+}
+
+// UpdateToken revokes the access token and records the revocation.
+func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.sqlite.oauth.access-token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	err := s.revoke(func(tx *sql.Tx) error {
+		querySQL := `
+			UPDATE %s
+			SET revoked = TRUE
+			WHERE id = ? AND user_id = ? AND client_id = ?
+			RETURNING id, user_id, expires_at
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+		querySQL = loop.FormatQuery(querySQL)
+
+		return s.recordTokens(tx, querySQL, aT.ID, aT.UserId, aT.ClientId)
+	})
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) GetToken(ID string) (accessToken.AccessToken, error) {
+	const op = "storage.sqlite.oauth.access-token.GetToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, COALESCE(user_id, 0), client_id, COALESCE(name, ''), scopes, revoked, created_at, updated_at, expires_at
+		FROM %s
+		WHERE id = ?
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+	querySQL = loop.FormatQuery(querySQL)
+
+	var aT accessToken.AccessToken
+
+	err := s.db.QueryRowContext(s.ctx, querySQL, ID).Scan(
+		&aT.ID,
+		&aT.UserId,
+		&aT.ClientId,
+		&aT.Name,
+		&aT.Scopes,
+		&aT.Revoked,
+		&aT.CreatedAt,
+		&aT.UpdatedAt,
+		&aT.ExpiresAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return accessToken.AccessToken{}, err
+	}
+
+	return aT, nil
+}
+
+// RevokeUserTokens revokes the access tokens of the user and their
+// refresh tokens, a revocation of the user is recorded when it had any.
+func (s *Storage) RevokeUserTokens(userID int64) error {
+	const op = "storage.sqlite.oauth.access-token.RevokeUserTokens"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	err := s.revoke(func(tx *sql.Tx) error {
+		querySQL := `
+			UPDATE %s
+			SET revoked = TRUE
+			WHERE user_id = ? AND revoked = FALSE
+			RETURNING id, expires_at
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+		querySQL = loop.FormatQuery(querySQL)
+
+		rows, err := tx.QueryContext(s.ctx, querySQL, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var (
+			IDs       []string
+			expiresAt int64
+		)
+		for rows.Next() {
+			var (
+				ID      string
+				expires int64
+			)
+			if err := rows.Scan(&ID, &expires); err != nil {
+				return err
+			}
+			IDs = append(IDs, ID)
+			expiresAt = max(expiresAt, expires)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(IDs) == 0 {
+			return nil
+		}
+
+		for _, ID := range IDs {
+			if err := s.revokeRefreshTokens(tx, ID); err != nil {
+				return err
+			}
+		}
+
+		return s.insertEvent(tx, revocation.Event{
+			Kind:      revocation.KindUser,
+			UserID:    userID,
+			CreatedAt: time.Now().Unix(),
+			ExpiresAt: expiresAt,
+		})
+	})
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeToken revokes the access token and the refresh tokens issued
+// with it, and records the revocation.
+func (s *Storage) RevokeToken(ID string) error {
+	const op = "storage.sqlite.oauth.access-token.RevokeToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	err := s.revoke(func(tx *sql.Tx) error {
+		querySQL := `
+			UPDATE %s
+			SET revoked = TRUE
+			WHERE id = ?
+			RETURNING id, user_id, expires_at
+		`
+		querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+		querySQL = loop.FormatQuery(querySQL)
+
+		if err := s.recordTokens(tx, querySQL, ID); err != nil {
+			return err
+		}
+
+		return s.revokeRefreshTokens(tx, ID)
+	})
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+// revoke runs fn in a transaction, the revocations and their events are
+// recorded together like the single statements of PostgreSQL do.
+func (s *Storage) revoke(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordTokens runs the revocation querySQL, which returns the id, the
+// user_id and the expires_at of the revoked tokens, and records a token
+// revocation for each of them.
+func (s *Storage) recordTokens(tx *sql.Tx, querySQL string, args ...any) error {
+	rows, err := tx.QueryContext(s.ctx, querySQL, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var events []revocation.Event
+	for rows.Next() {
+		var (
+			e      = revocation.Event{Kind: revocation.KindToken, CreatedAt: time.Now().Unix()}
+			userID sql.NullInt64
+		)
+		if err := rows.Scan(&e.TokenID, &userID, &e.ExpiresAt); err != nil {
+			return err
+		}
+		e.UserID = userID.Int64
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if err := s.insertEvent(tx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) revokeRefreshTokens(tx *sql.Tx, accessTokenID string) error {
+	querySQL := fmt.Sprintf(
+		`UPDATE %s SET revoked = TRUE WHERE revoked = FALSE AND access_token_id = ?`,
+		migrations.TableOauthRefreshToken,
+	)
+
+	_, err := tx.ExecContext(s.ctx, querySQL, accessTokenID)
+	return err
+}
+
+func (s *Storage) insertEvent(tx *sql.Tx, e revocation.Event) error {
+	querySQL := `
+		INSERT INTO %s (kind, token_id, user_id, created_at, expires_at)
+		VALUES (?, NULLIF(?, ''), NULLIF(?, 0), ?, ?)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRevocationEvent)
+	querySQL = loop.FormatQuery(querySQL)
+
+	_, err := tx.ExecContext(s.ctx, querySQL, e.Kind, e.TokenID, e.UserID, e.CreatedAt, e.ExpiresAt)
+	return err
+}
@@ -0,0 +1,125 @@
+package refresh_token
+
+import (
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateRefreshToken(rT *refreshTokenDomain.RefreshToken) (string, error) {
+	const op = "storage.sqlite.oauth.refresh-token.CreateRefreshToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `INSERT INTO %s (id, access_token_id, revoked, expires_at) VALUES (?, ?, ?, ?)`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
+
+	_, err := s.db.ExecContext(s.ctx, querySQL, rT.ID, rT.AccessTokenId, rT.Revoked, rT.ExpiresAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return "", err
+	}
+
+	return rT.ID, nil
+}
+
+func (s *Storage) ExistsToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
+	const op = "storage.sqlite.oauth.refresh-token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT COUNT(*) > 0 FROM %s WHERE id = ? AND access_token_id = ?`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
+
+	var isExists bool
+	if err := s.db.QueryRowContext(s.ctx, querySQL, rT.ID, rT.AccessTokenId).Scan(&isExists); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return isExists, nil
+}
+
+func (s *Storage) GetToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	const op = "storage.sqlite.oauth.refresh-token.GetToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT id, access_token_id, revoked, expires_at
+		FROM %s
+		WHERE id = ? AND access_token_id = ?
+		ORDER BY expires_at DESC
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
+	querySQL = loop.FormatQuery(querySQL)
+
+	return s.getToken(querySQL, rT.ID, rT.AccessTokenId)
+}
+
+func (s *Storage) UpdateToken(rT *refreshTokenDomain.RefreshToken) (bool, error) {
+	const op = "storage.sqlite.oauth.refresh-token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `UPDATE %s SET revoked = TRUE WHERE id = ? AND access_token_id = ?`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthRefreshToken)
+
+	if _, err := s.db.ExecContext(s.ctx, querySQL, rT.ID, rT.AccessTokenId); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) GetLastReceivedToken(rT *refreshTokenDomain.RefreshToken) (refreshTokenDomain.RefreshToken, error) {
+	const op = "storage.sqlite.oauth.refresh-token.GetLastReceivedToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT r.id, r.access_token_id, r.revoked, r.expires_at
+		FROM %[1]s a
+		INNER JOIN %[2]s r ON a.id = r.access_token_id
+		WHERE a.user_id = (SELECT a.user_id
+		                   FROM %[2]s r
+		                   INNER JOIN %[1]s a ON a.id = r.access_token_id
+		                   WHERE r.id = ? AND r.access_token_id = ?)
+		ORDER BY r.expires_at DESC
+		LIMIT 1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken, migrations.TableOauthRefreshToken)
+	querySQL = loop.FormatQuery(querySQL)
+
+	return s.getToken(querySQL, rT.ID, rT.AccessTokenId)
+}
+
+func (s *Storage) getToken(querySQL string, args ...any) (refreshTokenDomain.RefreshToken, error) {
+	var rT refreshTokenDomain.RefreshToken
+
+	err := s.db.QueryRowContext(s.ctx, querySQL, args...).Scan(
+		&rT.ID,
+		&rT.AccessTokenId,
+		&rT.Revoked,
+		&rT.ExpiresAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return refreshTokenDomain.RefreshToken{}, err
+	}
+
+	return rT, nil
+}
@@ -0,0 +1,234 @@
+package resource
+
+import (
+	resourceDomain "app/internal/domain/oauth/resource"
+	"app/migrations"
+	"app/pkg/client/sqlite"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const selectColumns = `id, identifier, name, scopes, token_ttl, client_id, created_at, updated_at`
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) CreateResource(r *resourceDomain.Resource) error {
+	const op = "storage.sqlite.oauth.resource.CreateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (id, identifier, name, scopes, token_ttl, client_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.ExecContext(
+		s.ctx,
+		querySQL,
+		r.ID,
+		r.Identifier,
+		r.Name,
+		sqlite.JSON{V: r.Scopes},
+		r.TokenTTL,
+		r.ClientId,
+		r.CreatedAt,
+		r.UpdatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) GetResource(ID string) (resourceDomain.Resource, error) {
+	const op = "storage.sqlite.oauth.resource.GetResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT %s FROM %s WHERE id = ?`, selectColumns, migrations.TableOauthResource)
+
+	r, err := scanResource(s.db.QueryRowContext(s.ctx, querySQL, ID))
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return r, err
+	}
+
+	return r, nil
+}
+
+// GetResourcesByIdentifiers returns the registered resources among the
+// identifiers, unknown identifiers are left out.
+func (s *Storage) GetResourcesByIdentifiers(identifiers []string) ([]resourceDomain.Resource, error) {
+	const op = "storage.sqlite.oauth.resource.GetResourcesByIdentifiers"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE identifier IN (SELECT value FROM json_each(?)) ORDER BY identifier`,
+		selectColumns,
+		migrations.TableOauthResource,
+	)
+
+	return s.query(querySQL, sqlite.JSON{V: identifiers})
+}
+
+// GetResourcesByClient returns the resources served by the resource
+// server that authenticates as the client.
+func (s *Storage) GetResourcesByClient(clientID string) ([]resourceDomain.Resource, error) {
+	const op = "storage.sqlite.oauth.resource.GetResourcesByClient"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(
+		`SELECT %s FROM %s WHERE client_id = ? ORDER BY identifier`,
+		selectColumns,
+		migrations.TableOauthResource,
+	)
+
+	return s.query(querySQL, clientID)
+}
+
+func (s *Storage) GetResources(limit, offset int) ([]resourceDomain.Resource, error) {
+	const op = "storage.sqlite.oauth.resource.GetResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		SELECT %s
+		FROM %s
+		ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?
+	`
+	querySQL = fmt.Sprintf(querySQL, selectColumns, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.query(querySQL, limit, offset)
+}
+
+func (s *Storage) CountResources() (int64, error) {
+	const op = "storage.sqlite.oauth.resource.CountResources"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, migrations.TableOauthResource)
+
+	var total int64
+	if err := s.db.QueryRowContext(s.ctx, querySQL).Scan(&total); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (s *Storage) UpdateResource(r *resourceDomain.Resource) error {
+	const op = "storage.sqlite.oauth.resource.UpdateResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		UPDATE %s
+		SET identifier = ?2,
+			name = ?3,
+			scopes = ?4,
+			token_ttl = ?5,
+			client_id = ?6,
+			updated_at = ?7
+		WHERE id = ?1
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthResource)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("prepare query", logging.StringAttr("query", querySQL))
+
+	return s.exec(querySQL, r.ID, r.Identifier, r.Name, sqlite.JSON{V: r.Scopes}, r.TokenTTL, r.ClientId, r.UpdatedAt)
+}
+
+func (s *Storage) DeleteResource(ID string) error {
+	const op = "storage.sqlite.oauth.resource.DeleteResource"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, migrations.TableOauthResource)
+
+	return s.exec(querySQL, ID)
+}
+
+func (s *Storage) query(querySQL string, args ...any) ([]resourceDomain.Resource, error) {
+	rows, err := s.db.QueryContext(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := make([]resourceDomain.Resource, 0)
+	for rows.Next() {
+		r, err := scanResource(rows)
+		if err != nil {
+			logging.L(s.ctx).Error("error scan row", logging.ErrAttr(err))
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// exec runs a statement that targets a single resource and reports
+// sql.ErrNoRows when nothing was affected.
+func (s *Storage) exec(querySQL string, args ...any) error {
+	res, err := s.db.ExecContext(s.ctx, querySQL, args...)
+	if err != nil {
+		logging.L(s.ctx).Error("error query db", logging.ErrAttr(err))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+type row interface {
+	Scan(dest ...any) error
+}
+
+func scanResource(row row) (resourceDomain.Resource, error) {
+	var r resourceDomain.Resource
+
+	err := row.Scan(
+		&r.ID,
+		&r.Identifier,
+		&r.Name,
+		sqlite.JSON{V: &r.Scopes},
+		&r.TokenTTL,
+		&r.ClientId,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+
+	return r, err
+}
@@ -0,0 +1,111 @@
+package token
+
+import (
+	accessToken "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+// Create stores the access token and its refresh token in a transaction,
+// neither is stored when the other can't be.
+func (s *Storage) Create(
+	aT *accessToken.AccessToken,
+	rT *refreshTokenDomain.RefreshToken,
+) error {
+	const op = "storage.sqlite.oauth.token.Create"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	accessSQL := `
+		INSERT INTO %s (id, user_id, client_id, name, scopes, revoked, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	accessSQL = fmt.Sprintf(accessSQL, migrations.TableOauthAccessToken)
+	accessSQL = loop.FormatQuery(accessSQL)
+
+	refreshSQL := `INSERT INTO %s (id, access_token_id, revoked, expires_at) VALUES (?, ?, ?, ?)`
+	refreshSQL = fmt.Sprintf(refreshSQL, migrations.TableOauthRefreshToken)
+
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		logging.L(s.ctx).Error("error begin", logging.ErrAttr(err))
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(
+		s.ctx,
+		accessSQL,
+		aT.ID,
+		aT.UserId,
+		aT.ClientId,
+		aT.Name,
+		aT.Scopes,
+		aT.Revoked,
+		aT.CreatedAt,
+		aT.UpdatedAt,
+		aT.ExpiresAt,
+	)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	_, err = tx.ExecContext(s.ctx, refreshSQL, rT.ID, aT.ID, rT.Revoked, rT.ExpiresAt)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) ExistsToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.sqlite.oauth.token.ExistsToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT COUNT(*) > 0 FROM %s WHERE id = ? AND user_id = ? AND client_id = ?`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+
+	var isExists bool
+	err := s.db.QueryRowContext(s.ctx, querySQL, aT.ID, aT.UserId, aT.ClientId).Scan(&isExists)
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return isExists, nil
+}
+
+func (s *Storage) UpdateToken(aT *accessToken.AccessToken) (bool, error) {
+	const op = "storage.sqlite.oauth.token.UpdateToken"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `UPDATE %s SET revoked = TRUE WHERE id = ? AND user_id = ? AND client_id = ?`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableOauthAccessToken)
+
+	if _, err := s.db.ExecContext(s.ctx, querySQL, aT.ID, aT.UserId, aT.ClientId); err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"app/internal/domain/user"
+	"app/migrations"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Storage struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func New(ctx context.Context, db *sql.DB) (*Storage, error) {
+	return &Storage{
+		ctx: ctx,
+		db:  db,
+	}, nil
+}
+
+func (s *Storage) Registration(req *user.CreateUser) error {
+	const op = "storage.sqlite.user.Registration"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `
+		INSERT INTO %s (uuid, name, email, email_verified_at, password, created_at, updated_at)
+		VALUES (?, ?, ?, COALESCE(?, 0), ?, ?, ?)
+	`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+	querySQL = loop.FormatQuery(querySQL)
+	logging.L(s.ctx).Info("query", logging.StringAttr("query", querySQL))
+
+	_, err := s.db.ExecContext(
+		s.ctx,
+		querySQL,
+		req.UUID,
+		req.Name,
+		req.Email,
+		req.EmailVerifiedAt,
+		req.Password,
+		req.CreatedAt,
+		req.UpdatedAt,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) Login(req *user.User) (user.User, error) {
+	const op = "storage.sqlite.user.Login"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, uuid, name, email, password FROM %s WHERE (name = ? OR email = ?) AND deactivated_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+
+	var u user.User
+
+	err := s.db.QueryRowContext(s.ctx, querySQL, req.Name, req.Email).Scan(
+		&u.ID,
+		&u.UUID,
+		&u.Name,
+		&u.Email,
+		&u.Password,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return u, err
+	}
+
+	return u, nil
+}
+
+func (s *Storage) GetUser(ID int64) (user.User, error) {
+	const op = "storage.sqlite.user.GetUser"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, uuid, name, email, is_active FROM %s WHERE id = ? AND deactivated_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+
+	return s.getUser(querySQL, ID)
+}
+
+func (s *Storage) GetUserByEmail(email string) (user.User, error) {
+	const op = "storage.sqlite.user.GetUserByEmail"
+	logging.L(s.ctx).Info("op", logging.StringAttr("op", op))
+
+	querySQL := `SELECT id, uuid, name, email, is_active FROM %s WHERE email = ? AND deactivated_at IS NULL`
+	querySQL = fmt.Sprintf(querySQL, migrations.TableUsers)
+
+	return s.getUser(querySQL, email)
+}
+
+func (s *Storage) getUser(querySQL string, args ...any) (user.User, error) {
+	var u user.User
+
+	err := s.db.QueryRowContext(s.ctx, querySQL, args...).Scan(
+		&u.ID,
+		&u.UUID,
+		&u.Name,
+		&u.Email,
+		&u.IsActive,
+	)
+
+	if err != nil {
+		logging.L(s.ctx).Error("error query", logging.ErrAttr(err))
+		return u, err
+	}
+
+	return u, nil
+}
@@ -1,67 +1,348 @@
 package storage
 
 import (
+	"app/internal/config"
+	"app/internal/storage/memory"
+	memoryClientStorage "app/internal/storage/memory/client"
+	memoryAccessToken "app/internal/storage/memory/oauth/access-token"
+	memoryRefreshToken "app/internal/storage/memory/oauth/refresh-token"
+	memoryResource "app/internal/storage/memory/oauth/resource"
+	memoryRevocation "app/internal/storage/memory/oauth/revocation"
+	memoryAuthToken "app/internal/storage/memory/oauth/token"
+	memoryUser "app/internal/storage/memory/user"
 	clientStorage "app/internal/storage/pgsql/client"
+	"app/internal/storage/pgsql/federation"
+	"app/internal/storage/pgsql/group"
 	accessToken "app/internal/storage/pgsql/oauth/access-token"
+	authorizationCode "app/internal/storage/pgsql/oauth/authorization-code"
+	authorizationRequest "app/internal/storage/pgsql/oauth/authorization-request"
+	deviceCode "app/internal/storage/pgsql/oauth/device-code"
 	refreshToken "app/internal/storage/pgsql/oauth/refresh-token"
+	resource "app/internal/storage/pgsql/oauth/resource"
+	revocation "app/internal/storage/pgsql/oauth/revocation"
 	authToken "app/internal/storage/pgsql/oauth/token"
+	tokenExchange "app/internal/storage/pgsql/oauth/token-exchange"
+	scimStorage "app/internal/storage/pgsql/scim"
 	"app/internal/storage/pgsql/user"
+	sqliteClientStorage "app/internal/storage/sqlite/client"
+	sqliteAccessToken "app/internal/storage/sqlite/oauth/access-token"
+	sqliteRefreshToken "app/internal/storage/sqlite/oauth/refresh-token"
+	sqliteResource "app/internal/storage/sqlite/oauth/resource"
+	sqliteRevocation "app/internal/storage/sqlite/oauth/revocation"
+	sqliteAuthToken "app/internal/storage/sqlite/oauth/token"
+	sqliteUser "app/internal/storage/sqlite/user"
+	"app/pkg/client/pgsql"
+	"app/pkg/client/sqlite"
 	"app/pkg/common/logging"
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mattn/go-sqlite3"
 )
 
 var (
 	ErrCodeExists = "23505"
 )
 
+var ErrUnknownDriver = errors.New("unknown storage driver")
+
+// ErrorCode returns the SQLSTATE of a PostgreSQL error, the unique
+// constraint violations of SQLite and memory are reported as
+// ErrCodeExists too.
 func ErrorCode(err error) string {
+	if errors.Is(err, memory.ErrExists) {
+		return ErrCodeExists
+	}
+
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
 		return pgErr.Code
 	}
 
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			return ErrCodeExists
+		}
+	}
+
 	return ""
 }
 
+func IsNotFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) || errors.Is(err, memory.ErrNotFound)
+}
+
 type Storage struct {
-	User         *user.Storage
-	Client       *clientStorage.Storage
-	AccessToken  *accessToken.Storage
-	RefreshToken *refreshToken.Storage
-	AuthToken    *authToken.Storage
+	User         UserRepository
+	Client       ClientRepository
+	AccessToken  AccessTokenRepository
+	RefreshToken RefreshTokenRepository
+	AuthToken    AuthTokenRepository
+	Resource     ResourceRepository
+	Revocation   RevocationRepository
+	// the storages below are only implemented for PostgreSQL, they are
+	// nil with another driver
+	DeviceCode           *deviceCode.Storage
+	TokenExchange        *tokenExchange.Storage
+	AuthorizationRequest *authorizationRequest.Storage
+	AuthorizationCode    *authorizationCode.Storage
+	Federation           *federation.Storage
+	Group                *group.Storage
+	Scim                 *scimStorage.Storage
+	ping                 func(ctx context.Context) error
+	close                func()
+}
+
+// New connects to the database of the configured driver and returns the
+// storages on it.
+func New(ctx context.Context, cfg config.DB) (*Storage, error) {
+	switch cfg.Driver {
+	case config.DriverPgsql:
+		pgClient, err := pgsql.New(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return newPgsql(ctx, pgClient)
+	case config.DriverSqlite:
+		db, err := sqlite.New(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return newSqlite(ctx, db)
+	case config.DriverMemory:
+		return newMemory(ctx, memory.New())
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, cfg.Driver)
+}
+
+// Full reports whether the storages only implemented for PostgreSQL are
+// set, the features that need them are left out otherwise.
+func (s *Storage) Full() bool {
+	return s.DeviceCode != nil
+}
+
+// Ping reports whether the database is reachable.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.ping(ctx)
 }
 
-func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
+// Close closes the connections to the database.
+func (s *Storage) Close() {
+	s.close()
+}
+
+func newPgsql(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 	storageUser, err := user.New(ctx, pgClient)
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage user", err)
+		logging.L(ctx).Error("failed to init storage user", logging.ErrAttr(err))
 		return nil, err
 	}
 
 	storageClient, err := clientStorage.New(ctx, pgClient)
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage client", err)
+		logging.L(ctx).Error("failed to init storage client", logging.ErrAttr(err))
 		return nil, err
 	}
 
 	storageAccessToken, err := accessToken.New(ctx, pgClient)
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage access token", err)
+		logging.L(ctx).Error("failed to init storage access token", logging.ErrAttr(err))
 		return nil, err
 	}
 
 	storageRefreshToken, err := refreshToken.New(ctx, pgClient)
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage refresh token", err)
+		logging.L(ctx).Error("failed to init storage refresh token", logging.ErrAttr(err))
 		return nil, err
 	}
 
 	storageAuthToken, err := authToken.New(ctx, pgClient)
 	if err != nil {
-		logging.L(ctx).Error("failed to init storage auth token", err)
+		logging.L(ctx).Error("failed to init storage auth token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageDeviceCode, err := deviceCode.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage device code", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageTokenExchange, err := tokenExchange.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage token exchange", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAuthorizationRequest, err := authorizationRequest.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage authorization request", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAuthorizationCode, err := authorizationCode.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage authorization code", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageResource, err := resource.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage resource", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageRevocation, err := revocation.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage revocation", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageFederation, err := federation.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage federation", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageGroup, err := group.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage group", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageScim, err := scimStorage.New(ctx, pgClient)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage scim", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return &Storage{
+		User:                 storageUser,
+		Client:               storageClient,
+		AccessToken:          storageAccessToken,
+		RefreshToken:         storageRefreshToken,
+		AuthToken:            storageAuthToken,
+		DeviceCode:           storageDeviceCode,
+		TokenExchange:        storageTokenExchange,
+		AuthorizationRequest: storageAuthorizationRequest,
+		AuthorizationCode:    storageAuthorizationCode,
+		Resource:             storageResource,
+		Revocation:           storageRevocation,
+		Federation:           storageFederation,
+		Group:                storageGroup,
+		Scim:                 storageScim,
+		ping:                 pgClient.Ping,
+		close:                pgClient.Close,
+	}, nil
+}
+
+func newSqlite(ctx context.Context, db *sql.DB) (*Storage, error) {
+	storageUser, err := sqliteUser.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage user", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageClient, err := sqliteClientStorage.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage client", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAccessToken, err := sqliteAccessToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage access token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageRefreshToken, err := sqliteRefreshToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage refresh token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAuthToken, err := sqliteAuthToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage auth token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageResource, err := sqliteResource.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage resource", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageRevocation, err := sqliteRevocation.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage revocation", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	return &Storage{
+		User:         storageUser,
+		Client:       storageClient,
+		AccessToken:  storageAccessToken,
+		RefreshToken: storageRefreshToken,
+		AuthToken:    storageAuthToken,
+		Resource:     storageResource,
+		Revocation:   storageRevocation,
+		ping:         db.PingContext,
+		close: func() {
+			if err := db.Close(); err != nil {
+				logging.L(ctx).Error("failed to close the database", logging.ErrAttr(err))
+			}
+		},
+	}, nil
+}
+
+func newMemory(ctx context.Context, db *memory.DB) (*Storage, error) {
+	storageUser, err := memoryUser.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage user", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageClient, err := memoryClientStorage.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage client", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAccessToken, err := memoryAccessToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage access token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageRefreshToken, err := memoryRefreshToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage refresh token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageAuthToken, err := memoryAuthToken.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage auth token", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageResource, err := memoryResource.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage resource", logging.ErrAttr(err))
+		return nil, err
+	}
+
+	storageRevocation, err := memoryRevocation.New(ctx, db)
+	if err != nil {
+		logging.L(ctx).Error("failed to init storage revocation", logging.ErrAttr(err))
 		return nil, err
 	}
 
@@ -71,5 +352,9 @@ func New(ctx context.Context, pgClient *pgxpool.Pool) (*Storage, error) {
 		AccessToken:  storageAccessToken,
 		RefreshToken: storageRefreshToken,
 		AuthToken:    storageAuthToken,
+		Resource:     storageResource,
+		Revocation:   storageRevocation,
+		ping:         func(context.Context) error { return nil },
+		close:        func() {},
 	}, nil
 }
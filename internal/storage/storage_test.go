@@ -0,0 +1,276 @@
+package storage_test
+
+import (
+	"app/internal/config"
+	"app/internal/domain/client"
+	accessTokenDomain "app/internal/domain/oauth/access-token"
+	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	revocationDomain "app/internal/domain/oauth/revocation"
+	"app/internal/domain/user"
+	"app/internal/storage"
+	sqliteClient "app/pkg/client/sqlite"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSqlite(t *testing.T) *storage.Storage {
+	t.Helper()
+
+	ctx := context.Background()
+	cfg := config.DB{
+		Driver: config.DriverSqlite,
+		SQLITE: config.SQLITE{
+			StoragePath:     filepath.Join(t.TempDir(), "storage.db"),
+			MigrationsTable: "migrations",
+		},
+		MaxAttempts: 1,
+	}
+
+	db, err := sqliteClient.New(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqliteClient.Migrate(db, cfg.SQLITE.MigrationsTable); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	storages, err := storage.New(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(storages.Close)
+
+	return storages
+}
+
+func newMemory(t *testing.T) *storage.Storage {
+	t.Helper()
+
+	storages, err := storage.New(context.Background(), config.DB{Driver: config.DriverMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(storages.Close)
+
+	return storages
+}
+
+func TestStorages(t *testing.T) {
+	drivers := []struct {
+		name string
+		new  func(t *testing.T) *storage.Storage
+	}{
+		{name: config.DriverSqlite, new: newSqlite},
+		{name: config.DriverMemory, new: newMemory},
+	}
+
+	for _, d := range drivers {
+		t.Run(d.name, func(t *testing.T) {
+			testStorages(t, d.new(t))
+		})
+	}
+}
+
+func testStorages(t *testing.T, storages *storage.Storage) {
+	if storages.Full() {
+		t.Fatal("expected the PostgreSQL only storages to be left out")
+	}
+	if err := storages.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("users", func(t *testing.T) {
+		err := storages.User.Registration(&user.CreateUser{
+			UUID:     "7b6d4c1e-0000-4000-8000-000000000001",
+			Name:     "alice",
+			Email:    "alice@example.com",
+			Password: "hash",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := storages.User.Login(&user.User{Email: "alice@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Name != "alice" || u.Password != "hash" {
+			t.Fatalf("unexpected user %+v", u)
+		}
+
+		_, err = storages.User.GetUserByEmail("bob@example.com")
+		if !storage.IsNotFound(err) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		oauthClient := &client.Client{
+			ID:           "client-1",
+			Name:         "web",
+			Secret:       "secret",
+			Provider:     "users",
+			RedirectURIs: []string{"https://example.com/callback"},
+			GrantTypes:   []string{client.GrantTypePassword},
+		}
+		if err := storages.Client.CreateClient(oauthClient); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := storages.Client.GetClient("client-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.RedirectURIs) != 1 || got.RedirectURIs[0] != "https://example.com/callback" {
+			t.Fatalf("unexpected redirect URIs %v", got.RedirectURIs)
+		}
+
+		err = storages.Client.CreateClient(oauthClient)
+		if storage.ErrorCode(err) != storage.ErrCodeExists {
+			t.Fatalf("expected a duplicate, got %v", err)
+		}
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		u, err := storages.User.GetUserByEmail("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		aT := &accessTokenDomain.AccessToken{ID: "access-1", UserId: u.ID, ClientId: "client-1", ExpiresAt: expiresAt}
+		rT := &refreshTokenDomain.RefreshToken{ID: "refresh-1", ExpiresAt: expiresAt}
+		if err := storages.AuthToken.Create(aT, rT); err != nil {
+			t.Fatal(err)
+		}
+
+		// the refresh token of a duplicate access token isn't kept
+		err = storages.AuthToken.Create(aT, &refreshTokenDomain.RefreshToken{ID: "refresh-2", ExpiresAt: expiresAt})
+		if storage.ErrorCode(err) != storage.ErrCodeExists {
+			t.Fatalf("expected a duplicate, got %v", err)
+		}
+		exists, err := storages.RefreshToken.ExistsToken(&refreshTokenDomain.RefreshToken{ID: "refresh-2", AccessTokenId: "access-1"})
+		if err != nil || exists {
+			t.Fatalf("expected the refresh token to be rolled back, got %v %v", exists, err)
+		}
+
+		if err := storages.AccessToken.RevokeToken("access-1"); err != nil {
+			t.Fatal(err)
+		}
+
+		refresh, err := storages.RefreshToken.GetToken(&refreshTokenDomain.RefreshToken{ID: "refresh-1", AccessTokenId: "access-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !refresh.Revoked {
+			t.Fatal("expected the refresh token to be revoked with its access token")
+		}
+
+		events, err := storages.Revocation.GetEventsAfter(0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Kind != revocationDomain.KindToken || events[0].TokenID != "access-1" {
+			t.Fatalf("unexpected events %+v", events)
+		}
+
+		aT = &accessTokenDomain.AccessToken{ID: "access-2", UserId: u.ID, ClientId: "client-1", ExpiresAt: expiresAt}
+		rT = &refreshTokenDomain.RefreshToken{ID: "refresh-3", ExpiresAt: expiresAt}
+		if err := storages.AuthToken.Create(aT, rT); err != nil {
+			t.Fatal(err)
+		}
+		if err := storages.AccessToken.RevokeUserTokens(u.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		events, err = storages.Revocation.GetEventsAfter(events[0].ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].Kind != revocationDomain.KindUser || events[0].UserUUID != u.UUID {
+			t.Fatalf("unexpected events %+v", events)
+		}
+	})
+
+	t.Run("key events", func(t *testing.T) {
+		for i, want := range []bool{true, false, true} {
+			keyID := "key-1"
+			if i == 2 {
+				keyID = "key-2"
+			}
+
+			rotated, err := storages.Revocation.CreateKeyEvent(keyID, time.Now().Unix())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rotated != want {
+				t.Fatalf("key %s: expected rotated %v", keyID, want)
+			}
+		}
+	})
+}
+
+func TestMemoryStoragesConcurrency(t *testing.T) {
+	storages := newMemory(t)
+
+	const workers = 32
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// every worker races for the same email, only one may win
+			errs <- storages.User.Registration(&user.CreateUser{
+				UUID:     fmt.Sprintf("7b6d4c1e-0000-4000-8000-%012d", i),
+				Name:     fmt.Sprintf("user%d", i),
+				Email:    "race@example.com",
+				Password: "hash",
+			})
+
+			expiresAt := time.Now().Add(time.Hour).Unix()
+			errs <- storages.AuthToken.Create(
+				&accessTokenDomain.AccessToken{ID: fmt.Sprintf("access-%d", i), UserId: 1, ClientId: "client-1", ExpiresAt: expiresAt},
+				&refreshTokenDomain.RefreshToken{ID: fmt.Sprintf("refresh-%d", i), ExpiresAt: expiresAt},
+			)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var duplicates int
+	for err := range errs {
+		switch {
+		case err == nil:
+		case storage.ErrorCode(err) == storage.ErrCodeExists:
+			duplicates++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if duplicates != workers-1 {
+		t.Fatalf("expected %d duplicate registrations, got %d", workers-1, duplicates)
+	}
+
+	for i := range workers {
+		rT, err := storages.RefreshToken.GetToken(&refreshTokenDomain.RefreshToken{
+			ID:            fmt.Sprintf("refresh-%d", i),
+			AccessTokenId: fmt.Sprintf("access-%d", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rT.AccessTokenId != fmt.Sprintf("access-%d", i) {
+			t.Fatalf("unexpected refresh token %+v", rT)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS oauth_device_codes
+(
+    id             TEXT PRIMARY KEY,
+    user_code      TEXT    NOT NULL UNIQUE,
+    client_id      TEXT    NOT NULL,
+    scopes         TEXT    NOT NULL DEFAULT '[]',
+    user_id        BIGINT           DEFAULT NULL,
+    status         TEXT    NOT NULL DEFAULT 'pending',
+    poll_interval  INT     NOT NULL DEFAULT 5,
+    last_polled_at INT              DEFAULT 0,
+    expires_at     INT              DEFAULT 0,
+    created_at     INT              DEFAULT 0
+);
+
+CREATE INDEX oauth_device_codes_expires_at_index ON oauth_device_codes (expires_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_device_codes;
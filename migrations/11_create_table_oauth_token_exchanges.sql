@@ -0,0 +1,27 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS token_exchange JSONB DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS oauth_token_exchanges
+(
+    id              TEXT PRIMARY KEY,
+    client_id       TEXT   NOT NULL,
+    subject_user_id BIGINT NOT NULL,
+    actor_user_id   BIGINT          DEFAULT NULL,
+    actor_client_id TEXT            DEFAULT NULL,
+    access_token_id TEXT   NOT NULL,
+    audience        TEXT[] NOT NULL DEFAULT '{}',
+    scopes          TEXT[] NOT NULL DEFAULT '{}',
+    created_at      INT             DEFAULT 0
+);
+
+CREATE INDEX oauth_token_exchanges_subject_user_id_index ON oauth_token_exchanges (subject_user_id);
+CREATE INDEX oauth_token_exchanges_actor_user_id_index ON oauth_token_exchanges (actor_user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_token_exchanges;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS token_exchange;
@@ -0,0 +1,39 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_requests
+(
+    id         TEXT PRIMARY KEY,
+    client_id  TEXT  NOT NULL,
+    params     JSONB NOT NULL,
+    expires_at INT         DEFAULT 0,
+    created_at INT         DEFAULT 0
+);
+
+CREATE INDEX oauth_authorization_requests_expires_at_index ON oauth_authorization_requests (expires_at);
+
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes
+(
+    id                    TEXT PRIMARY KEY,
+    client_id             TEXT   NOT NULL,
+    user_id               BIGINT NOT NULL,
+    redirect_uri          TEXT   NOT NULL,
+    scope                 TEXT   NOT NULL DEFAULT '',
+    code_challenge        TEXT   NOT NULL DEFAULT '',
+    code_challenge_method TEXT   NOT NULL DEFAULT '',
+    expires_at            INT             DEFAULT 0,
+    created_at            INT             DEFAULT 0
+);
+
+CREATE INDEX oauth_authorization_codes_expires_at_index ON oauth_authorization_codes (expires_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_authorization_codes;
+
+DROP TABLE IF EXISTS oauth_authorization_requests;
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS require_pushed_authorization_requests;
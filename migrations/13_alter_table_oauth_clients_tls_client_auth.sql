@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS tls_client_auth JSONB DEFAULT NULL;
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS tls_client_auth;
@@ -0,0 +1,25 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS oauth_resources
+(
+    id         TEXT PRIMARY KEY,
+    identifier TEXT   NOT NULL UNIQUE,
+    name       TEXT   NOT NULL,
+    scopes     TEXT[] NOT NULL DEFAULT '{}',
+    token_ttl  INT    NOT NULL DEFAULT 0,
+    client_id  TEXT            DEFAULT NULL,
+    created_at INT             DEFAULT 0,
+    updated_at INT             DEFAULT 0
+);
+
+CREATE INDEX oauth_resources_client_id_index ON oauth_resources (client_id);
+
+ALTER TABLE oauth_authorization_codes
+    ADD COLUMN IF NOT EXISTS resources TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+
+ALTER TABLE oauth_authorization_codes
+    DROP COLUMN IF EXISTS resources;
+
+DROP TABLE IF EXISTS oauth_resources;
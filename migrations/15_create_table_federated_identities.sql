@@ -0,0 +1,34 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS federation_states
+(
+    id            TEXT PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    client_id     TEXT NOT NULL,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at    INT  DEFAULT 0,
+    created_at    INT  DEFAULT 0
+);
+
+CREATE INDEX federation_states_expires_at_index ON federation_states (expires_at);
+
+CREATE TABLE IF NOT EXISTS federated_identities
+(
+    id         TEXT PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT   NOT NULL,
+    subject    TEXT   NOT NULL,
+    email      TEXT   NOT NULL DEFAULT '',
+    created_at INT             DEFAULT 0,
+    updated_at INT             DEFAULT 0,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX federated_identities_user_id_index ON federated_identities (user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS federated_identities;
+
+DROP TABLE IF EXISTS federation_states;
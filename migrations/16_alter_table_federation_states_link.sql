@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE federation_states
+    ADD COLUMN IF NOT EXISTS user_id BIGINT DEFAULT NULL;
+
+-- +goose Down
+
+ALTER TABLE federation_states
+    DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,21 @@
+-- +goose Up
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS external_id    TEXT   DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS display_name   TEXT   DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS deactivated_at BIGINT DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS deleted_at     BIGINT DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS version        BIGINT NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_users_name ON users (lower(name));
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_users_name;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS external_id,
+    DROP COLUMN IF EXISTS display_name,
+    DROP COLUMN IF EXISTS deactivated_at,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS version;
@@ -0,0 +1,27 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS groups
+(
+    id           BIGSERIAL PRIMARY KEY,
+    uuid         UUID   NOT NULL UNIQUE,
+    display_name TEXT   NOT NULL UNIQUE,
+    external_id  TEXT            DEFAULT NULL,
+    version      BIGINT NOT NULL DEFAULT 1,
+    created_at   INT             DEFAULT 0,
+    updated_at   INT             DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS group_members
+(
+    group_id BIGINT NOT NULL REFERENCES groups (id) ON DELETE CASCADE,
+    user_id  BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX group_members_user_id_index ON group_members (user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS group_members;
+
+DROP TABLE IF EXISTS groups;
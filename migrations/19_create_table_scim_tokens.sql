@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS scim_tokens
+(
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at INT DEFAULT 0
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS scim_tokens;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS oauth_revocation_events
+(
+    id         BIGSERIAL PRIMARY KEY,
+    kind       TEXT NOT NULL,
+    token_id   TEXT          DEFAULT NULL,
+    user_id    BIGINT        DEFAULT NULL,
+    key_id     TEXT          DEFAULT NULL,
+    created_at INT           DEFAULT 0,
+    expires_at INT           DEFAULT 0
+);
+
+CREATE INDEX oauth_revocation_events_expires_at_index ON oauth_revocation_events (expires_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_revocation_events;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS scopes;
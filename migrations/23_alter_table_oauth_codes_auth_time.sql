@@ -0,0 +1,15 @@
+-- +goose Up
+
+ALTER TABLE oauth_authorization_codes
+    ADD COLUMN IF NOT EXISTS auth_time BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE oauth_device_codes
+    ADD COLUMN IF NOT EXISTS auth_time BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+
+ALTER TABLE oauth_device_codes
+    DROP COLUMN IF EXISTS auth_time;
+
+ALTER TABLE oauth_authorization_codes
+    DROP COLUMN IF EXISTS auth_time;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS previous_secret            TEXT DEFAULT NULL,
+    ADD COLUMN IF NOT EXISTS previous_secret_expires_at INT  DEFAULT 0;
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS previous_secret,
+    DROP COLUMN IF EXISTS previous_secret_expires_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE oauth_clients
+SET secret = 'sha256$' || salted.salt || '$' || encode(digest(salted.salt || oauth_clients.secret, 'sha256'), 'hex')
+FROM (SELECT id, encode(gen_random_bytes(16), 'hex') AS salt FROM oauth_clients) AS salted
+WHERE salted.id = oauth_clients.id
+  AND oauth_clients.secret NOT LIKE 'sha256$%';
+
+UPDATE oauth_clients
+SET previous_secret = 'sha256$' || salted.salt || '$' || encode(digest(salted.salt || oauth_clients.previous_secret, 'sha256'), 'hex')
+FROM (SELECT id, encode(gen_random_bytes(16), 'hex') AS salt FROM oauth_clients) AS salted
+WHERE salted.id = oauth_clients.id
+  AND oauth_clients.previous_secret IS NOT NULL
+  AND oauth_clients.previous_secret NOT LIKE 'sha256$%';
+
+-- +goose Down
+
+-- Hashed secrets can't be restored, clients have to rotate their secrets.
@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS token_endpoint_auth_method TEXT NOT NULL DEFAULT 'client_secret_basic',
+    ADD COLUMN IF NOT EXISTS jwks                       TEXT          DEFAULT NULL;
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS token_endpoint_auth_method,
+    DROP COLUMN IF EXISTS jwks;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE oauth_clients
+SET redirect_uris = ARRAY [redirect]
+WHERE redirect <> '';
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS redirect;
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS redirect TEXT NOT NULL DEFAULT '';
+
+UPDATE oauth_clients
+SET redirect = COALESCE(redirect_uris[1], '');
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS redirect_uris;
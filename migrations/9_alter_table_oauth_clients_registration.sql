@@ -0,0 +1,13 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS grant_types               TEXT[] NOT NULL DEFAULT '{password,refresh_token}',
+    ADD COLUMN IF NOT EXISTS contacts                  TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS registration_access_token TEXT            DEFAULT NULL;
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN IF EXISTS grant_types,
+    DROP COLUMN IF EXISTS contacts,
+    DROP COLUMN IF EXISTS registration_access_token;
@@ -6,8 +6,19 @@ import "embed"
 var Content embed.FS
 
 var (
-	TableUsers             = "users"
-	TableOauthAccessToken  = "oauth_access_tokens"
-	TableOauthClient       = "oauth_clients"
-	TableOauthRefreshToken = "oauth_refresh_tokens"
+	TableUsers                     = "users"
+	TableOauthAccessToken          = "oauth_access_tokens"
+	TableOauthClient               = "oauth_clients"
+	TableOauthRefreshToken         = "oauth_refresh_tokens"
+	TableOauthDeviceCode           = "oauth_device_codes"
+	TableOauthTokenExchange        = "oauth_token_exchanges"
+	TableOauthAuthorizationRequest = "oauth_authorization_requests"
+	TableOauthAuthorizationCode    = "oauth_authorization_codes"
+	TableOauthResource             = "oauth_resources"
+	TableOauthRevocationEvent      = "oauth_revocation_events"
+	TableFederationState           = "federation_states"
+	TableFederatedIdentity         = "federated_identities"
+	TableGroup                     = "groups"
+	TableGroupMember               = "group_members"
+	TableScimToken                 = "scim_tokens"
 )
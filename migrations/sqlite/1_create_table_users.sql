@@ -0,0 +1,26 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id                INTEGER PRIMARY KEY,
+    uuid              TEXT    NOT NULL UNIQUE,
+    name              TEXT    NOT NULL,
+    email             TEXT    NOT NULL UNIQUE,
+    email_verified_at INTEGER          DEFAULT 0,
+    password          TEXT    NOT NULL,
+    remember_token    TEXT             DEFAULT NULL,
+    is_active         INTEGER          DEFAULT 0,
+    external_id       TEXT             DEFAULT NULL,
+    display_name      TEXT             DEFAULT NULL,
+    deactivated_at    INTEGER          DEFAULT NULL,
+    deleted_at        INTEGER          DEFAULT NULL,
+    version           INTEGER NOT NULL DEFAULT 1,
+    created_at        INTEGER          DEFAULT 0,
+    updated_at        INTEGER          DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_name ON users (lower(name));
+
+-- +goose Down
+
+DROP TABLE IF EXISTS users;
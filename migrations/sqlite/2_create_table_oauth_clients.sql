@@ -0,0 +1,35 @@
+-- +goose Up
+
+-- the arrays and the JSONB columns of PostgreSQL are JSON text
+CREATE TABLE IF NOT EXISTS oauth_clients
+(
+    id                                    TEXT PRIMARY KEY,
+    user_id                               INTEGER          DEFAULT 0,
+    name                                  TEXT    NOT NULL,
+    secret                                TEXT    NOT NULL,
+    previous_secret                       TEXT             DEFAULT NULL,
+    previous_secret_expires_at            INTEGER          DEFAULT 0,
+    provider                              TEXT    NOT NULL,
+    redirect_uris                         TEXT    NOT NULL DEFAULT '[]',
+    personal_access_client                BOOLEAN NOT NULL,
+    password_client                       BOOLEAN NOT NULL,
+    revoked                               BOOLEAN NOT NULL,
+    token_endpoint_auth_method            TEXT    NOT NULL DEFAULT 'client_secret_basic',
+    jwks                                  TEXT             DEFAULT NULL,
+    grant_types                           TEXT    NOT NULL DEFAULT '["password","refresh_token"]',
+    contacts                              TEXT    NOT NULL DEFAULT '[]',
+    registration_access_token             TEXT             DEFAULT NULL,
+    token_exchange                        TEXT             DEFAULT NULL,
+    require_pushed_authorization_requests BOOLEAN NOT NULL DEFAULT FALSE,
+    tls_client_auth                       TEXT             DEFAULT NULL,
+    created_at                            INTEGER          DEFAULT 0,
+    updated_at                            INTEGER          DEFAULT 0
+);
+
+-- NULLs are distinct in a unique index, unlike NULLS NOT DISTINCT of
+-- PostgreSQL, the clients without a user are compared on -1
+CREATE UNIQUE INDEX IF NOT EXISTS oauth_clients_uniq ON oauth_clients (name, provider, IFNULL(user_id, -1));
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_clients;
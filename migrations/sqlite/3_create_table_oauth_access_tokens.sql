@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS oauth_access_tokens
+(
+    id         TEXT PRIMARY KEY,
+    user_id    INTEGER          DEFAULT NULL,
+    client_id  TEXT    NOT NULL,
+    name       TEXT             DEFAULT NULL,
+    scopes     TEXT    NOT NULL DEFAULT '[]',
+    revoked    BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at INTEGER          DEFAULT 0,
+    updated_at INTEGER          DEFAULT 0,
+    expires_at INTEGER          DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS oauth_access_tokens_user_id_index ON oauth_access_tokens (user_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_access_tokens;
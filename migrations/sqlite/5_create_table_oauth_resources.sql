@@ -0,0 +1,19 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS oauth_resources
+(
+    id         TEXT PRIMARY KEY,
+    identifier TEXT    NOT NULL UNIQUE,
+    name       TEXT    NOT NULL,
+    scopes     TEXT    NOT NULL DEFAULT '[]',
+    token_ttl  INTEGER NOT NULL DEFAULT 0,
+    client_id  TEXT             DEFAULT NULL,
+    created_at INTEGER          DEFAULT 0,
+    updated_at INTEGER          DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS oauth_resources_client_id_index ON oauth_resources (client_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_resources;
@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- AUTOINCREMENT never reuses the ID of a deleted event, it is the cursor
+-- of the watches
+CREATE TABLE IF NOT EXISTS oauth_revocation_events
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind       TEXT NOT NULL,
+    token_id   TEXT    DEFAULT NULL,
+    user_id    INTEGER DEFAULT NULL,
+    key_id     TEXT    DEFAULT NULL,
+    created_at INTEGER DEFAULT 0,
+    expires_at INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS oauth_revocation_events_expires_at_index ON oauth_revocation_events (expires_at);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS oauth_revocation_events;
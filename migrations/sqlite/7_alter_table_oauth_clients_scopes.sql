@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE oauth_clients
+    ADD COLUMN scopes TEXT NOT NULL DEFAULT '[]';
+
+-- +goose Down
+
+ALTER TABLE oauth_clients
+    DROP COLUMN scopes;
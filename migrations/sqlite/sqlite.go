@@ -0,0 +1,10 @@
+package sqlite
+
+import "embed"
+
+// Content holds the migrations of the SQLite storage, they create the
+// tables of the PostgreSQL migrations SQLite stores, at their last
+// version.
+//
+//go:embed *.sql
+var Content embed.FS
@@ -26,20 +26,20 @@ func New(
 
 	pgxCfg, parseConfigErr := pgxpool.ParseConfig(dsn)
 	if parseConfigErr != nil {
-		logging.L(ctx).Error("Unable to parse config: ", fmt.Sprintf("%v\n", parseConfigErr))
+		logging.L(ctx).Error("Unable to parse config", logging.ErrAttr(parseConfigErr))
 		return nil, parseConfigErr
 	}
 
 	pool, parseConfigErr = pgxpool.NewWithConfig(ctx, pgxCfg)
 	if parseConfigErr != nil {
-		logging.L(ctx).Error("Failed to parse Postgres SQL configuration due to error", fmt.Sprintf("%v\n", parseConfigErr))
+		logging.L(ctx).Error("Failed to parse Postgres SQL configuration due to error", logging.ErrAttr(parseConfigErr))
 		return nil, parseConfigErr
 	}
 
 	err = loop.DoWithAttempt(ctx, func() error {
 		pingErr := pool.Ping(ctx)
 		if pingErr != nil {
-			logging.L(ctx).Warn("Failed to connect to postgres due to error", pingErr)
+			logging.L(ctx).Warn("Failed to connect to postgres due to error", logging.ErrAttr(pingErr))
 			logging.L(ctx).Warn("Going to do the next attempt")
 			return pingErr
 		}
@@ -33,14 +33,14 @@ func New(
 	if cfg.Driver != "" {
 		err := app.Connect()
 		if err != nil {
-			logging.L(ctx).Error("Couldn't connect to RabbitMQ: ", err)
+			logging.L(ctx).Error("Couldn't connect to RabbitMQ", logging.ErrAttr(err))
 			return nil, err
 		}
 
 		for _, queueData := range queue.List {
 			err = app.SetupQueueAndExchange(queueData.Exchange, queueData.Queue, queueData.RoutingKey)
 			if err != nil {
-				logging.L(ctx).Error("Error during setup RabbitMQ: ", err)
+				logging.L(ctx).Error("Error during setup RabbitMQ", logging.ErrAttr(err))
 				return nil, err
 			}
 		}
@@ -62,12 +62,12 @@ func (a *App) Connect() error {
 	err = loop.DoWithAttempt(a.ctx, func() error {
 		conn, err = amqp.Dial(dns)
 		if err != nil {
-			logging.L(a.ctx).Error("Error connect to RabbitMQ: %v. Repeat after %s seconds...", err, a.cfg.MaxDelay)
+			logging.L(a.ctx).Error("Error connect to RabbitMQ, repeat later", logging.ErrAttr(err), logging.StringAttr("delay", a.cfg.MaxDelay.String()))
 			return err
 		}
 		ch, err = conn.Channel()
 		if err != nil {
-			logging.L(a.ctx).Error("Error opening the RabbitMQ channel: %v. Repeat after 5 seconds...", err)
+			logging.L(a.ctx).Error("Error opening the RabbitMQ channel, repeat after 5 seconds", logging.ErrAttr(err))
 			return err
 		}
 		return nil
@@ -128,6 +128,12 @@ func (a *App) Channel() *amqp.Channel {
 }
 
 func (a *App) PublishMsg(exchangeName, routingKey string, msg []byte) {
+	// without a driver there is no connection, the message is dropped
+	if a.ch == nil {
+		logging.L(a.ctx).Warn("queue is not connected, message dropped", logging.StringAttr("routing_key", routingKey))
+		return
+	}
+
 	err := a.ch.Publish(
 		exchangeName,
 		routingKey,
@@ -139,7 +145,7 @@ func (a *App) PublishMsg(exchangeName, routingKey string, msg []byte) {
 		},
 	)
 	if err != nil {
-		logging.L(a.ctx).Error("Error when posting a message: %v", err)
+		logging.L(a.ctx).Error("Error when posting a message", logging.ErrAttr(err))
 	}
 }
 
@@ -147,10 +153,16 @@ func (a *App) ConsumeMsg(
 	queueName string,
 	handler func(amqp.Delivery),
 ) {
+	// without a driver there is no connection and nothing to consume
+	if a.conn == nil {
+		logging.L(a.ctx).Warn("queue is not connected, nothing consumed", logging.StringAttr("queue", queueName))
+		return
+	}
+
 	ch, err := a.conn.Channel()
 
 	if err != nil {
-		logging.L(a.ctx).Error("failed to open a channel", err)
+		logging.L(a.ctx).Error("failed to open a channel", logging.ErrAttr(err))
 		return
 	}
 
@@ -167,7 +179,7 @@ func (a *App) ConsumeMsg(
 	)
 
 	if err != nil {
-		logging.L(a.ctx).Error(fmt.Sprintf("failed to register consumer for %s", queueName), err)
+		logging.L(a.ctx).Error("failed to register consumer", logging.StringAttr("queue", queueName), logging.ErrAttr(err))
 		return
 	}
 
@@ -179,12 +191,12 @@ func (a *App) ConsumeMsg(
 
 func (a *App) Close() {
 
-	if a.conn == nil {
-		_ = a.conn.Close()
+	if a.ch != nil {
+		_ = a.ch.Close()
 	}
 
-	if a.ch == nil {
-		_ = a.ch.Close()
+	if a.conn != nil {
+		_ = a.conn.Close()
 	}
 }
 
@@ -0,0 +1,41 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSON stores the arrays and the JSONB columns of PostgreSQL as JSON text.
+// V is the value to store, a nil pointer is stored as NULL, or the pointer
+// the column is scanned into, NULL leaves it untouched.
+type JSON struct {
+	V any
+}
+
+func (j JSON) Value() (driver.Value, error) {
+	if v := reflect.ValueOf(j.V); !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (j JSON) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), j.V)
+	case []byte:
+		return json.Unmarshal(src, j.V)
+	}
+
+	return fmt.Errorf("sqlite: can't scan %T as JSON", src)
+}
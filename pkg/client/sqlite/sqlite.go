@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"app/internal/config"
+	sqliteMigrations "app/migrations/sqlite"
+	"app/pkg/common/logging"
+	"app/pkg/utils/loop"
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose/v3"
+)
+
+const driverName = "sqlite3"
+
+// New opens the SQLite database of cfg.SQLITE.StoragePath. The writes are
+// serialized by a single connection, the transactions take the write lock
+// when they begin so they don't fail halfway on a busy database.
+func New(
+	ctx context.Context,
+	cfg config.DB,
+) (*sql.DB, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate",
+		cfg.SQLITE.StoragePath,
+	)
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		logging.L(ctx).Error("Unable to open SQLite database", logging.ErrAttr(err))
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	err = loop.DoWithAttempt(ctx, func() error {
+		pingErr := db.PingContext(ctx)
+		if pingErr != nil {
+			logging.L(ctx).Warn("Failed to open SQLite database", logging.ErrAttr(pingErr))
+			return pingErr
+		}
+
+		return nil
+	}, cfg.MaxAttempts, cfg.MaxDelay)
+
+	if err != nil {
+		logging.L(ctx).Error("All attempts are exceeded. Unable to open SQLite database")
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Migrate applies the SQLite migrations, their versions are recorded in
+// table.
+func Migrate(db *sql.DB, table string) error {
+	goose.SetBaseFS(sqliteMigrations.Content)
+	goose.SetTableName(table)
+
+	if err := goose.SetDialect(driverName); err != nil {
+		return err
+	}
+
+	return goose.Up(db, ".")
+}
@@ -33,6 +33,20 @@ func Error(w http.ResponseWriter, r *http.Request, error any) {
 	})
 }
 
+// OAuthErrorResponse is the error body defined by RFC 6749, section 5.2.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func OAuthError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	render.Status(r, status)
+	render.JSON(w, r, OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
 type ValidationErr struct {
 	Password string `json:"password,omitempty"`
 }
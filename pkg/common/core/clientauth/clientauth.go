@@ -0,0 +1,227 @@
+package clientauth
+
+import (
+	"app/internal/domain/client"
+	"app/pkg/common/core/jwk"
+	"app/pkg/utils/replay"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"time"
+)
+
+// AssertionTypeJWTBearer is the client_assertion_type of private_key_jwt (RFC 7523).
+const AssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+var (
+	ErrInvalidClient      = errors.New("invalid client")
+	ErrMultipleMethods    = errors.New("more than one client authentication method used")
+	ErrMethodNotAllowed   = errors.New("client authentication method not allowed for client")
+	ErrInvalidAssertion   = errors.New("invalid client assertion")
+	ErrAssertionReplayed  = errors.New("client assertion already used")
+	ErrInvalidCertificate = errors.New("invalid client certificate")
+)
+
+var assertionAlgorithms = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+type Provider interface {
+	GetClient(ID string) (client.Client, error)
+}
+
+// Credentials are the client credentials presented with a request.
+type Credentials struct {
+	// Method is the authentication method the credentials were presented with.
+	Method        string
+	ClientID      string
+	ClientSecret  string
+	AssertionType string
+	Assertion     string
+	// Certificates is the TLS client certificate chain, leaf first.
+	Certificates []*x509.Certificate
+}
+
+type Authenticator struct {
+	clients   Provider
+	audiences []string
+	replay    *replay.Cache
+	clientCAs *x509.CertPool
+	now       func() time.Time
+}
+
+// New creates an Authenticator that accepts client assertions addressed
+// to any of the given audiences (the issuer and the endpoint URLs).
+func New(clients Provider, audiences ...string) *Authenticator {
+	return &Authenticator{
+		clients:   clients,
+		audiences: audiences,
+		replay:    replay.New(),
+		now:       time.Now,
+	}
+}
+
+// Authenticate verifies the credentials against the method the client
+// is registered with and returns the authenticated client.
+func (a *Authenticator) Authenticate(creds Credentials, audiences ...string) (client.Client, error) {
+	if creds.Method == client.AuthMethodPrivateKeyJWT {
+		return a.authenticateAssertion(creds, audiences)
+	}
+
+	if creds.ClientID == "" {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	c, err := a.clients.GetClient(creds.ClientID)
+	if err != nil || c.Revoked {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	// certificate based methods don't present other credentials, the
+	// client is identified by its client_id only
+	if c.UsesCertificate() && creds.Method == client.AuthMethodNone {
+		if err := a.authenticateCertificate(c, creds.Certificates); err != nil {
+			return client.Client{}, err
+		}
+		return c, nil
+	}
+
+	if c.TokenEndpointAuthMethod != creds.Method {
+		return client.Client{}, ErrMethodNotAllowed
+	}
+
+	if c.UsesSecret() && !c.AcceptsSecret(creds.ClientSecret, a.now()) {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	return c, nil
+}
+
+// TrustClientCAs sets the certificate authorities tls_client_auth
+// certificates are verified against, without them such clients can't
+// authenticate.
+func (a *Authenticator) TrustClientCAs(pool *x509.CertPool) {
+	a.clientCAs = pool
+}
+
+// authenticateCertificate checks the TLS client certificate (RFC 8705,
+// section 2). A tls_client_auth certificate must chain to a trusted CA and
+// carry the registered subject, a self-signed one must be registered.
+func (a *Authenticator) authenticateCertificate(c client.Client, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return ErrInvalidCertificate
+	}
+	leaf := chain[0]
+
+	if c.TokenEndpointAuthMethod == client.AuthMethodSelfSignedTLSClientAuth {
+		if !c.MatchesSelfSignedCertificate(leaf) {
+			return ErrInvalidCertificate
+		}
+		return nil
+	}
+
+	if a.clientCAs == nil || c.TLSClientAuth == nil {
+		return ErrInvalidCertificate
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.clientCAs,
+		Intermediates: intermediates,
+		CurrentTime:   a.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	if !c.TLSClientAuth.Matches(leaf) {
+		return fmt.Errorf("%w: subject mismatch", ErrInvalidCertificate)
+	}
+
+	return nil
+}
+
+func (a *Authenticator) authenticateAssertion(creds Credentials, audiences []string) (client.Client, error) {
+	if creds.AssertionType != AssertionTypeJWTBearer || creds.Assertion == "" {
+		return client.Client{}, ErrInvalidAssertion
+	}
+
+	unverified, _, err := jwt.NewParser().ParseUnverified(creds.Assertion, &jwt.RegisteredClaims{})
+	if err != nil {
+		return client.Client{}, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	clientID, err := unverified.Claims.GetIssuer()
+	if err != nil || clientID == "" || (creds.ClientID != "" && creds.ClientID != clientID) {
+		return client.Client{}, ErrInvalidAssertion
+	}
+
+	c, err := a.clients.GetClient(clientID)
+	if err != nil || c.Revoked {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	if c.TokenEndpointAuthMethod != client.AuthMethodPrivateKeyJWT {
+		return client.Client{}, ErrMethodNotAllowed
+	}
+
+	if c.JWKS == nil {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	keys, err := jwk.Parse([]byte(*c.JWKS))
+	if err != nil {
+		return client.Client{}, ErrInvalidClient
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err = jwt.ParseWithClaims(
+		creds.Assertion,
+		&claims,
+		keys.KeyFunc,
+		jwt.WithValidMethods(assertionAlgorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(c.ID),
+		jwt.WithSubject(c.ID),
+		jwt.WithTimeFunc(a.now),
+	)
+	if err != nil {
+		return client.Client{}, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	accepted := append(slices.Clone(a.audiences), audiences...)
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(accepted, aud)
+	}) {
+		return client.Client{}, fmt.Errorf("%w: audience mismatch", ErrInvalidAssertion)
+	}
+
+	if claims.ID == "" || !a.replay.Use(c.ID+":"+claims.ID, claims.ExpiresAt.Time) {
+		return client.Client{}, ErrAssertionReplayed
+	}
+
+	return c, nil
+}
+
+type ctxClient struct{}
+
+func ContextWithClient(ctx context.Context, c client.Client) context.Context {
+	return context.WithValue(ctx, ctxClient{}, c)
+}
+
+// FromContext returns the client authenticated for the current request.
+func FromContext(ctx context.Context) (client.Client, bool) {
+	c, ok := ctx.Value(ctxClient{}).(client.Client)
+	return c, ok
+}
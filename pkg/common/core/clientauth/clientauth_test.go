@@ -0,0 +1,171 @@
+package clientauth_test
+
+import (
+	"app/internal/domain/client"
+	"app/pkg/common/core/clientauth"
+	"app/pkg/common/core/mtls/mtlstest"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type clients map[string]client.Client
+
+func (c clients) GetClient(ID string) (client.Client, error) {
+	oauthClient, ok := c[ID]
+	if !ok {
+		return client.Client{}, errors.New("not found")
+	}
+	return oauthClient, nil
+}
+
+// certificateJWKS returns a JWK set holding the certificate in x5c.
+func certificateJWKS(t *testing.T, cert *mtlstest.Certificate) *string {
+	t.Helper()
+
+	pub := cert.Key.Public().(*ecdsa.PublicKey)
+	data, err := json.Marshal(map[string]any{
+		"keys": []map[string]any{{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+			"x5c": []string{base64.StdEncoding.EncodeToString(cert.Cert.Raw)},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := string(data)
+	return &jwks
+}
+
+func credentials(clientID string, certs ...*mtlstest.Certificate) clientauth.Credentials {
+	creds := clientauth.Credentials{Method: client.AuthMethodNone, ClientID: clientID}
+	for _, cert := range certs {
+		creds.Certificates = append(creds.Certificates, cert.Cert)
+	}
+	return creds
+}
+
+func TestAuthenticate_TLSClientAuth(t *testing.T) {
+	ca := mtlstest.NewCA(t, "client ca")
+	otherCA := mtlstest.NewCA(t, "other ca")
+
+	oauthClient := client.Client{ID: "svc", TLSClientAuth: &client.TLSClientAuth{SANDNS: "svc.internal"}}
+	if err := oauthClient.SetAuthMethod(client.AuthMethodTLSClientAuth, nil); err != nil {
+		t.Fatalf("SetAuthMethod: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	authenticator := clientauth.New(clients{"svc": oauthClient})
+	authenticator.TrustClientCAs(pool)
+
+	tests := []struct {
+		name    string
+		creds   clientauth.Credentials
+		wantErr error
+	}{
+		{
+			name:  "trusted certificate with the registered name",
+			creds: credentials("svc", ca.IssueClient(t, "svc", "svc.internal")),
+		},
+		{
+			name:    "trusted certificate with another name",
+			creds:   credentials("svc", ca.IssueClient(t, "svc", "other.internal")),
+			wantErr: clientauth.ErrInvalidCertificate,
+		},
+		{
+			name:    "untrusted certificate",
+			creds:   credentials("svc", otherCA.IssueClient(t, "svc", "svc.internal")),
+			wantErr: clientauth.ErrInvalidCertificate,
+		},
+		{
+			name:    "self-signed certificate",
+			creds:   credentials("svc", mtlstest.SelfSigned(t, "svc")),
+			wantErr: clientauth.ErrInvalidCertificate,
+		},
+		{
+			name:    "no certificate",
+			creds:   credentials("svc"),
+			wantErr: clientauth.ErrInvalidCertificate,
+		},
+		{
+			name:    "client secret instead of a certificate",
+			creds:   clientauth.Credentials{Method: client.AuthMethodClientSecretPost, ClientID: "svc", ClientSecret: "secret"},
+			wantErr: clientauth.ErrMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authenticator.Authenticate(tt.creds)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.ID != "svc" {
+				t.Fatalf("Authenticate() client = %q, want svc", got.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_TLSClientAuthWithoutTrustedCAs(t *testing.T) {
+	ca := mtlstest.NewCA(t, "client ca")
+
+	oauthClient := client.Client{ID: "svc", TLSClientAuth: &client.TLSClientAuth{SubjectDN: "CN=svc"}}
+	if err := oauthClient.SetAuthMethod(client.AuthMethodTLSClientAuth, nil); err != nil {
+		t.Fatalf("SetAuthMethod: %v", err)
+	}
+
+	authenticator := clientauth.New(clients{"svc": oauthClient})
+
+	_, err := authenticator.Authenticate(credentials("svc", ca.IssueClient(t, "svc")))
+	if !errors.Is(err, clientauth.ErrInvalidCertificate) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, clientauth.ErrInvalidCertificate)
+	}
+}
+
+func TestAuthenticate_SelfSignedTLSClientAuth(t *testing.T) {
+	registered := mtlstest.SelfSigned(t, "device")
+
+	oauthClient := client.Client{ID: "device"}
+	if err := oauthClient.SetAuthMethod(client.AuthMethodSelfSignedTLSClientAuth, certificateJWKS(t, registered)); err != nil {
+		t.Fatalf("SetAuthMethod: %v", err)
+	}
+
+	authenticator := clientauth.New(clients{"device": oauthClient})
+
+	if _, err := authenticator.Authenticate(credentials("device", registered)); err != nil {
+		t.Fatalf("Authenticate() with the registered certificate: %v", err)
+	}
+
+	_, err := authenticator.Authenticate(credentials("device", mtlstest.SelfSigned(t, "device")))
+	if !errors.Is(err, clientauth.ErrInvalidCertificate) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, clientauth.ErrInvalidCertificate)
+	}
+}
+
+func TestSetAuthMethod_Certificates(t *testing.T) {
+	var oauthClient client.Client
+
+	if err := oauthClient.SetAuthMethod(client.AuthMethodTLSClientAuth, nil); !errors.Is(err, client.ErrTLSClientAuth) {
+		t.Fatalf("tls_client_auth without subject: error = %v, want %v", err, client.ErrTLSClientAuth)
+	}
+
+	oauthClient.TLSClientAuth = &client.TLSClientAuth{SubjectDN: "CN=svc", SANDNS: "svc.internal"}
+	if err := oauthClient.SetAuthMethod(client.AuthMethodTLSClientAuth, nil); !errors.Is(err, client.ErrTLSClientAuth) {
+		t.Fatalf("tls_client_auth with two subjects: error = %v, want %v", err, client.ErrTLSClientAuth)
+	}
+
+	jwks := `{"keys":[{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`
+	if err := oauthClient.SetAuthMethod(client.AuthMethodSelfSignedTLSClientAuth, &jwks); !errors.Is(err, client.ErrCertificateJWKS) {
+		t.Fatalf("self_signed_tls_client_auth without x5c: error = %v, want %v", err, client.ErrCertificateJWKS)
+	}
+}
@@ -0,0 +1,254 @@
+package dpop
+
+import (
+	"app/pkg/common/core/jwk"
+	"app/pkg/utils/replay"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Header carries the DPoP proof of a request.
+	Header = "DPoP"
+	// NonceHeader carries the nonce the next proof has to include.
+	NonceHeader = "DPoP-Nonce"
+	// TokenType is the token type and authorization scheme of
+	// DPoP-bound access tokens.
+	TokenType = "DPoP"
+	proofType = "dpop+jwt"
+)
+
+var (
+	ErrInvalidProof  = errors.New("dpop: invalid proof")
+	ErrProofReplayed = errors.New("dpop: proof replayed")
+	ErrUseNonce      = errors.New("dpop: nonce required")
+	ErrKeyMismatch   = errors.New("dpop: proof key does not match the token binding")
+)
+
+var algorithms = []string{
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodRS384.Alg(),
+	jwt.SigningMethodRS512.Alg(),
+	jwt.SigningMethodPS256.Alg(),
+	jwt.SigningMethodPS384.Alg(),
+	jwt.SigningMethodPS512.Alg(),
+	jwt.SigningMethodES256.Alg(),
+	jwt.SigningMethodES384.Alg(),
+	jwt.SigningMethodES512.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
+type Options struct {
+	// Lifetime bounds how far the iat of a proof may be from the current
+	// time, proofs are remembered that long to detect replays.
+	Lifetime time.Duration
+	// RequireNonce makes every proof carry a nonce issued by the server
+	// (RFC 9449, section 8).
+	RequireNonce  bool
+	NonceLifetime time.Duration
+	NonceSecret   string
+}
+
+// Verifier checks DPoP proofs (RFC 9449). Nonces are stateless, they are
+// MACs of the current time window and stay valid for one more window.
+type Verifier struct {
+	opts   Options
+	replay *replay.Cache
+	now    func() time.Time
+}
+
+func New(opts Options) *Verifier {
+	return &Verifier{
+		opts:   opts,
+		replay: replay.New(),
+		now:    time.Now,
+	}
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	HTM   string `json:"htm"`
+	HTU   string `json:"htu"`
+	ATH   string `json:"ath,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Verify checks a proof sent with a request (RFC 9449, section 4.3) and
+// returns the JWK thumbprint of the proof key. accessToken is set when
+// the proof is presented with an access token, the proof must then carry
+// its hash.
+func (v *Verifier) Verify(proof, method, uri, accessToken string) (string, error) {
+	var thumbprint string
+	var c claims
+
+	_, err := jwt.ParseWithClaims(
+		proof,
+		&c,
+		func(token *jwt.Token) (any, error) {
+			if typ, _ := token.Header["typ"].(string); typ != proofType {
+				return nil, fmt.Errorf("typ must be %s", proofType)
+			}
+
+			key, err := headerKey(token.Header["jwk"])
+			if err != nil {
+				return nil, err
+			}
+
+			if thumbprint, err = key.Thumbprint(); err != nil {
+				return nil, err
+			}
+
+			return key.PublicKey()
+		},
+		jwt.WithValidMethods(algorithms),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+
+	if c.ID == "" || c.IssuedAt == nil {
+		return "", fmt.Errorf("%w: jti and iat are required", ErrInvalidProof)
+	}
+
+	if c.HTM != method || !sameURI(c.HTU, uri) {
+		return "", fmt.Errorf("%w: htm or htu mismatch", ErrInvalidProof)
+	}
+
+	now := v.now()
+	if age := now.Sub(c.IssuedAt.Time); age > v.opts.Lifetime || age < -v.opts.Lifetime {
+		return "", fmt.Errorf("%w: iat out of range", ErrInvalidProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		ath := base64.RawURLEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(ath), []byte(c.ATH)) != 1 {
+			return "", fmt.Errorf("%w: ath mismatch", ErrInvalidProof)
+		}
+	}
+
+	if v.opts.RequireNonce && !v.validNonce(c.Nonce) {
+		return "", ErrUseNonce
+	}
+
+	if !v.replay.Use(thumbprint+":"+c.ID, c.IssuedAt.Add(v.opts.Lifetime)) {
+		return "", ErrProofReplayed
+	}
+
+	return thumbprint, nil
+}
+
+// VerifyBound checks the proof presented with a DPoP-bound access token,
+// the proof key must be the one the token is bound to.
+func (v *Verifier) VerifyBound(proof, method, uri, accessToken, jkt string) error {
+	thumbprint, err := v.Verify(proof, method, uri, accessToken)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(jkt)) != 1 {
+		return ErrKeyMismatch
+	}
+
+	return nil
+}
+
+// RequiresNonce reports whether proofs must carry a server nonce.
+func (v *Verifier) RequiresNonce() bool {
+	return v.opts.RequireNonce
+}
+
+// Nonce returns the nonce of the current time window.
+func (v *Verifier) Nonce() string {
+	return v.nonce(v.window())
+}
+
+func (v *Verifier) validNonce(nonce string) bool {
+	window := v.window()
+	for _, w := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(nonce), []byte(v.nonce(w))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) window() int64 {
+	return v.now().Unix() / int64(max(v.opts.NonceLifetime.Seconds(), 1))
+}
+
+func (v *Verifier) nonce(window int64) string {
+	w := strconv.FormatInt(window, 36)
+	mac := hmac.New(sha256.New, []byte(v.opts.NonceSecret))
+	mac.Write([]byte("dpop-nonce:" + w))
+	return w + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// headerKey reads the public key from the jwk header of a proof, private
+// key members are rejected.
+func headerKey(header any) (jwk.Key, error) {
+	raw, ok := header.(map[string]any)
+	if !ok {
+		return jwk.Key{}, errors.New("jwk header is required")
+	}
+
+	for _, private := range []string{"d", "p", "q", "dp", "dq", "qi", "k"} {
+		if _, ok := raw[private]; ok {
+			return jwk.Key{}, errors.New("jwk header must not contain a private key")
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return jwk.Key{}, err
+	}
+
+	var key jwk.Key
+	if err := json.Unmarshal(data, &key); err != nil {
+		return jwk.Key{}, err
+	}
+
+	return key, nil
+}
+
+// sameURI compares htu with the request URI without query and fragment
+// (RFC 9449, section 4.3).
+func sameURI(htu, uri string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Host, b.Host) &&
+		a.EscapedPath() == b.EscapedPath()
+}
+
+type ctxThumbprint struct{}
+
+func ContextWithThumbprint(ctx context.Context, jkt string) context.Context {
+	return context.WithValue(ctx, ctxThumbprint{}, jkt)
+}
+
+// ThumbprintFromContext returns the thumbprint of the key of a verified
+// DPoP proof sent with the current request.
+func ThumbprintFromContext(ctx context.Context) (string, bool) {
+	jkt, ok := ctx.Value(ctxThumbprint{}).(string)
+	return jkt, ok
+}
@@ -0,0 +1,263 @@
+package dpop_test
+
+import (
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/jwk"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"testing"
+	"time"
+)
+
+const (
+	method      = "POST"
+	uri         = "https://sso.test/oauth/token"
+	accessToken = "access-token"
+	lifetime    = time.Minute
+)
+
+type signer struct {
+	key ed25519.PrivateKey
+	jwk map[string]any
+	jkt string
+}
+
+func newSigner(t *testing.T) signer {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := jwk.Key{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public)}
+	jkt, err := key.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer{
+		key: private,
+		jwk: map[string]any{"kty": key.Kty, "crv": key.Crv, "x": key.X},
+		jkt: jkt,
+	}
+}
+
+// proof describes a DPoP proof, the zero value of each field is replaced
+// by the one of a valid proof.
+type proof struct {
+	method   jwt.SigningMethod
+	typ      string
+	jwk      map[string]any
+	htm      string
+	htu      string
+	jti      string
+	iat      time.Time
+	ath      string
+	nonce    string
+	noJTI    bool
+	noATH    bool
+	signWith any
+}
+
+func (s signer) sign(t *testing.T, p proof) string {
+	t.Helper()
+
+	if p.method == nil {
+		p.method = jwt.SigningMethodEdDSA
+	}
+	if p.typ == "" {
+		p.typ = "dpop+jwt"
+	}
+	if p.jwk == nil {
+		p.jwk = s.jwk
+	}
+	if p.htm == "" {
+		p.htm = method
+	}
+	if p.htu == "" {
+		p.htu = uri
+	}
+	if p.jti == "" && !p.noJTI {
+		jti := make([]byte, 16)
+		_, _ = rand.Read(jti)
+		p.jti = base64.RawURLEncoding.EncodeToString(jti)
+	}
+	if p.iat.IsZero() {
+		p.iat = time.Now()
+	}
+	if p.ath == "" && !p.noATH {
+		sum := sha256.Sum256([]byte(accessToken))
+		p.ath = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	if p.signWith == nil {
+		p.signWith = s.key
+	}
+
+	claims := jwt.MapClaims{"htm": p.htm, "htu": p.htu, "iat": p.iat.Unix()}
+	if p.jti != "" {
+		claims["jti"] = p.jti
+	}
+	if p.ath != "" {
+		claims["ath"] = p.ath
+	}
+	if p.nonce != "" {
+		claims["nonce"] = p.nonce
+	}
+
+	token := jwt.NewWithClaims(p.method, claims)
+	token.Header["typ"] = p.typ
+	token.Header["jwk"] = p.jwk
+
+	signed, err := token.SignedString(p.signWith)
+	if err != nil {
+		t.Fatalf("sign proof: %v", err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	s := newSigner(t)
+
+	withPrivateKey := map[string]any{"d": "c2VjcmV0"}
+	for k, v := range s.jwk {
+		withPrivateKey[k] = v
+	}
+
+	tests := []struct {
+		name         string
+		proof        proof
+		uri          string
+		withoutToken bool
+		wantErr      error
+	}{
+		{name: "Valid"},
+		{name: "Valid without an Access Token", proof: proof{noATH: true}, withoutToken: true},
+		{name: "Query and Fragment ignored", uri: uri + "?state=1#top"},
+		{name: "Case-insensitive Scheme and Host", proof: proof{htu: "HTTPS://SSO.TEST/oauth/token"}},
+		{name: "Wrong htm", proof: proof{htm: "GET"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Wrong htu Path", proof: proof{htu: "https://sso.test/oauth/authorize"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Wrong htu Host", proof: proof{htu: "https://evil.test/oauth/token"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Wrong htu Scheme", proof: proof{htu: "http://sso.test/oauth/token"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Stale iat", proof: proof{iat: time.Now().Add(-2 * lifetime)}, wantErr: dpop.ErrInvalidProof},
+		{name: "Future iat", proof: proof{iat: time.Now().Add(2 * lifetime)}, wantErr: dpop.ErrInvalidProof},
+		{name: "Missing jti", proof: proof{noJTI: true}, wantErr: dpop.ErrInvalidProof},
+		{name: "ath Mismatch", proof: proof{ath: "bm90LXRoZS1oYXNo"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Missing ath", proof: proof{noATH: true}, wantErr: dpop.ErrInvalidProof},
+		{name: "Wrong typ", proof: proof{typ: "JWT"}, wantErr: dpop.ErrInvalidProof},
+		{name: "Missing jwk", proof: proof{jwk: map[string]any{}}, wantErr: dpop.ErrInvalidProof},
+		{name: "Private Key in jwk", proof: proof{jwk: withPrivateKey}, wantErr: dpop.ErrInvalidProof},
+		{
+			name:    "Signed by another Key",
+			proof:   proof{signWith: newSigner(t).key},
+			wantErr: dpop.ErrInvalidProof,
+		},
+		{
+			name:    "alg none",
+			proof:   proof{method: jwt.SigningMethodNone, signWith: jwt.UnsafeAllowNoneSignatureType},
+			wantErr: dpop.ErrInvalidProof,
+		},
+		{
+			name:    "alg HS256",
+			proof:   proof{method: jwt.SigningMethodHS256, signWith: []byte("secret")},
+			wantErr: dpop.ErrInvalidProof,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := dpop.New(dpop.Options{Lifetime: lifetime})
+
+			u, aT := uri, accessToken
+			if tt.uri != "" {
+				u = tt.uri
+			}
+			if tt.withoutToken {
+				aT = ""
+			}
+
+			jkt, err := v.Verify(s.sign(t, tt.proof), method, u, aT)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && jkt != s.jkt {
+				t.Fatalf("expected thumbprint %s, got %s", s.jkt, jkt)
+			}
+		})
+	}
+}
+
+func TestVerify_Replay(t *testing.T) {
+	s := newSigner(t)
+	v := dpop.New(dpop.Options{Lifetime: lifetime})
+
+	p := s.sign(t, proof{jti: "jti-1"})
+	if _, err := v.Verify(p, method, uri, accessToken); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if _, err := v.Verify(p, method, uri, accessToken); !errors.Is(err, dpop.ErrProofReplayed) {
+		t.Fatalf("expected %v, got %v", dpop.ErrProofReplayed, err)
+	}
+
+	// the jti is remembered per key
+	if _, err := v.Verify(newSigner(t).sign(t, proof{jti: "jti-1"}), method, uri, accessToken); err != nil {
+		t.Fatalf("same jti of another key: %v", err)
+	}
+}
+
+func TestVerify_Nonce(t *testing.T) {
+	s := newSigner(t)
+	opts := dpop.Options{Lifetime: lifetime, RequireNonce: true, NonceLifetime: time.Minute, NonceSecret: "secret"}
+	v := dpop.New(opts)
+
+	other := opts
+	other.NonceSecret = "other"
+
+	tests := []struct {
+		name    string
+		nonce   string
+		wantErr error
+	}{
+		{name: "Current Nonce", nonce: v.Nonce()},
+		{name: "Missing Nonce", wantErr: dpop.ErrUseNonce},
+		{name: "Forged Nonce", nonce: "0.bm90LWEtbWFj", wantErr: dpop.ErrUseNonce},
+		{name: "Nonce of another Secret", nonce: dpop.New(other).Nonce(), wantErr: dpop.ErrUseNonce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(s.sign(t, proof{nonce: tt.nonce}), method, uri, accessToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyBound(t *testing.T) {
+	s := newSigner(t)
+
+	tests := []struct {
+		name    string
+		jkt     string
+		wantErr error
+	}{
+		{name: "Bound Key", jkt: s.jkt},
+		{name: "Another Key", jkt: newSigner(t).jkt, wantErr: dpop.ErrKeyMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := dpop.New(dpop.Options{Lifetime: lifetime})
+
+			err := v.VerifyBound(s.sign(t, proof{}), method, uri, accessToken, tt.jkt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
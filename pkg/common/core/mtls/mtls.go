@@ -0,0 +1,125 @@
+package mtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Client certificate modes of a listener. Self-signed client certificates
+// (self_signed_tls_client_auth) are only accepted by the modes that don't
+// verify the chain, tls_client_auth chains are verified by the client
+// authenticator against the client CAs.
+const (
+	ClientAuthNone             = "none"
+	ClientAuthRequest          = "request"
+	ClientAuthRequire          = "require"
+	ClientAuthVerifyIfGiven    = "verify_if_given"
+	ClientAuthRequireAndVerify = "require_and_verify"
+)
+
+var (
+	ErrInvalidClientAuth = errors.New("mtls: invalid client auth mode")
+	ErrClientCARequired  = errors.New("mtls: client ca file is required to verify client certificates")
+	ErrInvalidCA         = errors.New("mtls: no certificates found in client ca file")
+)
+
+type Options struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	ClientAuth   string
+}
+
+// ServerConfig builds the TLS configuration of a listener, it returns nil
+// when no certificate is configured and the listener stays plaintext.
+func ServerConfig(opts Options) (*tls.Config, error) {
+	if opts.CertFile == "" && opts.KeyFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: load key pair: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch opts.ClientAuth {
+	case "", ClientAuthNone:
+		cfg.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		cfg.ClientAuth = tls.RequestClientCert
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	case ClientAuthVerifyIfGiven:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequireAndVerify:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidClientAuth, opts.ClientAuth)
+	}
+
+	var clientCAs *x509.CertPool
+	if opts.ClientCAFile != "" {
+		if clientCAs, err = LoadCertPool(opts.ClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// the CAs are only announced when the chain is verified, clients pick
+	// the certificate they send from the announced CAs and would hold back
+	// a self-signed one
+	if cfg.ClientAuth == tls.VerifyClientCertIfGiven || cfg.ClientAuth == tls.RequireAndVerifyClientCert {
+		if clientCAs == nil {
+			return nil, ErrClientCARequired
+		}
+		cfg.ClientCAs = clientCAs
+	}
+
+	return cfg, nil
+}
+
+// LoadCertPool reads the PEM encoded certificates of file into a pool.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: read client ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, ErrInvalidCA
+	}
+
+	return pool, nil
+}
+
+// Thumbprint returns the base64url encoded SHA-256 hash of the DER encoded
+// certificate, the x5t#S256 confirmation of certificate-bound tokens
+// (RFC 8705, section 3.1).
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type ctxThumbprint struct{}
+
+func ContextWithThumbprint(ctx context.Context, x5t string) context.Context {
+	return context.WithValue(ctx, ctxThumbprint{}, x5t)
+}
+
+// ThumbprintFromContext returns the thumbprint of the client certificate
+// presented with the current request.
+func ThumbprintFromContext(ctx context.Context) (string, bool) {
+	x5t, ok := ctx.Value(ctxThumbprint{}).(string)
+	return x5t, ok
+}
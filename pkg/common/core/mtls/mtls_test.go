@@ -0,0 +1,191 @@
+package mtls_test
+
+import (
+	"app/pkg/common/core/mtls"
+	"app/pkg/common/core/mtls/mtlstest"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type listener struct {
+	ca       *mtlstest.Certificate
+	clientCA *mtlstest.Certificate
+	opts     mtls.Options
+}
+
+func newListener(t *testing.T, clientAuth string) listener {
+	t.Helper()
+
+	dir := t.TempDir()
+	ca := mtlstest.NewCA(t, "server ca")
+	clientCA := mtlstest.NewCA(t, "client ca")
+
+	certFile, keyFile := ca.IssueServer(t).WriteFiles(t, dir, "server")
+	clientCAFile, _ := clientCA.WriteFiles(t, dir, "client-ca")
+
+	return listener{
+		ca:       ca,
+		clientCA: clientCA,
+		opts: mtls.Options{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: clientCAFile,
+			ClientAuth:   clientAuth,
+		},
+	}
+}
+
+// serve starts a TLS server that answers with the thumbprint of the
+// client certificate.
+func serve(t *testing.T, opts mtls.Options) *httptest.Server {
+	t.Helper()
+
+	cfg, err := mtls.ServerConfig(opts)
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			_, _ = io.WriteString(w, mtls.Thumbprint(r.TLS.PeerCertificates[0]))
+		}
+	}))
+	srv.TLS = cfg
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func get(srv *httptest.Server, ca *mtlstest.Certificate, cert *mtlstest.Certificate) (string, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+
+	cfg := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{cert.TLSCertificate()}
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	defer httpClient.CloseIdleConnections()
+
+	res, err := httpClient.Get(srv.URL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestServerConfig_Plaintext(t *testing.T) {
+	cfg, err := mtls.ServerConfig(mtls.Options{})
+	if err != nil || cfg != nil {
+		t.Fatalf("ServerConfig() = %v, %v, want nil, nil", cfg, err)
+	}
+}
+
+func TestServerConfig_Invalid(t *testing.T) {
+	l := newListener(t, "sometimes")
+	if _, err := mtls.ServerConfig(l.opts); !errors.Is(err, mtls.ErrInvalidClientAuth) {
+		t.Fatalf("ServerConfig() error = %v, want %v", err, mtls.ErrInvalidClientAuth)
+	}
+
+	l = newListener(t, mtls.ClientAuthRequireAndVerify)
+	l.opts.ClientCAFile = ""
+	if _, err := mtls.ServerConfig(l.opts); !errors.Is(err, mtls.ErrClientCARequired) {
+		t.Fatalf("ServerConfig() error = %v, want %v", err, mtls.ErrClientCARequired)
+	}
+
+	l.opts.ClientCAFile = filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(l.opts.ClientCAFile, []byte("no certificates"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mtls.ServerConfig(l.opts); !errors.Is(err, mtls.ErrInvalidCA) {
+		t.Fatalf("ServerConfig() error = %v, want %v", err, mtls.ErrInvalidCA)
+	}
+}
+
+func TestServerConfig_ClientAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientAuth string
+		cert       func(l listener) *mtlstest.Certificate
+		wantErr    bool
+	}{
+		{
+			name:       "none ignores the certificate",
+			clientAuth: mtls.ClientAuthNone,
+			cert:       func(l listener) *mtlstest.Certificate { return l.clientCA.IssueClient(t, "svc") },
+		},
+		{
+			name:       "request accepts no certificate",
+			clientAuth: mtls.ClientAuthRequest,
+			cert:       func(l listener) *mtlstest.Certificate { return nil },
+		},
+		{
+			name:       "request accepts a self-signed certificate",
+			clientAuth: mtls.ClientAuthRequest,
+			cert:       func(l listener) *mtlstest.Certificate { return mtlstest.SelfSigned(t, "svc") },
+		},
+		{
+			name:       "require rejects no certificate",
+			clientAuth: mtls.ClientAuthRequire,
+			cert:       func(l listener) *mtlstest.Certificate { return nil },
+			wantErr:    true,
+		},
+		{
+			name:       "require and verify accepts a ca issued certificate",
+			clientAuth: mtls.ClientAuthRequireAndVerify,
+			cert:       func(l listener) *mtlstest.Certificate { return l.clientCA.IssueClient(t, "svc") },
+		},
+		{
+			name:       "require and verify rejects a self-signed certificate",
+			clientAuth: mtls.ClientAuthRequireAndVerify,
+			cert:       func(l listener) *mtlstest.Certificate { return mtlstest.SelfSigned(t, "svc") },
+			wantErr:    true,
+		},
+		{
+			name:       "verify if given accepts no certificate",
+			clientAuth: mtls.ClientAuthVerifyIfGiven,
+			cert:       func(l listener) *mtlstest.Certificate { return nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newListener(t, tt.clientAuth)
+			srv := serve(t, l.opts)
+			cert := tt.cert(l)
+
+			got, err := get(srv, l.ca, cert)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request succeeded, want handshake error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+
+			want := ""
+			if cert != nil && tt.clientAuth != mtls.ClientAuthNone {
+				want = mtls.Thumbprint(cert.Cert)
+			}
+			if got != want {
+				t.Fatalf("thumbprint = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+// Package oidc is the relying party side of OpenID Connect: it signs
+// users in with an upstream provider through the authorization code flow
+// with PKCE and validates the ID tokens it returns.
+package oidc
+
+import (
+	"app/pkg/common/core/jwk"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const discoveryPath = "/.well-known/openid-configuration"
+
+// keysRefreshInterval limits how often the JWKS is fetched again for an
+// unknown kid, so tokens with random kids can't hammer the provider.
+// The first unknown kid after a quiet period is always looked up.
+const keysRefreshInterval = time.Minute
+
+var (
+	ErrDiscovery      = errors.New("oidc: discovery failed")
+	ErrExchange       = errors.New("oidc: code exchange failed")
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+)
+
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config is the registration of this server as a client of an upstream
+// provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Metadata is the part of the provider metadata (OpenID Connect Discovery
+// 1.0, section 3) the flow relies on.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Tokens is the token response of the provider.
+type Tokens struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Claims are the ID token claims (OpenID Connect Core 1.0, sections 2
+// and 5.1) used to find or provision the local user.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email"`
+	EmailVerified   Bool   `json:"email_verified"`
+	Name            string `json:"name"`
+}
+
+// Bool is a boolean claim, some providers send it as a string.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("oidc: invalid boolean %s", data)
+	}
+	return nil
+}
+
+// Provider is an upstream OpenID Connect provider. The metadata is
+// discovered on first use and the keys are fetched again when an ID token
+// is signed with an unknown key.
+type Provider struct {
+	cfg Config
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        *jwk.Set
+	refreshedAt time.Time
+}
+
+func New(cfg Config) *Provider {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{cfg: cfg}
+}
+
+// Metadata discovers the provider metadata, the issuer it announces must
+// be the configured one (OpenID Connect Discovery 1.0, section 4.3).
+func (p *Provider) Metadata(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	var md Metadata
+	if err := p.get(ctx, strings.TrimSuffix(p.cfg.Issuer, "/")+discoveryPath, &md); err != nil {
+		return md, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+
+	if md.Issuer != p.cfg.Issuer {
+		return md, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, md.Issuer, p.cfg.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return md, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	p.metadata = &md
+	return md, nil
+}
+
+// AuthCodeURL returns the authorization request the user-agent is sent
+// to, the code challenge is derived from the verifier (RFC 7636,
+// section 4.2).
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string, opts ...AuthOption) (string, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	endpoint, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid authorization endpoint", ErrDiscovery)
+	}
+
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	for _, opt := range opts {
+		opt(query)
+	}
+	endpoint.RawQuery = query.Encode()
+
+	return endpoint.String(), nil
+}
+
+// AuthOption sets an additional parameter of the authorization request.
+type AuthOption func(query url.Values)
+
+// WithPrompt asks the provider how to interact with the user, "login"
+// makes the user authenticate again (OpenID Connect Core, section 3.1.2.1).
+func WithPrompt(prompt string) AuthOption {
+	return func(query url.Values) {
+		query.Set("prompt", prompt)
+	}
+}
+
+// Exchange redeems the authorization code at the token endpoint, the
+// client authenticates with client_secret_basic when it has a secret.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (Tokens, error) {
+	var tokens Tokens
+
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return tokens, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	res, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(body, &oauthErr)
+		return tokens, fmt.Errorf("%w: status %d %s", ErrExchange, res.StatusCode, oauthErr.Error)
+	}
+
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	if tokens.IDToken == "" {
+		return tokens, fmt.Errorf("%w: no id_token in the response", ErrExchange)
+	}
+
+	return tokens, nil
+}
+
+// VerifyIDToken validates an ID token (OpenID Connect Core 1.0, section
+// 3.1.3.7): the signature against the JWKS of the provider, the issuer,
+// the audience and authorized party, the expiry and the nonce of the
+// authorization request.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	md, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(
+		raw,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			return p.key(ctx, md, token)
+		},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, fmt.Errorf("%w: azp does not match the client", ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// key resolves the verification key of an ID token, the JWKS is fetched
+// again when the kid is unknown, keys are rotated by the provider.
+func (p *Provider) key(ctx context.Context, md Metadata, token *jwt.Token) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key, err := p.keys.KeyFunc(token); err == nil {
+			return key, nil
+		}
+		if time.Since(p.refreshedAt) < keysRefreshInterval {
+			return nil, jwk.ErrKeyNotFound
+		}
+		p.refreshedAt = time.Now()
+	}
+
+	var data json.RawMessage
+	if err := p.get(ctx, md.JWKSURI, &data); err != nil {
+		return nil, err
+	}
+
+	keys, err := jwk.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+
+	return p.keys.KeyFunc(token)
+}
+
+func (p *Provider) get(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, res.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// NewCodeVerifier returns a PKCE code verifier of 43 characters
+// (RFC 7636, section 4.1).
+func NewCodeVerifier() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// CodeChallenge returns the S256 code challenge of a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
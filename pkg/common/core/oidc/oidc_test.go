@@ -0,0 +1,207 @@
+package oidc_test
+
+import (
+	"app/pkg/common/core/oidc"
+	"app/pkg/common/core/oidc/oidctest"
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const redirectURL = "https://sso.test/oauth/federation/corporate/callback"
+
+var alice = oidctest.Subject{Sub: "alice", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+func newProvider(t *testing.T, upstream *oidctest.Provider) *oidc.Provider {
+	return oidc.New(oidc.Config{
+		Issuer:       upstream.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+		HTTPClient:   upstream.Server.Client(),
+	})
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	upstream := oidctest.NewProvider(t)
+	provider := newProvider(t, upstream)
+	ctx := context.Background()
+
+	verifier := oidc.NewCodeVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	query := mustParse(t, authURL).Query()
+	if query.Get("scope") != "openid email profile" || query.Get("redirect_uri") != redirectURL {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	callback := upstream.Authorize(authURL, alice)
+	if callback.Query().Get("state") != "state-1" {
+		t.Fatalf("state = %q, want state-1", callback.Query().Get("state"))
+	}
+
+	if _, err := provider.Exchange(ctx, callback.Query().Get("code"), oidc.NewCodeVerifier()); !errors.Is(err, oidc.ErrExchange) {
+		t.Fatalf("Exchange with another verifier: err = %v, want ErrExchange", err)
+	}
+
+	callback = upstream.Authorize(authURL, alice)
+	tokens, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.Subject != alice.Sub || claims.Email != alice.Email || !bool(claims.EmailVerified) {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	upstream := oidctest.NewProvider(t)
+	provider := newProvider(t, upstream)
+	other := oidctest.NewProvider(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		idToken func() string
+		nonce   string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			idToken: func() string { return upstream.IDToken(alice, "n", nil) },
+			nonce:   "n",
+		},
+		{
+			name: "email_verified as a string",
+			idToken: func() string {
+				return upstream.IDToken(alice, "n", jwt.MapClaims{"email_verified": "true"})
+			},
+			nonce: "n",
+		},
+		{
+			name:    "nonce mismatch",
+			idToken: func() string { return upstream.IDToken(alice, "n", nil) },
+			nonce:   "other",
+			wantErr: true,
+		},
+		{
+			name:    "other audience",
+			idToken: func() string { return upstream.IDToken(alice, "n", jwt.MapClaims{"aud": "other-client"}) },
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "several audiences without azp",
+			idToken: func() string {
+				return upstream.IDToken(alice, "n", jwt.MapClaims{"aud": []string{oidctest.ClientID, "other-client"}})
+			},
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "several audiences with azp",
+			idToken: func() string {
+				return upstream.IDToken(alice, "n", jwt.MapClaims{
+					"aud": []string{oidctest.ClientID, "other-client"},
+					"azp": oidctest.ClientID,
+				})
+			},
+			nonce: "n",
+		},
+		{
+			name:    "other issuer",
+			idToken: func() string { return upstream.IDToken(alice, "n", jwt.MapClaims{"iss": other.Issuer()}) },
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			idToken: func() string {
+				return upstream.IDToken(alice, "n", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})
+			},
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name:    "without subject",
+			idToken: func() string { return upstream.IDToken(oidctest.Subject{}, "n", nil) },
+			nonce:   "n",
+			wantErr: true,
+		},
+		{
+			name:    "signed by another provider",
+			idToken: func() string { return other.IDToken(alice, "n", jwt.MapClaims{"iss": upstream.Issuer()}) },
+			nonce:   "n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, tt.idToken(), tt.nonce)
+			if tt.wantErr && !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestProvider_KeyRotation(t *testing.T) {
+	upstream := oidctest.NewProvider(t)
+	provider := newProvider(t, upstream)
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, upstream.IDToken(alice, "n", nil), "n"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	// an unknown kid makes the provider fetch the rotated keys
+	upstream.Rotate()
+	if _, err := provider.VerifyIDToken(ctx, upstream.IDToken(alice, "n", nil), "n"); err != nil {
+		t.Fatalf("VerifyIDToken with the rotated key: %v", err)
+	}
+
+	// the keys are not fetched again before the refresh interval
+	upstream.Rotate()
+	if _, err := provider.VerifyIDToken(ctx, upstream.IDToken(alice, "n", nil), "n"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("VerifyIDToken right after a second rotation: err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	upstream := oidctest.NewProvider(t)
+
+	provider := oidc.New(oidc.Config{
+		Issuer:     upstream.Issuer() + "/",
+		ClientID:   oidctest.ClientID,
+		HTTPClient: upstream.Server.Client(),
+	})
+
+	if _, err := provider.Metadata(context.Background()); !errors.Is(err, oidc.ErrDiscovery) {
+		t.Fatalf("err = %v, want ErrDiscovery", err)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %s: %v", raw, err)
+	}
+	return u
+}
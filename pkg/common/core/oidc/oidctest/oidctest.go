@@ -0,0 +1,238 @@
+// Package oidctest serves a fake OpenID Connect provider for tests of the
+// relying party side. The user consents to every authorization request
+// made through Authorize.
+package oidctest
+
+import (
+	"app/pkg/common/core/oidc"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	ClientID     = "sso"
+	ClientSecret = "upstream-secret"
+)
+
+// Subject is the user signing in at the provider.
+type Subject struct {
+	Sub           string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type grant struct {
+	subject       Subject
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// Provider is a fake OpenID Connect provider served by httptest.
+type Provider struct {
+	Server *httptest.Server
+
+	t      testing.TB
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	codes  map[string]grant
+	serial int
+}
+
+// NewProvider starts a provider, it is closed when the test ends.
+func NewProvider(t testing.TB) *Provider {
+	t.Helper()
+
+	p := &Provider{t: t, codes: map[string]grant{}}
+	p.Rotate()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+// Issuer returns the issuer identifier of the provider.
+func (p *Provider) Issuer() string {
+	return p.Server.URL
+}
+
+// Rotate replaces the signing key, the previous key is no longer
+// published.
+func (p *Provider) Rotate() {
+	p.t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		p.t.Fatalf("generate key: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.serial++
+	p.key = key
+	p.kid = "key-" + strconv.Itoa(p.serial)
+}
+
+// Authorize consents to the authorization request the relying party sent
+// the user-agent to and returns the redirect back to it with the code.
+func (p *Provider) Authorize(authURL string, subject Subject) *url.URL {
+	p.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("parse authorization request: %v", err)
+	}
+	query := u.Query()
+
+	if query.Get("client_id") != ClientID || query.Get("response_type") != "code" {
+		p.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		p.t.Fatalf("authorization request without PKCE %s", authURL)
+	}
+
+	code := oidc.NewCodeVerifier()
+
+	p.mu.Lock()
+	p.codes[code] = grant{
+		subject:       subject,
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		p.t.Fatalf("parse redirect_uri: %v", err)
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	return redirect
+}
+
+// IDToken signs an ID token for the relying party with the current key,
+// claims override the defaults.
+func (p *Provider) IDToken(subject Subject, nonce string, claims jwt.MapClaims) string {
+	p.t.Helper()
+
+	now := time.Now()
+	defaults := jwt.MapClaims{
+		"iss":            p.Issuer(),
+		"sub":            subject.Sub,
+		"aud":            ClientID,
+		"exp":            now.Add(time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          subject.Email,
+		"email_verified": subject.EmailVerified,
+		"name":           subject.Name,
+	}
+	for name, value := range claims {
+		defaults[name] = value
+	}
+
+	p.mu.Lock()
+	key, kid := p.key, p.kid
+	p.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, defaults)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		p.t.Fatalf("sign id token: %v", err)
+	}
+	return signed
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/jwks",
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	key, kid := p.key.PublicKey, p.kid
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// token redeems a code once, the client authenticates with
+// client_secret_basic and proves possession of the PKCE code verifier.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != ClientID || secret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	g, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok ||
+		g.redirectURI != r.PostForm.Get("redirect_uri") ||
+		g.codeChallenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": oidc.NewCodeVerifier(),
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     p.IDToken(g.subject, g.nonce, nil),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
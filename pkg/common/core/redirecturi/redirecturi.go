@@ -0,0 +1,110 @@
+package redirecturi
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrInvalid       = errors.New("redirect uri must be an absolute uri")
+	ErrFragment      = errors.New("redirect uri must not contain a fragment")
+	ErrInsecure      = errors.New("redirect uri must use https")
+	ErrNotRegistered = errors.New("redirect uri is not registered for the client")
+	ErrAmbiguous     = errors.New("redirect uri is required when several are registered")
+)
+
+// Validate checks a redirect URI before it is registered. https is
+// required unless allowHTTP is set, except for loopback redirects and
+// private-use schemes of native apps (RFC 8252, sections 7.1 and 7.3).
+func Validate(raw string, allowHTTP bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return ErrInvalid
+	}
+
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return ErrFragment
+	}
+
+	switch scheme := strings.ToLower(u.Scheme); {
+	case scheme == "https":
+		if u.Host == "" {
+			return ErrInvalid
+		}
+	case scheme == "http":
+		if u.Host == "" {
+			return ErrInvalid
+		}
+		if !allowHTTP && !IsLoopback(u) {
+			return ErrInsecure
+		}
+	case strings.Contains(scheme, "."):
+		// private-use scheme such as com.example.app:/callback
+	default:
+		return ErrInsecure
+	}
+
+	return nil
+}
+
+// IsLoopback reports whether u is an http redirect to a loopback IP
+// literal, the only case where the port may vary (RFC 8252, section 7.3).
+func IsLoopback(u *url.URL) bool {
+	if !strings.EqualFold(u.Scheme, "http") {
+		return false
+	}
+
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// Match reports whether requested is one of the registered URIs. The
+// comparison is an exact string match, only the port of loopback
+// redirects is ignored.
+func Match(registered []string, requested string) bool {
+	for _, uri := range registered {
+		if uri == requested {
+			return true
+		}
+	}
+
+	req, err := url.Parse(requested)
+	if err != nil || !IsLoopback(req) {
+		return false
+	}
+
+	for _, uri := range registered {
+		reg, err := url.Parse(uri)
+		if err != nil || !IsLoopback(reg) {
+			continue
+		}
+
+		if reg.Hostname() == req.Hostname() &&
+			reg.EscapedPath() == req.EscapedPath() &&
+			reg.RawQuery == req.RawQuery &&
+			reg.User.String() == req.User.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve returns the URI the user-agent is redirected to: requested when
+// it is registered, or the only registered URI when none was requested.
+func Resolve(registered []string, requested string) (string, error) {
+	if requested == "" {
+		if len(registered) == 1 {
+			return registered[0], nil
+		}
+		return "", ErrAmbiguous
+	}
+
+	if !Match(registered, requested) {
+		return "", ErrNotRegistered
+	}
+
+	return requested, nil
+}
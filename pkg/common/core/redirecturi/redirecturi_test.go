@@ -0,0 +1,121 @@
+package redirecturi_test
+
+import (
+	"app/pkg/common/core/redirecturi"
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		allowHTTP bool
+		wantErr   error
+	}{
+		{name: "https", uri: "https://app.example.com/callback"},
+		{name: "https with Query", uri: "https://app.example.com/callback?tenant=1"},
+		{name: "Relative", uri: "/callback", wantErr: redirecturi.ErrInvalid},
+		{name: "Empty", uri: "", wantErr: redirecturi.ErrInvalid},
+		{name: "https without Host", uri: "https:/callback", wantErr: redirecturi.ErrInvalid},
+		{name: "Fragment", uri: "https://app.example.com/callback#top", wantErr: redirecturi.ErrFragment},
+		{name: "Empty Fragment", uri: "https://app.example.com/callback#", wantErr: redirecturi.ErrFragment},
+		{name: "http on a public Host", uri: "http://app.example.com/callback", wantErr: redirecturi.ErrInsecure},
+		{name: "http on a public Host allowed", uri: "http://app.example.com/callback", allowHTTP: true},
+		{name: "http on localhost", uri: "http://localhost:8080/callback", wantErr: redirecturi.ErrInsecure},
+		{name: "http on IPv4 Loopback", uri: "http://127.0.0.1:8080/callback"},
+		{name: "http on IPv6 Loopback", uri: "http://[::1]:8080/callback"},
+		{name: "http without Host", uri: "http:/callback", allowHTTP: true, wantErr: redirecturi.ErrInvalid},
+		{name: "Private-use Scheme", uri: "com.example.app:/callback"},
+		{name: "Custom Scheme without a Domain", uri: "myapp://callback", wantErr: redirecturi.ErrInsecure},
+		{name: "javascript Scheme", uri: "javascript:alert(1)", wantErr: redirecturi.ErrInsecure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := redirecturi.Validate(tt.uri, tt.allowHTTP); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	registered := []string{
+		"https://app.example.com/callback",
+		"http://127.0.0.1/native?app=1",
+		"http://[::1]/native",
+		"com.example.app:/callback",
+	}
+
+	tests := []struct {
+		name      string
+		requested string
+		want      bool
+	}{
+		{name: "Exact", requested: "https://app.example.com/callback", want: true},
+		{name: "Other Path", requested: "https://app.example.com/callback/other"},
+		{name: "Trailing Slash", requested: "https://app.example.com/callback/"},
+		{name: "Added Query", requested: "https://app.example.com/callback?next=/admin"},
+		{name: "Other Port", requested: "https://app.example.com:8443/callback"},
+		{name: "Userinfo", requested: "https://evil@app.example.com/callback"},
+		{name: "Fragment", requested: "https://app.example.com/callback#top"},
+		{name: "Case of the Host", requested: "https://APP.example.com/callback"},
+		{name: "Loopback with another Port", requested: "http://127.0.0.1:51004/native?app=1", want: true},
+		{name: "IPv6 Loopback with another Port", requested: "http://[::1]:51004/native", want: true},
+		{name: "Loopback with another Path", requested: "http://127.0.0.1:51004/other?app=1"},
+		{name: "Loopback with another Query", requested: "http://127.0.0.1:51004/native?app=2"},
+		{name: "Loopback with Userinfo", requested: "http://evil@127.0.0.1:51004/native?app=1"},
+		{name: "Loopback with another Address", requested: "http://127.0.0.2:51004/native?app=1"},
+		{name: "localhost instead of the Loopback IP", requested: "http://localhost:51004/native?app=1"},
+		{name: "https on the Loopback IP", requested: "https://127.0.0.1:51004/native?app=1"},
+		{name: "Private-use Scheme", requested: "com.example.app:/callback", want: true},
+		{name: "Private-use Scheme with another Path", requested: "com.example.app:/other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redirecturi.Match(registered, tt.requested); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	one := []string{"https://app.example.com/callback"}
+	two := []string{"https://app.example.com/callback", "https://app.example.com/other"}
+
+	tests := []struct {
+		name       string
+		registered []string
+		requested  string
+		want       string
+		wantErr    error
+	}{
+		{name: "Only registered URI", registered: one, want: one[0]},
+		{name: "Several registered URIs", registered: two, wantErr: redirecturi.ErrAmbiguous},
+		{name: "None registered", wantErr: redirecturi.ErrAmbiguous},
+		{name: "Requested", registered: two, requested: two[1], want: two[1]},
+		{name: "Not registered", registered: one, requested: "https://evil.example.com/callback", wantErr: redirecturi.ErrNotRegistered},
+		{name: "Requested without Registrations", requested: one[0], wantErr: redirecturi.ErrNotRegistered},
+		{
+			name:       "Loopback keeps the requested Port",
+			registered: []string{"http://127.0.0.1/native"},
+			requested:  "http://127.0.0.1:51004/native",
+			want:       "http://127.0.0.1:51004/native",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := redirecturi.Resolve(tt.registered, tt.requested)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,594 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a parsed filter (RFC 7644, section 3.4.2.2).
+type Expr interface {
+	expr()
+}
+
+// Path is an attribute path, the schema URN of core attributes is
+// dropped. Attribute names are case-insensitive.
+type Path struct {
+	Attr string
+	Sub  string
+}
+
+func (p Path) String() string {
+	if p.Sub == "" {
+		return p.Attr
+	}
+	return p.Attr + "." + p.Sub
+}
+
+// key is the lowercase name of the path attributes are looked up with.
+func (p Path) key() string {
+	return strings.ToLower(p.String())
+}
+
+// Compare is an attribute expression, Op is lowercase and Value is nil
+// for "pr".
+type Compare struct {
+	Path  Path
+	Op    string
+	Value any
+}
+
+// Logical joins two expressions with "and" or "or".
+type Logical struct {
+	Op          string
+	Left, Right Expr
+}
+
+// Not negates an expression.
+type Not struct {
+	X Expr
+}
+
+// ValuePath filters the values of a multi-valued attribute, the paths of
+// Filter are relative to it.
+type ValuePath struct {
+	Attr   string
+	Filter Expr
+}
+
+func (Compare) expr()   {}
+func (Logical) expr()   {}
+func (Not) expr()       {}
+func (ValuePath) expr() {}
+
+var compareOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter, errors are invalidFilter errors.
+func ParseFilter(filter string) (Expr, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, BadRequest(TypeInvalidFilter, "unexpected %q", p.peek().text)
+	}
+
+	return e, nil
+}
+
+// ParsePath parses an attribute path.
+func ParsePath(path string) Path {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		// the attribute follows the schema URN, sub-attributes keep their dot
+		i := strings.LastIndex(path, ":")
+		path = path[i+1:]
+	}
+
+	attr, sub, _ := strings.Cut(path, ".")
+	return Path{Attr: attr, Sub: sub}
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenOpenBracket
+	tokenCloseBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokenOpenBracket, "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokenCloseBracket, "]"})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, BadRequest(TypeInvalidFilter, "unterminated string")
+			}
+			var str string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &str); err != nil {
+				return nil, BadRequest(TypeInvalidFilter, "invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, token{tokenString, str})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, s[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: -1}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "or", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "and", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.keyword("not") {
+		if p.peek().kind != tokenOpen {
+			return nil, BadRequest(TypeInvalidFilter, "not must be followed by a parenthesized filter")
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: x}, nil
+	}
+
+	if p.peek().kind == tokenOpen {
+		p.pos++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenClose {
+			return nil, BadRequest(TypeInvalidFilter, "missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+
+	return p.attrExp()
+}
+
+func (p *parser) attrExp() (Expr, error) {
+	t := p.peek()
+	if t.kind != tokenWord {
+		return nil, BadRequest(TypeInvalidFilter, "expected an attribute")
+	}
+	p.pos++
+	path := ParsePath(t.text)
+
+	if p.peek().kind == tokenOpenBracket {
+		p.pos++
+		if path.Sub != "" {
+			return nil, BadRequest(TypeInvalidFilter, "invalid value path %s", t.text)
+		}
+		filter, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenCloseBracket {
+			return nil, BadRequest(TypeInvalidFilter, "missing ]")
+		}
+		p.pos++
+		return ValuePath{Attr: path.Attr, Filter: filter}, nil
+	}
+
+	op := p.peek()
+	if op.kind != tokenWord {
+		return nil, BadRequest(TypeInvalidFilter, "expected an operator after %s", t.text)
+	}
+	p.pos++
+
+	name := strings.ToLower(op.text)
+	if name == "pr" {
+		return Compare{Path: path, Op: name}, nil
+	}
+	if !compareOps[name] {
+		return nil, BadRequest(TypeInvalidFilter, "unknown operator %q", op.text)
+	}
+
+	value, err := p.compValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return Compare{Path: path, Op: name, Value: value}, nil
+}
+
+func (p *parser) compValue() (any, error) {
+	t := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	p.pos--
+	return nil, BadRequest(TypeInvalidFilter, "invalid comparison value %q", t.text)
+}
+
+// Type is the type of an attribute a filter is translated for.
+type Type int
+
+const (
+	String Type = iota
+	CaseExactString
+	Boolean
+	DateTime
+)
+
+// Column is the SQL expression an attribute is stored in.
+type Column struct {
+	SQL  string
+	Type Type
+}
+
+// SQL translates the filter to an SQL condition. Columns maps the
+// lowercase attribute paths, such as "username" or "emails.value", to
+// their columns. The values are appended to args as numbered parameters.
+func SQL(e Expr, columns map[string]Column, args []any) (string, []any, error) {
+	b := &sqlBuilder{columns: columns, args: args}
+	cond, err := b.build(e, "")
+	return cond, b.args, err
+}
+
+type sqlBuilder struct {
+	columns map[string]Column
+	args    []any
+}
+
+func (b *sqlBuilder) param(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *sqlBuilder) build(e Expr, prefix string) (string, error) {
+	switch e := e.(type) {
+	case Logical:
+		left, err := b.build(e.Left, prefix)
+		if err != nil {
+			return "", err
+		}
+		right, err := b.build(e.Right, prefix)
+		if err != nil {
+			return "", err
+		}
+		return "(" + left + " " + strings.ToUpper(e.Op) + " " + right + ")", nil
+	case Not:
+		x, err := b.build(e.X, prefix)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + x, nil
+	case ValuePath:
+		if prefix != "" {
+			return "", BadRequest(TypeInvalidFilter, "nested value paths are not supported")
+		}
+		return b.build(e.Filter, e.Attr)
+	case Compare:
+		return b.compare(e, prefix)
+	}
+
+	return "", BadRequest(TypeInvalidFilter, "unsupported filter")
+}
+
+func (b *sqlBuilder) compare(c Compare, prefix string) (string, error) {
+	path := c.Path
+	if prefix != "" {
+		path = Path{Attr: prefix, Sub: c.Path.String()}
+	}
+
+	column, ok := b.columns[path.key()]
+	if !ok {
+		return "", BadRequest(TypeInvalidFilter, "attribute %s can't be filtered", path)
+	}
+
+	if c.Op == "pr" {
+		if column.Type == String || column.Type == CaseExactString {
+			return "(" + column.SQL + " IS NOT NULL AND " + column.SQL + " <> '')", nil
+		}
+		return column.SQL + " IS NOT NULL", nil
+	}
+
+	if c.Value == nil {
+		switch c.Op {
+		case "eq":
+			return column.SQL + " IS NULL", nil
+		case "ne":
+			return column.SQL + " IS NOT NULL", nil
+		}
+		return "", BadRequest(TypeInvalidFilter, "null can only be compared with eq and ne")
+	}
+
+	switch column.Type {
+	case Boolean:
+		v, ok := c.Value.(bool)
+		if !ok || (c.Op != "eq" && c.Op != "ne") {
+			return "", BadRequest(TypeInvalidFilter, "%s is compared with eq or ne and a boolean", path)
+		}
+		return column.SQL + sqlOps[c.Op] + b.param(v), nil
+	case DateTime:
+		s, _ := c.Value.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", BadRequest(TypeInvalidFilter, "%s is compared with a dateTime", path)
+		}
+		op, ok := sqlOps[c.Op]
+		if !ok {
+			return "", BadRequest(TypeInvalidFilter, "%s can't be compared with %s", path, c.Op)
+		}
+		return column.SQL + op + b.param(t.Unix()), nil
+	}
+
+	v, ok := c.Value.(string)
+	if !ok {
+		return "", BadRequest(TypeInvalidFilter, "%s is compared with a string", path)
+	}
+
+	lhs := column.SQL
+	if column.Type == String {
+		lhs = "lower(" + lhs + ")"
+		v = strings.ToLower(v)
+	}
+
+	switch c.Op {
+	case "co":
+		return lhs + " LIKE " + b.param("%"+escapeLike(v)+"%") + likeEscape, nil
+	case "sw":
+		return lhs + " LIKE " + b.param(escapeLike(v)+"%") + likeEscape, nil
+	case "ew":
+		return lhs + " LIKE " + b.param("%"+escapeLike(v)) + likeEscape, nil
+	case "ne":
+		return lhs + " IS DISTINCT FROM " + b.param(v), nil
+	}
+
+	return lhs + sqlOps[c.Op] + b.param(v), nil
+}
+
+var sqlOps = map[string]string{
+	"eq": " = ",
+	"ne": " <> ",
+	"gt": " > ",
+	"ge": " >= ",
+	"lt": " < ",
+	"le": " <= ",
+}
+
+// likeEscape names the escape character of the patterns, it is the
+// default of PostgreSQL but SQLite has none.
+const likeEscape = ` ESCAPE '\'`
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Match evaluates the filter against a value of a multi-valued attribute
+// decoded from JSON, strings are compared case-insensitively.
+func Match(e Expr, value map[string]any) bool {
+	switch e := e.(type) {
+	case Logical:
+		if e.Op == "and" {
+			return Match(e.Left, value) && Match(e.Right, value)
+		}
+		return Match(e.Left, value) || Match(e.Right, value)
+	case Not:
+		return !Match(e.X, value)
+	case Compare:
+		return matchCompare(e, value)
+	}
+
+	return false
+}
+
+func matchCompare(c Compare, value map[string]any) bool {
+	v, ok := lookup(value, c.Path.Attr)
+	if ok && c.Path.Sub != "" {
+		sub, isMap := v.(map[string]any)
+		if !isMap {
+			return false
+		}
+		v, ok = lookup(sub, c.Path.Sub)
+	}
+
+	if c.Op == "pr" {
+		return ok && v != nil && v != ""
+	}
+	if !ok || v == nil {
+		return c.Value == nil && c.Op == "eq"
+	}
+
+	switch want := c.Value.(type) {
+	case bool:
+		got, isBool := v.(bool)
+		return isBool && ((c.Op == "eq") == (got == want))
+	case float64:
+		got, isNumber := v.(float64)
+		return isNumber && compareOrdered(c.Op, got, want)
+	case string:
+		got, isString := v.(string)
+		if !isString {
+			return false
+		}
+		got, want = strings.ToLower(got), strings.ToLower(want)
+		switch c.Op {
+		case "co":
+			return strings.Contains(got, want)
+		case "sw":
+			return strings.HasPrefix(got, want)
+		case "ew":
+			return strings.HasSuffix(got, want)
+		}
+		return compareOrdered(c.Op, got, want)
+	}
+
+	return false
+}
+
+func compareOrdered[T float64 | string](op string, got, want T) bool {
+	switch op {
+	case "eq":
+		return got == want
+	case "ne":
+		return got != want
+	case "gt":
+		return got > want
+	case "ge":
+		return got >= want
+	case "lt":
+		return got < want
+	case "le":
+		return got <= want
+	}
+	return false
+}
+
+// lookup returns the attribute of the JSON object, names are
+// case-insensitive.
+func lookup(value map[string]any, attr string) (any, bool) {
+	if v, ok := value[attr]; ok {
+		return v, true
+	}
+	for k, v := range value {
+		if strings.EqualFold(k, attr) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// validAttr reports whether the name is an attribute name (RFC 7643,
+// section 2.1).
+func validAttr(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '$' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '_'))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Path) validate() error {
+	if !validAttr(p.Attr) || (p.Sub != "" && !validAttr(p.Sub)) {
+		return BadRequest(TypeInvalidPath, "invalid attribute path %s", fmt.Sprint(p))
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package scim
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request (RFC 7644, section 3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one operation of a PATCH request, Op is add, remove
+// or replace.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// PatchPath is the target of an operation: an attribute, optionally a
+// filter selecting values of a multi-valued attribute, and a
+// sub-attribute.
+type PatchPath struct {
+	Attr   string
+	Filter Expr
+	Sub    string
+}
+
+// ParsePatchPath parses the path of an operation, errors are invalidPath
+// errors.
+func ParsePatchPath(path string) (PatchPath, error) {
+	open := strings.IndexByte(path, '[')
+	if open < 0 {
+		p := ParsePath(path)
+		if err := p.validate(); err != nil {
+			return PatchPath{}, err
+		}
+		return PatchPath{Attr: p.Attr, Sub: p.Sub}, nil
+	}
+
+	closing := strings.LastIndexByte(path, ']')
+	if closing < open {
+		return PatchPath{}, BadRequest(TypeInvalidPath, "invalid path %s", path)
+	}
+
+	attr := ParsePath(path[:open])
+	if attr.Sub != "" {
+		return PatchPath{}, BadRequest(TypeInvalidPath, "invalid path %s", path)
+	}
+
+	filter, err := ParseFilter(path[open+1 : closing])
+	if err != nil {
+		return PatchPath{}, BadRequest(TypeInvalidPath, "invalid filter of path %s", path)
+	}
+
+	p := PatchPath{Attr: attr.Attr, Filter: filter}
+	if rest := path[closing+1:]; rest != "" {
+		if !strings.HasPrefix(rest, ".") {
+			return PatchPath{}, BadRequest(TypeInvalidPath, "invalid path %s", path)
+		}
+		p.Sub = rest[1:]
+	}
+
+	if err := (Path{Attr: p.Attr, Sub: p.Sub}).validate(); err != nil {
+		return PatchPath{}, err
+	}
+
+	return p, nil
+}
+
+// Validate checks the schema and the operations of the request.
+func (req PatchRequest) Validate() error {
+	if !slices.Contains(req.Schemas, SchemaPatchOp) {
+		return BadRequest(TypeInvalidSyntax, "the request must use the %s schema", SchemaPatchOp)
+	}
+	if len(req.Operations) == 0 {
+		return BadRequest(TypeInvalidValue, "the request has no operations")
+	}
+
+	for _, op := range req.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if op.Value == nil {
+				return BadRequest(TypeInvalidValue, "%s needs a value", op.Op)
+			}
+		case "remove":
+			if op.Path == "" {
+				return BadRequest(TypeNoTarget, "remove needs a path")
+			}
+		default:
+			return BadRequest(TypeInvalidSyntax, "unknown operation %q", op.Op)
+		}
+	}
+
+	return nil
+}
+
+// Apply applies the operations to the JSON representation of a resource.
+// The resource is then stored as a replacement of the resource.
+func (req PatchRequest) Apply(doc map[string]any) error {
+	for _, op := range req.Operations {
+		if err := applyOperation(doc, strings.ToLower(op.Op), op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(doc map[string]any, op, path string, value any) error {
+	if path == "" {
+		// without a path the value holds the attributes to add or replace
+		attrs, ok := value.(map[string]any)
+		if !ok {
+			return BadRequest(TypeInvalidValue, "%s without a path needs an object value", op)
+		}
+		for name, v := range attrs {
+			if err := applyOperation(doc, op, name, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	p, err := ParsePatchPath(path)
+	if err != nil {
+		return err
+	}
+
+	key := keyOf(doc, p.Attr)
+	current, exists := doc[key]
+
+	if p.Filter != nil {
+		values, ok := current.([]any)
+		if !ok {
+			if op == "add" {
+				return BadRequest(TypeInvalidPath, "%s is not multi-valued", p.Attr)
+			}
+			return BadRequest(TypeNoTarget, "no value of %s matches", p.Attr)
+		}
+		return applyFiltered(doc, key, values, p, op, value)
+	}
+
+	if p.Sub != "" {
+		return applySub(doc, key, current, p, op, value)
+	}
+
+	switch op {
+	case "remove":
+		delete(doc, key)
+	case "add":
+		if values, ok := current.([]any); ok && exists {
+			if added, ok := value.([]any); ok {
+				doc[key] = append(values, added...)
+			} else {
+				doc[key] = append(values, value)
+			}
+			return nil
+		}
+		if sub, ok := current.(map[string]any); ok {
+			if added, ok := value.(map[string]any); ok {
+				for k, v := range added {
+					sub[keyOf(sub, k)] = v
+				}
+				return nil
+			}
+		}
+		doc[key] = value
+	case "replace":
+		doc[key] = value
+	}
+
+	return nil
+}
+
+// applySub changes a sub-attribute of a complex attribute, or of every
+// value of a multi-valued one.
+func applySub(doc map[string]any, key string, current any, p PatchPath, op string, value any) error {
+	switch current := current.(type) {
+	case map[string]any:
+		setSub(current, p.Sub, op, value)
+	case []any:
+		for _, v := range current {
+			if sub, ok := v.(map[string]any); ok {
+				setSub(sub, p.Sub, op, value)
+			}
+		}
+	case nil:
+		if op != "remove" {
+			doc[key] = map[string]any{p.Sub: value}
+		}
+	default:
+		return BadRequest(TypeInvalidPath, "%s has no sub-attributes", p.Attr)
+	}
+
+	return nil
+}
+
+// applyFiltered changes the values of a multi-valued attribute the filter
+// of the path selects.
+func applyFiltered(doc map[string]any, key string, values []any, p PatchPath, op string, value any) error {
+	kept := make([]any, 0, len(values))
+	matched := 0
+
+	for _, v := range values {
+		element, ok := v.(map[string]any)
+		if !ok || !Match(p.Filter, element) {
+			kept = append(kept, v)
+			continue
+		}
+		matched++
+
+		switch {
+		case op == "remove" && p.Sub == "":
+			continue
+		case p.Sub != "":
+			setSub(element, p.Sub, op, value)
+		case op == "replace":
+			replacement, ok := value.(map[string]any)
+			if !ok {
+				return BadRequest(TypeInvalidValue, "the values of %s are objects", p.Attr)
+			}
+			element = replacement
+		default:
+			added, ok := value.(map[string]any)
+			if !ok {
+				return BadRequest(TypeInvalidValue, "the values of %s are objects", p.Attr)
+			}
+			for k, v := range added {
+				element[keyOf(element, k)] = v
+			}
+		}
+		kept = append(kept, element)
+	}
+
+	if matched == 0 && op != "add" {
+		return BadRequest(TypeNoTarget, "no value of %s matches", p.Attr)
+	}
+
+	doc[key] = kept
+	return nil
+}
+
+func setSub(value map[string]any, sub, op string, v any) {
+	key := keyOf(value, sub)
+	if op == "remove" {
+		delete(value, key)
+		return
+	}
+	value[key] = v
+}
+
+// keyOf returns the key of the attribute in the object, attribute names
+// are case-insensitive.
+func keyOf(value map[string]any, attr string) string {
+	if _, ok := value[attr]; ok {
+		return attr
+	}
+	for k := range value {
+		if strings.EqualFold(k, attr) {
+			return k
+		}
+	}
+	return attr
+}
+
+// Document returns the JSON representation of a resource operations are
+// applied to.
+func Document(resource any) (map[string]any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Decode decodes the JSON representation of a resource into v.
+func Decode(doc map[string]any, v any) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return BadRequest(TypeInvalidValue, "invalid resource: %s", err.Error())
+	}
+
+	return nil
+}
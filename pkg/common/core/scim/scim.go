@@ -0,0 +1,236 @@
+// Package scim implements the protocol parts of SCIM 2.0 (RFC 7643 and
+// RFC 7644) the resource endpoints share: errors, list responses,
+// pagination, ETags, filters and PATCH operations.
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentType = "application/scim+json"
+
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// The scimType of the errors (RFC 7644, section 3.12).
+const (
+	TypeInvalidFilter = "invalidFilter"
+	TypeUniqueness    = "uniqueness"
+	TypeMutability    = "mutability"
+	TypeInvalidSyntax = "invalidSyntax"
+	TypeInvalidPath   = "invalidPath"
+	TypeNoTarget      = "noTarget"
+	TypeInvalidValue  = "invalidValue"
+)
+
+// Error is the error response of RFC 7644, section 3.12.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	if e.ScimType == "" {
+		return e.Detail
+	}
+	return e.ScimType + ": " + e.Detail
+}
+
+// BadRequest returns a 400 error of the scimType.
+func BadRequest(scimType, format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+type errorResponse struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Write writes v as a SCIM response with the status.
+func Write(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes err as a SCIM error, errors other than *Error are
+// internal server errors.
+func WriteError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusInternalServerError, Detail: "internal server error"}
+	}
+
+	Write(w, e.Status, errorResponse{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(e.Status),
+		ScimType: e.ScimType,
+		Detail:   e.Detail,
+	})
+}
+
+// Meta is the meta attribute of a resource.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+	Version      string `json:"version,omitempty"`
+}
+
+// NewMeta returns the meta of a resource stored with unix timestamps.
+func NewMeta(resourceType, location string, created, lastModified, version int64) Meta {
+	return Meta{
+		ResourceType: resourceType,
+		Created:      FormatTime(created),
+		LastModified: FormatTime(lastModified),
+		Location:     location,
+		Version:      ETag(version),
+	}
+}
+
+// FormatTime formats a unix timestamp as an xsd:dateTime.
+func FormatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// ListResponse is the result of a query (RFC 7644, section 3.4.2).
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// NewListResponse returns the page of resources starting at startIndex.
+func NewListResponse(total int64, startIndex int, resources []any) ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+
+	return ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Page is the pagination of a query, StartIndex is 1-based.
+type Page struct {
+	StartIndex int
+	Count      int
+}
+
+// Offset is the number of resources before the page.
+func (p Page) Offset() int {
+	return p.StartIndex - 1
+}
+
+// ParsePage reads startIndex and count of the query (RFC 7644, section
+// 3.4.2.4), count is capped to maxCount and defaults to it.
+func ParsePage(query map[string][]string, maxCount int) (Page, error) {
+	page := Page{StartIndex: 1, Count: maxCount}
+
+	if v := first(query, "startIndex"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return page, BadRequest(TypeInvalidValue, "startIndex must be an integer")
+		}
+		if n > 1 {
+			page.StartIndex = n
+		}
+	}
+
+	if v := first(query, "count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return page, BadRequest(TypeInvalidValue, "count must be an integer")
+		}
+		page.Count = min(max(n, 0), maxCount)
+	}
+
+	return page, nil
+}
+
+func first(query map[string][]string, key string) string {
+	if v := query[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// ETag returns the weak entity tag of a resource version.
+func ETag(version int64) string {
+	return `W/"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// MatchesETag reports whether the If-Match or If-None-Match header lists
+// the tag of the version, weak comparison is used (RFC 9110, section
+// 8.8.3.2).
+func MatchesETag(header string, version int64) bool {
+	tag := strings.TrimPrefix(ETag(version), "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPrecondition answers the If-Match header of a request modifying a
+// resource of the version, it reports false after writing the 412.
+func CheckPrecondition(w http.ResponseWriter, r *http.Request, version int64) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || MatchesETag(header, version) {
+		return true
+	}
+
+	WriteError(w, &Error{Status: http.StatusPreconditionFailed, Detail: "the resource has been modified"})
+	return false
+}
+
+// Bool is a boolean attribute, some clients send booleans as the strings
+// "True" and "False".
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*b = Bool(v)
+		return nil
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err == nil {
+			*b = Bool(parsed)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid boolean %s", data)
+}
@@ -0,0 +1,315 @@
+package scim_test
+
+import (
+	"app/pkg/common/core/scim"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var columns = map[string]scim.Column{
+	"username":     {SQL: "name", Type: scim.String},
+	"externalid":   {SQL: "external_id", Type: scim.CaseExactString},
+	"emails.value": {SQL: "email", Type: scim.String},
+	"active":       {SQL: "(deactivated_at IS NULL)", Type: scim.Boolean},
+	"meta.created": {SQL: "created_at", Type: scim.DateTime},
+}
+
+func TestFilterSQL(t *testing.T) {
+	tests := []struct {
+		filter string
+		sql    string
+		args   []any
+	}{
+		{
+			filter: `userName eq "Bjensen"`,
+			sql:    `lower(name) = $1`,
+			args:   []any{"bjensen"},
+		},
+		{
+			filter: `externalId eq "Ab" and active eq false`,
+			sql:    `(external_id = $1 AND (deactivated_at IS NULL) = $2)`,
+			args:   []any{"Ab", false},
+		},
+		{
+			filter: `emails[value co "100%"] or not (userName sw "j_")`,
+			sql:    `(lower(email) LIKE $1 ESCAPE '\' OR NOT lower(name) LIKE $2 ESCAPE '\')`,
+			args:   []any{`%100\%%`, `j\_%`},
+		},
+		{
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName pr`,
+			sql:    `(name IS NOT NULL AND name <> '')`,
+		},
+		{
+			filter: `meta.created gt "2011-05-13T04:42:34Z"`,
+			sql:    `created_at > $1`,
+			args:   []any{int64(1305261754)},
+		},
+		{
+			filter: `externalId ne null`,
+			sql:    `external_id IS NOT NULL`,
+		},
+	}
+
+	for _, tt := range tests {
+		expr, err := scim.ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%s): %v", tt.filter, err)
+		}
+
+		sql, args, err := scim.SQL(expr, columns, nil)
+		if err != nil {
+			t.Fatalf("SQL(%s): %v", tt.filter, err)
+		}
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("SQL(%s) = %s %v, want %s %v", tt.filter, sql, args, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		`userName`,
+		`userName eq`,
+		`userName like "a"`,
+		`(userName eq "a"`,
+		`userName eq "a" and`,
+		`title eq "a"`,
+		`active gt true`,
+	} {
+		expr, err := scim.ParseFilter(filter)
+		if err == nil {
+			_, _, err = scim.SQL(expr, columns, nil)
+		}
+
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != scim.TypeInvalidFilter {
+			t.Errorf("%s: got %v, want an invalidFilter error", filter, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	value := map[string]any{"value": "Bjensen@example.com", "type": "work", "primary": true}
+
+	tests := map[string]bool{
+		`value eq "bjensen@example.com"`:        true,
+		`type eq "work" and primary eq true`:    true,
+		`type eq "home" or value ew ".com"`:     true,
+		`not (type eq "work")`:                  false,
+		`display pr`:                            false,
+		`value sw "bjensen" and type ne "work"`: false,
+	}
+
+	for filter, want := range tests {
+		expr, err := scim.ParseFilter(filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%s): %v", filter, err)
+		}
+		if got := scim.Match(expr, value); got != want {
+			t.Errorf("Match(%s) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	doc := func() map[string]any {
+		return map[string]any{
+			"userName": "bjensen",
+			"active":   true,
+			"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work"},
+				map[string]any{"value": "babs@example.com", "type": "home"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		ops  []scim.PatchOperation
+		want map[string]any
+	}{
+		{
+			name: "replace attribute with string boolean",
+			ops:  []scim.PatchOperation{{Op: "Replace", Path: "active", Value: "False"}},
+			want: map[string]any{"active": "False"},
+		},
+		{
+			name: "replace without path",
+			ops: []scim.PatchOperation{{Op: "replace", Value: map[string]any{
+				"DisplayName": "Babs",
+				"username":    "babs",
+			}}},
+			want: map[string]any{"userName": "babs", "DisplayName": "Babs"},
+		},
+		{
+			name: "replace filtered sub-attribute",
+			ops:  []scim.PatchOperation{{Op: "replace", Path: `emails[type eq "work"].value`, Value: "b@example.com"}},
+			want: map[string]any{"emails": []any{
+				map[string]any{"value": "b@example.com", "type": "work"},
+				map[string]any{"value": "babs@example.com", "type": "home"},
+			}},
+		},
+		{
+			name: "remove filtered value",
+			ops:  []scim.PatchOperation{{Op: "remove", Path: `emails[type eq "home"]`}},
+			want: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work"},
+			}},
+		},
+		{
+			name: "add to multi-valued attribute",
+			ops: []scim.PatchOperation{{Op: "add", Path: "emails", Value: []any{
+				map[string]any{"value": "other@example.com"},
+			}}},
+			want: map[string]any{"emails": []any{
+				map[string]any{"value": "bjensen@example.com", "type": "work"},
+				map[string]any{"value": "babs@example.com", "type": "home"},
+				map[string]any{"value": "other@example.com"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := scim.PatchRequest{Schemas: []string{scim.SchemaPatchOp}, Operations: tt.ops}
+			if err := req.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			got := doc()
+			if err := req.Apply(got); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+
+			want := doc()
+			for k, v := range tt.want {
+				want[k] = v
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	tests := []struct {
+		req      scim.PatchRequest
+		scimType string
+	}{
+		{
+			req:      scim.PatchRequest{Operations: []scim.PatchOperation{{Op: "remove", Path: "active"}}},
+			scimType: scim.TypeInvalidSyntax,
+		},
+		{
+			req: scim.PatchRequest{
+				Schemas:    []string{scim.SchemaPatchOp},
+				Operations: []scim.PatchOperation{{Op: "remove"}},
+			},
+			scimType: scim.TypeNoTarget,
+		},
+		{
+			req: scim.PatchRequest{
+				Schemas:    []string{scim.SchemaPatchOp},
+				Operations: []scim.PatchOperation{{Op: "replace", Path: `emails[type eq "other"].value`, Value: "x"}},
+			},
+			scimType: scim.TypeNoTarget,
+		},
+		{
+			req: scim.PatchRequest{
+				Schemas:    []string{scim.SchemaPatchOp},
+				Operations: []scim.PatchOperation{{Op: "replace", Path: `emails[type eq "work"`, Value: "x"}},
+			},
+			scimType: scim.TypeInvalidPath,
+		},
+	}
+
+	for _, tt := range tests {
+		err := tt.req.Validate()
+		if err == nil {
+			err = tt.req.Apply(map[string]any{
+				"emails": []any{map[string]any{"value": "a@example.com", "type": "work"}},
+			})
+		}
+
+		var scimErr *scim.Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != tt.scimType {
+			t.Errorf("%+v: got %v, want a %s error", tt.req.Operations, err, tt.scimType)
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	page, err := scim.ParsePage(map[string][]string{"startIndex": {"0"}, "count": {"500"}}, 100)
+	if err != nil {
+		t.Fatalf("ParsePage: %v", err)
+	}
+	if page.StartIndex != 1 || page.Count != 100 || page.Offset() != 0 {
+		t.Errorf("got %+v, want the first page of 100", page)
+	}
+
+	page, err = scim.ParsePage(map[string][]string{"startIndex": {"11"}, "count": {"10"}}, 100)
+	if err != nil {
+		t.Fatalf("ParsePage: %v", err)
+	}
+	if page.Offset() != 10 || page.Count != 10 {
+		t.Errorf("got %+v, want the second page of 10", page)
+	}
+
+	if _, err := scim.ParsePage(map[string][]string{"count": {"ten"}}, 100); err == nil {
+		t.Error("an invalid count was accepted")
+	}
+}
+
+func TestETag(t *testing.T) {
+	if tag := scim.ETag(3); tag != `W/"3"` {
+		t.Fatalf("ETag(3) = %s", tag)
+	}
+
+	for header, want := range map[string]bool{
+		`W/"3"`:        true,
+		`"3"`:          true,
+		`W/"2", W/"3"`: true,
+		`*`:            true,
+		`W/"4"`:        false,
+	} {
+		if got := scim.MatchesETag(header, 3); got != want {
+			t.Errorf("MatchesETag(%s) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	scim.WriteError(rec, scim.BadRequest(scim.TypeInvalidValue, "userName is required"))
+
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != scim.ContentType {
+		t.Fatalf("got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["status"] != "400" || body["scimType"] != scim.TypeInvalidValue {
+		t.Errorf("got %v", body)
+	}
+}
+
+func TestBool(t *testing.T) {
+	for data, want := range map[string]scim.Bool{`true`: true, `"False"`: false, `"True"`: true} {
+		var b scim.Bool
+		if err := json.Unmarshal([]byte(data), &b); err != nil || b != want {
+			t.Errorf("%s: got %v %v, want %v", data, b, err, want)
+		}
+	}
+
+	var b scim.Bool
+	if err := json.Unmarshal([]byte(`"yes please"`), &b); err == nil {
+		t.Error("an invalid boolean was accepted")
+	}
+}
@@ -3,40 +3,115 @@ package token
 import (
 	accessTokenDomain "app/internal/domain/oauth/access-token"
 	refreshTokenDomain "app/internal/domain/oauth/refresh-token"
+	"app/pkg/common/core/dpop"
+	"app/pkg/common/core/mtls"
 	"app/pkg/utils/crypt"
+	"context"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
-	"log/slog"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
+// AccessTokenType is the typ header of JWT access tokens (RFC 9068,
+// section 2.1).
+const AccessTokenType = "at+jwt"
+
+var (
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenType    = errors.New("token is not an access token")
+)
+
+// UserClaim is the claim set of an access token (RFC 9068, section 2.2),
+// UUID and ExpAt are the legacy claims emitted during the migration.
+// AuthTime is when the user authenticated, it survives the refreshes.
 type UserClaim struct {
 	jwt.RegisteredClaims
-	UUID     string `json:"uuid"`
-	Email    string `json:"email"`
-	ClientID string `json:"client_id"`
-	ExpAt    int64  `json:"exp_at"`
+	UUID     string                          `json:"uuid,omitempty"`
+	Email    string                          `json:"email"`
+	ClientID string                          `json:"client_id"`
+	ExpAt    int64                           `json:"exp_at,omitempty"`
+	Scope    string                          `json:"scope,omitempty"`
+	AuthTime int64                           `json:"auth_time,omitempty"`
+	Act      *accessTokenDomain.Actor        `json:"act,omitempty"`
+	Cnf      *accessTokenDomain.Confirmation `json:"cnf,omitempty"`
+}
+
+// UserUUID returns the UUID of the user, tokens issued before the sub
+// claim was introduced only carry the legacy uuid claim.
+func (c *UserClaim) UserUUID() string {
+	if c.Subject != "" {
+		return c.Subject
+	}
+	return c.UUID
+}
+
+// IntendedFor reports whether the access token was issued by issuer for
+// its own endpoints. A token restricted to the audience of resources
+// (RFC 8707) is only accepted by them, the default audience is the client
+// the token was issued to.
+func (c *UserClaim) IntendedFor(issuer string) bool {
+	if c.Issuer != issuer {
+		return false
+	}
+	return slices.Contains(c.Audience, issuer) || (c.ClientID != "" && slices.Contains(c.Audience, c.ClientID))
+}
+
+// Options configures the access tokens, LegacyClaims keeps emitting the
+// uuid and exp_at claims for resource servers that still read them.
+type Options struct {
+	Issuer       string
+	TTL          time.Duration
+	Secret       string
+	LegacyClaims bool
 }
 
 func GenerateAccessToken(
 	payload *accessTokenDomain.Payload,
-	tokenTTL time.Duration,
-	tokenSecret string,
+	opts Options,
 ) (string, error) {
-	expAccessToken := time.Now().Add(tokenTTL).Unix()
+	now := time.Now()
+	exp := now.Add(opts.TTL)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, &UserClaim{
-		RegisteredClaims: jwt.RegisteredClaims{},
-		UUID:             payload.UUID,
-		Email:            payload.Email,
-		ClientID:         payload.ClientID,
-		ExpAt:            expAccessToken,
-	})
+	audience := payload.Audience
+	if len(audience) == 0 && payload.ClientID != "" {
+		audience = jwt.ClaimStrings{payload.ClientID}
+	}
+
+	claims := &UserClaim{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opts.Issuer,
+			Subject:   payload.UUID,
+			Audience:  audience,
+			ExpiresAt: jwt.NewNumericDate(exp),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        payload.ID,
+		},
+		Email:    payload.Email,
+		ClientID: payload.ClientID,
+		Scope:    payload.Scope,
+		AuthTime: payload.AuthTime,
+		Act:      payload.Act,
+		Cnf:      payload.Cnf,
+	}
+	if opts.LegacyClaims {
+		claims.UUID = payload.UUID
+		claims.ExpAt = exp.Unix()
+	}
 
-	accessToken, err := token.SignedString([]byte(tokenSecret))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	token.Header["typ"] = AccessTokenType
+
+	accessToken, err := token.SignedString([]byte(opts.Secret))
 
 	if err != nil {
 		return "", err
@@ -45,6 +120,52 @@ func GenerateAccessToken(
 	return accessToken, nil
 }
 
+// ParseAccessToken verifies the signature, the typ header and the expiry
+// of an access token. Tokens issued before RFC 9068 was adopted have the
+// JWT typ and only the legacy exp_at claim, they are accepted until they
+// expire.
+func ParseAccessToken(tokenStr string, tokenSecret string) (*UserClaim, error) {
+	claims := &UserClaim{}
+
+	token, err := jwt.ParseWithClaims(
+		tokenStr,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			return []byte(tokenSecret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}),
+	)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	typ, _ := token.Header["typ"].(string)
+	switch {
+	case strings.EqualFold(typ, AccessTokenType), strings.EqualFold(typ, "application/"+AccessTokenType):
+		if claims.ExpiresAt == nil {
+			return nil, ErrTokenExpired
+		}
+	case typ == "JWT" && claims.ExpiresAt == nil:
+		if claims.ExpAt < time.Now().Unix() {
+			return nil, ErrTokenExpired
+		}
+	default:
+		return nil, ErrTokenType
+	}
+
+	return claims, nil
+}
+
+// KeyID identifies the secret the access tokens are signed with without
+// disclosing it, it changes when the secret is rotated.
+func KeyID(tokenSecret string) string {
+	sum := sha256.Sum256([]byte(tokenSecret))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
 func GenerateRefreshToken(
 	payload *refreshTokenDomain.Payload,
 ) (string, error) {
@@ -98,6 +219,94 @@ func ParseRefreshToken(token string) (refreshTokenDomain.Payload, error) {
 		return oldDateRefreshToken, err
 	}
 
-	slog.Info("dataToken", dataToken)
 	return oldDateRefreshToken, nil
 }
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, tokenStr, ok := AuthorizationToken(r)
+	if !ok || scheme != "Bearer" {
+		return "", false
+	}
+	return tokenStr, true
+}
+
+// SessionCookie holds the access token of a browser session, it is only
+// sent to the SAML endpoints.
+const SessionCookie = "sso_session"
+
+// SessionToken returns the access token of the session cookie.
+func SessionToken(r *http.Request) (string, bool) {
+	c, err := r.Cookie(SessionCookie)
+	if err != nil || c.Value == "" {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// AuthorizationToken returns the access token of an Authorization header
+// with the Bearer or the DPoP scheme, the scheme is returned in its
+// canonical case.
+func AuthorizationToken(r *http.Request) (string, string, bool) {
+	scheme, tokenStr, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	tokenStr = strings.TrimSpace(tokenStr)
+	if !ok || tokenStr == "" {
+		return "", "", false
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		return "Bearer", tokenStr, true
+	case strings.EqualFold(scheme, dpop.TokenType):
+		return dpop.TokenType, tokenStr, true
+	}
+
+	return "", "", false
+}
+
+// ConfirmationFromContext returns the key binding for the tokens issued
+// to the current request, nil when they are plain bearer tokens.
+func ConfirmationFromContext(ctx context.Context) *accessTokenDomain.Confirmation {
+	var cnf accessTokenDomain.Confirmation
+	cnf.JKT, _ = dpop.ThumbprintFromContext(ctx)
+	cnf.X5TS256, _ = mtls.ThumbprintFromContext(ctx)
+
+	if cnf.JKT == "" && cnf.X5TS256 == "" {
+		return nil
+	}
+	return &cnf
+}
+
+// TokenType returns the token type of an access token with the binding.
+func TokenType(cnf *accessTokenDomain.Confirmation) string {
+	if cnf != nil && cnf.JKT != "" {
+		return dpop.TokenType
+	}
+	return "Bearer"
+}
+
+type ctxAccessToken struct{}
+
+type ctxClaims struct{}
+
+func ContextWithAccessToken(ctx context.Context, aT accessTokenDomain.AccessToken) context.Context {
+	return context.WithValue(ctx, ctxAccessToken{}, aT)
+}
+
+// AccessTokenFromContext returns the access token the request was
+// authenticated with.
+func AccessTokenFromContext(ctx context.Context) (accessTokenDomain.AccessToken, bool) {
+	aT, ok := ctx.Value(ctxAccessToken{}).(accessTokenDomain.AccessToken)
+	return aT, ok
+}
+
+func ContextWithClaims(ctx context.Context, claims *UserClaim) context.Context {
+	return context.WithValue(ctx, ctxClaims{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the access token the request
+// was authenticated with.
+func ClaimsFromContext(ctx context.Context) (*UserClaim, bool) {
+	claims, ok := ctx.Value(ctxClaims{}).(*UserClaim)
+	return claims, ok && claims != nil
+}
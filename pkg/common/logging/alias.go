@@ -27,6 +27,7 @@ var (
 	NewJSONHandler = slog.NewJSONHandler
 	New            = slog.New
 	SetDefault     = slog.SetDefault
+	NewLogLogger   = slog.NewLogLogger
 
 	StringAttr   = slog.String
 	BoolAttr     = slog.Bool
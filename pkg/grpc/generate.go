@@ -0,0 +1,7 @@
+// Package grpc holds the code generated from the proto definitions, the
+// plugins are run with the versions pinned in go.mod, protoc-gen-openapi
+// with the one of buf.gen.yaml.
+package grpc
+
+//go:generate go run github.com/bufbuild/buf/cmd/buf@v1.50.0 lint ../..
+//go:generate go run github.com/bufbuild/buf/cmd/buf@v1.50.0 generate ../.. --template ../../buf.gen.yaml --output ../.. --exclude-path ../../proto/google
@@ -0,0 +1,9 @@
+package grpc
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI v3 document of the REST gateway, generated from
+// the HTTP annotations of the services.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
@@ -4,7 +4,9 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -33,6 +35,11 @@ func GetMD5Hash(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+func GetSHA256Hash(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
 func GetSecret() string {
 	length := 48
 	buf := make([]byte, length)
@@ -53,6 +60,35 @@ func GetSHA512(key, secret string) string {
 	return fmt.Sprintf("%x", sha)
 }
 
+const secretHashPrefix = "sha256$"
+
+// HashSecret returns a salted SHA-256 hash of a generated client secret
+// in the form "sha256$<salt>$<hash>".
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt due to error %w", err)
+	}
+
+	saltHex := hex.EncodeToString(salt)
+	return secretHashPrefix + saltHex + "$" + secretDigest(saltHex, secret), nil
+}
+
+// VerifySecret compares secret with a hash produced by HashSecret in constant time.
+func VerifySecret(hashedSecret, secret string) bool {
+	salt, digest, ok := strings.Cut(strings.TrimPrefix(hashedSecret, secretHashPrefix), "$")
+	if !ok || !strings.HasPrefix(hashedSecret, secretHashPrefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(digest), []byte(secretDigest(salt, secret))) == 1
+}
+
+func secretDigest(salt, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
+
 func EncryptWithPublicKey(data []byte, pK *rsa.PublicKey) (string, error) {
 	hash := sha512.New()
 	var chunkSize = pK.N.BitLen()/4 - 2*len(data) - 2
@@ -0,0 +1,77 @@
+package replay
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Cache remembers one-time identifiers (such as JWT jti values) until
+// they expire so that they can't be used twice.
+type Cache struct {
+	mu       sync.Mutex
+	items    map[string]time.Time
+	expiries expiries
+	now      func() time.Time
+}
+
+func New() *Cache {
+	return &Cache{
+		items: make(map[string]time.Time),
+		now:   time.Now,
+	}
+}
+
+// Use records key until expiresAt and reports false when the key was
+// already recorded and has not expired yet.
+func (c *Cache) Use(key string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	if _, ok := c.items[key]; ok {
+		return false
+	}
+
+	c.items[key] = expiresAt
+	heap.Push(&c.expiries, entry{key: key, expiresAt: expiresAt})
+	return true
+}
+
+// sweep forgets the keys expired at now, the heap yields them first so
+// only the expired ones are visited.
+func (c *Cache) sweep(now time.Time) {
+	for len(c.expiries) > 0 && c.expiries[0].expiresAt.Before(now) {
+		e := heap.Pop(&c.expiries).(entry)
+		if exp, ok := c.items[e.key]; ok && exp.Equal(e.expiresAt) {
+			delete(c.items, e.key)
+		}
+	}
+}
+
+type entry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// expiries is a min-heap of the entries by expiry.
+type expiries []entry
+
+func (e expiries) Len() int           { return len(e) }
+func (e expiries) Less(i, j int) bool { return e[i].expiresAt.Before(e[j].expiresAt) }
+func (e expiries) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e *expiries) Push(x any) {
+	*e = append(*e, x.(entry))
+}
+
+func (e *expiries) Pop() any {
+	old := *e
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = entry{}
+	*e = old[:n-1]
+	return x
+}
@@ -0,0 +1,33 @@
+package replay_test
+
+import (
+	"app/pkg/utils/replay"
+	"testing"
+	"time"
+)
+
+func TestCache_Use(t *testing.T) {
+	c := replay.New()
+	now := time.Now()
+
+	if !c.Use("live", now.Add(time.Minute)) {
+		t.Fatal("first use of live rejected")
+	}
+	if c.Use("live", now.Add(time.Minute)) {
+		t.Fatal("replay of live accepted")
+	}
+
+	// an expired key is forgotten, the ones after it in the heap stay
+	if !c.Use("expired", now.Add(-time.Second)) {
+		t.Fatal("first use of expired rejected")
+	}
+	if !c.Use("expired", now.Add(time.Minute)) {
+		t.Fatal("use of expired after it expired rejected")
+	}
+	if c.Use("expired", now.Add(time.Minute)) {
+		t.Fatal("replay of the recorded again expired accepted")
+	}
+	if c.Use("live", now.Add(time.Minute)) {
+		t.Fatal("replay of live accepted after the sweep")
+	}
+}
@@ -0,0 +1,264 @@
+package tests
+
+import (
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/tests/suite"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"testing"
+)
+
+func TestAdminAPI(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// a user that is not an administrator can be granted the scope by the
+	// admin client, the administrator can sign in without it
+	name, email := newUser()
+	if _, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	userToken := adminClientLogin(ctx, t, st, email, password, st.Cfg.Admin.Scope)
+	unscopedToken := adminClientLogin(ctx, t, st, st.Admin.Email, st.Admin.Password, "")
+
+	create := map[string]any{
+		"name":         "admin-app",
+		"redirectUris": []string{"https://app.example.com/callback"},
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   any
+		status int
+	}{
+		{
+			name:   "Rotate Secret Anonymously",
+			method: http.MethodPost,
+			path:   "/oauth/client/some-client/secret",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "List Clients Anonymously",
+			method: http.MethodGet,
+			path:   "/oauth/clients",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Create Client Anonymously",
+			method: http.MethodPost,
+			path:   "/oauth/client",
+			body:   create,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Rotate Secret through the Gateway Anonymously",
+			method: http.MethodPost,
+			path:   "/v1/clients/some-client:rotateSecret",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Create Resource Anonymously",
+			method: http.MethodPost,
+			path:   "/oauth/resource",
+			body:   map[string]any{"identifier": "https://api.example.com", "name": "api"},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "Patch Resource Anonymously",
+			method: http.MethodPatch,
+			path:   "/oauth/resource/some-resource",
+			body:   map[string]any{"accessTokenTtl": 86400},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "List Resources as a User granted the Admin Scope",
+			method: http.MethodGet,
+			path:   "/oauth/resources",
+			token:  userToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Create Resource as a User granted the Admin Scope",
+			method: http.MethodPost,
+			path:   "/oauth/resource",
+			token:  userToken,
+			body:   map[string]any{"identifier": "https://api.example.com", "name": "api"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Delete Resource as a User granted the Admin Scope",
+			method: http.MethodDelete,
+			path:   "/oauth/resource/some-resource",
+			token:  userToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "Create Resource as Admin without the Admin Scope",
+			method: http.MethodPost,
+			path:   "/oauth/resource",
+			token:  unscopedToken,
+			body:   map[string]any{"identifier": "https://api.example.com", "name": "api"},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "List Resources as Admin without the Admin Scope",
+			method: http.MethodGet,
+			path:   "/oauth/resources",
+			token:  unscopedToken,
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "List Resources as Admin",
+			method: http.MethodGet,
+			path:   "/oauth/resources",
+			token:  st.AdminToken(),
+			status: http.StatusOK,
+		},
+		{
+			name:   "Create Client as Admin",
+			method: http.MethodPost,
+			path:   "/oauth/client",
+			token:  st.AdminToken(),
+			body:   create,
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := bearerRequest(t, tt.method, st.HTTP.URL+tt.path, tt.token, tt.body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAdminAPI_GRPC(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.ClientClient.CreateClient(ctx, &gRPCSSO.CreateClientRequest{
+		Name:         "anonymous-app",
+		RedirectUris: []string{"https://app.example.com/callback"},
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	_, err = st.ClientClient.RotateClientSecret(ctx, &gRPCSSO.RotateClientSecretRequest{Id: "some-client"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+
+	// the tokens are granted by the password grant of the admin client
+	name, email := newUser()
+	if _, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{
+			name:  "Create Client as a User granted the Admin Scope",
+			token: adminClientLogin(ctx, t, st, email, password, st.Cfg.Admin.Scope),
+			code:  codes.PermissionDenied,
+		},
+		{
+			name:  "Create Client as Admin without the Admin Scope",
+			token: adminClientLogin(ctx, t, st, st.Admin.Email, st.Admin.Password, ""),
+			code:  codes.PermissionDenied,
+		},
+		{
+			name:  "Create Client as Admin",
+			token: st.AdminToken(),
+			code:  codes.OK,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+			_, err := st.ClientClient.CreateClient(ctx, &gRPCSSO.CreateClientRequest{
+				Name:         fmt.Sprintf("grpc-admin-app%d", i),
+				RedirectUris: []string{"https://app.example.com/callback"},
+			})
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %v, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestLogin_UnregisteredScope(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	clientID, clientSecret := createClient(ctx, st)
+	_, err := st.AuthClient.Login(ctx, &gRPCSSO.LoginRequest{
+		Login:        st.Admin.Email,
+		Password:     st.Admin.Password,
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Scope:        st.Cfg.Admin.Scope,
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// adminClientLogin signs the user in with the client of the administrator.
+func adminClientLogin(ctx context.Context, t *testing.T, st *suite.Suite, login, password, scope string) string {
+	t.Helper()
+
+	res, err := st.AuthClient.Login(ctx, &gRPCSSO.LoginRequest{
+		Login:        login,
+		Password:     password,
+		ClientId:     st.Admin.ClientID,
+		ClientSecret: st.Admin.ClientSecret,
+		Scope:        scope,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.GetToken().GetAccessToken()
+}
+
+func bearerRequest(t *testing.T, method, target, accessToken string, body any) *http.Response {
+	t.Helper()
+
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, target, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
@@ -1,201 +1,290 @@
 package tests
 
-//
-//import (
-//	sso "app/pkg/http/grpc-server"
-//	"app/tests/suite"
-//	"github.com/brianvoe/gofakeit/v6"
-//	"github.com/golang-jwt/jwt/v5"
-//	"github.com/stretchr/testify/assert"
-//	"github.com/stretchr/testify/require"
-//	"testing"
-//	"time"
-//)
-//
-//const (
-//	emptyAppID = 0
-//	appID      = 1
-//	appSecret  = "tests-secret"
-//
-//	passDefaultLen = 10
-//)
-//
-//// TODO: add token fail validation cases
-//
-//func TestRegisterLogin_Login_HappyPath(t *testing.T) {
-//	ctx, st := suite.New(t)
-//
-//	email := gofakeit.Email()
-//	pass := randomFakePassword()
-//
-//	respReg, err := st.AuthClient.Register(ctx, &sso.RegisterRequest{
-//		Username: email,
-//		Password: pass,
-//	})
-//	require.NoError(t, err)
-//	assert.NotEmpty(t, respReg.GetUserId())
-//
-//	respLogin, err := st.AuthClient.Login(ctx, &sso.LoginRequest{
-//		Username: email,
-//		Password: pass,
-//		AppId:    appID,
-//	})
-//	require.NoError(t, err)
-//
-//	token := respLogin.GetToken()
-//	require.NotEmpty(t, token)
-//
-//	loginTime := time.Now()
-//
-//	tokenParsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-//		return []byte(appSecret), nil
-//	})
-//	require.NoError(t, err)
-//
-//	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
-//	require.True(t, ok)
-//
-//	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
-//	assert.Equal(t, email, claims["email"].(string))
-//	assert.Equal(t, appID, int(claims["app_id"].(float64)))
-//
-//	const deltaSeconds = 1
-//
-//	// check if exp of token is in correct range, ttl get from st.Cfg.TokenTTL
-//	assert.InDelta(t, loginTime.Add(st.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
-//}
-//
-//func TestRegisterLogin_DuplicatedRegistration(t *testing.T) {
-//	ctx, st := suite.New(t)
-//
-//	email := gofakeit.Email()
-//	pass := randomFakePassword()
-//
-//	respReg, err := st.AuthClient.Register(ctx, &sso.RegisterRequest{
-//		Username: email,
-//		Password: pass,
-//	})
-//	require.NoError(t, err)
-//	require.NotEmpty(t, respReg.GetUserId())
-//
-//	respReg, err = st.AuthClient.Register(ctx, &sso.RegisterRequest{
-//		Username: email,
-//		Password: pass,
-//	})
-//	require.Error(t, err)
-//	assert.Empty(t, respReg.GetUserId())
-//	assert.ErrorContains(t, err, "user already exists")
-//}
-//
-//func TestRegister_FailCases(t *testing.T) {
-//	ctx, st := suite.New(t)
-//
-//	tests := []struct {
-//		name        string
-//		username    string
-//		password    string
-//		expectedErr string
-//	}{
-//		{
-//			name:        "Register with Empty Password",
-//			username:    gofakeit.Email(),
-//			password:    "",
-//			expectedErr: "password is required",
-//		},
-//		{
-//			name:        "Register with Empty Email",
-//			username:    "",
-//			password:    randomFakePassword(),
-//			expectedErr: "email is required",
-//		},
-//		{
-//			name:        "Register with Both Empty",
-//			username:    "",
-//			password:    "",
-//			expectedErr: "email is required",
-//		},
-//	}
-//
-//	for _, tt := range tests {
-//		t.Run(tt.name, func(t *testing.T) {
-//			_, err := st.AuthClient.Register(ctx, &sso.RegisterRequest{
-//				Username: tt.username,
-//				Password: tt.password,
-//			})
-//			require.Error(t, err)
-//			require.Contains(t, err.Error(), tt.expectedErr)
-//
-//		})
-//	}
-//}
-//
-//func TestLogin_FailCases(t *testing.T) {
-//	ctx, st := suite.New(t)
-//
-//	tests := []struct {
-//		name        string
-//		username    string
-//		password    string
-//		appID       int32
-//		expectedErr string
-//	}{
-//		{
-//			name:        "Login with Empty Password",
-//			username:    gofakeit.Email(),
-//			password:    "",
-//			appID:       appID,
-//			expectedErr: "password is required",
-//		},
-//		{
-//			name:        "Login with Empty Email",
-//			username:    "",
-//			password:    randomFakePassword(),
-//			appID:       appID,
-//			expectedErr: "email is required",
-//		},
-//		{
-//			name:        "Login with Both Empty Email and Password",
-//			username:    "",
-//			password:    "",
-//			appID:       appID,
-//			expectedErr: "email is required",
-//		},
-//		{
-//			name:        "Login with Non-Matching Password",
-//			username:    gofakeit.Email(),
-//			password:    randomFakePassword(),
-//			appID:       appID,
-//			expectedErr: "invalid email or password",
-//		},
-//		{
-//			name:        "Login without AppID",
-//			username:    gofakeit.Email(),
-//			password:    randomFakePassword(),
-//			appID:       emptyAppID,
-//			expectedErr: "app_id is required",
-//		},
-//	}
-//
-//	for _, tt := range tests {
-//		t.Run(tt.name, func(t *testing.T) {
-//			_, err := st.AuthClient.Register(ctx, &sso.RegisterRequest{
-//				Username: gofakeit.Email(),
-//				Password: randomFakePassword(),
-//			})
-//			require.NoError(t, err)
-//
-//			_, err = st.AuthClient.Login(ctx, &sso.LoginRequest{
-//				Username: tt.username,
-//				Password: tt.password,
-//				AppId:    tt.appID,
-//			})
-//			require.Error(t, err)
-//			require.Contains(t, err.Error(), tt.expectedErr)
-//		})
-//	}
-//}
-//
-//func randomFakePassword() string {
-//	return gofakeit.Password(true, true, true, true, false, passDefaultLen)
-//}
-//*/
+import (
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/tests/suite"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+const password = "password123"
+
+var userSeq atomic.Int64
+
+// newUser returns a name and an email no other test registers.
+func newUser() (string, string) {
+	n := userSeq.Add(1)
+	return fmt.Sprintf("user%d", n), fmt.Sprintf("user%d@example.com", n)
+}
+
+// createClient registers a confidential client for the password grant.
+func createClient(ctx context.Context, st *suite.Suite) (string, string) {
+	st.Helper()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+st.AdminToken())
+	resp, err := st.ClientClient.CreateClient(ctx, &gRPCSSO.CreateClientRequest{
+		Name:         fmt.Sprintf("app%d", userSeq.Add(1)),
+		RedirectUris: []string{"https://app.example.com/callback"},
+	})
+	if err != nil {
+		st.Fatalf("create client: %v", err)
+	}
+
+	return resp.GetClient().GetId(), resp.GetSecret()
+}
+
+func TestRegisterLogin_Login_HappyPath(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	clientID, clientSecret := createClient(ctx, st)
+	name, email := newUser()
+
+	respReg, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{
+		Username: name,
+		Email:    email,
+		Password: password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if respReg.GetUserId() == 0 || respReg.GetUuid() == "" {
+		t.Fatalf("unexpected registration %v", respReg)
+	}
+
+	respLogin, err := st.AuthClient.Login(ctx, &gRPCSSO.LoginRequest{
+		Login:        email,
+		Password:     password,
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := respLogin.GetToken()
+	if token.GetAccessToken() == "" || token.GetRefreshToken() == "" {
+		t.Fatalf("unexpected token %v", token)
+	}
+
+	validated, err := st.AuthClient.ValidateToken(ctx, &gRPCSSO.ValidateTokenRequest{AccessToken: token.GetAccessToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validated.GetActive() || validated.GetUserUuid() != respReg.GetUuid() || validated.GetClientId() != clientID {
+		t.Fatalf("unexpected validation %v", validated)
+	}
+
+	refreshed, err := st.AuthClient.RefreshToken(ctx, &gRPCSSO.RefreshTokenRequest{
+		RefreshToken: token.GetRefreshToken(),
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = st.AuthClient.Logout(ctx, &gRPCSSO.LogoutRequest{
+		AccessToken:  refreshed.GetToken().GetAccessToken(),
+		RefreshToken: refreshed.GetToken().GetRefreshToken(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validated, err = st.AuthClient.ValidateToken(ctx, &gRPCSSO.ValidateTokenRequest{
+		AccessToken: refreshed.GetToken().GetAccessToken(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validated.GetActive() {
+		t.Fatal("expected the token to be revoked by the logout")
+	}
+}
+
+func TestRegisterLogin_DuplicatedRegistration(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	name, email := newUser()
+	req := &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password}
+
+	if _, err := st.AuthClient.Register(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := st.AuthClient.Register(ctx, req)
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+}
+
+func TestRegister_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	name, email := newUser()
+	tests := []struct {
+		name string
+		req  *gRPCSSO.RegisterRequest
+	}{
+		{
+			name: "Register with Empty Password",
+			req:  &gRPCSSO.RegisterRequest{Username: name, Email: email},
+		},
+		{
+			name: "Register with Empty Email",
+			req:  &gRPCSSO.RegisterRequest{Username: name, Password: password},
+		},
+		{
+			name: "Register with Short Password",
+			req:  &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: "short"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Register(ctx, tt.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogin_FailCases(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	clientID, clientSecret := createClient(ctx, st)
+	name, email := newUser()
+	_, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *gRPCSSO.LoginRequest
+		code codes.Code
+	}{
+		{
+			name: "Login with Empty Password",
+			req:  &gRPCSSO.LoginRequest{Login: email, ClientId: clientID, ClientSecret: clientSecret},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "Login with Non-Matching Password",
+			req:  &gRPCSSO.LoginRequest{Login: email, Password: "password456", ClientId: clientID, ClientSecret: clientSecret},
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "Login with Wrong Client Secret",
+			req:  &gRPCSSO.LoginRequest{Login: email, Password: password, ClientId: clientID, ClientSecret: "secret"},
+			code: codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Login(ctx, tt.req)
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestHTTP_RegisterLogin(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	health, err := http.Get(st.HTTP.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = health.Body.Close()
+	if health.StatusCode != http.StatusOK {
+		t.Fatalf("expected a healthy app, got %d", health.StatusCode)
+	}
+
+	clientID, clientSecret := createClient(ctx, st)
+	name, email := newUser()
+
+	resp := postJSON(t, st.HTTP.URL+"/oauth/registration", "", "", map[string]string{
+		"name":            name,
+		"email":           email,
+		"password":        password,
+		"confirmPassword": password,
+	})
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("registration: unexpected status %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, st.HTTP.URL+"/oauth/login", clientID, clientSecret, map[string]string{
+		"login":    email,
+		"password": password,
+	})
+	var tokens struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&tokens)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Data.AccessToken == "" {
+		t.Fatalf("login: unexpected status %d", resp.StatusCode)
+	}
+
+	// the REST gateway reaches the gRPC services in-process
+	resp = postJSON(t, st.HTTP.URL+"/v1/auth/validate-token", "", "", map[string]string{
+		"access_token": tokens.Data.AccessToken,
+	})
+	var validated struct {
+		Active bool   `json:"active"`
+		Email  string `json:"email"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&validated)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validated.Active || validated.Email != email {
+		t.Fatalf("unexpected validation %+v", validated)
+	}
+}
+
+func postJSON(t *testing.T, target, clientID, clientSecret string, body any) *http.Response {
+	t.Helper()
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if clientID != "" {
+		// RFC 6749 form-encodes the client credentials of basic auth
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
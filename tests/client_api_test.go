@@ -0,0 +1,218 @@
+package tests
+
+import (
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/tests/suite"
+	"encoding/json"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClientAPI_DecodeErrors(t *testing.T) {
+	_, st := suite.New(t)
+	adminToken := st.AdminToken()
+
+	id := createHTTPClient(t, st, adminToken, "decoded-app")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "Create with an empty Body", method: http.MethodPost, path: "/oauth/client"},
+		{name: "Create with malformed JSON", method: http.MethodPost, path: "/oauth/client", body: `{"name": "app",`},
+		{name: "Create with a mistyped Field", method: http.MethodPost, path: "/oauth/client", body: `{"name": 5}`},
+		{name: "Update with malformed JSON", method: http.MethodPatch, path: "/oauth/client/" + id, body: `{"name": "app",`},
+		{name: "Update with a mistyped Field", method: http.MethodPatch, path: "/oauth/client/" + id, body: `{"scopes": "read"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, st.HTTP.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("expected %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestClientAPI_DropsSecret checks that a client switching to a method
+// without a secret loses the current and the previous secret.
+func TestClientAPI_DropsSecret(t *testing.T) {
+	_, st := suite.New(t)
+	adminToken := st.AdminToken()
+
+	tests := []struct {
+		name   string
+		update map[string]any
+	}{
+		{
+			name:   "None",
+			update: map[string]any{"tokenEndpointAuthMethod": "none"},
+		},
+		{
+			name: "Private Key JWT",
+			update: map[string]any{
+				"tokenEndpointAuthMethod": "private_key_jwt",
+				"jwks":                    json.RawMessage(`{"keys":[{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}]}`),
+			},
+		},
+		{
+			name: "TLS Client Auth",
+			update: map[string]any{
+				"tokenEndpointAuthMethod": "tls_client_auth",
+				"tlsClientAuth":           map[string]any{"subjectDn": "CN=svc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := createHTTPClient(t, st, adminToken, "app-"+strings.ToLower(strings.ReplaceAll(tt.name, " ", "-")))
+
+			// the rotation leaves a previous secret in its grace period
+			resp := bearerRequest(t, http.MethodPost, st.HTTP.URL+"/oauth/client/"+id+"/secret", adminToken, nil)
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("rotate secret: expected %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+
+			resp = bearerRequest(t, http.MethodPatch, st.HTTP.URL+"/oauth/client/"+id, adminToken, tt.update)
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("update: expected %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+
+			stored, err := st.Storages.Client.GetClient(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Secret != "" || stored.PreviousSecret != nil || stored.PreviousSecretExpiresAt != 0 {
+				t.Fatalf("secrets kept for %s: %q, %v", stored.TokenEndpointAuthMethod, stored.Secret, stored.PreviousSecret)
+			}
+		})
+	}
+}
+
+// createHTTPClient registers a client with a secret through the HTTP API
+// and returns its ID.
+func createHTTPClient(t *testing.T, st *suite.Suite, adminToken, name string) string {
+	t.Helper()
+
+	resp := bearerRequest(t, http.MethodPost, st.HTTP.URL+"/oauth/client", adminToken, map[string]any{
+		"name":         name,
+		"redirectUris": []string{"https://app.example.com/callback"},
+	})
+	defer resp.Body.Close()
+
+	var created struct {
+		Data struct {
+			ID     string `json:"id"`
+			Secret string `json:"secret"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || created.Data.ID == "" || created.Data.Secret == "" {
+		t.Fatalf("create client: status %d, %+v", resp.StatusCode, created)
+	}
+
+	return created.Data.ID
+}
+
+func TestClientAPI_GRPCFields(t *testing.T) {
+	ctx, st := suite.New(t)
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+st.AdminToken())
+
+	created, err := st.ClientClient.CreateClient(ctx, &gRPCSSO.CreateClientRequest{
+		Name:                    "grpc-fields-app",
+		RedirectUris:            []string{"https://app.example.com/callback"},
+		TokenEndpointAuthMethod: "tls_client_auth",
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		Scopes:                  []string{"profile", "videos"},
+		TokenExchange: &gRPCSSO.TokenExchange{
+			Audiences:     []string{"https://api.example.com"},
+			Scopes:        []string{"videos"},
+			Impersonation: true,
+		},
+		RequirePushedAuthorizationRequests: true,
+		TlsClientAuth:                      &gRPCSSO.TLSClientAuth{SubjectDn: "CN=svc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := created.GetClient()
+	wantGrants := []string{"authorization_code", "refresh_token", "urn:ietf:params:oauth:grant-type:token-exchange"}
+	if !slices.Equal(c.GetGrantTypes(), wantGrants) ||
+		!slices.Equal(c.GetScopes(), []string{"profile", "videos"}) ||
+		!c.GetTokenExchange().GetImpersonation() ||
+		!c.GetRequirePushedAuthorizationRequests() ||
+		c.GetTlsClientAuth().GetSubjectDn() != "CN=svc" ||
+		created.GetSecret() != "" {
+		t.Fatalf("unexpected client %+v", created)
+	}
+
+	par := false
+	updated, err := st.ClientClient.UpdateClient(ctx, &gRPCSSO.UpdateClientRequest{
+		Id:                                 c.GetId(),
+		Scopes:                             &gRPCSSO.Scopes{},
+		RequirePushedAuthorizationRequests: &par,
+		TokenExchange:                      &gRPCSSO.TokenExchange{Audiences: []string{"https://api.example.com"}, Delegation: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c = updated.GetClient()
+	if len(c.GetScopes()) != 0 ||
+		c.GetRequirePushedAuthorizationRequests() ||
+		c.GetTokenExchange().GetImpersonation() || !c.GetTokenExchange().GetDelegation() ||
+		!slices.Equal(c.GetGrantTypes(), wantGrants) {
+		t.Fatalf("unexpected client %+v", updated)
+	}
+
+	tests := []struct {
+		name string
+		req  *gRPCSSO.UpdateClientRequest
+	}{
+		{
+			name: "Unknown Grant Type",
+			req:  &gRPCSSO.UpdateClientRequest{Id: c.GetId(), GrantTypes: []string{"implicit"}},
+		},
+		{
+			name: "Invalid Scope",
+			req:  &gRPCSSO.UpdateClientRequest{Id: c.GetId(), Scopes: &gRPCSSO.Scopes{Scopes: []string{"bad\"scope"}}},
+		},
+		{
+			name: "Two Certificate Subjects",
+			req:  &gRPCSSO.UpdateClientRequest{Id: c.GetId(), TlsClientAuth: &gRPCSSO.TLSClientAuth{SubjectDn: "CN=svc", SanDns: "svc.internal"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := st.ClientClient.UpdateClient(ctx, tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
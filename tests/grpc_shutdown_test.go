@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"app/internal/config"
+	"app/tests/suite"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"testing"
+	"time"
+)
+
+// TestGRPC_ShutdownPropagatesHealth checks that the server keeps serving
+// while it reports NOT_SERVING, so the load balancers see it going away
+// before it stops accepting calls.
+func TestGRPC_ShutdownPropagatesHealth(t *testing.T) {
+	const delay = 500 * time.Millisecond
+
+	ctx, st := suite.New(t, func(cfg *config.Config) {
+		cfg.GRPC.HealthPropagationDelay = delay
+	})
+
+	res, err := st.HealthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil || res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("before shutdown: status = %v, err = %v", res.GetStatus(), err)
+	}
+
+	stopped := make(chan struct{})
+	start := time.Now()
+	go func() {
+		st.GRPC.Stop()
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(delay / 2)
+	for {
+		res, err = st.HealthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("call refused during the propagation delay: %v", err)
+		}
+		if res.GetStatus() == healthpb.HealthCheckResponse_NOT_SERVING {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %v, want NOT_SERVING", res.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatal("server stopped before the propagation delay")
+	default:
+	}
+
+	<-stopped
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Fatalf("stopped after %v, want at least %v", elapsed, delay)
+	}
+	if _, err := st.HealthClient.Check(ctx, &healthpb.HealthCheckRequest{}); err == nil {
+		t.Fatal("call served after the server stopped")
+	}
+}
@@ -0,0 +1,307 @@
+package tests
+
+import (
+	"app/internal/config"
+	"app/pkg/common/core/token"
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/tests/suite"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"github.com/crewjam/saml"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	samlLoginURL = "https://login.example.com/signin"
+	samlEntityID = "https://sp.example.com/saml/metadata"
+)
+
+var samlFormValue = regexp.MustCompile(`name="(SAMLRequest|SAMLResponse)" value="([^"]*)"`)
+
+// noRedirects is the browser that stops at the redirects to look at them.
+var noRedirects = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func TestSAML_BrowserSession(t *testing.T) {
+	ctx, st := suite.New(t, func(cfg *config.Config) {
+		cfg.SAML.CertFile, cfg.SAML.KeyFile = writeSAMLKeyPair(t)
+		cfg.SAML.LoginURL = samlLoginURL
+		cfg.SAML.ServiceProviders = []config.SAMLServiceProvider{{
+			EntityID: samlEntityID,
+			ACSURL:   "https://sp.example.com/saml/acs",
+		}}
+	})
+	sp := samlServiceProvider(t, st)
+
+	authnRequest, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatalf("MakeAuthenticationRequest: %v", err)
+	}
+	redirect, err := authnRequest.Redirect("relay", sp)
+	if err != nil {
+		t.Fatalf("Redirect: %v", err)
+	}
+
+	// the browser without a session goes to the login page
+	res := browse(t, http.MethodGet, redirect.String(), nil, nil)
+	_ = res.Body.Close()
+	returnTo := loginRedirect(t, st, res)
+
+	// the login page signs the user in and starts the session
+	clientID, clientSecret := createClient(ctx, st)
+	name, email := newUser()
+	if _, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := st.AuthClient.Login(ctx, &gRPCSSO.LoginRequest{
+		Login:        email,
+		Password:     password,
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = bearerRequest(t, http.MethodPost, st.HTTP.URL+"/saml/session", login.GetToken().GetAccessToken(), nil)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("session: status = %d", res.StatusCode)
+	}
+	var session *http.Cookie
+	for _, c := range res.Cookies() {
+		if c.Name == token.SessionCookie {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || session.Path != "/saml" {
+		t.Fatalf("unexpected session cookie %+v", session)
+	}
+
+	// and sends the browser back to the single sign on
+	res = browse(t, http.MethodGet, returnTo, nil, session)
+	a := samlAssertion(t, sp, res, authnRequest.ID)
+	_ = res.Body.Close()
+	if a.Subject.NameID.Value != email {
+		t.Fatalf("name ID = %+v", a.Subject.NameID)
+	}
+
+	// the session serves the POST binding too
+	authnRequest, err = sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPPostBinding), saml.HTTPPostBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatalf("MakeAuthenticationRequest: %v", err)
+	}
+	match := samlFormValue.FindSubmatch(authnRequest.Post("relay"))
+	if match == nil {
+		t.Fatal("no SAMLRequest in the post form")
+	}
+	form := url.Values{"SAMLRequest": {html.UnescapeString(string(match[2]))}, "RelayState": {"relay"}}
+
+	res = browse(t, http.MethodPost, st.HTTP.URL+"/saml/sso", form, session)
+	samlAssertion(t, sp, res, authnRequest.ID)
+	_ = res.Body.Close()
+
+	// a revoked session is no session
+	_, err = st.AuthClient.Logout(ctx, &gRPCSSO.LogoutRequest{AccessToken: login.GetToken().GetAccessToken()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = browse(t, http.MethodPost, st.HTTP.URL+"/saml/sso", form, session)
+	_ = res.Body.Close()
+	loginRedirect(t, st, res)
+}
+
+func TestSAML_EndSession(t *testing.T) {
+	_, st := suite.New(t, func(cfg *config.Config) {
+		cfg.SAML.CertFile, cfg.SAML.KeyFile = writeSAMLKeyPair(t)
+	})
+
+	res := browse(t, http.MethodDelete, st.HTTP.URL+"/saml/session", nil, nil)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("end session: status = %d", res.StatusCode)
+	}
+
+	cookies := res.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != token.SessionCookie || cookies[0].MaxAge >= 0 {
+		t.Fatalf("unexpected cookies %+v", cookies)
+	}
+
+	res = bearerRequest(t, http.MethodPost, st.HTTP.URL+"/saml/session", "", nil)
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous session: status = %d", res.StatusCode)
+	}
+}
+
+// browse sends the request as a browser navigation, with the form of a
+// POST and the session cookie.
+func browse(t *testing.T, method, target string, form url.Values, session *http.Cookie) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if session != nil {
+		req.AddCookie(session)
+	}
+
+	res, err := noRedirects.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+// loginRedirect checks the redirect to the login page and returns the
+// SSO URL it comes back to.
+func loginRedirect(t *testing.T, st *suite.Suite, res *http.Response) string {
+	t.Helper()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("sso: status = %d, want a redirect to the login page", res.StatusCode)
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	returnTo := location.Query().Get("return_to")
+	location.RawQuery = ""
+	if location.String() != samlLoginURL {
+		t.Fatalf("login redirect = %s", location)
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme+"://"+u.Host+u.Path != st.HTTP.URL+"/saml/sso" || u.Query().Get("SAMLRequest") == "" ||
+		u.Query().Get("RelayState") != "relay" {
+		t.Fatalf("return_to = %s", returnTo)
+	}
+
+	return returnTo
+}
+
+// samlServiceProvider returns the service provider of samlEntityID, it
+// trusts the metadata the identity provider publishes.
+func samlServiceProvider(t *testing.T, st *suite.Suite) *saml.ServiceProvider {
+	t.Helper()
+
+	res, err := http.Get(st.HTTP.URL + "/saml/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var metadata saml.EntityDescriptor
+	if err := xml.NewDecoder(res.Body).Decode(&metadata); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+
+	acsURL, _ := url.Parse("https://sp.example.com/saml/acs")
+	metadataURL, _ := url.Parse(samlEntityID)
+
+	return &saml.ServiceProvider{
+		EntityID:    samlEntityID,
+		AcsURL:      *acsURL,
+		MetadataURL: *metadataURL,
+		IDPMetadata: &metadata,
+	}
+}
+
+// samlAssertion validates the response the identity provider posts to
+// the ACS URL as the service provider does.
+func samlAssertion(t *testing.T, sp *saml.ServiceProvider, res *http.Response, requestID string) *saml.Assertion {
+	t.Helper()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("sso: status = %d, body %s", res.StatusCode, body)
+	}
+
+	match := samlFormValue.FindSubmatch(body)
+	if match == nil || string(match[1]) != "SAMLResponse" {
+		t.Fatalf("no SAMLResponse in %s", body)
+	}
+
+	form := url.Values{"SAMLResponse": {html.UnescapeString(string(match[2]))}}
+	req, err := http.NewRequest(http.MethodPost, sp.AcsURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := sp.ParseResponse(req, []string{requestID})
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		t.Fatalf("ParseResponse: %v", err)
+	}
+
+	return a
+}
+
+// writeSAMLKeyPair writes the RSA key and the self-signed certificate
+// assertions are signed with.
+func writeSAMLKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sso"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "saml.crt")
+	keyFile := filepath.Join(dir, "saml.key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
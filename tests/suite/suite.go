@@ -1,68 +1,271 @@
 package suite
 
-//
-//import (
-//	"app/internal/config"
-//	sso "app/pkg/http/grpc-server"
-//	"context"
-//	"google.golang.org/grpc"
-//	"google.golang.org/grpc/credentials/insecure"
-//	"net"
-//	"os"
-//	"strconv"
-//	"testing"
-//)
-//
-//type Suite struct {
-//	*testing.T
-//	Cfg        *config.Config
-//	AuthClient sso.AuthClient
-//}
-//
-//const (
-//	grpcHost = "localhost"
-//)
-//
-//// New creates new tests suite.
-////
-//// TODO: for pipeline tests we need to wait for app is ready
-//func New(t *testing.T) (context.Context, *Suite) {
-//	t.Helper()
-//	t.Parallel()
-//
-//	cfg := config.MustLoadPath(configPath())
-//
-//	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
-//
-//	t.Cleanup(func() {
-//		t.Helper()
-//		cancelCtx()
-//	})
-//
-//	cc, err := grpc.DialContext(context.Background(),
-//		grpcAddress(cfg),
-//		grpc.WithTransportCredentials(insecure.NewCredentials()))
-//	if err != nil {
-//		t.Fatalf("grpc-server server connection failed: %v", err)
-//	}
-//
-//	return ctx, &Suite{
-//		T:          t,
-//		Cfg:        cfg,
-//		AuthClient: sso.NewAuthClient(cc),
-//	}
-//}
-//
-//func configPath() string {
-//	const key = "CONFIG_PATH"
-//
-//	if v := os.Getenv(key); v != "" {
-//		return v
-//	}
-//
-//	return "../config/local_tests.yaml"
-//}
-//
-//func grpcAddress(cfg *config.Config) string {
-//	return net.JoinHostPort(grpcHost, strconv.Itoa(cfg.GRPC.Port))
-//}
+import (
+	appGRPC "app/internal/app/grpc"
+	appApi "app/internal/app/http"
+	"app/internal/config"
+	"app/internal/domain/client"
+	"app/internal/domain/user"
+	"app/internal/storage"
+	"app/pkg/client/rabbitmq"
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/pkg/utils/crypt"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bufferSize is the buffer of the in-memory gRPC connections.
+const bufferSize = 1 << 20
+
+// adminPassword is the password of the administrator of every suite.
+const adminPassword = "admin-password"
+
+// Suite is the whole app booted in the test process: the storages are
+// in memory, the queue has no driver, the gRPC server listens on bufconn
+// and the HTTP server is an httptest server.
+type Suite struct {
+	*testing.T
+	Cfg          *config.Config
+	Storages     *storage.Storage
+	HTTP         *httptest.Server
+	AuthClient   gRPCSSO.AuthServiceClient
+	ClientClient gRPCSSO.ClientServiceClient
+	TokenClient  gRPCSSO.TokenServiceClient
+	HealthClient healthpb.HealthClient
+	GRPC         *appGRPC.App
+	Admin        Admin
+}
+
+// Admin is the administrator of the suite and the client registered for
+// the admin scope it signs in with.
+type Admin struct {
+	UUID         string
+	Email        string
+	Password     string
+	ClientID     string
+	ClientSecret string
+}
+
+// New boots the app for the test, it is stopped when the test ends. The
+// options change the config before the app is built, the issuer is the
+// URL of the HTTP server.
+func New(t *testing.T, opts ...func(cfg *config.Config)) (context.Context, *Suite) {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	t.Cleanup(server.Close)
+
+	cfg := config.MustLoadPath(configPath())
+	cfg.DB.Driver = config.DriverMemory
+	cfg.Queue.Driver = ""
+	cfg.GRPC.HealthPropagationDelay = 0
+	cfg.Issuer = "http://" + server.Listener.Addr().String()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	writeRefreshTokenKeys(t)
+
+	// the administrator is listed before the app reads the config
+	admin := Admin{UUID: uuid.NewString(), Password: adminPassword}
+	cfg.Admin.Users = append(cfg.Admin.Users, admin.UUID)
+
+	// the calls of a test share one context, bcrypt under the race detector
+	// easily takes longer than the gRPC timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	storages, err := storage.New(context.Background(), cfg.DB)
+	if err != nil {
+		t.Fatalf("storage init failed: %v", err)
+	}
+	t.Cleanup(storages.Close)
+	createAdmin(t, storages, cfg, &admin)
+
+	queueClient, err := rabbitmq.New(context.Background(), cfg.Queue)
+	if err != nil {
+		t.Fatalf("queue init failed: %v", err)
+	}
+	t.Cleanup(queueClient.Close)
+
+	gRPCApp := appGRPC.New(context.Background(), storages, queueClient, cfg)
+	listener := bufconn.Listen(bufferSize)
+	go func() {
+		_ = gRPCApp.Serve(listener)
+	}()
+	t.Cleanup(gRPCApp.Stop)
+
+	httpApp := appApi.New(context.Background(), storages, cfg, queueClient, gRPCApp.GatewayConn())
+	handler, err := httpApp.Handler()
+	if err != nil {
+		t.Fatalf("http server init failed: %v", err)
+	}
+	server.Config.Handler = handler
+	server.Start()
+
+	cc, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc-server server connection failed: %v", err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+
+	return ctx, &Suite{
+		T:            t,
+		Cfg:          cfg,
+		Storages:     storages,
+		HTTP:         server,
+		AuthClient:   gRPCSSO.NewAuthServiceClient(cc),
+		ClientClient: gRPCSSO.NewClientServiceClient(cc),
+		TokenClient:  gRPCSSO.NewTokenServiceClient(cc),
+		HealthClient: healthpb.NewHealthClient(cc),
+		GRPC:         gRPCApp,
+		Admin:        admin,
+	}
+}
+
+// AdminToken signs the administrator in with the password grant and
+// returns the access token granted the admin scope, the management APIs
+// need one.
+func (s *Suite) AdminToken() string {
+	s.Helper()
+
+	login, err := s.AuthClient.Login(context.Background(), &gRPCSSO.LoginRequest{
+		Login:        s.Admin.Email,
+		Password:     s.Admin.Password,
+		ClientId:     s.Admin.ClientID,
+		ClientSecret: s.Admin.ClientSecret,
+		Scope:        s.Cfg.Admin.Scope,
+	})
+	if err != nil {
+		s.Fatalf("admin login failed: %v", err)
+	}
+
+	return login.GetToken().GetAccessToken()
+}
+
+// createAdmin registers the administrator and its client, the client is
+// registered for the admin scope by the storage as no admin exists yet.
+func createAdmin(t *testing.T, storages *storage.Storage, cfg *config.Config, admin *Admin) {
+	t.Helper()
+
+	passwordHash, err := crypt.GeneratePasswordHash(admin.Password)
+	if err != nil {
+		t.Fatalf("admin registration failed: %v", err)
+	}
+
+	admin.Email = "admin-" + admin.UUID + "@example.com"
+	err = storages.User.Registration(&user.CreateUser{
+		UUID:     admin.UUID,
+		Name:     "admin-" + admin.UUID,
+		Email:    admin.Email,
+		Password: passwordHash,
+	})
+	if err != nil {
+		t.Fatalf("admin registration failed: %v", err)
+	}
+
+	admin.ClientID = uuid.NewString()
+	admin.ClientSecret = crypt.GetSecret()
+	secretHash, err := crypt.HashSecret(admin.ClientSecret)
+	if err != nil {
+		t.Fatalf("admin client failed: %v", err)
+	}
+
+	now := time.Now().Unix()
+	err = storages.Client.CreateClient(&client.Client{
+		ID:                      admin.ClientID,
+		Name:                    "admin-" + admin.ClientID,
+		Secret:                  secretHash,
+		Provider:                "users",
+		RedirectURIs:            []string{},
+		PasswordClient:          true,
+		TokenEndpointAuthMethod: client.AuthMethodClientSecretBasic,
+		GrantTypes:              []string{client.GrantTypePassword, client.GrantTypeRefreshToken},
+		Contacts:                []string{},
+		Scopes:                  []string{cfg.Admin.Scope},
+		CreatedAt:               now,
+		UpdatedAt:               now,
+	})
+	if err != nil {
+		t.Fatalf("admin client failed: %v", err)
+	}
+}
+
+func configPath() string {
+	const key = "CONFIG_PATH"
+
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	path, err := filepath.Abs("../config/config.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	return path
+}
+
+// refreshTokenKey is generated once for all the tests, the refresh tokens
+// expect a key of the size cmd/pemKeys generates.
+var refreshTokenKey = sync.OnceValues(func() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 4096)
+})
+
+// writeRefreshTokenKeys writes the keys the refresh tokens are encrypted
+// with where they are read from, relative to a temporary working
+// directory.
+func writeRefreshTokenKeys(t *testing.T) {
+	t.Helper()
+
+	key, err := refreshTokenKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	keyDir := filepath.Join(dir, "storage", "secret")
+	if err := os.MkdirAll(keyDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	public := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-public.key"), public, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(keyDir, "oauth-private.key"), private, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
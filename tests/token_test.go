@@ -0,0 +1,66 @@
+package tests
+
+import (
+	gRPCSSO "app/pkg/grpc/sso/v1"
+	"app/tests/suite"
+	"testing"
+)
+
+func TestIntrospect_RefreshToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	clientID, clientSecret := createClient(ctx, st)
+	otherID, otherSecret := createClient(ctx, st)
+	name, email := newUser()
+	if _, err := st.AuthClient.Register(ctx, &gRPCSSO.RegisterRequest{Username: name, Email: email, Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := st.AuthClient.Login(ctx, &gRPCSSO.LoginRequest{
+		Login:        email,
+		Password:     password,
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		active       bool
+	}{
+		{
+			name:         "Introspect by the Client of the Token",
+			clientID:     clientID,
+			clientSecret: clientSecret,
+			active:       true,
+		},
+		{
+			name:         "Introspect by another Client",
+			clientID:     otherID,
+			clientSecret: otherSecret,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := st.TokenClient.Introspect(ctx, &gRPCSSO.IntrospectRequest{
+				Token:         login.GetToken().GetRefreshToken(),
+				TokenTypeHint: "refresh_token",
+				ClientId:      tt.clientID,
+				ClientSecret:  tt.clientSecret,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.GetActive() != tt.active {
+				t.Fatalf("expected active %v, got %v", tt.active, res)
+			}
+			if tt.active && res.GetClientId() != clientID {
+				t.Fatalf("unexpected client %q", res.GetClientId())
+			}
+		})
+	}
+}